@@ -0,0 +1,85 @@
+// tsbs_convert_queries converts query files between the gob format written by
+// tsbs_generate_queries and readable JSON-lines, one query per line. The query
+// runners read both formats.
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/spf13/pflag"
+	"github.com/timescale/tsbs/pkg/query"
+	"github.com/timescale/tsbs/pkg/targets/constants"
+)
+
+// pools are the query types of the formats that can be converted, the
+// Cassandra and Mongo queries are not SQL or HTTP requests
+var pools = map[string]*sync.Pool{
+	constants.FormatAkumuli:         &query.HTTPPool,
+	constants.FormatClickhouse:      &query.ClickHousePool,
+	constants.FormatCrateDB:         &query.CrateDBPool,
+	constants.FormatInflux:          &query.HTTPPool,
+	constants.FormatKwdb:            &query.KwdbPool,
+	constants.FormatQuestDB:         &query.HTTPPool,
+	constants.FormatSiriDB:          &query.SiriDBPool,
+	constants.FormatTimescaleDB:     &query.TimescaleDBPool,
+	constants.FormatTimestream:      &query.TimestreamPool,
+	constants.FormatVictoriaMetrics: &query.HTTPPool,
+}
+
+func main() {
+	format := pflag.String("format", constants.FormatKwdb, fmt.Sprintf("Target database of the query file, one of: %s", strings.Join(formats(), ", ")))
+	to := pflag.String("to", query.FileFormatJSON, "Format to convert the queries to: json or gob, the input format is detected")
+	file := pflag.String("file", "", "File to read the queries from, default stdin")
+	output := pflag.String("output", "", "File to write the converted queries to, default stdout")
+	pflag.Parse()
+
+	pool, ok := pools[*format]
+	if !ok {
+		log.Fatalf("unsupported format '%s', supports: %s", *format, strings.Join(formats(), ", "))
+	}
+
+	var r io.Reader = os.Stdin
+	if len(*file) > 0 {
+		f, err := os.Open(*file)
+		if err != nil {
+			log.Fatalf("cannot open file for read %s: %v", *file, err)
+		}
+		defer f.Close()
+		r = f
+	}
+	var w io.Writer = os.Stdout
+	if len(*output) > 0 {
+		f, err := os.Create(*output)
+		if err != nil {
+			log.Fatalf("cannot create file %s: %v", *output, err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	out := bufio.NewWriter(w)
+	n, err := query.ConvertQueries(bufio.NewReader(r), out, pool, *to)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := out.Flush(); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Fprintf(os.Stderr, "converted %d queries\n", n)
+}
+
+func formats() []string {
+	names := make([]string, 0, len(pools))
+	for name := range pools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -8,13 +8,13 @@ import (
 	"runtime/pprof"
 
 	kwdb "github.com/timescale/tsbs/pkg/targets/kwdb"
-	"github.com/timescale/tsbs/pkg/targets/kwdb/commonpool"
 
 	"github.com/blagojts/viper"
 	"github.com/spf13/pflag"
 	"github.com/timescale/tsbs/internal/utils"
 	"github.com/timescale/tsbs/load"
 	"github.com/timescale/tsbs/pkg/data/source"
+	"github.com/timescale/tsbs/pkg/targets/kwdb/commonpool"
 )
 
 func initProgramOptions() (*kwdb.LoadingOptions, load.BenchmarkRunner, *load.BenchmarkRunnerConfig) {
@@ -37,6 +37,9 @@ func initProgramOptions() (*kwdb.LoadingOptions, load.BenchmarkRunner, *load.Ben
 	opts.User = viper.GetString("user")
 	opts.Pass = viper.GetString("pass")
 	opts.Host = viper.GetString("host")
+	opts.Hosts = viper.GetString("hosts")
+	opts.HostsStrategy = viper.GetString("hosts-strategy")
+	opts.HealthCheckInterval = viper.GetDuration("health-check-interval")
 	opts.Port = viper.GetInt("port")
 	opts.DBName = viper.GetString("db-name")
 	opts.Type = viper.GetString("insert-type")
@@ -46,6 +49,13 @@ func initProgramOptions() (*kwdb.LoadingOptions, load.BenchmarkRunner, *load.Ben
 	opts.Preparesize = viper.GetInt("preparesize")
 	opts.CertDir = viper.GetString("certdir")
 	opts.Partition = viper.GetBool("partition")
+	opts.DryRun = viper.GetBool("dry-run")
+	opts.RetryMaxAttempts = viper.GetInt("retry-max-attempts")
+	opts.RetryBackoff = viper.GetDuration("retry-backoff")
+	opts.RetryMaxBackoff = viper.GetDuration("retry-max-backoff")
+	opts.RetrySQLStates = viper.GetString("retry-sqlstates")
+	opts.ContinueOnError = viper.GetBool("continue-on-error")
+	opts.OutOfOrderStats = viper.GetBool("out-of-order-stats")
 	loaderConf.HashWorkers = true
 	loaderConf.NoFlowControl = true
 	loaderConf.ChannelCapacity = 50
@@ -70,9 +80,17 @@ func main() {
 	if err != nil {
 		panic(err)
 	}
+	if opts.DryRun {
+		creator := benchmark.GetDBCreator()
+		creator.Init()
+		creator.CreateDB(loaderConf.DBName)
+		return
+	}
+	// stops the health checks of --hosts
+	defer commonpool.CloseClusters()
 	loader.RunBenchmark(benchmark)
 
-	_db, err := commonpool.GetConnection(opts.User, opts.Pass, opts.Host, opts.CertDir, opts.Port)
+	_db, err := opts.Connect(0)
 
 	if err != nil {
 		panic(err)
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/timescale/tsbs/pkg/query"
+)
+
+const (
+	explainAfter   = "after"
+	explainInstead = "instead"
+)
+
+// queryPlan is the EXPLAIN ANALYZE plan of a sampled query, written to the
+// explain file one json object per line
+type queryPlan struct {
+	ID    uint64   `json:"id"`
+	Label string   `json:"label"`
+	SQL   string   `json:"sql"`
+	Plan  []string `json:"plan"`
+}
+
+// explainer samples every Nth query of each label, whose plans are captured
+// with EXPLAIN ANALYZE after or instead of their timed execution
+type explainer struct {
+	every   uint64
+	instead bool
+	name    string
+
+	mu      sync.Mutex
+	counts  map[string]uint64
+	file    *os.File
+	w       *bufio.Writer
+	written uint64
+	// failed is the number of plans that could not be captured after the
+	// timed execution, those queries keep their latency
+	failed uint64
+}
+
+// newExplainer returns nil when every is 0
+func newExplainer(every uint64, mode, file string) (*explainer, error) {
+	if every == 0 {
+		return nil, nil
+	}
+	if mode != explainAfter && mode != explainInstead {
+		return nil, fmt.Errorf("unknown explain mode '%s', supports %s and %s", mode, explainAfter, explainInstead)
+	}
+	if len(file) == 0 {
+		return nil, fmt.Errorf("--explain-file is required with --explain-sample")
+	}
+	f, err := os.Create(file)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create explain file %s: %v", file, err)
+	}
+	return &explainer{
+		every:   every,
+		instead: mode == explainInstead,
+		name:    file,
+		counts:  make(map[string]uint64),
+		file:    f,
+		w:       bufio.NewWriter(f),
+	}, nil
+}
+
+// sample returns true for the first and then every Nth query of label
+func (e *explainer) sample(label string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	n := e.counts[label]
+	e.counts[label] = n + 1
+	return n%e.every == 0
+}
+
+func (e *explainer) write(plan *queryPlan) error {
+	b, err := json.Marshal(plan)
+	if err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.w.Write(b)
+	e.w.WriteByte('\n')
+	e.written++
+	return nil
+}
+
+// fail logs and counts a plan that could not be captured after the timed
+// execution of its query
+func (e *explainer) fail(tq *query.Kwdb, err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.failed++
+	log.Printf("cannot capture the plan of query %d (%s): %v", tq.GetID(), tq.HumanLabel, err)
+}
+
+// close writes the buffered plans and closes the explain file
+func (e *explainer) close() {
+	if err := e.w.Flush(); err != nil {
+		panic(err)
+	}
+	e.file.Close()
+	fmt.Printf("wrote %d query plans to %s\n", e.written, e.name)
+	if e.failed > 0 {
+		fmt.Printf("%d query plans could not be captured\n", e.failed)
+	}
+}
+
+// explainQuery runs EXPLAIN ANALYZE for the query and writes its plan. The
+// plan of a prepared query comes from a prepared EXPLAIN ANALYZE of its
+// template, executed with the arguments of the query. It has its own
+// --query-timeout, apart from the timed execution.
+func (p *processor) explainQuery(tq *query.Kwdb, prepare bool) error {
+	ctx, cancel := queryContext()
+	defer cancel()
+	qry := string(tq.SqlQuery)
+	plan := &queryPlan{ID: tq.GetID(), Label: string(tq.HumanLabel), SQL: qry}
+	if !prepare {
+		for _, q := range strings.Split(qry, ";") {
+			if len(strings.TrimSpace(q)) == 0 {
+				continue
+			}
+			rows, err := p.db.Connection.Query(ctx, "EXPLAIN ANALYZE "+q)
+			if err != nil {
+				return p.queryError(ctx, err)
+			}
+			for rows.Next() {
+				values, err := rows.Values()
+				if err != nil {
+					rows.Close()
+					return p.queryError(ctx, err)
+				}
+				plan.Plan = append(plan.Plan, explainLine(values))
+			}
+			if err := rows.Err(); err != nil {
+				return p.queryError(ctx, err)
+			}
+		}
+		return explain.write(plan)
+	}
+
+	name := "explain " + tq.Querytype
+	if !p.explained[tq.Querytype] {
+		if _, err := p.db.Connection.Prepare(ctx, name, "EXPLAIN ANALYZE "+p.templates[tq.Querytype]); err != nil {
+			return p.queryError(ctx, err)
+		}
+		p.explained[tq.Querytype] = true
+	}
+	tableBuffer := p.buffer[tq.Querytype]
+	p.RunSelect(tq.Querytype, strings.Split(qry, ","), tableBuffer)
+	res := p.db.Connection.PgConn().ExecPrepared(ctx, name, tableBuffer.args, p.formats[tq.Querytype], []int16{}).Read()
+	tableBuffer.Reset()
+	if res.Err != nil {
+		return p.queryError(ctx, res.Err)
+	}
+	rows, err := decodeResults(p.db.Connection.TypeMap(), []*pgconn.Result{res})
+	if err != nil {
+		return err
+	}
+	for _, values := range rows {
+		plan.Plan = append(plan.Plan, explainLine(values))
+	}
+	return explain.write(plan)
+}
+
+// explainLine joins the columns of a row of EXPLAIN ANALYZE, which returns
+// one line of the plan per row
+func explainLine(values []interface{}) string {
+	cols := make([]string, len(values))
+	for i, v := range values {
+		cols[i] = formatValue(v)
+	}
+	return strings.Join(cols, "\t")
+}
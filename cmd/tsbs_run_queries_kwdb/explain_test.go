@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/timescale/tsbs/pkg/query"
+)
+
+func TestExplainerSample(t *testing.T) {
+	cases := []struct {
+		desc   string
+		every  uint64
+		labels []string
+		want   []bool
+	}{
+		{
+			desc:   "every query",
+			every:  1,
+			labels: []string{"a", "a", "a"},
+			want:   []bool{true, true, true},
+		},
+		{
+			desc:   "every third query",
+			every:  3,
+			labels: []string{"a", "a", "a", "a", "a", "a", "a"},
+			want:   []bool{true, false, false, true, false, false, true},
+		},
+		{
+			desc:   "counted per label",
+			every:  2,
+			labels: []string{"a", "b", "a", "b", "b", "a"},
+			want:   []bool{true, true, false, false, true, true},
+		},
+	}
+	for _, c := range cases {
+		e := &explainer{every: c.every, counts: make(map[string]uint64)}
+		var got []bool
+		for _, label := range c.labels {
+			got = append(got, e.sample(label))
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got %v want %v", c.desc, got, c.want)
+		}
+	}
+}
+
+func TestExplainLine(t *testing.T) {
+	ts := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		desc   string
+		values []interface{}
+		want   string
+	}{
+		{desc: "no columns", values: nil, want: ""},
+		{desc: "one column", values: []interface{}{"Scan cpu"}, want: "Scan cpu"},
+		{
+			desc:   "columns joined by tabs",
+			values: []interface{}{int64(0), "Scan cpu", "rows", 1.5, nil, ts, []byte("x")},
+			want:   "0\tScan cpu\trows\t1.5\tNULL\t2016-01-01T00:00:00Z\tx",
+		},
+	}
+	for _, c := range cases {
+		if got := explainLine(c.values); got != c.want {
+			t.Errorf("%s: got %q want %q", c.desc, got, c.want)
+		}
+	}
+}
+
+func TestNewExplainer(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "plans.jsonl")
+	if e, err := newExplainer(0, "bogus", ""); e != nil || err != nil {
+		t.Errorf("expected no explainer when off, got %v, %v", e, err)
+	}
+	if _, err := newExplainer(1, "bogus", file); err == nil {
+		t.Errorf("expected error for unknown explain mode")
+	}
+	if _, err := newExplainer(1, explainAfter, ""); err == nil {
+		t.Errorf("expected error without an explain file")
+	}
+	e, err := newExplainer(2, explainInstead, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !e.instead || e.every != 2 {
+		t.Errorf("incorrect explainer: %+v", e)
+	}
+
+	tq := &query.Kwdb{HumanLabel: []byte("a")}
+	e.fail(tq, errors.New("canceled"))
+	e.fail(tq, errors.New("canceled"))
+	if e.failed != 2 || e.written != 0 {
+		t.Errorf("incorrect counts: got %d failed and %d written want 2 and 0", e.failed, e.written)
+	}
+	e.close()
+}
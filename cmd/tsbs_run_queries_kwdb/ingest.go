@@ -0,0 +1,198 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/timescale/tsbs/load"
+	"github.com/timescale/tsbs/pkg/data/source"
+	"github.com/timescale/tsbs/pkg/query"
+	"github.com/timescale/tsbs/pkg/targets"
+	kwdb "github.com/timescale/tsbs/pkg/targets/kwdb"
+)
+
+// timelineHeader extends the columns of the load report with the queries of
+// the same period
+const timelineHeader = "time,per. metric/s,metric total,overall metric/s,per. row/s,row total,overall row/s," +
+	"per. query/s,query total,overall query/s,p50 ms,p95 ms,p99 ms,max ms"
+
+// ingestOptions configure the concurrent ingest mode, in which the data of
+// file is loaded while the queries run
+type ingestOptions struct {
+	file            string
+	workers         int
+	batchSize       uint
+	maxRowRate      uint64
+	insertType      string
+	useCase         string
+	createTables    bool
+	reportingPeriod time.Duration
+	// retry policy of the failed statements, as in tsbs_load_kwdb
+	retryMaxAttempts int
+	retryBackoff     time.Duration
+	retryMaxBackoff  time.Duration
+	retrySQLStates   string
+	continueOnError  bool
+}
+
+// newLoader returns the load runner and the benchmark of the ingest, they use
+// the connection flags and the database of the queries
+func (o *ingestOptions) newLoader() (load.BenchmarkRunner, targets.Benchmark) {
+	opts := &kwdb.LoadingOptions{
+		User:          user,
+		Pass:          pass,
+		Host:          host,
+		Port:          port,
+		Hosts:         hosts,
+		HostsStrategy: strategy,
+		CertDir:       certdir,
+		DBName:        runner.DatabaseName(),
+		Type:          o.insertType,
+		Case:          o.useCase,
+		Workers:       o.workers,
+		DoCreate:      o.createTables,
+		Preparesize:   int(o.batchSize),
+		// the queries compete with the ingest, so its statements are retried
+		RetryMaxAttempts: o.retryMaxAttempts,
+		RetryBackoff:     o.retryBackoff,
+		RetryMaxBackoff:  o.retryMaxBackoff,
+		RetrySQLStates:   o.retrySQLStates,
+		ContinueOnError:  o.continueOnError,
+	}
+	loaderConf := load.BenchmarkRunnerConfig{
+		DBName:    opts.DBName,
+		BatchSize: o.batchSize,
+		Workers:   uint(o.workers),
+		DoLoad:    true,
+		// the database is queried meanwhile, so it is never dropped and created
+		DoCreateDB:      false,
+		HashWorkers:     true,
+		NoFlowControl:   true,
+		ChannelCapacity: 50,
+		FileName:        o.file,
+		MaxRowRate:      o.maxRowRate,
+	}
+	benchmark, err := kwdb.NewBenchmark(opts.DBName, opts, &source.DataSourceConfig{
+		Type: source.FileDataSourceType,
+		File: &source.FileDataSourceConfig{Location: o.file},
+	})
+	if err != nil {
+		panic(err)
+	}
+	return load.GetBenchmarkRunner(loaderConf), benchmark
+}
+
+// runWithIngest runs the queries while the data of --load-file is loaded, and
+// reports the load throughput and the query latencies on one timeline
+func runWithIngest() {
+	loader, benchmark := ingest.newLoader()
+	tl := newTimeline(loader)
+	runner.SetStatListener(tl.observe)
+
+	done := make(chan struct{})
+	reported := make(chan struct{})
+	go func() {
+		tl.run(ingest.reportingPeriod, done)
+		close(reported)
+	}()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		loader.RunBenchmark(benchmark)
+	}()
+	runner.Run(&query.KwdbPool, newProcessor)
+	wg.Wait()
+
+	close(done)
+	<-reported
+}
+
+// timeline reports the load throughput and the query latencies of the same
+// periods
+type timeline struct {
+	loader load.BenchmarkRunner
+
+	mu sync.Mutex
+	// latencies holds the query latencies of the current period in us
+	latencies *hdrhistogram.Histogram
+	queries   uint64
+
+	start       time.Time
+	prevTime    time.Time
+	prevMetrics uint64
+	prevRows    uint64
+	prevQueries uint64
+}
+
+func newTimeline(loader load.BenchmarkRunner) *timeline {
+	return &timeline{
+		loader:    loader,
+		latencies: hdrhistogram.New(1, 3600000000, 3),
+	}
+}
+
+// observe records the latency of a query, it is the stat listener of the
+// query runner
+func (t *timeline) observe(_ []byte, latencyMs float64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.queries++
+	_ = t.latencies.RecordValue(int64(latencyMs * 1e3))
+}
+
+// run prints a line every period until done is closed, then a last line for
+// the partial period
+func (t *timeline) run(period time.Duration, done <-chan struct{}) {
+	t.start = time.Now()
+	t.prevTime = t.start
+	fmt.Println(timelineHeader)
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			fmt.Println(t.line(now))
+		case <-done:
+			fmt.Println(t.line(time.Now()))
+			return
+		}
+	}
+}
+
+// line returns the timeline line of the period ending at now and starts the
+// next period
+func (t *timeline) line(now time.Time) string {
+	metrics, rows := t.loader.Counts()
+	t.mu.Lock()
+	queries := t.queries
+	latencies := "-,-,-,-"
+	if t.latencies.TotalCount() > 0 {
+		latencies = fmt.Sprintf("%0.2f,%0.2f,%0.2f,%0.2f",
+			float64(t.latencies.ValueAtQuantile(50))/1e3,
+			float64(t.latencies.ValueAtQuantile(95))/1e3,
+			float64(t.latencies.ValueAtQuantile(99))/1e3,
+			float64(t.latencies.Max())/1e3)
+	}
+	t.latencies.Reset()
+	t.mu.Unlock()
+
+	took := now.Sub(t.prevTime).Seconds()
+	sinceStart := now.Sub(t.start).Seconds()
+	s := fmt.Sprintf("%d,%0.2f,%E,%0.2f", now.Unix(), float64(metrics-t.prevMetrics)/took, float64(metrics), float64(metrics)/sinceStart)
+	if rows > 0 {
+		s += fmt.Sprintf(",%0.2f,%E,%0.2f", float64(rows-t.prevRows)/took, float64(rows), float64(rows)/sinceStart)
+	} else {
+		s += ",-,-,-"
+	}
+	s += fmt.Sprintf(",%0.2f,%d,%0.2f,%s", float64(queries-t.prevQueries)/took, queries, float64(queries)/sinceStart, latencies)
+
+	t.prevTime = now
+	t.prevMetrics = metrics
+	t.prevRows = rows
+	t.prevQueries = queries
+	return s
+}
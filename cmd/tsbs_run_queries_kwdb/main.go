@@ -2,12 +2,15 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
+	"os"
 	"strings"
 	"time"
 
 	"github.com/blagojts/viper"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/spf13/pflag"
 	"github.com/timescale/tsbs/internal/utils"
 	"github.com/timescale/tsbs/pkg/query"
@@ -18,12 +21,27 @@ var (
 	user      string
 	pass      string
 	host      string
+	hosts     string
+	strategy  string
+	workers   int
 	certdir   string
 	querytype string
 	port      int
 	runner    *query.BenchmarkRunner
 	prepare   bool
 	compress  string
+
+	// queryTypes holds the query types of --query-type, empty accepts every
+	// query type of the query file
+	queryTypes map[string]bool
+	ingest     ingestOptions
+	// validation compares the query results with golden results, nil when off
+	validation    *validator
+	timebucketOpt bool
+	// queryTimeout cancels queries running longer, 0 = no timeout
+	queryTimeout time.Duration
+	// explain captures the plans of sampled queries, nil when off
+	explain *explainer
 )
 
 func init() {
@@ -33,8 +51,31 @@ func init() {
 	pflag.String("user", "root", "User to connect to kwdb")
 	pflag.String("pass", "", "Password for the user connecting to kwdb")
 	pflag.String("host", "", "kwdb host")
+	pflag.String("hosts", "", "Comma separated host:port list of the cluster nodes, overrides host")
+	pflag.String("hosts-strategy", commonpool.StrategyRoundRobin, "How workers are spread over hosts: round-robin or partition")
 	pflag.String("certdir", "", "dir of cert files")
 	pflag.Int("port", 26257, "kwdb Port")
+	pflag.String("load-file", "", "Data file to load while the queries run, enables the concurrent ingest mode")
+	pflag.Int("load-workers", 1, "Number of parallel clients inserting in the concurrent ingest mode")
+	pflag.Uint("load-batch-size", 1000, "Number of rows to batch together in a single insert in the concurrent ingest mode")
+	pflag.Uint64("load-max-row-rate", 0, "Limit the rate of inserted rows per second in the concurrent ingest mode, 0 = no limit")
+	pflag.String("load-insert-type", "insert", "kwdb insert type of the concurrent ingest mode: insert, prepare, prepareiot or copy")
+	pflag.String("load-case", "cpu-only", "Use case of the data file of the concurrent ingest mode")
+	pflag.Bool("load-create-tables", true, "Create the sub tables of the data file in the concurrent ingest mode")
+	pflag.Int("load-retry-max-attempts", 3, "Max attempts of a failed statement in the concurrent ingest mode, 1 disables retries")
+	pflag.Duration("load-retry-backoff", time.Second, "Wait before the first retry in the concurrent ingest mode, doubled after every attempt")
+	pflag.Duration("load-retry-max-backoff", 30*time.Second, "Max wait between two retries in the concurrent ingest mode")
+	pflag.String("load-retry-sqlstates", "", "Comma separated SQLSTATEs to retry in the concurrent ingest mode, empty = those of tsbs_load_kwdb")
+	pflag.Bool("load-continue-on-error", false, "Skip statements that failed after all retries in the concurrent ingest mode instead of aborting")
+	pflag.Duration("reporting-period", 10*time.Second, "Period of the load and query timeline in the concurrent ingest mode")
+	pflag.String("validate-golden", "", "Compare the query results with the golden results of this file, a mismatch fails the run")
+	pflag.String("record-golden", "", "Write the query results to this file, to be used as golden results by --validate-golden")
+	pflag.Float64("validate-tolerance", 1e-6, "Relative tolerance of numbers when comparing with golden results")
+	pflag.Bool("timebucket-opt", true, "Push down time_bucket aggregations (enable_timebucket_opt), disable to record reference golden results")
+	pflag.Duration("query-timeout", 0, "Cancel queries running longer than this and count them as failed, 0 = no timeout")
+	pflag.Uint64("explain-sample", 0, "Run EXPLAIN ANALYZE for every Nth query of each label, 0 = off")
+	pflag.String("explain-mode", explainAfter, "Run EXPLAIN ANALYZE after or instead of the timed execution of the sampled queries: after or instead")
+	pflag.String("explain-file", "", "File to write the query plans of --explain-sample to")
 	pflag.Parse()
 	err := utils.SetupConfigFile()
 
@@ -47,15 +88,62 @@ func init() {
 	user = viper.GetString("user")
 	pass = viper.GetString("pass")
 	host = viper.GetString("host")
+	hosts = viper.GetString("hosts")
+	strategy = viper.GetString("hosts-strategy")
+	workers = int(config.Workers)
 	certdir = viper.GetString("certdir")
 	querytype = viper.GetString("query-type")
+	queryTypes = parseQueryTypes(querytype)
 	prepare = viper.GetBool("prepare")
 	compress = viper.GetString("compress")
 	port = viper.GetInt("port")
+	ingest = ingestOptions{
+		file:             viper.GetString("load-file"),
+		workers:          viper.GetInt("load-workers"),
+		batchSize:        viper.GetUint("load-batch-size"),
+		maxRowRate:       viper.GetUint64("load-max-row-rate"),
+		insertType:       viper.GetString("load-insert-type"),
+		useCase:          viper.GetString("load-case"),
+		createTables:     viper.GetBool("load-create-tables"),
+		reportingPeriod:  viper.GetDuration("reporting-period"),
+		retryMaxAttempts: viper.GetInt("load-retry-max-attempts"),
+		retryBackoff:     viper.GetDuration("load-retry-backoff"),
+		retryMaxBackoff:  viper.GetDuration("load-retry-max-backoff"),
+		retrySQLStates:   viper.GetString("load-retry-sqlstates"),
+		continueOnError:  viper.GetBool("load-continue-on-error"),
+	}
+	timebucketOpt = viper.GetBool("timebucket-opt")
+	queryTimeout = viper.GetDuration("query-timeout")
+	validation, err = newValidator(viper.GetString("validate-golden"), viper.GetString("record-golden"), viper.GetFloat64("validate-tolerance"))
+	if err != nil {
+		panic(err)
+	}
+	explain, err = newExplainer(viper.GetUint64("explain-sample"), viper.GetString("explain-mode"), viper.GetString("explain-file"))
+	if err != nil {
+		panic(err)
+	}
+	// instead模式下抽样的查询不返回结果, 无法记录或校验
+	if explain != nil && explain.instead && validation != nil {
+		panic("--explain-mode=instead does not read the results of the sampled queries and cannot be combined with --validate-golden or --record-golden")
+	}
+	// 回放的工作负载是普通SQL语句, 没有查询类型和模板
+	if len(config.ReplayFile) > 0 && prepare {
+		panic("--replay-file runs plain SQL statements and cannot be combined with --prepare")
+	}
 	runner = query.NewBenchmarkRunner(config)
 }
 func main() {
-	runner.Run(&query.KwdbPool, newProcessor)
+	if len(ingest.file) > 0 {
+		runWithIngest()
+	} else {
+		runner.Run(&query.KwdbPool, newProcessor)
+	}
+	if explain != nil {
+		explain.close()
+	}
+	if validation != nil && !validation.close() {
+		os.Exit(1)
+	}
 }
 
 type queryExecutorOptions struct {
@@ -70,10 +158,48 @@ type processor struct {
 	prepareStmt strings.Builder
 	formatBuf   []int16
 	buffer      map[string]*fixedArgList
+	// formats holds the parameter formats of every prepared query type
+	formats map[string][]int16
+	// templates holds the sql of every prepared query type, explained the
+	// query types whose EXPLAIN ANALYZE is prepared
+	templates map[string]string
+	explained map[string]bool
+}
+
+// parseQueryTypes returns the query types of a --query-type list such as
+// lastpoint:50,high-cpu-1:30, the weights are only used by the generator
+func parseQueryTypes(s string) map[string]bool {
+	types := make(map[string]bool)
+	for _, entry := range strings.Split(s, ",") {
+		if i := strings.IndexByte(entry, ':'); i >= 0 {
+			entry = entry[:i]
+		}
+		if entry = strings.TrimSpace(entry); len(entry) > 0 {
+			types[entry] = true
+		}
+	}
+	return types
+}
+
+// getConnection returns a connection for the worker, spread over the nodes of
+// --hosts when set
+func getConnection(workerNum int) (*commonpool.Conn, error) {
+	if len(hosts) == 0 {
+		return commonpool.GetConnection(user, pass, host, certdir, port)
+	}
+	nodes, err := commonpool.ParseHosts(hosts, port)
+	if err != nil {
+		return nil, err
+	}
+	cluster, err := commonpool.GetCluster(user, pass, certdir, nodes, strategy, 0)
+	if err != nil {
+		return nil, err
+	}
+	return cluster.GetConnection(workerNum, workers)
 }
 
 func (p *processor) Init(workerNum int) {
-	db, err := commonpool.GetConnection(user, pass, host, certdir, port)
+	db, err := getConnection(workerNum)
 	if err != nil {
 		panic(err)
 	}
@@ -83,39 +209,126 @@ func (p *processor) Init(workerNum int) {
 		printResponse: runner.DoPrintResponses(),
 	}
 	ctx := context.Background()
+	p.setSession(ctx)
+	p.formats = make(map[string][]int16)
+	p.templates = make(map[string]string)
+	p.explained = make(map[string]bool)
+	if prepare {
+		// 查询模板初始化, 混合负载中其余的查询类型在第一次出现时再prepare
+		for qt := range queryTypes {
+			p.prepareTemplate(ctx, qt)
+		}
+	}
+
+}
+
+// setSession sets the session variables of the connection of the worker
+func (p *processor) setSession(ctx context.Context) {
 	// 此配置用于打开time_bucket+聚合计算的SQL语句的下推计算功能.范围是sessions级别的，只针对于当前窗口
-	_, err = p.db.Connection.Exec(ctx, "set enable_timebucket_opt = true;")
+	_, err := p.db.Connection.Exec(ctx, fmt.Sprintf("set enable_timebucket_opt = %t;", timebucketOpt))
 	if err != nil {
 		//	panic(err)
 	}
 	if err := p.setSessionCompress(ctx); err != nil {
 		fmt.Println("set session pg_extend_compress error")
 	}
-	if prepare {
-		// 查询模板初始化
-		p.Initquery(querytype)
-		sql := p.prepareStmt.String()
-		_, err1 := p.db.Connection.Prepare(ctx, querytype, sql)
-		if err1 != nil {
-			panic(fmt.Sprintf("%s Prepare failed,err :%s, sql :%s", querytype, err1, sql))
+}
+
+// RunHook runs the statement of --cold-cache-sql on the connection of the
+// worker before a cold query
+func (p *processor) RunHook(sql string) error {
+	ctx, cancel := queryContext()
+	defer cancel()
+	if _, err := p.db.Connection.Exec(ctx, sql); err != nil {
+		return p.queryError(ctx, err)
+	}
+	return nil
+}
+
+// queryContext returns the context of a query, cancelled after --query-timeout
+func queryContext() (context.Context, context.CancelFunc) {
+	if queryTimeout > 0 {
+		return context.WithTimeout(context.Background(), queryTimeout)
+	}
+	return context.WithCancel(context.Background())
+}
+
+// queryError returns the error of a query, wrapping context.DeadlineExceeded
+// when it timed out. A cancelled query closes the connection, it is replaced
+// and the statements are prepared again on first use.
+func (p *processor) queryError(ctx context.Context, err error) error {
+	if ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("query timed out after %v: %w", queryTimeout, context.DeadlineExceeded)
+	}
+	if p.db.Connection.IsClosed() {
+		if rerr := p.db.Reconnect(); rerr != nil {
+			panic(fmt.Sprintf("cannot reconnect after failed query: %v", rerr))
 		}
+		p.setSession(context.Background())
+		p.formats = make(map[string][]int16)
+		p.explained = make(map[string]bool)
 	}
+	return err
+}
 
+// prepareTemplate prepares the statement of a query type on the connection of
+// the worker, the statement is named after the query type
+func (p *processor) prepareTemplate(ctx context.Context, queryType string) {
+	p.prepareStmt.Reset()
+	p.formatBuf = nil
+	p.Initquery(queryType)
+	if _, ok := p.buffer[queryType]; !ok {
+		panic(fmt.Sprintf("query type \"%s\" does not support prepare", queryType))
+	}
+	sql := p.prepareStmt.String()
+	_, err := p.db.Connection.Prepare(ctx, queryType, sql)
+	if err != nil {
+		panic(fmt.Sprintf("%s Prepare failed,err :%s, sql :%s", queryType, err, sql))
+	}
+	p.formats[queryType] = p.formatBuf
+	p.templates[queryType] = sql
 }
 
 func (p *processor) ProcessQuery(q query.Query, prepare bool) ([]*query.Stat, error) {
+	return p.processQuery(q, prepare, false)
+}
+
+// ProcessWarmQuery runs the warm run of --prewarm-queries, which is never
+// sampled for EXPLAIN ANALYZE so only the first runs count towards the sample
+func (p *processor) ProcessWarmQuery(q query.Query) ([]*query.Stat, error) {
+	return p.processQuery(q, true, true)
+}
+
+func (p *processor) processQuery(q query.Query, prepare, warm bool) ([]*query.Stat, error) {
 	tq := q.(*query.Kwdb)
 
-	if tq.Querytype != querytype {
+	if len(queryTypes) > 0 && !queryTypes[tq.Querytype] {
 		panic(fmt.Sprintf("The specified query type \"%s\" is inconsistent with the query file type \"%s\"", querytype, tq.Querytype))
 	}
+	if _, ok := p.formats[tq.Querytype]; prepare && !ok {
+		p.prepareTemplate(context.Background(), tq.Querytype)
+	}
 	start := time.Now()
 	qry := string(tq.SqlQuery)
 	if p.opts.debug {
 		fmt.Println(qry)
 	}
 	querys := strings.Split(qry, ";")
-	ctx := context.Background()
+	// 抽样的查询用EXPLAIN ANALYZE记录执行计划, instead模式下不再计时执行,
+	// 作为跳过的查询计入统计; 预热后的warm执行不参与抽样
+	sampled := !warm && explain != nil && explain.sample(string(tq.HumanLabel))
+	if sampled && explain.instead {
+		if err := p.explainQuery(tq, prepare); err != nil {
+			return nil, err
+		}
+		return []*query.Stat{query.GetSkippedStat(q.HumanLabelName())}, nil
+	}
+	ctx, cancel := queryContext()
+	defer cancel()
+	// 校验或打印结果时保存查询返回的所有行, 计时结束后再格式化
+	collect := p.opts.printResponse || validation != nil
+	var values [][]interface{}
+	var results []*pgconn.Result
 
 	for i := 0; i < len(querys); i++ {
 		if !prepare {
@@ -123,31 +336,67 @@ func (p *processor) ProcessQuery(q query.Query, prepare bool) ([]*query.Stat, er
 			rows, err := p.db.Connection.Query(ctx, querys[i])
 			if err != nil {
 				log.Println("Error running query: '", querys[i], "'")
-				return nil, err
+				return nil, p.queryError(ctx, err)
 			}
 
 			for rows.Next() {
+				if !collect {
+					continue
+				}
+				row, err := rows.Values()
+				if err != nil {
+					rows.Close()
+					return nil, p.queryError(ctx, err)
+				}
+				values = append(values, row)
 			}
 			if err := rows.Err(); err != nil {
 				log.Println("Error reading query result: '", querys[i], "'")
-				return nil, err
+				return nil, p.queryError(ctx, err)
 			}
 		} else {
 			fmt.Println(querys)
 
 			tableBuffer := p.buffer[tq.Querytype]
 			p.RunSelect(tq.Querytype, strings.Split(qry, ","), tableBuffer)
-			res := p.db.Connection.PgConn().ExecPrepared(ctx, tq.Querytype, tableBuffer.args, p.formatBuf, []int16{}).Read()
+			res := p.db.Connection.PgConn().ExecPrepared(ctx, tq.Querytype, tableBuffer.args, p.formats[tq.Querytype], []int16{}).Read()
+			tableBuffer.Reset()
 			if res.Err != nil {
-				panic(res.Err)
+				return nil, p.queryError(ctx, res.Err)
+			}
+			if collect {
+				results = append(results, res)
 			}
-			tableBuffer.Reset()
 		}
 	}
 	took := float64(time.Since(start).Nanoseconds()) / 1e6
+	// 查询本身已成功执行, 获取执行计划失败不影响其统计, 单独计数
+	if sampled {
+		if err := p.explainQuery(tq, prepare); err != nil {
+			explain.fail(tq, err)
+		}
+	}
 	stat := query.GetStat()
 	stat.Init(q.HumanLabelName(), took)
 
+	if collect {
+		prepared, err := decodeResults(p.db.Connection.TypeMap(), results)
+		if err != nil {
+			return nil, err
+		}
+		res := &queryResult{ID: tq.GetID(), Label: string(tq.HumanLabel), SQL: qry, Rows: formatRows(append(values, prepared...))}
+		if p.opts.printResponse {
+			b, err := json.MarshalIndent(res, "", "  ")
+			if err != nil {
+				return nil, err
+			}
+			fmt.Println(string(b))
+		}
+		if validation != nil {
+			validation.check(res)
+		}
+	}
+
 	return []*query.Stat{stat}, nil
 }
 
@@ -62,141 +62,122 @@ func (fa *fixedArgList) Reset() {
 }
 
 func (p *processor) Initquery(s string) {
+	if p.buffer == nil {
+		p.buffer = make(map[string]*fixedArgList)
+	}
 	switch s {
 	// cpu-only
 	case QueryTypeCPUMaxAll1:
 		p.InitCpu1()
 		buffer := newFixedArgList(3)
 		buffer.Init()
-		p.buffer = make(map[string]*fixedArgList)
 		p.buffer[s] = buffer
 	case QueryTypeCPUMaxAll8:
 		p.InitCpu8()
 		buffer := newFixedArgList(10)
 		buffer.Init()
-		p.buffer = make(map[string]*fixedArgList)
 		p.buffer[s] = buffer
 	case QueryTypeDoubleGroupby1, QueryTypeDoubleGroupby5, QueryTypeDoubleGroupbyAll:
 		p.InitDoubleGroupby()
 		buffer := newFixedArgList(2)
 		buffer.Init()
-		p.buffer = make(map[string]*fixedArgList)
 		p.buffer[s] = buffer
 	case QueryTypeGroupbyOrderbyLimit:
 		p.InitGroupbyOrder()
 		buffer := newFixedArgList(1)
 		buffer.Init()
-		p.buffer = make(map[string]*fixedArgList)
 		p.buffer[s] = buffer
 	case QueryTypeHighCPU1:
 		p.InitHighCpu1()
 		buffer := newFixedArgList(3)
 		buffer.Init()
-		p.buffer = make(map[string]*fixedArgList)
 		p.buffer[s] = buffer
 	case QueryTypeHighCPUAll:
 		p.InitHighCpuall()
 		buffer := newFixedArgList(2)
 		buffer.Init()
-		p.buffer = make(map[string]*fixedArgList)
 		p.buffer[s] = buffer
 	case QueryTypeLastPoint:
 		p.InitLastPoint()
 		buffer := newFixedArgList(0)
 		buffer.Init()
-		p.buffer = make(map[string]*fixedArgList)
 		p.buffer[s] = buffer
 	case QueryTypeSingleGroupby1_1_1, QueryTypeSingleGroupby1_1_12,
 		QueryTypeSingleGroupby5_1_1, QueryTypeSingleGroupby5_1_12:
 		p.InitSingleGroupby_Host1()
 		buffer := newFixedArgList(3)
 		buffer.Init()
-		p.buffer = make(map[string]*fixedArgList)
 		p.buffer[s] = buffer
 	case QueryTypeSingleGroupby1_8_1, QueryTypeSingleGroupby5_8_1:
 		p.InitSingleGroupby_Hosts()
 		buffer := newFixedArgList(10)
 		buffer.Init()
-		p.buffer = make(map[string]*fixedArgList)
 		p.buffer[s] = buffer
 	// iot
 	case QueryTypeLastLoc:
 		p.InitLastLoc()
 		buffer := newFixedArgList(1)
 		buffer.Init()
-		p.buffer = make(map[string]*fixedArgList)
 		p.buffer[s] = buffer
 	case QueryTypeSingleLastLoc:
 		p.InitSingleLastLoc()
 		buffer := newFixedArgList(1)
 		buffer.Init()
-		p.buffer = make(map[string]*fixedArgList)
 		p.buffer[s] = buffer
 	case QueryTypeLowFuel:
 		p.InitSingleLastLoc()
 		buffer := newFixedArgList(1)
 		buffer.Init()
-		p.buffer = make(map[string]*fixedArgList)
 		p.buffer[s] = buffer
 	case QueryTypeHighLoad:
 		p.InitHighLoad()
 		buffer := newFixedArgList(1)
 		buffer.Init()
-		p.buffer = make(map[string]*fixedArgList)
 		p.buffer[s] = buffer
 	case QueryTypeStationaryTrucks:
 		p.InitStationaryTrucks()
 		buffer := newFixedArgList(3)
 		buffer.Init()
-		p.buffer = make(map[string]*fixedArgList)
 		p.buffer[s] = buffer
 	case QueryTypeLongDrivingSessions:
 		p.InitLongDrivingSessions()
 		buffer := newFixedArgList(3)
 		buffer.Init()
-		p.buffer = make(map[string]*fixedArgList)
 		p.buffer[s] = buffer
 	case QueryTypeLongDailySessions:
 		p.InitLongDailySessions()
 		buffer := newFixedArgList(3)
 		buffer.Init()
-		p.buffer = make(map[string]*fixedArgList)
 		p.buffer[s] = buffer
 	case QueryTypeAvgVsProjFuelConsumption:
 		p.InitConsumption()
 		buffer := newFixedArgList(0)
 		buffer.Init()
-		p.buffer = make(map[string]*fixedArgList)
 		p.buffer[s] = buffer
 	case QueryTypeAvgDailyDrivingDuration:
 		p.InitDuration()
 		buffer := newFixedArgList(2)
 		buffer.Init()
-		p.buffer = make(map[string]*fixedArgList)
 		p.buffer[s] = buffer
 	case QueryTypeAvgDailyDrivingSession:
 		p.InitSession()
 		buffer := newFixedArgList(2)
 		buffer.Init()
-		p.buffer = make(map[string]*fixedArgList)
 		p.buffer[s] = buffer
 	case QueryTypeAvgLoad:
 		p.InitLoad()
 		buffer := newFixedArgList(0)
 		buffer.Init()
-		p.buffer = make(map[string]*fixedArgList)
 		p.buffer[s] = buffer
 	case QueryTypeDailyActivity:
 		p.InitActivity()
 		buffer := newFixedArgList(2)
 		buffer.Init()
-		p.buffer = make(map[string]*fixedArgList)
 		p.buffer[s] = buffer
 	case QueryTypeBreakdownFrequency:
 		p.InitFrequency()
 		buffer := newFixedArgList(2)
 		buffer.Init()
-		p.buffer = make(map[string]*fixedArgList)
 		p.buffer[s] = buffer
 	}
 }
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// maxDiffLines limits the rows printed for one mismatched query
+const maxDiffLines = 20
+
+// queryResult is the result of a query, as printed with --print-responses and
+// written to golden files, one json object per line
+type queryResult struct {
+	ID    uint64     `json:"id"`
+	Label string     `json:"label"`
+	SQL   string     `json:"sql"`
+	Rows  [][]string `json:"rows"`
+}
+
+// validator compares the results of the queries with the golden results of
+// an earlier run and/or records them as golden results
+type validator struct {
+	golden    map[uint64]*queryResult
+	tolerance float64
+
+	mu         sync.Mutex
+	recordFile *os.File
+	record     *bufio.Writer
+	checked    uint64
+	mismatched uint64
+	missing    uint64
+}
+
+// newValidator returns nil when neither goldenFile nor recordFile is set
+func newValidator(goldenFile, recordFile string, tolerance float64) (*validator, error) {
+	if len(goldenFile) == 0 && len(recordFile) == 0 {
+		return nil, nil
+	}
+	v := &validator{tolerance: tolerance}
+	if len(goldenFile) > 0 {
+		f, err := os.Open(goldenFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot open golden file %s: %v", goldenFile, err)
+		}
+		defer f.Close()
+		v.golden, err = readGolden(f)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read golden file %s: %v", goldenFile, err)
+		}
+	}
+	if len(recordFile) > 0 {
+		f, err := os.Create(recordFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot create golden file %s: %v", recordFile, err)
+		}
+		v.recordFile = f
+		v.record = bufio.NewWriter(f)
+	}
+	return v, nil
+}
+
+func readGolden(r io.Reader) (map[uint64]*queryResult, error) {
+	golden := make(map[uint64]*queryResult)
+	dec := json.NewDecoder(r)
+	for {
+		res := &queryResult{}
+		err := dec.Decode(res)
+		if err == io.EOF {
+			return golden, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		golden[res.ID] = res
+	}
+}
+
+// check compares the result with its golden result and records it, a
+// mismatch is printed with its diff to stderr
+func (v *validator) check(res *queryResult) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.record != nil {
+		b, err := json.Marshal(res)
+		if err != nil {
+			panic(err)
+		}
+		v.record.Write(b)
+		v.record.WriteByte('\n')
+	}
+	if v.golden == nil {
+		return
+	}
+
+	v.checked++
+	want, ok := v.golden[res.ID]
+	if !ok {
+		v.missing++
+		fmt.Fprintf(os.Stderr, "query %d (%s) has no golden result\n", res.ID, res.Label)
+		return
+	}
+	if want.SQL != res.SQL {
+		v.mismatched++
+		fmt.Fprintf(os.Stderr, "query %d (%s) differs from the golden query, the golden results are of another query file:\n- %s\n+ %s\n",
+			res.ID, res.Label, want.SQL, res.SQL)
+		return
+	}
+	if diff := diffRows(want.Rows, res.Rows, v.tolerance); len(diff) > 0 {
+		v.mismatched++
+		fmt.Fprintf(os.Stderr, "query %d (%s) result differs from the golden result:\n%s\n%s", res.ID, res.Label, res.SQL, diff)
+	}
+}
+
+// close writes the recorded golden results and prints the summary of the
+// validation, it returns false when a result differed or was missing
+func (v *validator) close() bool {
+	if v.record != nil {
+		if err := v.record.Flush(); err != nil {
+			panic(err)
+		}
+		v.recordFile.Close()
+	}
+	if v.golden == nil {
+		return true
+	}
+	if v.mismatched == 0 && v.missing == 0 {
+		fmt.Printf("validation passed: %d queries match the golden results\n", v.checked)
+		return true
+	}
+	fmt.Printf("validation failed: %d of %d queries differ from the golden results, %d have none\n", v.mismatched, v.checked, v.missing)
+	return false
+}
+
+// diffRows returns the rows that differ between want and got, prefixed with
+// - and + respectively, or an empty string when they match. The rows are
+// compared in sorted order, since queries without ORDER BY return them in any
+// order, and numbers within the relative tolerance are equal.
+func diffRows(want, got [][]string, tolerance float64) string {
+	want = sortedRows(want)
+	got = sortedRows(got)
+	var b strings.Builder
+	lines := 0
+	for i := 0; i < len(want) || i < len(got); i++ {
+		if i < len(want) && i < len(got) && rowsEqual(want[i], got[i], tolerance) {
+			continue
+		}
+		if lines >= maxDiffLines {
+			b.WriteString("...\n")
+			break
+		}
+		if i < len(want) {
+			fmt.Fprintf(&b, "- %s\n", strings.Join(want[i], ","))
+		}
+		if i < len(got) {
+			fmt.Fprintf(&b, "+ %s\n", strings.Join(got[i], ","))
+		}
+		lines++
+	}
+	if b.Len() > 0 && len(want) != len(got) {
+		fmt.Fprintf(&b, "rows: want %d, got %d\n", len(want), len(got))
+	}
+	return b.String()
+}
+
+func sortedRows(rows [][]string) [][]string {
+	sorted := make([][]string, len(rows))
+	copy(sorted, rows)
+	sort.Slice(sorted, func(i, j int) bool {
+		return strings.Join(sorted[i], "\x00") < strings.Join(sorted[j], "\x00")
+	})
+	return sorted
+}
+
+func rowsEqual(a, b []string, tolerance float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] == b[i] {
+			continue
+		}
+		x, err1 := strconv.ParseFloat(a[i], 64)
+		y, err2 := strconv.ParseFloat(b[i], 64)
+		if err1 != nil || err2 != nil {
+			return false
+		}
+		if math.Abs(x-y) > tolerance*math.Max(math.Abs(x), math.Abs(y)) {
+			return false
+		}
+	}
+	return true
+}
+
+// formatRows formats the rows scanned by pgx for a query result
+func formatRows(rows [][]interface{}) [][]string {
+	var result [][]string
+	for _, values := range rows {
+		row := make([]string, len(values))
+		for i, v := range values {
+			row[i] = formatValue(v)
+		}
+		result = append(result, row)
+	}
+	return result
+}
+
+// decodeResults decodes the rows read from a prepared statement with the
+// type map of the connection, like pgx decodes the rows of a query, so both
+// are formatted the same
+func decodeResults(m *pgtype.Map, results []*pgconn.Result) ([][]interface{}, error) {
+	var rows [][]interface{}
+	for _, res := range results {
+		for _, raw := range res.Rows {
+			values := make([]interface{}, len(raw))
+			for i, buf := range raw {
+				if buf == nil {
+					continue
+				}
+				fd := res.FieldDescriptions[i]
+				dt, ok := m.TypeForOID(fd.DataTypeOID)
+				if !ok {
+					values[i] = string(buf)
+					continue
+				}
+				v, err := dt.Codec.DecodeValue(m, fd.DataTypeOID, fd.Format, buf)
+				if err != nil {
+					return nil, fmt.Errorf("cannot decode column %s: %v", fd.Name, err)
+				}
+				values[i] = v
+			}
+			rows = append(rows, values)
+		}
+	}
+	return rows, nil
+}
+
+// formatValue formats a value scanned by pgx for a result row
+func formatValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "NULL"
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'g', -1, 32)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	case []byte:
+		return string(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func TestRowsEqual(t *testing.T) {
+	cases := []struct {
+		desc      string
+		a, b      []string
+		tolerance float64
+		want      bool
+	}{
+		{desc: "same strings", a: []string{"host_1", "12"}, b: []string{"host_1", "12"}, want: true},
+		{desc: "different lengths", a: []string{"host_1"}, b: []string{"host_1", "12"}},
+		{desc: "different strings", a: []string{"host_1"}, b: []string{"host_2"}, tolerance: 1},
+		{desc: "string and number", a: []string{"NULL"}, b: []string{"0"}, tolerance: 1},
+		{desc: "same number in another format", a: []string{"1"}, b: []string{"1.0"}, want: true},
+		{desc: "within the relative tolerance", a: []string{"1000000"}, b: []string{"1000000.5"}, tolerance: 1e-6, want: true},
+		{desc: "beyond the relative tolerance", a: []string{"1"}, b: []string{"1.00001"}, tolerance: 1e-6},
+		{desc: "negative numbers", a: []string{"-2"}, b: []string{"-2.000001"}, tolerance: 1e-6, want: true},
+		{desc: "zero and zero", a: []string{"0"}, b: []string{"-0.0"}, tolerance: 1e-6, want: true},
+		// the tolerance is relative, nothing but zero equals zero
+		{desc: "zero and a tiny number", a: []string{"0"}, b: []string{"1e-300"}, tolerance: 1e-6},
+		{desc: "no tolerance", a: []string{"0.1"}, b: []string{"0.10000000000000001"}, want: true},
+		{desc: "no tolerance and another number", a: []string{"0.1"}, b: []string{"0.1000001"}},
+	}
+	for _, c := range cases {
+		if got := rowsEqual(c.a, c.b, c.tolerance); got != c.want {
+			t.Errorf("%s: got %v want %v", c.desc, got, c.want)
+		}
+		if got := rowsEqual(c.b, c.a, c.tolerance); got != c.want {
+			t.Errorf("%s swapped: got %v want %v", c.desc, got, c.want)
+		}
+	}
+}
+
+func TestSortedRows(t *testing.T) {
+	cases := []struct {
+		desc string
+		rows [][]string
+		want [][]string
+	}{
+		{desc: "nil", rows: nil, want: [][]string{}},
+		{desc: "sorted", rows: [][]string{{"a", "1"}, {"b", "2"}}, want: [][]string{{"a", "1"}, {"b", "2"}}},
+		{desc: "unsorted", rows: [][]string{{"b", "2"}, {"a", "1"}, {"a", "0"}}, want: [][]string{{"a", "0"}, {"a", "1"}, {"b", "2"}}},
+		{desc: "prefix first", rows: [][]string{{"a", "c"}, {"a"}}, want: [][]string{{"a"}, {"a", "c"}}},
+		// the columns are compared apart, a comma in a value does not
+		// join two columns
+		{desc: "comma in a value", rows: [][]string{{"a,b"}, {"a", "c"}}, want: [][]string{{"a", "c"}, {"a,b"}}},
+	}
+	for _, c := range cases {
+		before := append([][]string(nil), c.rows...)
+		got := sortedRows(c.rows)
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got %v want %v", c.desc, got, c.want)
+		}
+		if !reflect.DeepEqual(c.rows, before) {
+			t.Errorf("%s: rows changed to %v", c.desc, c.rows)
+		}
+	}
+}
+
+func TestDiffRows(t *testing.T) {
+	cases := []struct {
+		desc      string
+		want, got [][]string
+		tolerance float64
+		diff      string
+	}{
+		{desc: "no rows", diff: ""},
+		{
+			desc: "same rows in another order",
+			want: [][]string{{"host_1", "1"}, {"host_2", "2"}},
+			got:  [][]string{{"host_2", "2"}, {"host_1", "1"}},
+			diff: "",
+		},
+		{
+			desc:      "numbers within the tolerance",
+			want:      [][]string{{"host_1", "1"}},
+			got:       [][]string{{"host_1", "1.0000001"}},
+			tolerance: 1e-6,
+			diff:      "",
+		},
+		{
+			desc: "changed value",
+			want: [][]string{{"host_1", "1"}, {"host_2", "2"}},
+			got:  [][]string{{"host_1", "1"}, {"host_2", "3"}},
+			diff: "- host_2,2\n+ host_2,3\n",
+		},
+		{
+			desc: "missing row",
+			want: [][]string{{"host_1", "1"}, {"host_2", "2"}},
+			got:  [][]string{{"host_1", "1"}},
+			diff: "- host_2,2\nrows: want 2, got 1\n",
+		},
+		{
+			desc: "extra row",
+			want: nil,
+			got:  [][]string{{"host_1", "1"}},
+			diff: "+ host_1,1\nrows: want 0, got 1\n",
+		},
+	}
+	for _, c := range cases {
+		if got := diffRows(c.want, c.got, c.tolerance); got != c.diff {
+			t.Errorf("%s: incorrect diff:\ngot\n%s\nwant\n%s", c.desc, got, c.diff)
+		}
+	}
+
+	// the diff is cut after maxDiffLines mismatched rows
+	var want, got [][]string
+	for i := 0; i < maxDiffLines+5; i++ {
+		host := fmt.Sprintf("host_%02d", i)
+		want = append(want, []string{host, "1"})
+		got = append(got, []string{host, "2"})
+	}
+	diff := diffRows(want, got, 0)
+	if lines := strings.Count(diff, "\n"); lines != 2*maxDiffLines+1 || !strings.HasSuffix(diff, "...\n") {
+		t.Errorf("incorrect long diff: got %d lines want %d ending with ...:\n%s", lines, 2*maxDiffLines+1, diff)
+	}
+}
+
+func TestReadGolden(t *testing.T) {
+	cases := []struct {
+		desc    string
+		in      string
+		want    map[uint64]*queryResult
+		wantErr bool
+	}{
+		{desc: "empty", in: "", want: map[uint64]*queryResult{}},
+		{
+			desc: "results",
+			in: `{"id":0,"label":"a","sql":"SELECT 1","rows":[["1"]]}
+{"id":2,"label":"b","sql":"SELECT 2","rows":null}
+`,
+			want: map[uint64]*queryResult{
+				0: {ID: 0, Label: "a", SQL: "SELECT 1", Rows: [][]string{{"1"}}},
+				2: {ID: 2, Label: "b", SQL: "SELECT 2"},
+			},
+		},
+		{
+			desc: "later result of the same id",
+			in: `{"id":1,"label":"a","sql":"SELECT 1","rows":[["1"]]}
+{"id":1,"label":"a","sql":"SELECT 1","rows":[["2"]]}
+`,
+			want: map[uint64]*queryResult{1: {ID: 1, Label: "a", SQL: "SELECT 1", Rows: [][]string{{"2"}}}},
+		},
+		{desc: "malformed line", in: `{"id":0,"label":"a"}` + "\n{bad", wantErr: true},
+		{desc: "wrong type", in: `{"id":"zero"}`, wantErr: true},
+	}
+	for _, c := range cases {
+		got, err := readGolden(strings.NewReader(c.in))
+		if (err != nil) != c.wantErr {
+			t.Errorf("%s: incorrect error: %v", c.desc, err)
+			continue
+		}
+		if !c.wantErr && !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got %v want %v", c.desc, got, c.want)
+		}
+	}
+}
+
+func TestFormatRows(t *testing.T) {
+	ts := time.Date(2016, 1, 1, 8, 0, 0, 500, time.FixedZone("", 8*3600))
+	got := formatRows([][]interface{}{{"host_1", int64(12), 0.5, float32(0.25), ts, nil, []byte("x")}})
+	want := [][]string{{"host_1", "12", "0.5", "0.25", "2016-01-01T00:00:00.0000005Z", "NULL", "x"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("incorrect rows: got %v want %v", got, want)
+	}
+	if got := formatRows(nil); got != nil {
+		t.Errorf("expected nil rows for no rows, got %v", got)
+	}
+}
+
+func TestValidatePreparedAndQueryRows(t *testing.T) {
+	// a golden result recorded from the rows pgx decodes for a query
+	ts := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	golden := &queryResult{ID: 0, Label: "a", SQL: "SELECT 1", Rows: formatRows([][]interface{}{
+		{ts, ts, "host_1", int64(12), 0.5, nil},
+	})}
+	var buf strings.Builder
+	b, _ := json.Marshal(golden)
+	buf.Write(b)
+	v := &validator{}
+	var err error
+	if v.golden, err = readGolden(strings.NewReader(buf.String())); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// the same rows read in text format from a prepared statement
+	prepared := &pgconn.Result{
+		FieldDescriptions: []pgconn.FieldDescription{
+			{Name: "k_timestamp", DataTypeOID: pgtype.TimestamptzOID},
+			{Name: "time_bucket", DataTypeOID: pgtype.TimestampOID},
+			{Name: "hostname", DataTypeOID: pgtype.VarcharOID},
+			{Name: "usage_user", DataTypeOID: pgtype.Int8OID},
+			{Name: "avg", DataTypeOID: pgtype.Float8OID},
+			{Name: "max", DataTypeOID: pgtype.Float8OID},
+		},
+		Rows: [][][]byte{{[]byte("2016-01-01 00:00:00+00:00"), []byte("2016-01-01 00:00:00"), []byte("host_1"), []byte("12"), []byte("0.5"), nil}},
+	}
+	rows, err := decodeResults(pgtype.NewMap(), []*pgconn.Result{prepared})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	v.check(&queryResult{ID: 0, Label: "a", SQL: "SELECT 1", Rows: formatRows(rows)})
+	if v.checked != 1 || v.mismatched != 0 {
+		t.Errorf("prepared rows differ from the golden rows of the query: %v want %v", formatRows(rows), golden.Rows)
+	}
+
+	// unknown types stay text, malformed values fail
+	rows, err = decodeResults(pgtype.NewMap(), []*pgconn.Result{{
+		FieldDescriptions: []pgconn.FieldDescription{{Name: "plan", DataTypeOID: 999999}},
+		Rows:              [][][]byte{{[]byte("time: 1ms")}},
+	}})
+	if err != nil || !reflect.DeepEqual(rows, [][]interface{}{{"time: 1ms"}}) {
+		t.Errorf("incorrect rows of an unknown type: %v, %v", rows, err)
+	}
+	if _, err := decodeResults(pgtype.NewMap(), []*pgconn.Result{{
+		FieldDescriptions: []pgconn.FieldDescription{{Name: "usage_user", DataTypeOID: pgtype.Int8OID}},
+		Rows:              [][][]byte{{[]byte("x")}},
+	}}); err == nil {
+		t.Errorf("expected error for a malformed value")
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"os/exec"
 	"path/filepath"
+	"strings"
+	"sync"
 )
 
 type Executor struct {
@@ -39,6 +41,62 @@ func (e *Executor) ExecuteWithOutput(ctx context.Context, cmd *exec.Cmd) (*Execu
 	}, nil
 }
 
+// ExecuteStreaming 与 ExecuteWithOutput 相同，但在命令执行期间将 stdout 和 stderr 的
+// 每一行交给 onLine，onLine 不会被并发调用
+func (e *Executor) ExecuteStreaming(ctx context.Context, cmd *exec.Cmd, onLine func(line string)) (*ExecutionResult, error) {
+	var stdout, stderr bytes.Buffer
+	var mu sync.Mutex
+	stdoutLines := &lineWriter{buf: &stdout, mu: &mu, onLine: onLine}
+	stderrLines := &lineWriter{buf: &stderr, mu: &mu, onLine: onLine}
+	cmd.Stdout = stdoutLines
+	cmd.Stderr = stderrLines
+
+	err := cmd.Run()
+	stdoutLines.flush()
+	stderrLines.flush()
+	if err != nil {
+		return &ExecutionResult{
+			Output: stderr.String(),
+			Error:  err,
+		}, fmt.Errorf("command failed: %w", err)
+	}
+
+	return &ExecutionResult{
+		Output: stdout.String(),
+		Error:  nil,
+	}, nil
+}
+
+// lineWriter 保存写入的全部内容，并将其中完整的行交给 onLine
+type lineWriter struct {
+	buf     *bytes.Buffer
+	mu      *sync.Mutex
+	onLine  func(line string)
+	partial string
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf.Write(p)
+	lines := strings.Split(w.partial+string(p), "\n")
+	w.partial = lines[len(lines)-1]
+	for _, line := range lines[:len(lines)-1] {
+		w.onLine(strings.TrimRight(line, "\r"))
+	}
+	return len(p), nil
+}
+
+// flush 将最后一行不以换行结尾的内容交给 onLine
+func (w *lineWriter) flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.partial != "" {
+		w.onLine(w.partial)
+		w.partial = ""
+	}
+}
+
 func (e *Executor) GetBinaryPath(name string) string {
 	return filepath.Join(e.binPath, name)
 }
@@ -0,0 +1,28 @@
+package executor
+
+import (
+	"context"
+	"os/exec"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestExecuteStreaming(t *testing.T) {
+	var lines []string
+	cmd := exec.Command("sh", "-c", "echo first; echo second >&2; printf third")
+	result, err := NewExecutor("").ExecuteStreaming(context.Background(), cmd, func(line string) {
+		lines = append(lines, line)
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Output != "first\nthird" {
+		t.Errorf("incorrect output: got %q", result.Output)
+	}
+	// stdout 和 stderr 由不同的 goroutine 读取，行的先后顺序不确定
+	sort.Strings(lines)
+	if want := []string{"first", "second", "third"}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("incorrect lines: got %q want %q", lines, want)
+	}
+}
@@ -102,6 +102,17 @@ func (g *DataGenerator) CreateSimulator(config *common.DataGeneratorConfig) (com
 	return scfg.NewSimulator(g.config.LogInterval, g.config.Limit), nil
 }
 
+// devopsStats reports the anomalies and the churn of the devops simulators
+type devopsStats interface {
+	Anomalies() uint64
+	Churn() (deaths, births, tagChanges uint64)
+}
+
+// nullStats reports the field values the simulators left out
+type nullStats interface {
+	Nulls() uint64
+}
+
 func (g *DataGenerator) runSimulator(sim common.Simulator, serializer serialize.PointSerializer, dgc *common.DataGeneratorConfig) error {
 	defer g.bufOut.Flush()
 
@@ -136,6 +147,26 @@ func (g *DataGenerator) runSimulator(sim common.Simulator, serializer serialize.
 			}
 		}
 	}
+
+	// write out the ground truth of the injected anomalies
+	if c, ok := sim.(io.Closer); ok {
+		if err := c.Close(); err != nil {
+			return fmt.Errorf("can not write anomaly file: %s", err)
+		}
+	}
+	if v, ok := sim.(devopsStats); ok {
+		if anomalies := v.Anomalies(); anomalies > 0 {
+			fmt.Fprintf(os.Stderr, "injected %d anomalies\n", anomalies)
+		}
+		if deaths, births, changes := v.Churn(); deaths+births+changes > 0 {
+			fmt.Fprintf(os.Stderr, "decommissioned %d hosts, registered %d hosts, changed tags %d times\n", deaths, births, changes)
+		}
+	}
+	if v, ok := sim.(nullStats); ok {
+		if nulls := v.Nulls(); nulls > 0 {
+			fmt.Fprintf(os.Stderr, "left out %d field values\n", nulls)
+		}
+	}
 	return nil
 }
 
@@ -147,6 +178,8 @@ func (g *DataGenerator) getSerializer(sim common.Simulator, target targets.Imple
 		fallthrough
 	case constants.FormatTimescaleDB:
 		g.writeHeader(sim.Headers())
+	case constants.FormatKwdb:
+		g.writeTypedHeader(sim.Headers())
 	}
 	return target.Serializer(), nil
 }
@@ -180,3 +213,53 @@ func (g *DataGenerator) writeHeader(headers *common.GeneratedDataHeaders) {
 	}
 	g.bufOut.WriteString("\n")
 }
+
+// nullMarker marks the fields whose values may be missing in the typed header
+const nullMarker = "null"
+
+// writeTypedHeader writes the same header block as writeHeader, but every
+// field carries its type and the measurement specific tags follow the fields
+// marked with a trailing "tag". Fields whose values may be missing are marked
+// with a trailing "null", e.g.
+//
+//	tags,hostname string,region string
+//	disk,total int64,used_percent int64 null,path string tag
+func (g *DataGenerator) writeTypedHeader(headers *common.GeneratedDataHeaders) {
+	g.bufOut.WriteString("tags")
+	for i, key := range headers.TagKeys {
+		g.bufOut.WriteString(",")
+		g.bufOut.WriteString(key)
+		g.bufOut.WriteString(" ")
+		g.bufOut.WriteString(headers.TagTypes[i])
+	}
+	g.bufOut.WriteString("\n")
+
+	keys := make([]string, 0, len(headers.FieldKeys))
+	for k := range headers.FieldKeys {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, measurementName := range keys {
+		g.bufOut.WriteString(measurementName)
+		fieldTypes := headers.FieldTypes[measurementName]
+		for i, field := range headers.FieldKeys[measurementName] {
+			g.bufOut.WriteString(",")
+			g.bufOut.WriteString(field)
+			g.bufOut.WriteString(" ")
+			g.bufOut.WriteString(fieldTypes[i])
+			if nullable := headers.NullableFields[measurementName]; i < len(nullable) && nullable[i] {
+				g.bufOut.WriteString(" " + nullMarker)
+			}
+		}
+		tagTypes := headers.MeasurementTagTypes[measurementName]
+		for i, tag := range headers.MeasurementTagKeys[measurementName] {
+			g.bufOut.WriteString(",")
+			g.bufOut.WriteString(tag)
+			g.bufOut.WriteString(" ")
+			g.bufOut.WriteString(tagTypes[i])
+			g.bufOut.WriteString(" tag")
+		}
+		g.bufOut.WriteString("\n")
+	}
+	g.bufOut.WriteString("\n")
+}
@@ -62,6 +62,9 @@ type QueryGenerator struct {
 	factories map[string]interface{}
 	tsStart   time.Time
 	tsEnd     time.Time
+	// queryMix holds the query types of the query-type flag with their
+	// weights, a single entry for a plain query type
+	queryMix []weightedQueryType
 
 	// bufOut represents the buffered writer that should actually be passed to
 	// any operations that write out data.
@@ -89,7 +92,16 @@ func (g *QueryGenerator) Generate(config common.GeneratorConfig) error {
 		return err
 	}
 
-	filler := g.useCaseMatrix[g.conf.Use][g.conf.QueryType](useGen)
+	var filler queryUtils.QueryFiller
+	if len(g.queryMix) == 1 && g.queryMix[0].name == g.conf.QueryType {
+		filler = g.useCaseMatrix[g.conf.Use][g.conf.QueryType](useGen)
+	} else {
+		fillers := make([]queryUtils.QueryFiller, len(g.queryMix))
+		for i, t := range g.queryMix {
+			fillers[i] = g.useCaseMatrix[g.conf.Use][t.name](useGen)
+		}
+		filler = newMixedFiller(g.queryMix, fillers)
+	}
 
 	return g.runQueryGeneration(useGen, filler, g.conf)
 }
@@ -121,9 +133,18 @@ func (g *QueryGenerator) init(conf common.GeneratorConfig) error {
 		return fmt.Errorf(errBadUseFmt, g.conf.Use)
 	}
 
-	if _, ok := g.useCaseMatrix[g.conf.Use][g.conf.QueryType]; !ok {
+	g.queryMix, err = parseQueryMix(g.conf.QueryType)
+	if err != nil {
+		return err
+	}
+	if len(g.queryMix) == 0 {
 		return fmt.Errorf(errBadQueryTypeFmt, g.conf.Use, g.conf.QueryType)
 	}
+	for _, t := range g.queryMix {
+		if _, ok := g.useCaseMatrix[g.conf.Use][t.name]; !ok {
+			return fmt.Errorf(errBadQueryTypeFmt, g.conf.Use, t.name)
+		}
+	}
 
 	g.tsStart, err = internalUtils.ParseUTCTime(g.conf.TimeStart)
 	if err != nil {
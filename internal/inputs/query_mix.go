@@ -0,0 +1,93 @@
+package inputs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	queryUtils "github.com/timescale/tsbs/cmd/tsbs_generate_queries/utils"
+	"github.com/timescale/tsbs/pkg/query"
+)
+
+const errBadQueryWeightFmt = "invalid weight for query type '%s': '%s'"
+
+// weightedQueryType is one entry of a mixed workload, e.g. lastpoint:50
+type weightedQueryType struct {
+	name   string
+	weight int
+}
+
+// parseQueryMix parses a comma separated list of query types with optional
+// weights, e.g. "lastpoint:50,high-cpu-1:30,double-groupby-1:20". A type
+// without a weight has weight 1, so a single query type keeps its old meaning.
+func parseQueryMix(s string) ([]weightedQueryType, error) {
+	var mix []weightedQueryType
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if len(entry) == 0 {
+			continue
+		}
+		t := weightedQueryType{name: entry, weight: 1}
+		if i := strings.LastIndexByte(entry, ':'); i >= 0 {
+			t.name = strings.TrimSpace(entry[:i])
+			w, err := strconv.Atoi(strings.TrimSpace(entry[i+1:]))
+			if err != nil || w <= 0 {
+				return nil, fmt.Errorf(errBadQueryWeightFmt, t.name, entry[i+1:])
+			}
+			t.weight = w
+		}
+		mix = append(mix, t)
+	}
+	return mix, nil
+}
+
+// mixedFiller interleaves the queries of several query types in proportion to
+// their weights. It uses smooth weighted round-robin instead of random picks,
+// so the counts match the weights exactly and the random sequence of the
+// fillers stays the same as for a single query type.
+type mixedFiller struct {
+	names   []string
+	weights []int
+	current []int
+	total   int
+	fillers []queryUtils.QueryFiller
+}
+
+func newMixedFiller(mix []weightedQueryType, fillers []queryUtils.QueryFiller) *mixedFiller {
+	f := &mixedFiller{
+		names:   make([]string, len(mix)),
+		weights: make([]int, len(mix)),
+		current: make([]int, len(mix)),
+		fillers: fillers,
+	}
+	for i, t := range mix {
+		f.names[i] = t.name
+		f.weights[i] = t.weight
+		f.total += t.weight
+	}
+	return f
+}
+
+// next returns the index of the query type of the next query
+func (f *mixedFiller) next() int {
+	best := 0
+	for i := range f.current {
+		f.current[i] += f.weights[i]
+		if f.current[i] > f.current[best] {
+			best = i
+		}
+	}
+	f.current[best] -= f.total
+	return best
+}
+
+// Fill fills the query with the next query type of the mix. kwdb queries are
+// tagged with their own query type, so that the runner can prepare the
+// matching template.
+func (f *mixedFiller) Fill(q query.Query) query.Query {
+	i := f.next()
+	if kaiwudb, ok := q.(*query.Kwdb); ok {
+		kaiwudb.SetQuerytype(f.names[i])
+	}
+	return f.fillers[i].Fill(q)
+}
@@ -0,0 +1,77 @@
+package inputs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseQueryMix(t *testing.T) {
+	cases := []struct {
+		desc      string
+		in        string
+		want      []weightedQueryType
+		shouldErr bool
+	}{
+		{
+			desc: "single query type",
+			in:   "lastpoint",
+			want: []weightedQueryType{{name: "lastpoint", weight: 1}},
+		},
+		{
+			desc: "weighted mix",
+			in:   "lastpoint:50, high-cpu-1:30,double-groupby-1:20",
+			want: []weightedQueryType{
+				{name: "lastpoint", weight: 50},
+				{name: "high-cpu-1", weight: 30},
+				{name: "double-groupby-1", weight: 20},
+			},
+		},
+		{
+			desc: "mixed weights",
+			in:   "lastpoint,high-cpu-1:3",
+			want: []weightedQueryType{
+				{name: "lastpoint", weight: 1},
+				{name: "high-cpu-1", weight: 3},
+			},
+		},
+		{desc: "bad weight", in: "lastpoint:x", shouldErr: true},
+		{desc: "zero weight", in: "lastpoint:0", shouldErr: true},
+	}
+	for _, c := range cases {
+		got, err := parseQueryMix(c.in)
+		if c.shouldErr {
+			if err == nil {
+				t.Errorf("%s: expected error", c.desc)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", c.desc, err)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got %v want %v", c.desc, got, c.want)
+		}
+	}
+}
+
+func TestMixedFillerNext(t *testing.T) {
+	mix := []weightedQueryType{{name: "a", weight: 5}, {name: "b", weight: 3}, {name: "c", weight: 2}}
+	f := newMixedFiller(mix, nil)
+	counts := make([]int, len(mix))
+	var first []int
+	for i := 0; i < 100; i++ {
+		n := f.next()
+		counts[n]++
+		if i < 10 {
+			first = append(first, n)
+		}
+	}
+	if !reflect.DeepEqual(counts, []int{50, 30, 20}) {
+		t.Errorf("incorrect counts: got %v", counts)
+	}
+	// the types are interleaved rather than generated in blocks
+	want := []int{0, 1, 2, 0, 0, 1, 0, 2, 1, 0}
+	if !reflect.DeepEqual(first, want) {
+		t.Errorf("incorrect order: got %v want %v", first, want)
+	}
+}
@@ -32,7 +32,7 @@ func RegisterTools(
 			"properties": map[string]interface{}{
 				"use_case": map[string]interface{}{
 					"type":        "string",
-					"description": "Use case type. Supported options: cpu-only, cpu-single, devops, iot, devops-generic. Note: If format is 'kwdb', only 'cpu-only' and 'iot' are supported. Default: 'cpu-only'",
+					"description": "Use case type. Supported options: cpu-only, cpu-single, devops, iot, devops-generic. Note: If format is 'kwdb', cpu-single is not supported. Default: 'cpu-only'",
 					"enum":        []string{"cpu-only", "cpu-single", "devops", "iot", "devops-generic"},
 					"default":     "cpu-only",
 				},
@@ -74,15 +74,80 @@ func RegisterTools(
 				},
 				"outoforder": map[string]interface{}{
 					"type":        "number",
-					"description": "Out-of-order ratio, a float between 0.0-1.0 (optional). Only used for cpu-only use case.",
+					"description": "Out-of-order ratio, a float between 0.0-1.0 (optional). Only used for cpu-only and iot use cases.",
 					"minimum":     0.0,
 					"maximum":     1.0,
 				},
 				"outoforderwindow": map[string]interface{}{
 					"type":        "string",
-					"description": "Out-of-order time window (optional). Only used for cpu-only use case. Format: number + unit (s=seconds, m=minutes, h=hours).",
+					"description": "Out-of-order time window (optional). Only used for cpu-only and iot use cases, required by iot with outoforder. Format: number + unit (s=seconds, m=minutes, h=hours).",
 					"pattern":     "^\\d+[smh]$",
 				},
+				"outoforderdelay": map[string]interface{}{
+					"type":        "string",
+					"description": "Distribution of the delay of late data points within the out-of-order window (optional). Only used for iot use case.",
+					"enum":        []string{"uniform", "exponential"},
+				},
+				"profile": map[string]interface{}{
+					"type":        "string",
+					"description": "Comma separated value profile (optional), among diurnal and skew. Only used for devops use cases.",
+				},
+				"profile_measurements": map[string]interface{}{
+					"type":        "string",
+					"description": "Comma separated measurements the profile and the anomalies apply to (optional), default cpu.",
+				},
+				"anomaly_rate": map[string]interface{}{
+					"type":        "number",
+					"description": "Chance of every host to start an anomaly at each of its points, a float between 0.0-1.0 (optional). Only used for devops use cases.",
+					"minimum":     0.0,
+					"maximum":     1.0,
+				},
+				"anomaly_types": map[string]interface{}{
+					"type":        "string",
+					"description": "Comma separated anomaly types (optional), among spike, step and flatline, default all of them.",
+				},
+				"anomaly_file": map[string]interface{}{
+					"type":        "string",
+					"description": "CSV file the injected anomalies are written to as ground truth (optional).",
+				},
+				"device_death_rate": map[string]interface{}{
+					"type":        "number",
+					"description": "Chance of every host to be decommissioned per hour, a float between 0.0-1.0 (optional). Only used for devops use cases.",
+					"minimum":     0.0,
+					"maximum":     1.0,
+				},
+				"device_birth_rate": map[string]interface{}{
+					"type":        "number",
+					"description": "Chance of every decommissioned host to be replaced by a new host per hour, a float between 0.0-1.0 (optional). Only used for devops use cases.",
+					"minimum":     0.0,
+					"maximum":     1.0,
+				},
+				"tag_change_interval": map[string]interface{}{
+					"type":        "string",
+					"description": "Interval of the tag changes of the hosts (optional). Only used for devops use cases. Format: number + unit (s=seconds, m=minutes, h=hours).",
+					"pattern":     "^\\d+[smh]$",
+				},
+				"tag_change_ratio": map[string]interface{}{
+					"type":        "number",
+					"description": "Ratio of the hosts changing tags at every tag change interval, a float between 0.0-1.0 (optional), default 0.1.",
+					"minimum":     0.0,
+					"maximum":     1.0,
+				},
+				"null_ratio": map[string]interface{}{
+					"type":        "number",
+					"description": "Chance of every field value to be null, a float between 0.0-1.0 (optional). Sparse fields for all use cases.",
+					"minimum":     0.0,
+					"maximum":     1.0,
+				},
+				"null_field_ratios": map[string]interface{}{
+					"type":        "string",
+					"description": "Comma separated null ratios of single fields overriding null_ratio, as field=ratio or measurement.field=ratio (optional), e.g. 'usage_user=0.5,diagnostics.fuel_state=0.2'.",
+				},
+				"cardinality": map[string]interface{}{
+					"type":        "integer",
+					"description": "Number of distinct values of the high cardinality tag container_id spread over the hosts, 0 or at least the scale (optional). Only used for devops use cases.",
+					"minimum":     0,
+				},
 				"output_file": map[string]interface{}{
 					"type":        "string",
 					"description": "Output file path (optional). If not specified, filename will be auto-generated: {use_case}_{format}_scale_{scale}_{order}order.dat",
@@ -107,13 +172,14 @@ func RegisterTools(
 				},
 				"insert_type": map[string]interface{}{
 					"type":        "string",
-					"description": "Insert type. Must be one of: 'insert' (regular insert), 'prepare' (prepared statement), 'prepareiot' (IoT prepared). Default: 'insert'",
-					"enum":        []string{"insert", "prepare", "prepareiot"},
+					"description": "Insert type. Must be one of: 'insert' (regular insert), 'prepare' (cpu-only prepared statement), 'prepareiot' (IoT prepared), 'copy' (COPY FROM STDIN, needs the header block of the data file). Default: 'insert'",
+					"enum":        kwdbInsertTypes,
 					"default":     "insert",
 				},
 				"case": map[string]interface{}{
 					"type":        "string",
-					"description": "Use case type. Must match the use_case used when generating data. Examples: 'cpu-only', 'iot'",
+					"description": "Use case type. Must match the use_case used when generating data. One of: cpu-only, devops, devops-generic, iot",
+					"enum":        kwdbLoadUseCases,
 				},
 				"batch_size": map[string]interface{}{
 					"type":        "integer",
@@ -148,7 +214,7 @@ func RegisterTools(
 			"properties": map[string]interface{}{
 				"use_case": map[string]interface{}{
 					"type":        "string",
-					"description": "Use case type. Supported options: cpu-only, cpu-single, devops, iot, devops-generic. Note: If format is 'kwdb', only 'cpu-only' and 'iot' are supported. Default: 'cpu-only'",
+					"description": "Use case type. Supported options: cpu-only, cpu-single, devops, iot, devops-generic. Note: If format is 'kwdb', only 'cpu-only', 'devops' and 'iot' are supported. Default: 'cpu-only'",
 					"enum":        []string{"cpu-only", "cpu-single", "devops", "iot", "devops-generic"},
 					"default":     "cpu-only",
 				},
@@ -164,7 +230,7 @@ func RegisterTools(
 				},
 				"query_type": map[string]interface{}{
 					"type":        "string",
-					"description": "Query type. For cpu-only: single-groupby-1-1-1, single-groupby-1-1-12, single-groupby-1-8-1, single-groupby-5-1-1, single-groupby-5-1-12, single-groupby-5-8-1, cpu-max-all-1, cpu-max-all-8, double-groupby-1, double-groupby-5, double-groupby-all, high-cpu-1, high-cpu-all, lastpoint, groupby-orderby-limit. For iot: last-loc, single-last-loc, low-fuel, high-load, stationary-trucks, long-driving-sessions, long-daily-sessions, avg-vs-proj-fuel-consumption, avg-daily-driving-duration, avg-daily-driving-session, daily-activity, breakdown-frequency, avg-load",
+					"description": "Query type. For cpu-only: single-groupby-1-1-1, single-groupby-1-1-12, single-groupby-1-8-1, single-groupby-5-1-1, single-groupby-5-1-12, single-groupby-5-8-1, cpu-max-all-1, cpu-max-all-8, double-groupby-1, double-groupby-5, double-groupby-all, high-cpu-1, high-cpu-all, lastpoint, groupby-orderby-limit. For iot: last-loc, single-last-loc, low-fuel, high-load, stationary-trucks, long-driving-sessions, long-daily-sessions, avg-vs-proj-fuel-consumption, avg-daily-driving-duration, avg-daily-driving-session, daily-activity, breakdown-frequency, avg-load. A weighted mix such as 'lastpoint:50,high-cpu-1:30,double-groupby-1:20' writes the query types interleaved into one file",
 				},
 				"format": map[string]interface{}{
 					"type":        "string",
@@ -225,12 +291,22 @@ func RegisterTools(
 				},
 				"query_type": map[string]interface{}{
 					"type":        "string",
-					"description": "Query type. Must match the query_type used when generating queries. Examples: 'lastpoint', 'single-groupby-1-1-1', 'cpu-max-all-1'",
+					"description": "Query type. Must match the query_type used when generating queries, a weighted mix runs every query type of the mix. Examples: 'lastpoint', 'single-groupby-1-1-1', 'cpu-max-all-1'",
 				},
 				"server_config_name": map[string]interface{}{
 					"type":        "string",
 					"description": "Server configuration name (optional).",
 				},
+				"max_queries": map[string]interface{}{
+					"type":        "integer",
+					"description": "Limit the number of queries to run (optional). Also used to compute the progress percentage while running.",
+					"minimum":     1,
+				},
+				"expected_queries": map[string]interface{}{
+					"type":        "integer",
+					"description": "Number of queries in the file, i.e. the queries used when generating it (optional). Used to compute the progress percentage while running.",
+					"minimum":     1,
+				},
 			},
 			"required": []string{"file", "query_type"},
 		},
@@ -327,7 +403,7 @@ func RegisterTools(
 				},
 				"result": map[string]interface{}{
 					"type":        "object",
-					"description": "Task result data",
+					"description": "Task result data. While the load runs, result.live holds the current and overall metrics/rows per second, metrics_total, rows_total and estimated_rows",
 				},
 				"metrics": map[string]interface{}{
 					"type":        "object",
@@ -431,7 +507,7 @@ func RegisterTools(
 				},
 				"result": map[string]interface{}{
 					"type":        "object",
-					"description": "Task result data",
+					"description": "Task result data. While the queries run, result.live holds queries_total, the current and overall queries per second and expected_queries",
 				},
 				"metrics": map[string]interface{}{
 					"type":        "object",
@@ -442,6 +518,336 @@ func RegisterTools(
 	}, func(ctx context.Context, req *mcp.CallToolRequest, input RunQueriesStatusInput) (*mcp.CallToolResult, StatusOutput, error) {
 		return handleGetRunQueriesStatus(ctx, req, input, statusService)
 	})
+
+	// 结果对比工具
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "compare_tsbs_results",
+		Description: "Compare the performance metrics of two or more load or query tasks. The first task is the baseline, every other task gets per-metric deltas (ingest rate, query rate, per-query p50/p95/p99 latency) and metrics that got worse by more than the threshold are flagged as regressions.",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"task_ids": map[string]interface{}{
+					"type":        "array",
+					"description": "Task IDs to compare, at least two. The first one is the baseline. Get the task_id from the return result of tsbs_load_kwdb or tsbs_run_queries_kwdb tool.",
+					"items":       map[string]interface{}{"type": "string"},
+					"minItems":    2,
+				},
+				"threshold_pct": map[string]interface{}{
+					"type":        "number",
+					"description": "Regression threshold in percent. A rate dropping or a latency rising by more than this is a regression. Default: 5",
+					"default":     service.DefaultRegressionThreshold,
+					"minimum":     0.0,
+				},
+			},
+			"required": []string{"task_ids"},
+		},
+		OutputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"status": map[string]interface{}{
+					"type":        "string",
+					"description": "ok, regression or error",
+				},
+				"message": map[string]interface{}{
+					"type":        "string",
+					"description": "Summary or error message",
+				},
+				"baseline_task_id": map[string]interface{}{
+					"type":        "string",
+					"description": "Baseline task ID",
+				},
+				"threshold_pct": map[string]interface{}{
+					"type":        "number",
+					"description": "Regression threshold in percent",
+				},
+				"regressions": map[string]interface{}{
+					"type":        "integer",
+					"description": "Total number of regressed metrics",
+				},
+				"comparisons": map[string]interface{}{
+					"type":        "array",
+					"description": "Per task deltas against the baseline: metric, baseline, value, delta, delta_pct, regression",
+				},
+			},
+		},
+	}, func(ctx context.Context, req *mcp.CallToolRequest, input CompareResultsInput) (*mcp.CallToolResult, CompareResultsOutput, error) {
+		return handleCompareResults(ctx, req, input, statusService)
+	})
+
+	// 端到端流水线工具
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "tsbs_run_pipeline",
+		Description: "Run a whole benchmark in one task: generate data, load it into KWDB, generate queries and run them, one subtask per stage. Query generation and execution run once per query type. Poll get_tsbs_pipeline_status for per-stage progress and cancel with cancel_tsbs_pipeline.",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"use_case": map[string]interface{}{
+					"type":        "string",
+					"description": "Use case type. Supported options for kwdb: cpu-only, devops, devops-generic, iot. devops-generic has no query types, its pipeline only generates and loads the data. Default: 'cpu-only'",
+					"enum":        kwdbLoadUseCases,
+					"default":     "cpu-only",
+				},
+				"seed": map[string]interface{}{
+					"type":        "integer",
+					"description": "Random seed for data and queries. Default: 123",
+					"default":     123,
+				},
+				"scale": map[string]interface{}{
+					"type":        "integer",
+					"description": "Data scale (number of devices/hosts). Must be greater than 0.",
+					"minimum":     1,
+				},
+				"log_interval": map[string]interface{}{
+					"type":        "string",
+					"description": "Logging interval of the generated data. Default: '10s'",
+					"default":     "10s",
+					"pattern":     "^\\d+[smh]$",
+				},
+				"timestamp_start": map[string]interface{}{
+					"type":        "string",
+					"description": "Start timestamp in RFC3339 format, used for data and queries. Example: '2016-01-01T00:00:00Z'",
+					"format":      "date-time",
+				},
+				"timestamp_end": map[string]interface{}{
+					"type":        "string",
+					"description": "End timestamp in RFC3339 format, used for data and queries. Must be long enough for every query type.",
+					"format":      "date-time",
+				},
+				"insert_type": map[string]interface{}{
+					"type":        "string",
+					"description": "Insert type of the load stage: insert, prepare (cpu-only), prepareiot (iot) or copy. Default: 'insert'",
+					"enum":        kwdbInsertTypes,
+					"default":     "insert",
+				},
+				"batch_size": map[string]interface{}{
+					"type":        "integer",
+					"description": "Batch size of the load stage (optional).",
+				},
+				"load_workers": map[string]interface{}{
+					"type":        "integer",
+					"description": "Number of workers of the load stage (optional).",
+				},
+				"query_types": map[string]interface{}{
+					"type":        "array",
+					"description": "Query types to generate and run, e.g. ['single-groupby-1-1-1', 'cpu-max-all-1']. Must be empty for devops-generic.",
+					"items":       map[string]interface{}{"type": "string"},
+				},
+				"queries": map[string]interface{}{
+					"type":        "integer",
+					"description": "Number of queries to generate per query type. Default: 100",
+					"default":     100,
+				},
+				"query_workers": map[string]interface{}{
+					"type":        "integer",
+					"description": "Number of workers of the run queries stages. Default: 1",
+					"default":     1,
+				},
+				"prepare": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Generate and run prepared queries. Default: false",
+					"default":     false,
+				},
+			},
+			"required": []string{"scale", "timestamp_start", "timestamp_end", "query_types"},
+		},
+		OutputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"task_id": map[string]interface{}{
+					"type":        "string",
+					"description": "Pipeline task ID",
+				},
+				"status": map[string]interface{}{
+					"type":        "string",
+					"description": "running or error",
+				},
+				"message": map[string]interface{}{
+					"type":        "string",
+					"description": "Status message or error message",
+				},
+				"stages": map[string]interface{}{
+					"type":        "array",
+					"description": "Stages in execution order: subtask_id, type, query_type",
+				},
+			},
+		},
+	}, func(ctx context.Context, req *mcp.CallToolRequest, input PipelineInput) (*mcp.CallToolResult, PipelineOutput, error) {
+		return handleRunPipeline(ctx, req, input, taskService, executionService, cfg)
+	})
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "get_tsbs_pipeline_status",
+		Description: "Query the status of a pipeline task started by tsbs_run_pipeline. Returns the overall status and progress and the status, progress and result of every stage.",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"test_task_id": map[string]interface{}{
+					"type":        "string",
+					"description": "Task ID. Get the task_id from the return result of tsbs_run_pipeline tool.",
+				},
+			},
+			"required": []string{"test_task_id"},
+		},
+		OutputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"status": map[string]interface{}{
+					"type":        "string",
+					"description": "Pipeline status: running, completed, failed, cancelled or error",
+				},
+				"progress": map[string]interface{}{
+					"type":        "integer",
+					"description": "Overall progress percentage (0-100)",
+				},
+				"message": map[string]interface{}{
+					"type":        "string",
+					"description": "Status message or error message",
+				},
+				"error": map[string]interface{}{
+					"type":        "string",
+					"description": "Error message if the pipeline failed",
+				},
+				"stages": map[string]interface{}{
+					"type":        "array",
+					"description": "Stages: subtask_id, type, status (pending, running, completed, failed, skipped, cancelled), progress, result",
+				},
+			},
+		},
+	}, func(ctx context.Context, req *mcp.CallToolRequest, input StatusInput) (*mcp.CallToolResult, PipelineStatusOutput, error) {
+		return handleGetPipelineStatus(ctx, req, input, statusService)
+	})
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "cancel_tsbs_pipeline",
+		Description: "Cancel a running pipeline task. Kills the command of the current stage and skips the remaining stages.",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"test_task_id": map[string]interface{}{
+					"type":        "string",
+					"description": "Task ID. Get the task_id from the return result of tsbs_run_pipeline tool.",
+				},
+			},
+			"required": []string{"test_task_id"},
+		},
+	}, func(ctx context.Context, req *mcp.CallToolRequest, input StatusInput) (*mcp.CallToolResult, CancelOutput, error) {
+		return handleCancelTask(ctx, req, input, taskService, executionService)
+	})
+
+	// 任务管理工具
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "list_tasks",
+		Description: "List tasks, newest first. Can filter by task type and status.",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"task_type": map[string]interface{}{
+					"type":        "string",
+					"description": "Task type filter (optional).",
+					"enum":        []string{"generate_data", "load", "generate_queries", "run_queries", "pipeline"},
+				},
+				"status": map[string]interface{}{
+					"type":        "string",
+					"description": "Status filter (optional).",
+					"enum":        []string{"running", "completed", "failed", "cancelled"},
+				},
+				"limit": map[string]interface{}{
+					"type":        "integer",
+					"description": "Maximum number of tasks to return. Default: 50",
+					"default":     50,
+					"minimum":     1,
+				},
+			},
+		},
+		OutputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"status": map[string]interface{}{
+					"type":        "string",
+					"description": "ok or error",
+				},
+				"message": map[string]interface{}{
+					"type":        "string",
+					"description": "Error message",
+				},
+				"tasks": map[string]interface{}{
+					"type":        "array",
+					"description": "Tasks: task_id, task_type, status, progress, created_at, completed_at, error, output_file and running, which tells whether the process is still alive in this server",
+				},
+			},
+		},
+	}, func(ctx context.Context, req *mcp.CallToolRequest, input ListTasksInput) (*mcp.CallToolResult, ListTasksOutput, error) {
+		return handleListTasks(ctx, req, input, taskService, executionService)
+	})
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "cancel_task",
+		Description: "Cancel a running task of any type. Kills its TSBS process and marks the task cancelled. A task left running by a previous server process is only marked cancelled.",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"test_task_id": map[string]interface{}{
+					"type":        "string",
+					"description": "Task ID to cancel.",
+				},
+			},
+			"required": []string{"test_task_id"},
+		},
+	}, func(ctx context.Context, req *mcp.CallToolRequest, input StatusInput) (*mcp.CallToolResult, CancelOutput, error) {
+		return handleCancelTask(ctx, req, input, taskService, executionService)
+	})
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "delete_task",
+		Description: "Delete a task, or all tasks older than a duration, with their subtasks and results. By default also removes their data, query and results files inside the TSBS directories. Running tasks are not deleted.",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"test_task_id": map[string]interface{}{
+					"type":        "string",
+					"description": "Task ID to delete. Either test_task_id or older_than is required.",
+				},
+				"older_than": map[string]interface{}{
+					"type":        "string",
+					"description": "Delete all tasks created longer ago than this duration, e.g. '168h'.",
+					"pattern":     "^\\d+[smh]$",
+				},
+				"status": map[string]interface{}{
+					"type":        "string",
+					"description": "With older_than, only delete tasks of this status (optional).",
+					"enum":        []string{"completed", "failed", "cancelled"},
+				},
+				"delete_files": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Also remove the output and results files of the tasks that no other task still references. Default: true",
+					"default":     true,
+				},
+			},
+		},
+		OutputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"status": map[string]interface{}{
+					"type":        "string",
+					"description": "ok or error",
+				},
+				"message": map[string]interface{}{
+					"type":        "string",
+					"description": "Summary or error message",
+				},
+				"deleted": map[string]interface{}{
+					"type":        "array",
+					"description": "Deleted task IDs",
+				},
+				"removed_files": map[string]interface{}{
+					"type":        "array",
+					"description": "Removed files",
+				},
+			},
+		},
+	}, func(ctx context.Context, req *mcp.CallToolRequest, input DeleteTaskInput) (*mcp.CallToolResult, DeleteTaskOutput, error) {
+		return handleDeleteTask(ctx, req, input, taskService, executionService)
+	})
 }
 
 // 工具输入输出类型定义
@@ -456,7 +862,24 @@ type GenerateDataInput struct {
 	OrderQuantity    *int     `json:"orderquantity,omitempty"`
 	OutOfOrder       *float64 `json:"outoforder,omitempty"`
 	OutOfOrderWindow *string  `json:"outoforderwindow,omitempty"`
+	OutOfOrderDelay  *string  `json:"outoforderdelay,omitempty"`
 	OutputFile       *string  `json:"output_file,omitempty"`
+
+	Profile             *string  `json:"profile,omitempty"`
+	ProfileMeasurements *string  `json:"profile_measurements,omitempty"`
+	AnomalyRate         *float64 `json:"anomaly_rate,omitempty"`
+	AnomalyTypes        *string  `json:"anomaly_types,omitempty"`
+	AnomalyFile         *string  `json:"anomaly_file,omitempty"`
+
+	DeviceDeathRate   *float64 `json:"device_death_rate,omitempty"`
+	DeviceBirthRate   *float64 `json:"device_birth_rate,omitempty"`
+	TagChangeInterval *string  `json:"tag_change_interval,omitempty"`
+	TagChangeRatio    *float64 `json:"tag_change_ratio,omitempty"`
+
+	NullRatio       *float64 `json:"null_ratio,omitempty"`
+	NullFieldRatios *string  `json:"null_field_ratios,omitempty"`
+
+	Cardinality *uint64 `json:"cardinality,omitempty"`
 }
 
 type GenerateDataOutput struct {
@@ -506,6 +929,8 @@ type RunQueriesInput struct {
 	Prepare          *bool   `json:"prepare,omitempty"`
 	QueryType        string  `json:"query_type"`
 	ServerConfigName *string `json:"server_config_name,omitempty"`
+	MaxQueries       *int    `json:"max_queries,omitempty"`
+	ExpectedQueries  int     `json:"expected_queries,omitempty"`
 }
 
 type RunQueriesOutput struct {
@@ -533,6 +958,102 @@ type StatusOutput struct {
 	Metrics    json.RawMessage `json:"metrics,omitempty"`
 }
 
+type CompareResultsInput struct {
+	TaskIDs      []string `json:"task_ids"`
+	ThresholdPct *float64 `json:"threshold_pct,omitempty"`
+}
+
+type CompareResultsOutput struct {
+	Status         string                   `json:"status"`
+	Message        string                   `json:"message,omitempty"`
+	BaselineTaskID string                   `json:"baseline_task_id,omitempty"`
+	ThresholdPct   float64                  `json:"threshold_pct,omitempty"`
+	Regressions    int                      `json:"regressions"`
+	Comparisons    []service.TaskComparison `json:"comparisons,omitempty"`
+}
+
+type PipelineInput struct {
+	UseCase        string   `json:"use_case"`
+	Seed           int      `json:"seed"`
+	Scale          int      `json:"scale"`
+	LogInterval    string   `json:"log_interval"`
+	TimestampStart string   `json:"timestamp_start"`
+	TimestampEnd   string   `json:"timestamp_end"`
+	InsertType     string   `json:"insert_type,omitempty"`
+	BatchSize      *int     `json:"batch_size,omitempty"`
+	LoadWorkers    *int     `json:"load_workers,omitempty"`
+	QueryTypes     []string `json:"query_types"`
+	Queries        int      `json:"queries"`
+	QueryWorkers   *int     `json:"query_workers,omitempty"`
+	Prepare        *bool    `json:"prepare,omitempty"`
+}
+
+type PipelineOutput struct {
+	TaskID  string                  `json:"task_id"`
+	Status  string                  `json:"status"`
+	Message string                  `json:"message"`
+	Stages  []service.PipelineStage `json:"stages,omitempty"`
+}
+
+type PipelineStageStatus struct {
+	SubtaskID string          `json:"subtask_id"`
+	Type      string          `json:"type"`
+	Status    string          `json:"status"`
+	Progress  int             `json:"progress"`
+	Result    json.RawMessage `json:"result,omitempty"`
+}
+
+type PipelineStatusOutput struct {
+	Status   string                `json:"status"`
+	Progress int                   `json:"progress"`
+	Message  string                `json:"message,omitempty"`
+	Error    string                `json:"error,omitempty"`
+	Stages   []PipelineStageStatus `json:"stages,omitempty"`
+}
+
+type CancelOutput struct {
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+type ListTasksInput struct {
+	TaskType string `json:"task_type,omitempty"`
+	Status   string `json:"status,omitempty"`
+	Limit    int    `json:"limit,omitempty"`
+}
+
+type TaskSummary struct {
+	TaskID      string `json:"task_id"`
+	TaskType    string `json:"task_type"`
+	Status      string `json:"status"`
+	Progress    int    `json:"progress"`
+	CreatedAt   string `json:"created_at"`
+	CompletedAt string `json:"completed_at,omitempty"`
+	Error       string `json:"error,omitempty"`
+	OutputFile  string `json:"output_file,omitempty"`
+	Running     bool   `json:"running"`
+}
+
+type ListTasksOutput struct {
+	Status  string        `json:"status"`
+	Message string        `json:"message,omitempty"`
+	Tasks   []TaskSummary `json:"tasks"`
+}
+
+type DeleteTaskInput struct {
+	TestTaskID  string `json:"test_task_id,omitempty"`
+	OlderThan   string `json:"older_than,omitempty"`
+	Status      string `json:"status,omitempty"`
+	DeleteFiles *bool  `json:"delete_files,omitempty"`
+}
+
+type DeleteTaskOutput struct {
+	Status       string   `json:"status"`
+	Message      string   `json:"message"`
+	Deleted      []string `json:"deleted,omitempty"`
+	RemovedFiles []string `json:"removed_files,omitempty"`
+}
+
 // 工具处理函数
 func handleGenerateData(
 	ctx context.Context,
@@ -576,14 +1097,13 @@ func handleGenerateData(
 		}, nil
 	}
 
-	// 如果 format 是 kwdb，只支持 cpu-only 和 iot
+	// 如果 format 是 kwdb，只支持 kwdb 写入工具支持的场景
 	if input.Format == "kwdb" {
-		kwdbSupportedUseCases := []string{common.UseCaseCPUOnly, common.UseCaseIoT}
-		if !utils.IsIn(input.UseCase, kwdbSupportedUseCases) {
+		if !utils.IsIn(input.UseCase, kwdbLoadUseCases) {
 			return nil, GenerateDataOutput{
 				TaskID:  "",
 				Status:  "error",
-				Message: fmt.Sprintf("参数验证失败: invalid use_case for kwdb format: %s, kwdb only supports: %s", input.UseCase, strings.Join(kwdbSupportedUseCases, ", ")),
+				Message: fmt.Sprintf("参数验证失败: invalid use_case for kwdb format: %s, kwdb only supports: %s", input.UseCase, strings.Join(kwdbLoadUseCases, ", ")),
 			}, nil
 		}
 	}
@@ -669,7 +1189,24 @@ func handleGenerateData(
 		OrderQuantity:    input.OrderQuantity,
 		OutOfOrder:       input.OutOfOrder,
 		OutOfOrderWindow: input.OutOfOrderWindow,
+		OutOfOrderDelay:  input.OutOfOrderDelay,
 		OutputFile:       input.OutputFile,
+
+		Profile:             input.Profile,
+		ProfileMeasurements: input.ProfileMeasurements,
+		AnomalyRate:         input.AnomalyRate,
+		AnomalyTypes:        input.AnomalyTypes,
+		AnomalyFile:         input.AnomalyFile,
+
+		DeviceDeathRate:   input.DeviceDeathRate,
+		DeviceBirthRate:   input.DeviceBirthRate,
+		TagChangeInterval: input.TagChangeInterval,
+		TagChangeRatio:    input.TagChangeRatio,
+
+		NullRatio:       input.NullRatio,
+		NullFieldRatios: input.NullFieldRatios,
+
+		Cardinality: input.Cardinality,
 	}
 	// 异步执行
 	go executionService.ExecuteGenerateData(ctx, taskID, serviceInput)
@@ -702,19 +1239,28 @@ func handleLoadData(
 	if input.InsertType == "" {
 		input.InsertType = "insert"
 	} else {
-		validTypes := map[string]bool{
-			"insert":     true,
-			"prepare":    true,
-			"prepareiot": true,
-		}
-		if !validTypes[input.InsertType] {
+		if !utils.IsIn(input.InsertType, kwdbInsertTypes) {
 			return nil, LoadDataOutput{
 				TaskID:  "",
 				Status:  "error",
-				Message: fmt.Sprintf("参数验证失败: invalid insert_type: %s, must be one of: insert, prepare, prepareiot", input.InsertType),
+				Message: fmt.Sprintf("参数验证失败: invalid insert_type: %s, must be one of: %s", input.InsertType, strings.Join(kwdbInsertTypes, ", ")),
 			}, nil
 		}
 	}
+	if !utils.IsIn(input.Case, kwdbLoadUseCases) {
+		return nil, LoadDataOutput{
+			TaskID:  "",
+			Status:  "error",
+			Message: fmt.Sprintf("参数验证失败: invalid case: %s, must be one of: %s", input.Case, strings.Join(kwdbLoadUseCases, ", ")),
+		}, nil
+	}
+	if err := checkInsertType(input.Case, input.InsertType); err != nil {
+		return nil, LoadDataOutput{
+			TaskID:  "",
+			Status:  "error",
+			Message: fmt.Sprintf("参数验证失败: %v", err),
+		}, nil
+	}
 	if input.Partition == nil {
 		partition := false
 		input.Partition = &partition
@@ -774,12 +1320,12 @@ func handleGenerateQueries(
 		input.Prepare = &prepare
 	}
 
-	// 验证 use_case（如果 format 是 kwdb，只支持 cpu-only 和 iot）
+	// 验证 use_case（如果 format 是 kwdb，只支持 cpu-only、devops 和 iot）
 	if input.UseCase == "" {
 		input.UseCase = common.UseCaseCPUOnly
 	}
 	if input.Format == "kwdb" {
-		kwdbSupportedUseCases := []string{common.UseCaseCPUOnly, common.UseCaseIoT}
+		kwdbSupportedUseCases := []string{common.UseCaseCPUOnly, common.UseCaseDevops, common.UseCaseIoT}
 		if !utils.IsIn(input.UseCase, kwdbSupportedUseCases) {
 			return nil, GenerateQueriesOutput{
 				TaskID:  "",
@@ -810,37 +1356,7 @@ func handleGenerateQueries(
 	timeInterval := endTime.Sub(startTime)
 
 	// 根据查询类型确定最小时间间隔要求
-	var minInterval time.Duration
-	if input.UseCase == "cpu-only" {
-		// 根据查询类型确定最小时间间隔
-		if strings.HasPrefix(input.QueryType, "single-groupby") {
-			// single-groupby-X-X-X 中的最后一个数字是小时数
-			// 例如 single-groupby-1-1-1 需要 1 小时
-			parts := strings.Split(input.QueryType, "-")
-			if len(parts) >= 4 {
-				var hours int
-				if _, parseErr := fmt.Sscanf(parts[3], "%d", &hours); parseErr == nil && hours > 0 {
-					minInterval = time.Duration(hours) * time.Hour
-				} else {
-					minInterval = time.Hour // 默认 1 小时
-				}
-			} else {
-				minInterval = time.Hour // 默认 1 小时
-			}
-		} else if strings.HasPrefix(input.QueryType, "double-groupby") {
-			minInterval = 12 * time.Hour
-		} else if strings.HasPrefix(input.QueryType, "high-cpu") {
-			minInterval = 12 * time.Hour
-		} else if strings.HasPrefix(input.QueryType, "cpu-max-all") {
-			minInterval = 8 * time.Hour
-		} else {
-			// 其他查询类型默认需要 1 小时
-			minInterval = time.Hour
-		}
-	} else {
-		// IoT 用例也需要一定的时间间隔
-		minInterval = time.Hour
-	}
+	minInterval := minQueryInterval(input.UseCase, input.QueryType)
 
 	// 检查时间间隔是否足够大
 	if timeInterval < minInterval {
@@ -888,6 +1404,55 @@ func handleGenerateQueries(
 	}, nil
 }
 
+// minQueryInterval 返回查询类型要求的最小时间范围
+func minQueryInterval(useCase, queryType string) time.Duration {
+	// 混合负载(如 lastpoint:50,high-cpu-1:30)取各查询类型中最大的时间范围
+	if strings.ContainsAny(queryType, ",:") {
+		var maxInterval time.Duration
+		for _, entry := range strings.Split(queryType, ",") {
+			name, _, _ := strings.Cut(entry, ":")
+			if interval := minQueryInterval(useCase, strings.TrimSpace(name)); interval > maxInterval {
+				maxInterval = interval
+			}
+		}
+		return maxInterval
+	}
+
+	var minInterval time.Duration
+	if useCase == "cpu-only" || useCase == "devops" {
+		// 根据查询类型确定最小时间间隔
+		if strings.HasPrefix(queryType, "single-groupby") {
+			// single-groupby-X-X-X 中的最后一个数字是小时数
+			// 例如 single-groupby-1-1-1 需要 1 小时
+			parts := strings.Split(queryType, "-")
+			if len(parts) >= 4 {
+				var hours int
+				if _, parseErr := fmt.Sscanf(parts[3], "%d", &hours); parseErr == nil && hours > 0 {
+					minInterval = time.Duration(hours) * time.Hour
+				} else {
+					minInterval = time.Hour // 默认 1 小时
+				}
+			} else {
+				minInterval = time.Hour // 默认 1 小时
+			}
+		} else if strings.HasPrefix(queryType, "double-groupby") {
+			minInterval = 12 * time.Hour
+		} else if strings.HasPrefix(queryType, "high-cpu") {
+			minInterval = 12 * time.Hour
+		} else if strings.HasPrefix(queryType, "cpu-max-all") {
+			minInterval = 8 * time.Hour
+		} else {
+			// 其他查询类型默认需要 1 小时
+			minInterval = time.Hour
+		}
+	} else {
+		// IoT 用例也需要一定的时间间隔
+		minInterval = time.Hour
+	}
+
+	return minInterval
+}
+
 func handleRunQueries(
 	ctx context.Context,
 	_ *mcp.CallToolRequest,
@@ -932,6 +1497,8 @@ func handleRunQueries(
 		Prepare:          input.Prepare,
 		QueryType:        input.QueryType,
 		ServerConfigName: input.ServerConfigName,
+		MaxQueries:       input.MaxQueries,
+		ExpectedQueries:  input.ExpectedQueries,
 	}
 	// 异步执行
 	go executionService.ExecuteRunQueries(ctx, taskID, serviceInput)
@@ -1103,3 +1670,395 @@ func handleGetRunQueriesStatus(
 
 	return nil, output, nil
 }
+
+func handleCompareResults(
+	ctx context.Context,
+	_ *mcp.CallToolRequest,
+	input CompareResultsInput,
+	statusService *service.StatusService,
+) (*mcp.CallToolResult, CompareResultsOutput, error) {
+	threshold := service.DefaultRegressionThreshold
+	if input.ThresholdPct != nil {
+		threshold = *input.ThresholdPct
+	}
+
+	result, err := statusService.CompareResults(ctx, input.TaskIDs, threshold)
+	if err != nil {
+		return nil, CompareResultsOutput{
+			Status:  "error",
+			Message: fmt.Sprintf("Failed to compare results: %v", err),
+		}, nil
+	}
+
+	output := CompareResultsOutput{
+		Status:         "ok",
+		Message:        fmt.Sprintf("No regressions beyond %.2f%% against baseline %s", result.ThresholdPct, result.BaselineTaskID),
+		BaselineTaskID: result.BaselineTaskID,
+		ThresholdPct:   result.ThresholdPct,
+		Regressions:    result.Regressions,
+		Comparisons:    result.Comparisons,
+	}
+	if result.Regressions > 0 {
+		output.Status = "regression"
+		output.Message = fmt.Sprintf("%d metrics regressed beyond %.2f%% against baseline %s", result.Regressions, result.ThresholdPct, result.BaselineTaskID)
+	}
+
+	return nil, output, nil
+}
+
+// kwdbLoadUseCases 和 kwdbInsertTypes 是 kwdb 写入工具支持的场景和写入方式
+var (
+	kwdbLoadUseCases = []string{common.UseCaseCPUOnly, common.UseCaseDevops, common.UseCaseDevopsGeneric, common.UseCaseIoT}
+	kwdbInsertTypes  = []string{"insert", "prepare", "prepareiot", "copy"}
+)
+
+// checkInsertType 检查写入方式是否支持该场景：prepare 只支持 cpu-only，prepareiot 只支持 iot
+func checkInsertType(useCase, insertType string) error {
+	switch {
+	case insertType == "prepare" && useCase != common.UseCaseCPUOnly:
+		return fmt.Errorf("insert_type prepare only supports use_case %s, got: %s", common.UseCaseCPUOnly, useCase)
+	case insertType == "prepareiot" && useCase != common.UseCaseIoT:
+		return fmt.Errorf("insert_type prepareiot only supports use_case %s, got: %s", common.UseCaseIoT, useCase)
+	}
+	return nil
+}
+
+func handleRunPipeline(
+	ctx context.Context,
+	_ *mcp.CallToolRequest,
+	input PipelineInput,
+	taskService *service.TaskService,
+	executionService *service.ExecutionService,
+	cfg *config.Config,
+) (*mcp.CallToolResult, PipelineOutput, error) {
+	// 参数验证和默认值，与各阶段工具保持一致
+	if input.UseCase == "" {
+		input.UseCase = common.UseCaseCPUOnly
+	}
+	if input.Seed == 0 {
+		input.Seed = 123
+	}
+	if input.LogInterval == "" {
+		input.LogInterval = "10s"
+	}
+	if input.InsertType == "" {
+		input.InsertType = "insert"
+	}
+	if input.Queries <= 0 {
+		input.Queries = 100
+	}
+	if input.QueryWorkers == nil {
+		workers := 1
+		input.QueryWorkers = &workers
+	}
+	if input.Prepare == nil {
+		prepare := false
+		input.Prepare = &prepare
+	}
+
+	fail := func(format string, args ...interface{}) (*mcp.CallToolResult, PipelineOutput, error) {
+		return nil, PipelineOutput{
+			Status:  "error",
+			Message: "参数验证失败: " + fmt.Sprintf(format, args...),
+		}, nil
+	}
+	if !utils.IsIn(input.UseCase, kwdbLoadUseCases) {
+		return fail("invalid use_case for kwdb format: %s, kwdb only supports: %s", input.UseCase, strings.Join(kwdbLoadUseCases, ", "))
+	}
+	if input.Scale <= 0 {
+		return fail("scale must be greater than 0, got: %d", input.Scale)
+	}
+	if !utils.IsIn(input.InsertType, kwdbInsertTypes) {
+		return fail("invalid insert_type: %s, must be one of: %s", input.InsertType, strings.Join(kwdbInsertTypes, ", "))
+	}
+	if err := checkInsertType(input.UseCase, input.InsertType); err != nil {
+		return fail("%v", err)
+	}
+	if _, err := time.ParseDuration(input.LogInterval); err != nil {
+		return fail("invalid log_interval format: %v, expected duration format (e.g., 10s, 1m, 1h)", err)
+	}
+	startTime, err := time.Parse(time.RFC3339, input.TimestampStart)
+	if err != nil {
+		return fail("invalid timestamp_start format: %v, expected RFC3339 format (e.g., 2016-01-01T00:00:00Z)", err)
+	}
+	endTime, err := time.Parse(time.RFC3339, input.TimestampEnd)
+	if err != nil {
+		return fail("invalid timestamp_end format: %v, expected RFC3339 format (e.g., 2016-01-01T00:00:00Z)", err)
+	}
+	if !endTime.After(startTime) {
+		return fail("timestamp_end must be after timestamp_start")
+	}
+	// devops-generic 没有查询类型，流水线只生成和写入数据
+	if input.UseCase == common.UseCaseDevopsGeneric {
+		if len(input.QueryTypes) > 0 {
+			return fail("devops-generic has no query types, query_types must be empty")
+		}
+	} else if len(input.QueryTypes) == 0 {
+		return fail("query_types must not be empty")
+	}
+	for _, queryType := range input.QueryTypes {
+		if minInterval := minQueryInterval(input.UseCase, queryType); endTime.Sub(startTime) < minInterval {
+			return fail("time interval too small: got %v, need at least %v for query type %s", endTime.Sub(startTime), minInterval, queryType)
+		}
+	}
+
+	taskID, err := taskService.CreateTask(ctx, "pipeline", input)
+	if err != nil {
+		return nil, PipelineOutput{
+			Status:  "error",
+			Message: fmt.Sprintf("任务创建失败: %v", err),
+		}, nil
+	}
+
+	testDBName := cfg.TSBS.TestDBName
+	if testDBName == "" {
+		testDBName = "tsbs" // 默认值
+	}
+	partition := false
+	serviceInput := service.PipelineInput{
+		GenerateData: service.GenerateDataInput{
+			UseCase:        input.UseCase,
+			Seed:           input.Seed,
+			Scale:          input.Scale,
+			LogInterval:    input.LogInterval,
+			TimestampStart: input.TimestampStart,
+			TimestampEnd:   input.TimestampEnd,
+			Format:         "kwdb",
+		},
+		LoadData: service.LoadDataInput{
+			User:       cfg.Database.User,
+			Password:   cfg.Database.Password,
+			Host:       cfg.Database.Host,
+			Port:       cfg.Database.Port,
+			InsertType: input.InsertType,
+			DBName:     testDBName,
+			Case:       input.UseCase,
+			BatchSize:  input.BatchSize,
+			Workers:    input.LoadWorkers,
+			Partition:  &partition,
+		},
+		GenerateQueries: service.GenerateQueriesInput{
+			UseCase:        input.UseCase,
+			Seed:           input.Seed,
+			Scale:          input.Scale,
+			Format:         "kwdb",
+			Queries:        input.Queries,
+			DBName:         testDBName,
+			TimestampStart: input.TimestampStart,
+			TimestampEnd:   input.TimestampEnd,
+			Prepare:        input.Prepare,
+		},
+		RunQueries: service.RunQueriesInput{
+			User:     cfg.Database.User,
+			Password: cfg.Database.Password,
+			Host:     cfg.Database.Host,
+			Port:     cfg.Database.Port,
+			Workers:  input.QueryWorkers,
+			Prepare:  input.Prepare,
+		},
+		QueryTypes: input.QueryTypes,
+	}
+	stages, err := executionService.StartPipeline(ctx, taskID, serviceInput)
+	if err != nil {
+		taskService.FailTask(ctx, taskID, err.Error())
+		return nil, PipelineOutput{
+			TaskID:  taskID,
+			Status:  "error",
+			Message: fmt.Sprintf("流水线启动失败: %v", err),
+		}, nil
+	}
+
+	return nil, PipelineOutput{
+		TaskID:  taskID,
+		Status:  "running",
+		Message: "流水线任务已启动",
+		Stages:  stages,
+	}, nil
+}
+
+func handleGetPipelineStatus(
+	ctx context.Context,
+	_ *mcp.CallToolRequest,
+	input StatusInput,
+	statusService *service.StatusService,
+) (*mcp.CallToolResult, PipelineStatusOutput, error) {
+	status, err := statusService.GetTaskStatus(ctx, input.TestTaskID)
+	if err != nil {
+		return nil, PipelineStatusOutput{
+			Status:  "error",
+			Message: fmt.Sprintf("Failed to query task status: %v", err),
+		}, nil
+	}
+	subtasks, err := statusService.ListSubtasks(ctx, input.TestTaskID)
+	if err != nil {
+		return nil, PipelineStatusOutput{
+			Status:  "error",
+			Message: fmt.Sprintf("Failed to query pipeline stages: %v", err),
+		}, nil
+	}
+
+	output := PipelineStatusOutput{
+		Status:   status.Status,
+		Progress: status.Progress,
+		Message:  status.Message,
+		Error:    status.Error,
+	}
+	for _, subtask := range subtasks {
+		output.Stages = append(output.Stages, PipelineStageStatus{
+			SubtaskID: subtask.SubtaskID,
+			Type:      subtask.SubtaskType,
+			Status:    subtask.Status,
+			Progress:  subtask.Progress,
+			Result:    json.RawMessage(subtask.Result),
+		})
+	}
+
+	return nil, output, nil
+}
+
+func handleCancelTask(
+	ctx context.Context,
+	_ *mcp.CallToolRequest,
+	input StatusInput,
+	taskService *service.TaskService,
+	executionService *service.ExecutionService,
+) (*mcp.CallToolResult, CancelOutput, error) {
+	if executionService.Cancel(input.TestTaskID) {
+		return nil, CancelOutput{
+			Status:  "cancelled",
+			Message: "任务已取消",
+		}, nil
+	}
+
+	task, err := taskService.GetTask(ctx, input.TestTaskID)
+	if err != nil {
+		return nil, CancelOutput{
+			Status:  "error",
+			Message: fmt.Sprintf("Failed to query task: %v", err),
+		}, nil
+	}
+	if task.Status != "running" {
+		return nil, CancelOutput{
+			Status:  "error",
+			Message: fmt.Sprintf("Task is not running, status: %s", task.Status),
+		}, nil
+	}
+	// 服务重启前启动的任务已没有进程，只更新状态
+	if err := taskService.CancelTask(ctx, input.TestTaskID); err != nil {
+		return nil, CancelOutput{
+			Status:  "error",
+			Message: fmt.Sprintf("Failed to cancel task: %v", err),
+		}, nil
+	}
+	return nil, CancelOutput{
+		Status:  "cancelled",
+		Message: "任务没有在本服务中执行，已标记为取消",
+	}, nil
+}
+
+func handleListTasks(
+	ctx context.Context,
+	_ *mcp.CallToolRequest,
+	input ListTasksInput,
+	taskService *service.TaskService,
+	executionService *service.ExecutionService,
+) (*mcp.CallToolResult, ListTasksOutput, error) {
+	if input.Limit <= 0 {
+		input.Limit = 50
+	}
+
+	tasks, err := taskService.ListTasks(ctx, service.TaskFilter{
+		TaskType: input.TaskType,
+		Status:   input.Status,
+		Limit:    input.Limit,
+	})
+	if err != nil {
+		return nil, ListTasksOutput{
+			Status:  "error",
+			Message: fmt.Sprintf("Failed to list tasks: %v", err),
+		}, nil
+	}
+
+	output := ListTasksOutput{Status: "ok", Tasks: []TaskSummary{}}
+	for _, task := range tasks {
+		summary := TaskSummary{
+			TaskID:    task.TaskID,
+			TaskType:  task.TaskType,
+			Status:    task.Status,
+			Progress:  task.Progress,
+			CreatedAt: task.CreatedAt.Format(time.RFC3339),
+			Running:   executionService.Running(task.TaskID),
+		}
+		if task.CompletedAt.Valid {
+			summary.CompletedAt = task.CompletedAt.Time.Format(time.RFC3339)
+		}
+		if task.ErrorMessage.Valid {
+			summary.Error = task.ErrorMessage.String
+		}
+		if task.OutputFile.Valid {
+			summary.OutputFile = task.OutputFile.String
+		}
+		output.Tasks = append(output.Tasks, summary)
+	}
+
+	return nil, output, nil
+}
+
+func handleDeleteTask(
+	ctx context.Context,
+	_ *mcp.CallToolRequest,
+	input DeleteTaskInput,
+	taskService *service.TaskService,
+	executionService *service.ExecutionService,
+) (*mcp.CallToolResult, DeleteTaskOutput, error) {
+	deleteFiles := input.DeleteFiles == nil || *input.DeleteFiles
+
+	var taskIDs []string
+	switch {
+	case input.TestTaskID != "":
+		taskIDs = []string{input.TestTaskID}
+	case input.OlderThan != "":
+		olderThan, err := time.ParseDuration(input.OlderThan)
+		if err != nil {
+			return nil, DeleteTaskOutput{
+				Status:  "error",
+				Message: fmt.Sprintf("参数验证失败: invalid older_than format: %v, expected duration format (e.g., 24h, 168h)", err),
+			}, nil
+		}
+		tasks, err := taskService.ListTasks(ctx, service.TaskFilter{
+			Status:        input.Status,
+			CreatedBefore: time.Now().Add(-olderThan),
+		})
+		if err != nil {
+			return nil, DeleteTaskOutput{
+				Status:  "error",
+				Message: fmt.Sprintf("Failed to list tasks: %v", err),
+			}, nil
+		}
+		for _, task := range tasks {
+			if !executionService.Running(task.TaskID) {
+				taskIDs = append(taskIDs, task.TaskID)
+			}
+		}
+	default:
+		return nil, DeleteTaskOutput{
+			Status:  "error",
+			Message: "参数验证失败: test_task_id or older_than is required",
+		}, nil
+	}
+
+	output := DeleteTaskOutput{Status: "ok"}
+	for _, taskID := range taskIDs {
+		removed, err := executionService.DeleteTask(ctx, taskID, deleteFiles)
+		if err != nil {
+			output.Status = "error"
+			output.Message = fmt.Sprintf("Failed to delete task %s: %v", taskID, err)
+			return nil, output, nil
+		}
+		output.Deleted = append(output.Deleted, taskID)
+		output.RemovedFiles = append(output.RemovedFiles, removed...)
+	}
+	output.Message = fmt.Sprintf("已删除 %d 个任务和 %d 个文件", len(output.Deleted), len(output.RemovedFiles))
+
+	return nil, output, nil
+}
@@ -0,0 +1,217 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// DefaultRegressionThreshold 是默认的回归阈值（百分比）
+const DefaultRegressionThreshold = 5.0
+
+// MetricDelta 是一个指标相对于基准任务的变化
+type MetricDelta struct {
+	Metric     string  `json:"metric"`
+	Baseline   float64 `json:"baseline"`
+	Value      float64 `json:"value"`
+	Delta      float64 `json:"delta"`
+	DeltaPct   float64 `json:"delta_pct"`
+	Regression bool    `json:"regression"`
+}
+
+// TaskComparison 是一个任务与基准任务的对比结果
+type TaskComparison struct {
+	TaskID      string        `json:"task_id"`
+	Deltas      []MetricDelta `json:"deltas"`
+	Regressions int           `json:"regressions"`
+}
+
+// CompareOutput 是多个任务性能指标的对比结果，第一个任务为基准
+type CompareOutput struct {
+	BaselineTaskID string           `json:"baseline_task_id"`
+	ThresholdPct   float64          `json:"threshold_pct"`
+	Comparisons    []TaskComparison `json:"comparisons"`
+	Regressions    int              `json:"regressions"`
+}
+
+// comparedMetric 描述一个参与对比的指标，higherIsBetter 决定回归的方向
+type comparedMetric struct {
+	name           string
+	value          float64
+	higherIsBetter bool
+}
+
+// CompareResults 对比多个任务保存在 tsbs_test_results 中的性能指标，第一个任务为基准，
+// 变化超过 thresholdPct 的指标被标记为回归
+func (s *StatusService) CompareResults(ctx context.Context, taskIDs []string, thresholdPct float64) (CompareOutput, error) {
+	if len(taskIDs) < 2 {
+		return CompareOutput{}, fmt.Errorf("at least two task ids are required, got %d", len(taskIDs))
+	}
+	if thresholdPct <= 0 {
+		thresholdPct = DefaultRegressionThreshold
+	}
+
+	results := make([]map[string]map[string]interface{}, len(taskIDs))
+	for i, taskID := range taskIDs {
+		r, err := s.getResults(ctx, taskID)
+		if err != nil {
+			return CompareOutput{}, err
+		}
+		if len(r) == 0 {
+			return CompareOutput{}, fmt.Errorf("no results found for task: %s", taskID)
+		}
+		results[i] = r
+	}
+
+	output := CompareOutput{BaselineTaskID: taskIDs[0], ThresholdPct: thresholdPct}
+	baseline := collectComparedMetrics(results[0])
+	for i := 1; i < len(taskIDs); i++ {
+		comparison := TaskComparison{
+			TaskID: taskIDs[i],
+			Deltas: compareMetrics(baseline, collectComparedMetrics(results[i]), thresholdPct),
+		}
+		for _, d := range comparison.Deltas {
+			if d.Regression {
+				comparison.Regressions++
+			}
+		}
+		output.Regressions += comparison.Regressions
+		output.Comparisons = append(output.Comparisons, comparison)
+	}
+	return output, nil
+}
+
+// getResults 返回任务每种 result_type 最近一次保存的性能指标
+func (s *StatusService) getResults(ctx context.Context, taskID string) (map[string]map[string]interface{}, error) {
+	query := `
+		SELECT result_type, metrics
+		FROM tsbs_test_results
+		WHERE task_id = $1
+		ORDER BY created_at, id
+	`
+
+	rows, err := s.db.DB.QueryContext(ctx, query, taskID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get results: %w", err)
+	}
+	defer rows.Close()
+
+	results := make(map[string]map[string]interface{})
+	for rows.Next() {
+		var resultType string
+		var metricsJSON []byte
+		if err := rows.Scan(&resultType, &metricsJSON); err != nil {
+			return nil, fmt.Errorf("failed to scan result: %w", err)
+		}
+		var metrics map[string]interface{}
+		if err := json.Unmarshal(metricsJSON, &metrics); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal metrics of task %s: %w", taskID, err)
+		}
+		// 按时间排序，后保存的结果覆盖先保存的。流水线的每种查询类型各保存一条
+		// query 结果，合并它们的 query_types 和查询速率
+		if prev, ok := results[resultType]; ok {
+			mergeQueryResults(metrics, prev)
+		}
+		results[resultType] = metrics
+	}
+	return results, rows.Err()
+}
+
+// allQueriesLabel 是 tsbs_run_queries_kwdb 汇总所有查询的统计信息的标签
+const allQueriesLabel = "all queries"
+
+// mergeQueryResults 将 prev 中 metrics 没有的查询类型加入 metrics，并将查询速率
+// 改为两者的查询总数除以总耗时。每条结果的 all queries 只汇总了其自身的查询，
+// 合并后不再保留
+func mergeQueryResults(metrics, prev map[string]interface{}) {
+	prevQueries, ok1 := prev["queries_total"].(float64)
+	prevDuration, ok2 := prev["duration_sec"].(float64)
+	queries, ok3 := metrics["queries_total"].(float64)
+	duration, ok4 := metrics["duration_sec"].(float64)
+	if ok1 && ok2 && ok3 && ok4 && prevDuration+duration > 0 {
+		metrics["queries_total"] = prevQueries + queries
+		metrics["duration_sec"] = prevDuration + duration
+		metrics["queries_per_sec"] = (prevQueries + queries) / (prevDuration + duration)
+	} else {
+		// 无法汇总时不比较只属于最后一条结果的查询速率
+		delete(metrics, "queries_per_sec")
+	}
+
+	prevTypes, ok := prev["query_types"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	queryTypes, ok := metrics["query_types"].(map[string]interface{})
+	if !ok {
+		queryTypes = make(map[string]interface{})
+		metrics["query_types"] = queryTypes
+	}
+	for label, stat := range prevTypes {
+		if _, ok := queryTypes[label]; !ok {
+			queryTypes[label] = stat
+		}
+	}
+	delete(queryTypes, allQueriesLabel)
+}
+
+// collectComparedMetrics 从各 result_type 的指标中取出参与对比的指标：写入速率、
+// 查询速率以及每种查询类型的 p50/p95/p99 延迟
+func collectComparedMetrics(results map[string]map[string]interface{}) map[string]comparedMetric {
+	compared := make(map[string]comparedMetric)
+	add := func(name string, v interface{}, higherIsBetter bool) {
+		if f, ok := v.(float64); ok {
+			compared[name] = comparedMetric{name: name, value: f, higherIsBetter: higherIsBetter}
+		}
+	}
+
+	if load, ok := results["load"]; ok {
+		add("load.metrics_per_sec", load["metrics_per_sec"], true)
+		add("load.rows_per_sec", load["rows_per_sec"], true)
+	}
+	if query, ok := results["query"]; ok {
+		add("query.queries_per_sec", query["queries_per_sec"], true)
+		queryTypes, _ := query["query_types"].(map[string]interface{})
+		for label, v := range queryTypes {
+			stat, _ := v.(map[string]interface{})
+			percentiles, _ := stat["percentiles_ms"].(map[string]interface{})
+			if percentiles == nil {
+				// 没有结果文件时只能用中位数比较
+				add("query."+label+".p50", stat["median_ms"], false)
+				continue
+			}
+			for _, q := range []string{"50", "95", "99"} {
+				add("query."+label+".p"+q, percentiles["q"+q], false)
+			}
+		}
+	}
+	return compared
+}
+
+// compareMetrics 计算两个任务共有指标的变化，按指标名排序
+func compareMetrics(baseline, candidate map[string]comparedMetric, thresholdPct float64) []MetricDelta {
+	var deltas []MetricDelta
+	for name, b := range baseline {
+		c, ok := candidate[name]
+		if !ok {
+			continue
+		}
+		d := MetricDelta{
+			Metric:   name,
+			Baseline: b.value,
+			Value:    c.value,
+			Delta:    c.value - b.value,
+		}
+		if b.value != 0 {
+			d.DeltaPct = d.Delta / b.value * 100
+		}
+		if b.higherIsBetter {
+			d.Regression = d.DeltaPct < -thresholdPct
+		} else {
+			d.Regression = d.DeltaPct > thresholdPct
+		}
+		deltas = append(deltas, d)
+	}
+	sort.Slice(deltas, func(i, j int) bool { return deltas[i].Metric < deltas[j].Metric })
+	return deltas
+}
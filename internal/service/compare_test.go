@@ -0,0 +1,108 @@
+package service
+
+import (
+	"testing"
+)
+
+func TestCompareMetrics(t *testing.T) {
+	baseline := collectComparedMetrics(map[string]map[string]interface{}{
+		"load": {"metrics_per_sec": 1000.0, "rows_per_sec": 100.0},
+		"query": {
+			"queries_per_sec": 50.0,
+			"query_types": map[string]interface{}{
+				"all queries": map[string]interface{}{
+					"percentiles_ms": map[string]interface{}{"q50": 10.0, "q95": 20.0, "q99": 30.0},
+				},
+			},
+		},
+	})
+	candidate := collectComparedMetrics(map[string]map[string]interface{}{
+		"load": {"metrics_per_sec": 900.0, "rows_per_sec": 98.0},
+		"query": {
+			"queries_per_sec": 60.0,
+			"query_types": map[string]interface{}{
+				"all queries": map[string]interface{}{
+					"percentiles_ms": map[string]interface{}{"q50": 10.0, "q95": 25.0, "q99": 29.0},
+				},
+			},
+		},
+	})
+
+	deltas := compareMetrics(baseline, candidate, 5)
+	want := map[string]bool{
+		"load.metrics_per_sec":  true,
+		"load.rows_per_sec":     false,
+		"query.queries_per_sec": false,
+		"query.all queries.p50": false,
+		"query.all queries.p95": true,
+		"query.all queries.p99": false,
+	}
+	if len(deltas) != len(want) {
+		t.Fatalf("incorrect number of deltas: got %d want %d", len(deltas), len(want))
+	}
+	for _, d := range deltas {
+		regression, ok := want[d.Metric]
+		if !ok {
+			t.Errorf("unexpected metric %s", d.Metric)
+			continue
+		}
+		if d.Regression != regression {
+			t.Errorf("%s: incorrect regression: got %v want %v (delta %.2f%%)", d.Metric, d.Regression, regression, d.DeltaPct)
+		}
+	}
+	if deltas[0].Metric != "load.metrics_per_sec" || deltas[0].DeltaPct != -10 {
+		t.Errorf("unexpected first delta: %+v", deltas[0])
+	}
+}
+
+func TestComparePipelineQueryResults(t *testing.T) {
+	// 流水线每种查询类型各保存一条 query 结果，按保存顺序合并
+	pipeline := func(rates ...float64) map[string]map[string]interface{} {
+		var merged map[string]interface{}
+		for i, rate := range rates {
+			label := []string{"single-groupby-1-1-1", "cpu-max-all-1"}[i]
+			metrics := map[string]interface{}{
+				"queries_total":   100.0,
+				"duration_sec":    100.0 / rate,
+				"queries_per_sec": rate,
+				"query_types": map[string]interface{}{
+					label:         map[string]interface{}{"median_ms": 1000 / rate},
+					"all queries": map[string]interface{}{"median_ms": 1000 / rate},
+				},
+			}
+			if merged != nil {
+				mergeQueryResults(metrics, merged)
+			}
+			merged = metrics
+		}
+		return map[string]map[string]interface{}{"query": merged}
+	}
+
+	// 第二种查询类型的速率不变，第一种下降一半
+	baseline := collectComparedMetrics(pipeline(100, 10))
+	candidate := collectComparedMetrics(pipeline(50, 10))
+	if got := baseline["query.queries_per_sec"].value; got != 200.0/11 {
+		t.Errorf("incorrect pipeline queries per sec: got %v want %v", got, 200.0/11)
+	}
+	deltas := compareMetrics(baseline, candidate, 5)
+	want := map[string]bool{
+		"query.queries_per_sec":          true,
+		"query.single-groupby-1-1-1.p50": true,
+		"query.cpu-max-all-1.p50":        false,
+	}
+	if len(deltas) != len(want) {
+		t.Fatalf("incorrect number of deltas: got %+v", deltas)
+	}
+	for _, d := range deltas {
+		if regression, ok := want[d.Metric]; !ok || d.Regression != regression {
+			t.Errorf("%s: unexpected delta %+v", d.Metric, d)
+		}
+	}
+
+	// 缺少查询总数或耗时的结果无法汇总查询速率
+	metrics := map[string]interface{}{"queries_per_sec": 10.0}
+	mergeQueryResults(metrics, map[string]interface{}{"queries_per_sec": 100.0})
+	if _, ok := metrics["queries_per_sec"]; ok {
+		t.Errorf("unexpected queries per sec of the last result: %v", metrics["queries_per_sec"])
+	}
+}
@@ -3,11 +3,15 @@ package service
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/timescale/tsbs/internal/config"
@@ -19,27 +23,127 @@ type ExecutionService struct {
 	db     *db.Connection
 	config *config.Config
 	exec   *executor.Executor
+
+	// running 保存正在执行的任务的取消函数
+	mu      sync.Mutex
+	running map[string]context.CancelFunc
 }
 
 func NewExecutionService(conn *db.Connection, cfg *config.Config) *ExecutionService {
 	return &ExecutionService{
-		db:     conn,
-		config: cfg,
-		exec:   executor.NewExecutor(cfg.TSBS.BinPath),
+		db:      conn,
+		config:  cfg,
+		exec:    executor.NewExecutor(cfg.TSBS.BinPath),
+		running: make(map[string]context.CancelFunc),
+	}
+}
+
+// track 登记正在执行的任务并返回其 context，Cancel 取消该 context 时命令被终止。
+// 任务结束后调用返回的函数注销任务，任务被取消时同时将其标记为 cancelled
+func (s *ExecutionService) track(taskID string) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.mu.Lock()
+	s.running[taskID] = cancel
+	s.mu.Unlock()
+
+	return ctx, func() {
+		s.mu.Lock()
+		delete(s.running, taskID)
+		s.mu.Unlock()
+		if ctx.Err() != nil {
+			NewTaskService(s.db).CancelTask(context.Background(), taskID)
+		}
+		cancel()
+	}
+}
+
+// Running 返回任务是否正在本服务中执行
+func (s *ExecutionService) Running(taskID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.running[taskID]
+	return ok
+}
+
+// Cancel 取消正在执行的任务，任务不在执行时返回 false
+func (s *ExecutionService) Cancel(taskID string) bool {
+	s.mu.Lock()
+	cancel, ok := s.running[taskID]
+	s.mu.Unlock()
+	if ok {
+		cancel()
 	}
+	return ok
+}
+
+// DeleteTask 删除未在执行的任务。deleteFiles 为 true 时同时删除任务的输出文件和结果文件，
+// 只删除 TSBS 工作目录下且不再被其余任务引用的文件，返回删除的文件
+func (s *ExecutionService) DeleteTask(ctx context.Context, taskID string, deleteFiles bool) ([]string, error) {
+	if s.Running(taskID) {
+		return nil, fmt.Errorf("task is running, cancel it first: %s", taskID)
+	}
+	files, err := NewTaskService(s.db).DeleteTask(ctx, taskID)
+	if err != nil || !deleteFiles {
+		return nil, err
+	}
+
+	var removed []string
+	for _, file := range files {
+		if !s.inWorkDirs(file) {
+			fmt.Printf("[DeleteTask] WARN: keeping %s of task %s, it is outside the TSBS directories\n", file, taskID)
+			continue
+		}
+		if err := os.Remove(file); err != nil {
+			if !os.IsNotExist(err) {
+				fmt.Printf("[DeleteTask] WARN: failed to remove %s of task %s: %v\n", file, taskID, err)
+			}
+			continue
+		}
+		removed = append(removed, file)
+	}
+	return removed, nil
+}
+
+// inWorkDirs 返回文件是否位于 TSBS 的工作、数据、查询或报告目录下
+func (s *ExecutionService) inWorkDirs(file string) bool {
+	file, err := filepath.Abs(file)
+	if err != nil {
+		return false
+	}
+	for _, dir := range []string{s.config.TSBS.WorkDir, s.config.TSBS.DataDir, s.config.TSBS.QueryDir, s.config.TSBS.ReportsDir} {
+		if dir == "" {
+			continue
+		}
+		dir, err := filepath.Abs(dir)
+		if err != nil {
+			continue
+		}
+		if rel, err := filepath.Rel(dir, file); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
 }
 
 func (s *ExecutionService) ExecuteGenerateData(ctx context.Context, taskID string, input GenerateDataInput) {
+	runCtx, done := s.track(taskID)
+	defer done()
+	s.runGenerateData(runCtx, s.newTaskRecorder(taskID), input)
+}
+
+// runGenerateData 执行命令并通过 rec 记录状态，ctx 取消时终止命令
+func (s *ExecutionService) runGenerateData(ctx context.Context, rec taskRecorder, input GenerateDataInput) {
+	taskID := rec.ID()
 	defer func() {
 		if r := recover(); r != nil {
 			fmt.Printf("PANIC in ExecuteGenerateData for task %s: %v\n", taskID, r)
+			rec.Fail(context.Background(), fmt.Sprintf("panic: %v", r))
 		}
 	}()
 
-	cmdCtx, cmdCancel := context.WithCancel(context.Background())
+	cmdCtx, cmdCancel := context.WithCancel(ctx)
 	defer cmdCancel()
 	bgCtx := context.Background()
-	taskService := NewTaskService(s.db)
 
 	format := input.Format
 	if format == "" {
@@ -73,7 +177,7 @@ func (s *ExecutionService) ExecuteGenerateData(ctx context.Context, taskID strin
 		args = append(args, fmt.Sprintf("--orderquantity=%d", *input.OrderQuantity))
 	}
 
-	if input.UseCase == "cpu-only" {
+	if input.UseCase == "cpu-only" || input.UseCase == "iot" {
 		if input.OutOfOrder != nil {
 			args = append(args, fmt.Sprintf("--outoforder=%f", *input.OutOfOrder))
 		}
@@ -81,6 +185,52 @@ func (s *ExecutionService) ExecuteGenerateData(ctx context.Context, taskID strin
 			args = append(args, "--outoforderwindow="+*input.OutOfOrderWindow)
 		}
 	}
+	// 迟到数据的延迟分布只对 iot 生效
+	if input.UseCase == "iot" && input.OutOfOrderDelay != nil {
+		args = append(args, "--outoforderdelay="+*input.OutOfOrderDelay)
+	}
+
+	// 数值特征、异常注入、设备变化和高基数标签只对 devops 类场景生效
+	if input.UseCase != "iot" {
+		if input.Profile != nil {
+			args = append(args, "--profile="+*input.Profile)
+		}
+		if input.ProfileMeasurements != nil {
+			args = append(args, "--profile-measurements="+*input.ProfileMeasurements)
+		}
+		if input.AnomalyRate != nil {
+			args = append(args, fmt.Sprintf("--anomaly-rate=%g", *input.AnomalyRate))
+		}
+		if input.AnomalyTypes != nil {
+			args = append(args, "--anomaly-types="+*input.AnomalyTypes)
+		}
+		if input.AnomalyFile != nil {
+			args = append(args, "--anomaly-file="+*input.AnomalyFile)
+		}
+		if input.DeviceDeathRate != nil {
+			args = append(args, fmt.Sprintf("--device-death-rate=%g", *input.DeviceDeathRate))
+		}
+		if input.DeviceBirthRate != nil {
+			args = append(args, fmt.Sprintf("--device-birth-rate=%g", *input.DeviceBirthRate))
+		}
+		if input.TagChangeInterval != nil {
+			args = append(args, "--tag-change-interval="+*input.TagChangeInterval)
+		}
+		if input.TagChangeRatio != nil {
+			args = append(args, fmt.Sprintf("--tag-change-ratio=%g", *input.TagChangeRatio))
+		}
+		if input.Cardinality != nil {
+			args = append(args, fmt.Sprintf("--cardinality=%d", *input.Cardinality))
+		}
+	}
+
+	// 稀疏字段对所有场景生效
+	if input.NullRatio != nil {
+		args = append(args, fmt.Sprintf("--null-ratio=%g", *input.NullRatio))
+	}
+	if input.NullFieldRatios != nil {
+		args = append(args, "--null-field-ratios="+*input.NullFieldRatios)
+	}
 
 	// 执行命令
 	binPath := filepath.Join(s.config.TSBS.BinPath, "tsbs_generate_data")
@@ -92,7 +242,7 @@ func (s *ExecutionService) ExecuteGenerateData(ctx context.Context, taskID strin
 		}
 	}
 	if _, err := os.Stat(binPath); os.IsNotExist(err) {
-		taskService.FailTask(bgCtx, taskID, fmt.Sprintf("Binary file not found: %s", binPath))
+		rec.Fail(bgCtx, fmt.Sprintf("Binary file not found: %s", binPath))
 		return
 	}
 
@@ -100,7 +250,7 @@ func (s *ExecutionService) ExecuteGenerateData(ctx context.Context, taskID strin
 
 	outFile, err := os.Create(*outputFile)
 	if err != nil {
-		taskService.FailTask(bgCtx, taskID, fmt.Sprintf("Failed to create output file: %v", err))
+		rec.Fail(bgCtx, fmt.Sprintf("Failed to create output file: %v", err))
 		return
 	}
 	defer outFile.Close()
@@ -111,11 +261,11 @@ func (s *ExecutionService) ExecuteGenerateData(ctx context.Context, taskID strin
 	cmd.Stderr = &stderrBuf
 
 	if err := cmd.Start(); err != nil {
-		taskService.FailTask(bgCtx, taskID, fmt.Sprintf("Failed to start command: %v", err))
+		rec.Fail(bgCtx, fmt.Sprintf("Failed to start command: %v", err))
 		return
 	}
 
-	taskService.UpdateTaskProgress(bgCtx, taskID, 5)
+	rec.UpdateProgress(bgCtx, 5)
 
 	// 等待完成
 	done := make(chan error, 1)
@@ -136,7 +286,7 @@ func (s *ExecutionService) ExecuteGenerateData(ctx context.Context, taskID strin
 		select {
 		case <-cmdCtx.Done():
 			cmd.Process.Kill()
-			taskService.FailTask(bgCtx, taskID, "Task cancelled")
+			rec.Fail(bgCtx, "Task cancelled")
 			return
 		case err := <-done:
 			// 检查命令是否失败或输出文件是否包含错误信息
@@ -184,7 +334,7 @@ func (s *ExecutionService) ExecuteGenerateData(ctx context.Context, taskID strin
 
 			if hasError {
 				fmt.Printf("[ExecuteGenerateData] ERROR: %s\n", errorMsg)
-				if failErr := taskService.FailTask(bgCtx, taskID, errorMsg); failErr != nil {
+				if failErr := rec.Fail(bgCtx, errorMsg); failErr != nil {
 					fmt.Printf("[ExecuteGenerateData] ERROR: Failed to update task status: %v\n", failErr)
 				} else {
 					fmt.Printf("[ExecuteGenerateData] Task %s marked as failed\n", taskID)
@@ -196,12 +346,12 @@ func (s *ExecutionService) ExecuteGenerateData(ctx context.Context, taskID strin
 			if stat, statErr := os.Stat(*outputFile); statErr != nil {
 				errorMsg := fmt.Sprintf("Output file not found after command completion: %v", statErr)
 				fmt.Printf("[ExecuteGenerateData] ERROR: %s\n", errorMsg)
-				taskService.FailTask(bgCtx, taskID, errorMsg)
+				rec.Fail(bgCtx, errorMsg)
 				return
 			} else if stat.Size() == 0 {
 				errorMsg := "Output file is empty after command completion"
 				fmt.Printf("[ExecuteGenerateData] ERROR: %s\n", errorMsg)
-				taskService.FailTask(bgCtx, taskID, errorMsg)
+				rec.Fail(bgCtx, errorMsg)
 				return
 			}
 
@@ -209,7 +359,7 @@ func (s *ExecutionService) ExecuteGenerateData(ctx context.Context, taskID strin
 				"output_file":  *outputFile,
 				"completed_at": time.Now().Format(time.RFC3339),
 			}
-			taskService.CompleteTask(bgCtx, taskID, result, *outputFile)
+			rec.Complete(bgCtx, result, *outputFile)
 			return
 		case <-ticker.C:
 			if stat, err := os.Stat(*outputFile); err == nil {
@@ -218,36 +368,44 @@ func (s *ExecutionService) ExecuteGenerateData(ctx context.Context, taskID strin
 					firstCheck = false
 					lastFileSize = currentSize
 					if currentSize > 0 {
-						taskService.UpdateTaskProgress(bgCtx, taskID, 30)
+						rec.UpdateProgress(bgCtx, 30)
 					} else {
-						taskService.UpdateTaskProgress(bgCtx, taskID, 10)
+						rec.UpdateProgress(bgCtx, 10)
 					}
 				} else if currentSize > lastFileSize {
 					fileGrowing = true
 					lastFileSize = currentSize
-					taskService.UpdateTaskProgress(bgCtx, taskID, 50)
+					rec.UpdateProgress(bgCtx, 50)
 				} else if fileGrowing && currentSize == lastFileSize && currentSize > 0 {
-					taskService.UpdateTaskProgress(bgCtx, taskID, 90)
+					rec.UpdateProgress(bgCtx, 90)
 				}
 			} else if firstCheck {
 				firstCheck = false
-				taskService.UpdateTaskProgress(bgCtx, taskID, 5)
+				rec.UpdateProgress(bgCtx, 5)
 			}
 		}
 	}
 }
 
 func (s *ExecutionService) ExecuteLoadData(ctx context.Context, taskID string, input LoadDataInput) {
+	runCtx, done := s.track(taskID)
+	defer done()
+	s.runLoadData(runCtx, s.newTaskRecorder(taskID), input)
+}
+
+// runLoadData 执行命令并通过 rec 记录状态，ctx 取消时终止命令
+func (s *ExecutionService) runLoadData(ctx context.Context, rec taskRecorder, input LoadDataInput) {
+	taskID := rec.ID()
 	defer func() {
 		if r := recover(); r != nil {
 			fmt.Printf("PANIC in ExecuteLoadData for task %s: %v\n", taskID, r)
+			rec.Fail(context.Background(), fmt.Sprintf("panic: %v", r))
 		}
 	}()
 
-	cmdCtx, cmdCancel := context.WithCancel(context.Background())
+	cmdCtx, cmdCancel := context.WithCancel(ctx)
 	defer cmdCancel()
 	bgCtx := context.Background()
-	taskService := NewTaskService(s.db)
 
 	args := []string{
 		"--file=" + input.File,
@@ -280,6 +438,13 @@ func (s *ExecutionService) ExecuteLoadData(ctx context.Context, taskID string, i
 		args = append(args, fmt.Sprintf("--partition=%v", *input.Partition))
 	}
 
+	resultsFile, err := s.resultsFilePath(taskID, "load")
+	if err != nil {
+		rec.Fail(bgCtx, fmt.Sprintf("Failed to create reports directory: %v", err))
+		return
+	}
+	args = append(args, "--results-file="+resultsFile)
+
 	binPath := filepath.Join(s.config.TSBS.BinPath, "tsbs_load_kwdb")
 	if !filepath.IsAbs(binPath) {
 		if absBinPath, err := filepath.Abs(binPath); err == nil {
@@ -288,12 +453,14 @@ func (s *ExecutionService) ExecuteLoadData(ctx context.Context, taskID string, i
 	}
 
 	if _, err := os.Stat(binPath); os.IsNotExist(err) {
-		taskService.FailTask(bgCtx, taskID, fmt.Sprintf("Binary file not found: %s", binPath))
+		rec.Fail(bgCtx, fmt.Sprintf("Binary file not found: %s", binPath))
 		return
 	}
 
+	rec.UpdateProgress(bgCtx, 5)
+	reporter := &liveReporter{rec: rec, parser: newLoadProgress(input.File)}
 	cmd := exec.CommandContext(cmdCtx, binPath, args...)
-	result, err := s.exec.ExecuteWithOutput(cmdCtx, cmd)
+	result, err := s.exec.ExecuteStreaming(cmdCtx, cmd, reporter.onLine)
 	if err != nil {
 		errorMsg := fmt.Sprintf("Command failed: %v", err)
 		if result != nil && len(result.Output) > 0 {
@@ -303,30 +470,38 @@ func (s *ExecutionService) ExecuteLoadData(ctx context.Context, taskID string, i
 			}
 			errorMsg = fmt.Sprintf("Command failed: %v\nOutput: %s", err, errorOutput)
 		}
-		taskService.FailTask(bgCtx, taskID, errorMsg)
+		rec.Fail(bgCtx, errorMsg)
 		return
 	}
 
-	metrics := parseLoadMetrics(result.Output)
+	metrics := s.collectMetrics(bgCtx, rec.TaskID(), "load", parseLoadMetrics(result.Output), resultsFile)
 	taskResult := map[string]interface{}{
 		"metrics":      metrics,
 		"output":       result.Output,
 		"completed_at": time.Now().Format(time.RFC3339),
 	}
-	taskService.CompleteTask(bgCtx, taskID, taskResult, "")
+	rec.Complete(bgCtx, taskResult, "")
 }
 
 func (s *ExecutionService) ExecuteGenerateQueries(ctx context.Context, taskID string, input GenerateQueriesInput) {
+	runCtx, done := s.track(taskID)
+	defer done()
+	s.runGenerateQueries(runCtx, s.newTaskRecorder(taskID), input)
+}
+
+// runGenerateQueries 执行命令并通过 rec 记录状态，ctx 取消时终止命令
+func (s *ExecutionService) runGenerateQueries(ctx context.Context, rec taskRecorder, input GenerateQueriesInput) {
+	taskID := rec.ID()
 	defer func() {
 		if r := recover(); r != nil {
 			fmt.Printf("PANIC in ExecuteGenerateQueries for task %s: %v\n", taskID, r)
+			rec.Fail(context.Background(), fmt.Sprintf("panic: %v", r))
 		}
 	}()
 
-	cmdCtx, cmdCancel := context.WithCancel(context.Background())
+	cmdCtx, cmdCancel := context.WithCancel(ctx)
 	defer cmdCancel()
 	bgCtx := context.Background()
-	taskService := NewTaskService(s.db)
 
 	format := input.Format
 	if format == "" {
@@ -375,14 +550,14 @@ func (s *ExecutionService) ExecuteGenerateQueries(ctx context.Context, taskID st
 	}
 
 	if _, err := os.Stat(binPath); os.IsNotExist(err) {
-		taskService.FailTask(bgCtx, taskID, fmt.Sprintf("Binary file not found: %s", binPath))
+		rec.Fail(bgCtx, fmt.Sprintf("Binary file not found: %s", binPath))
 		return
 	}
 
 	cmd := exec.CommandContext(cmdCtx, binPath, args...)
 	outFile, err := os.Create(*outputFile)
 	if err != nil {
-		taskService.FailTask(bgCtx, taskID, fmt.Sprintf("Failed to create output file: %v", err))
+		rec.Fail(bgCtx, fmt.Sprintf("Failed to create output file: %v", err))
 		return
 	}
 	defer outFile.Close()
@@ -393,11 +568,11 @@ func (s *ExecutionService) ExecuteGenerateQueries(ctx context.Context, taskID st
 	cmd.Stderr = &stderrBuf
 
 	if err := cmd.Start(); err != nil {
-		taskService.FailTask(bgCtx, taskID, fmt.Sprintf("Failed to start command: %v", err))
+		rec.Fail(bgCtx, fmt.Sprintf("Failed to start command: %v", err))
 		return
 	}
 
-	taskService.UpdateTaskProgress(bgCtx, taskID, 5)
+	rec.UpdateProgress(bgCtx, 5)
 
 	// 等待完成
 	done := make(chan error, 1)
@@ -419,7 +594,7 @@ func (s *ExecutionService) ExecuteGenerateQueries(ctx context.Context, taskID st
 			if cmd.Process != nil {
 				cmd.Process.Kill()
 			}
-			taskService.FailTask(bgCtx, taskID, "Task cancelled")
+			rec.Fail(bgCtx, "Task cancelled")
 			return
 		case err := <-done:
 			if err != nil {
@@ -436,7 +611,7 @@ func (s *ExecutionService) ExecuteGenerateQueries(ctx context.Context, taskID st
 					errorMsg = fmt.Sprintf("Command failed: %v (output file is empty, no stderr output)", err)
 				}
 				fmt.Printf("[ExecuteGenerateQueries] ERROR: %s\n", errorMsg)
-				if failErr := taskService.FailTask(bgCtx, taskID, errorMsg); failErr != nil {
+				if failErr := rec.Fail(bgCtx, errorMsg); failErr != nil {
 					fmt.Printf("[ExecuteGenerateQueries] ERROR: Failed to update task status: %v\n", failErr)
 				} else {
 					fmt.Printf("[ExecuteGenerateQueries] Task %s marked as failed\n", taskID)
@@ -451,14 +626,14 @@ func (s *ExecutionService) ExecuteGenerateQueries(ctx context.Context, taskID st
 			if stat, statErr = os.Stat(*outputFile); statErr != nil {
 				errorMsg := fmt.Sprintf("Output file not found after command completion: %v", statErr)
 				fmt.Printf("[ExecuteGenerateQueries] ERROR: %s\n", errorMsg)
-				if failErr := taskService.FailTask(bgCtx, taskID, errorMsg); failErr != nil {
+				if failErr := rec.Fail(bgCtx, errorMsg); failErr != nil {
 					fmt.Printf("[ExecuteGenerateQueries] ERROR: Failed to update task status: %v\n", failErr)
 				}
 				return
 			} else if stat.Size() == 0 {
 				errorMsg := "Output file is empty after command completion"
 				fmt.Printf("[ExecuteGenerateQueries] ERROR: %s\n", errorMsg)
-				if failErr := taskService.FailTask(bgCtx, taskID, errorMsg); failErr != nil {
+				if failErr := rec.Fail(bgCtx, errorMsg); failErr != nil {
 					fmt.Printf("[ExecuteGenerateQueries] ERROR: Failed to update task status: %v\n", failErr)
 				}
 				return
@@ -468,7 +643,7 @@ func (s *ExecutionService) ExecuteGenerateQueries(ctx context.Context, taskID st
 				"file_size":    stat.Size(),
 				"completed_at": time.Now().Format(time.RFC3339),
 			}
-			if compErr := taskService.CompleteTask(bgCtx, taskID, result, *outputFile); compErr != nil {
+			if compErr := rec.Complete(bgCtx, result, *outputFile); compErr != nil {
 				fmt.Printf("[ExecuteGenerateQueries] ERROR: Failed to complete task %s: %v\n", taskID, compErr)
 			} else {
 				fmt.Printf("[ExecuteGenerateQueries] Successfully completed task %s\n", taskID)
@@ -481,36 +656,44 @@ func (s *ExecutionService) ExecuteGenerateQueries(ctx context.Context, taskID st
 					firstCheck = false
 					lastFileSize = currentSize
 					if currentSize > 0 {
-						taskService.UpdateTaskProgress(bgCtx, taskID, 30)
+						rec.UpdateProgress(bgCtx, 30)
 					} else {
-						taskService.UpdateTaskProgress(bgCtx, taskID, 10)
+						rec.UpdateProgress(bgCtx, 10)
 					}
 				} else if currentSize > lastFileSize {
 					fileGrowing = true
 					lastFileSize = currentSize
-					taskService.UpdateTaskProgress(bgCtx, taskID, 50)
+					rec.UpdateProgress(bgCtx, 50)
 				} else if fileGrowing && currentSize == lastFileSize && currentSize > 0 {
-					taskService.UpdateTaskProgress(bgCtx, taskID, 90)
+					rec.UpdateProgress(bgCtx, 90)
 				}
 			} else if firstCheck {
 				firstCheck = false
-				taskService.UpdateTaskProgress(bgCtx, taskID, 5)
+				rec.UpdateProgress(bgCtx, 5)
 			}
 		}
 	}
 }
 
 func (s *ExecutionService) ExecuteRunQueries(ctx context.Context, taskID string, input RunQueriesInput) {
+	runCtx, done := s.track(taskID)
+	defer done()
+	s.runRunQueries(runCtx, s.newTaskRecorder(taskID), input)
+}
+
+// runRunQueries 执行命令并通过 rec 记录状态，ctx 取消时终止命令
+func (s *ExecutionService) runRunQueries(ctx context.Context, rec taskRecorder, input RunQueriesInput) {
+	taskID := rec.ID()
 	defer func() {
 		if r := recover(); r != nil {
 			fmt.Printf("PANIC in ExecuteRunQueries for task %s: %v\n", taskID, r)
+			rec.Fail(context.Background(), fmt.Sprintf("panic: %v", r))
 		}
 	}()
 
-	cmdCtx, cmdCancel := context.WithCancel(context.Background())
+	cmdCtx, cmdCancel := context.WithCancel(ctx)
 	defer cmdCancel()
 	bgCtx := context.Background()
-	taskService := NewTaskService(s.db)
 
 	args := []string{
 		"--file=" + input.File,
@@ -533,6 +716,17 @@ func (s *ExecutionService) ExecuteRunQueries(ctx context.Context, taskID string,
 		args = append(args, "--prepare=false")
 	}
 
+	if input.MaxQueries != nil {
+		args = append(args, fmt.Sprintf("--max-queries=%d", *input.MaxQueries))
+	}
+
+	resultsFile, err := s.resultsFilePath(taskID, "query")
+	if err != nil {
+		rec.Fail(bgCtx, fmt.Sprintf("Failed to create reports directory: %v", err))
+		return
+	}
+	args = append(args, "--results-file="+resultsFile)
+
 	binPath := filepath.Join(s.config.TSBS.BinPath, "tsbs_run_queries_kwdb")
 	if !filepath.IsAbs(binPath) {
 		if absBinPath, err := filepath.Abs(binPath); err == nil {
@@ -541,17 +735,23 @@ func (s *ExecutionService) ExecuteRunQueries(ctx context.Context, taskID string,
 	}
 
 	if _, err := os.Stat(binPath); os.IsNotExist(err) {
-		taskService.FailTask(bgCtx, taskID, fmt.Sprintf("Binary file not found: %s", binPath))
+		rec.Fail(bgCtx, fmt.Sprintf("Binary file not found: %s", binPath))
 		return
 	}
 
 	if _, err := os.Stat(input.File); os.IsNotExist(err) {
-		taskService.FailTask(bgCtx, taskID, fmt.Sprintf("Query file not found: %s", input.File))
+		rec.Fail(bgCtx, fmt.Sprintf("Query file not found: %s", input.File))
 		return
 	}
 
+	expectedQueries := int64(input.ExpectedQueries)
+	if input.MaxQueries != nil && *input.MaxQueries > 0 && (expectedQueries == 0 || int64(*input.MaxQueries) < expectedQueries) {
+		expectedQueries = int64(*input.MaxQueries)
+	}
+	rec.UpdateProgress(bgCtx, 5)
+	reporter := &liveReporter{rec: rec, parser: &queryProgress{totalQueries: expectedQueries}}
 	cmd := exec.CommandContext(cmdCtx, binPath, args...)
-	result, err := s.exec.ExecuteWithOutput(cmdCtx, cmd)
+	result, err := s.exec.ExecuteStreaming(cmdCtx, cmd, reporter.onLine)
 	if err != nil {
 		errorMsg := fmt.Sprintf("Command failed: %v", err)
 		if result != nil && len(result.Output) > 0 {
@@ -561,17 +761,17 @@ func (s *ExecutionService) ExecuteRunQueries(ctx context.Context, taskID string,
 			}
 			errorMsg = fmt.Sprintf("Command failed: %v\nOutput: %s", err, errorOutput)
 		}
-		taskService.FailTask(bgCtx, taskID, errorMsg)
+		rec.Fail(bgCtx, errorMsg)
 		return
 	}
 
-	metrics := parseQueryMetrics(result.Output)
+	metrics := s.collectMetrics(bgCtx, rec.TaskID(), "query", parseQueryMetrics(result.Output), resultsFile)
 	taskResult := map[string]interface{}{
 		"metrics":      metrics,
 		"output":       result.Output,
 		"completed_at": time.Now().Format(time.RFC3339),
 	}
-	taskService.CompleteTask(bgCtx, taskID, taskResult, "")
+	rec.Complete(bgCtx, taskResult, "")
 }
 
 // 辅助函数
@@ -582,18 +782,153 @@ func getOrderQuantity(q *int) int {
 	return *q
 }
 
+var (
+	// loaded 100 metrics in 1.000sec with 2 workers (mean rate 100.00 metrics/sec)
+	loadSummaryRe = regexp.MustCompile(`loaded (\d+) (metrics|rows) in ([\d.]+)sec with (\d+) workers \(mean rate ([\d.]+) (?:metrics|rows)/sec\)`)
+	// failed to load 2 batches
+	loadFailedRe = regexp.MustCompile(`failed to load (\d+) batches`)
+	// loaded 3 out of order rows, at most 1m0s late, in 2 batches (mean 15.00ms per batch, 10.00ms per batch in order)
+	loadOutOfOrderRe = regexp.MustCompile(`loaded (\d+) out of order rows, at most (\S+) late, in (\d+) batches \(mean ([\d.]+)ms per batch, ([\d.]+)ms per batch in order\)`)
+	// Run complete after 10 queries with 2 workers (Overall query rate 5.00 queries/sec):
+	queryRunRe = regexp.MustCompile(`Run complete after (\d+) queries with (\d+) workers \(Overall query rate ([\d.]+) queries/sec\)`)
+	// min:     1.00ms, med:     2.00ms, mean:     2.00ms, max:    3.00ms, stddev:     0.50ms, sum:   0.1sec, count: 10
+	queryStatRe = regexp.MustCompile(`^min:\s*([\d.]+)ms, med:\s*([\d.]+)ms, mean:\s*([\d.]+)ms, max:\s*([\d.]+)ms, stddev:\s*([\d.]+)ms, sum:\s*([\d.]+)sec, count: (\d+)`)
+	// wall clock time: 2.000000sec
+	wallClockRe = regexp.MustCompile(`wall clock time: ([\d.]+)sec`)
+	// results json 中查询类型名的非字母数字字符会被替换为 _
+	labelStripRe = regexp.MustCompile("[^a-zA-Z0-9]+")
+)
+
+// parseLoadMetrics 从 tsbs_load_kwdb 的汇总输出中解析写入性能指标
 func parseLoadMetrics(output string) map[string]interface{} {
-	// 从输出中解析性能指标
-	// 这里简化处理，实际需要解析 tsbs_load_kwdb 的输出格式
-	return map[string]interface{}{
-		"output": output,
-	}
+	metrics := map[string]interface{}{}
+	for _, m := range loadSummaryRe.FindAllStringSubmatch(output, -1) {
+		total, _ := strconv.ParseInt(m[1], 10, 64)
+		duration, _ := strconv.ParseFloat(m[3], 64)
+		workers, _ := strconv.Atoi(m[4])
+		rate, _ := strconv.ParseFloat(m[5], 64)
+		metrics[m[2]+"_total"] = total
+		metrics[m[2]+"_per_sec"] = rate
+		metrics["duration_sec"] = duration
+		metrics["workers"] = workers
+	}
+	if m := loadFailedRe.FindStringSubmatch(output); m != nil {
+		failed, _ := strconv.ParseInt(m[1], 10, 64)
+		metrics["failed_batches"] = failed
+	}
+	// 乱序写入的行数、最大延迟，以及含乱序行的批次与顺序批次的平均耗时
+	if m := loadOutOfOrderRe.FindStringSubmatch(output); m != nil {
+		rows, _ := strconv.ParseInt(m[1], 10, 64)
+		lateness, _ := time.ParseDuration(m[2])
+		batches, _ := strconv.ParseInt(m[3], 10, 64)
+		batchMs, _ := strconv.ParseFloat(m[4], 64)
+		inOrderMs, _ := strconv.ParseFloat(m[5], 64)
+		metrics["out_of_order_rows"] = rows
+		metrics["out_of_order_max_lateness_sec"] = lateness.Seconds()
+		metrics["out_of_order_batches"] = batches
+		metrics["out_of_order_batch_mean_ms"] = batchMs
+		metrics["in_order_batch_mean_ms"] = inOrderMs
+	}
+	return metrics
 }
 
+// parseQueryMetrics 从 tsbs_run_queries_kwdb 的汇总输出中解析查询性能指标，
+// 每种查询类型的统计信息保存在 query_types 中
 func parseQueryMetrics(output string) map[string]interface{} {
-	// 从输出中解析查询性能指标
-	// 这里简化处理，实际需要解析 tsbs_run_queries_kwdb 的输出格式
-	return map[string]interface{}{
-		"output": output,
+	metrics := map[string]interface{}{}
+	if m := queryRunRe.FindStringSubmatch(output); m != nil {
+		queries, _ := strconv.ParseInt(m[1], 10, 64)
+		workers, _ := strconv.Atoi(m[2])
+		rate, _ := strconv.ParseFloat(m[3], 64)
+		metrics["queries_total"] = queries
+		metrics["workers"] = workers
+		metrics["queries_per_sec"] = rate
+	}
+	if m := wallClockRe.FindStringSubmatch(output); m != nil {
+		duration, _ := strconv.ParseFloat(m[1], 64)
+		metrics["duration_sec"] = duration
+	}
+
+	// 统计信息的上一行是以冒号结尾的查询类型名
+	queryTypes := map[string]interface{}{}
+	label := ""
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if m := queryStatRe.FindStringSubmatch(line); m != nil && label != "" {
+			stat := map[string]interface{}{}
+			for i, key := range []string{"min_ms", "median_ms", "mean_ms", "max_ms", "stddev_ms", "sum_sec"} {
+				v, _ := strconv.ParseFloat(m[i+1], 64)
+				stat[key] = v
+			}
+			count, _ := strconv.ParseInt(m[7], 10, 64)
+			stat["count"] = count
+			queryTypes[label] = stat
+			label = ""
+			continue
+		}
+		if strings.HasSuffix(line, ":") && !strings.HasPrefix(line, "Run complete") {
+			label = strings.TrimSpace(strings.TrimSuffix(line, ":"))
+		} else {
+			label = ""
+		}
+	}
+	if len(queryTypes) > 0 {
+		metrics["query_types"] = queryTypes
+	}
+	return metrics
+}
+
+// readResultsFile 读取 --results-file 生成的 json 汇总结果
+func readResultsFile(path string) (map[string]interface{}, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var results map[string]interface{}
+	if err := json.Unmarshal(content, &results); err != nil {
+		return nil, fmt.Errorf("failed to parse results file %s: %w", path, err)
+	}
+	return results, nil
+}
+
+// addQueryPercentiles 将 results json 中各查询类型的分位数合并到 query_types 中
+func addQueryPercentiles(metrics, results map[string]interface{}) {
+	queryTypes, _ := metrics["query_types"].(map[string]interface{})
+	totals, _ := results["Totals"].(map[string]interface{})
+	quantiles, _ := totals["overallQuantiles"].(map[string]interface{})
+	for label, stat := range queryTypes {
+		if q, ok := quantiles[labelStripRe.ReplaceAllString(label, "_")]; ok {
+			stat.(map[string]interface{})["percentiles_ms"] = q
+		}
+	}
+}
+
+// resultsFilePath 返回任务的 --results-file 路径，并创建其所在的报告目录
+func (s *ExecutionService) resultsFilePath(taskID, resultType string) (string, error) {
+	dir := s.config.TSBS.ReportsDir
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%s_%s_results.json", taskID, resultType))
+	if absPath, err := filepath.Abs(path); err == nil {
+		path = absPath
+	}
+	return path, nil
+}
+
+// collectMetrics 合并输出中解析的指标与 results json，并保存到 tsbs_test_results
+func (s *ExecutionService) collectMetrics(ctx context.Context, taskID, resultType string, metrics map[string]interface{}, resultsFile string) map[string]interface{} {
+	if results, err := readResultsFile(resultsFile); err == nil {
+		if resultType == "query" {
+			addQueryPercentiles(metrics, results)
+		}
+		metrics["results_file"] = resultsFile
+		metrics["results"] = results
+	} else {
+		fmt.Printf("[collectMetrics] WARN: failed to read results file for task %s: %v\n", taskID, err)
+	}
+	if err := NewTaskService(s.db).SaveResult(ctx, taskID, resultType, metrics); err != nil {
+		fmt.Printf("[collectMetrics] ERROR: failed to save %s results for task %s: %v\n", resultType, taskID, err)
 	}
+	return metrics
 }
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/timescale/tsbs/internal/config"
+)
+
+func TestParseLoadMetrics(t *testing.T) {
+	output := `time,per. metric/s,metric total,overall metric/s,per. row/s,row total,overall row/s
+1700000000,1000.00,1.000000E+04,1000.00,100.00,1.000000E+03,100.00
+
+Summary:
+loaded 10000 metrics in 10.000sec with 4 workers (mean rate 1000.00 metrics/sec)
+loaded 1000 rows in 10.000sec with 4 workers (mean rate 100.00 rows/sec)
+failed to load 2 batches
+loaded 3 out of order rows, at most 1m0s late, in 2 batches (mean 15.00ms per batch, 10.00ms per batch in order)
+`
+	metrics := parseLoadMetrics(output)
+	want := map[string]interface{}{
+		"metrics_total":   int64(10000),
+		"metrics_per_sec": 1000.0,
+		"rows_total":      int64(1000),
+		"rows_per_sec":    100.0,
+		"duration_sec":    10.0,
+		"workers":         4,
+		"failed_batches":  int64(2),
+
+		"out_of_order_rows":             int64(3),
+		"out_of_order_max_lateness_sec": 60.0,
+		"out_of_order_batches":          int64(2),
+		"out_of_order_batch_mean_ms":    15.0,
+		"in_order_batch_mean_ms":        10.0,
+	}
+	for k, v := range want {
+		if metrics[k] != v {
+			t.Errorf("%s: got %v want %v", k, metrics[k], v)
+		}
+	}
+}
+
+func TestParseQueryMetrics(t *testing.T) {
+	output := `After 10 queries with 2 workers:
+Run complete after 20 queries with 2 workers (Overall query rate 10.00 queries/sec):
+KWDB max cpu, rand    1 hosts, rand 12hr by 1h:
+min:     1.00ms, med:     2.00ms, mean:     2.50ms, max:    5.00ms, stddev:     0.50ms, sum:   0.1sec, count: 20
+all queries                                     :
+min:     1.00ms, med:     2.00ms, mean:     2.50ms, max:    5.00ms, stddev:     0.50ms, sum:   0.1sec, count: 20
+wall clock time: 2.000000sec
+`
+	metrics := parseQueryMetrics(output)
+	if got := metrics["queries_total"]; got != int64(20) {
+		t.Errorf("queries_total: got %v want 20", got)
+	}
+	if got := metrics["queries_per_sec"]; got != 10.0 {
+		t.Errorf("queries_per_sec: got %v want 10", got)
+	}
+	if got := metrics["duration_sec"]; got != 2.0 {
+		t.Errorf("duration_sec: got %v want 2", got)
+	}
+	queryTypes, ok := metrics["query_types"].(map[string]interface{})
+	if !ok || len(queryTypes) != 2 {
+		t.Fatalf("query_types: got %v want 2 types", metrics["query_types"])
+	}
+	stat := queryTypes["KWDB max cpu, rand    1 hosts, rand 12hr by 1h"].(map[string]interface{})
+	if stat["mean_ms"] != 2.5 || stat["count"] != int64(20) {
+		t.Errorf("unexpected stat: %v", stat)
+	}
+
+	results := map[string]interface{}{
+		"Totals": map[string]interface{}{
+			"overallQuantiles": map[string]interface{}{
+				"all_queries": map[string]interface{}{"q50": 2.0},
+			},
+		},
+	}
+	addQueryPercentiles(metrics, results)
+	if _, ok := queryTypes["all queries"].(map[string]interface{})["percentiles_ms"]; !ok {
+		t.Errorf("percentiles were not merged into 'all queries'")
+	}
+}
+
+func TestInWorkDirs(t *testing.T) {
+	s := &ExecutionService{config: &config.Config{TSBS: config.TSBSConfig{
+		WorkDir:  "/data/tsbs_work",
+		QueryDir: "/data/queries",
+	}}}
+	cases := []struct {
+		file string
+		want bool
+	}{
+		{file: "/data/tsbs_work/load_data/cpu-only.dat", want: true},
+		{file: "/data/queries/scale100/lastpoint.dat", want: true},
+		{file: "/data/tsbs_work/../secret", want: false},
+		{file: "/data/tsbs_workspace/file", want: false},
+		{file: "/etc/passwd", want: false},
+	}
+	for _, c := range cases {
+		if got := s.inWorkDirs(c.file); got != c.want {
+			t.Errorf("%s: got %v want %v", c.file, got, c.want)
+		}
+	}
+}
+
+func TestCancel(t *testing.T) {
+	s := &ExecutionService{running: make(map[string]context.CancelFunc)}
+	if s.Cancel("task") {
+		t.Errorf("cancelled a task that is not running")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.running["task"] = cancel
+	if !s.Running("task") || !s.Cancel("task") {
+		t.Fatalf("failed to cancel a running task")
+	}
+	if ctx.Err() == nil {
+		t.Errorf("context of the cancelled task is not done")
+	}
+}
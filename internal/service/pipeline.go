@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// 流水线的阶段类型，即 tsbs_test_subtasks 的 subtask_type
+const (
+	StageGenerateData    = "generate_data"
+	StageLoad            = "load"
+	StageGenerateQueries = "generate_queries"
+	StageRunQueries      = "run_queries"
+)
+
+// PipelineInput 是端到端流水线的输入。LoadData.File、GenerateQueries.QueryType、
+// RunQueries.File 和 RunQueries.QueryType 由流水线根据上一阶段的输出填充
+type PipelineInput struct {
+	GenerateData    GenerateDataInput
+	LoadData        LoadDataInput
+	GenerateQueries GenerateQueriesInput
+	RunQueries      RunQueriesInput
+	QueryTypes      []string
+}
+
+// PipelineStage 是流水线的一个阶段，对应 tsbs_test_subtasks 中的一个子任务
+type PipelineStage struct {
+	SubtaskID string `json:"subtask_id"`
+	Type      string `json:"type"`
+	QueryType string `json:"query_type,omitempty"`
+}
+
+// pipelineStages 返回流水线的各阶段：生成数据、写入数据，然后为每种查询类型生成查询，
+// 最后依次执行各类查询
+func pipelineStages(queryTypes []string) []PipelineStage {
+	stages := []PipelineStage{{Type: StageGenerateData}, {Type: StageLoad}}
+	for _, queryType := range queryTypes {
+		stages = append(stages, PipelineStage{Type: StageGenerateQueries, QueryType: queryType})
+	}
+	for _, queryType := range queryTypes {
+		stages = append(stages, PipelineStage{Type: StageRunQueries, QueryType: queryType})
+	}
+	return stages
+}
+
+// StartPipeline 为流水线的每个阶段创建子任务并在后台依次执行，返回各阶段。
+// 没有查询类型时（如 devops-generic）流水线只生成和写入数据。
+// 通过 Cancel 取消主任务会终止当前阶段并取消后续阶段
+func (s *ExecutionService) StartPipeline(ctx context.Context, taskID string, input PipelineInput) ([]PipelineStage, error) {
+	taskService := NewTaskService(s.db)
+	stages := pipelineStages(input.QueryTypes)
+	for i := range stages {
+		subtaskID, err := taskService.CreateSubtask(ctx, taskID, stages[i].Type)
+		if err != nil {
+			return nil, err
+		}
+		stages[i].SubtaskID = subtaskID
+	}
+
+	pipelineCtx, done := s.track(taskID)
+	go func() {
+		defer done()
+		s.runPipeline(pipelineCtx, taskID, stages, input)
+	}()
+	return stages, nil
+}
+
+func (s *ExecutionService) runPipeline(ctx context.Context, taskID string, stages []PipelineStage, input PipelineInput) {
+	bgCtx := context.Background()
+	taskService := NewTaskService(s.db)
+
+	dataFile := ""
+	queryFiles := make(map[string]string)
+	for i, stage := range stages {
+		if ctx.Err() != nil {
+			s.cancelPipeline(taskID, stages[i:])
+			return
+		}
+
+		rec := &subtaskRecorder{tasks: taskService, taskID: taskID, stage: stage, index: i, numStages: len(stages)}
+		taskService.UpdateSubtaskStatus(bgCtx, stage.SubtaskID, "running")
+		fmt.Printf("[ExecutePipeline] task %s: starting stage %d/%d %s %s\n", taskID, i+1, len(stages), stage.Type, stage.QueryType)
+
+		switch stage.Type {
+		case StageGenerateData:
+			s.runGenerateData(ctx, rec, input.GenerateData)
+			dataFile = rec.outputFile
+		case StageLoad:
+			loadInput := input.LoadData
+			loadInput.File = dataFile
+			s.runLoadData(ctx, rec, loadInput)
+		case StageGenerateQueries:
+			queriesInput := input.GenerateQueries
+			queriesInput.QueryType = stage.QueryType
+			s.runGenerateQueries(ctx, rec, queriesInput)
+			queryFiles[stage.QueryType] = rec.outputFile
+		case StageRunQueries:
+			runInput := input.RunQueries
+			runInput.File = queryFiles[stage.QueryType]
+			runInput.QueryType = stage.QueryType
+			runInput.ExpectedQueries = input.GenerateQueries.Queries
+			s.runRunQueries(ctx, rec, runInput)
+		}
+
+		if ctx.Err() != nil {
+			s.cancelPipeline(taskID, stages[i:])
+			return
+		}
+		if rec.failed {
+			for _, skipped := range stages[i+1:] {
+				taskService.FinishSubtask(bgCtx, skipped.SubtaskID, "skipped", map[string]interface{}{})
+			}
+			taskService.FailTask(bgCtx, taskID, fmt.Sprintf("Stage %s %s failed: %s", stage.Type, stage.QueryType, rec.errorMsg))
+			return
+		}
+	}
+
+	result := map[string]interface{}{
+		"stages":       stages,
+		"data_file":    dataFile,
+		"query_files":  queryFiles,
+		"completed_at": time.Now().Format(time.RFC3339),
+	}
+	taskService.CompleteTask(bgCtx, taskID, result, dataFile)
+}
+
+// cancelPipeline 将被取消的流水线未完成的阶段标记为 cancelled，流水线本身由 track 标记
+func (s *ExecutionService) cancelPipeline(taskID string, remaining []PipelineStage) {
+	bgCtx := context.Background()
+	taskService := NewTaskService(s.db)
+	for _, stage := range remaining {
+		taskService.FinishSubtask(bgCtx, stage.SubtaskID, "cancelled", map[string]interface{}{"error": "Task cancelled"})
+	}
+}
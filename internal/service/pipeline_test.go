@@ -0,0 +1,35 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPipelineStages(t *testing.T) {
+	stages := pipelineStages([]string{"single-groupby-1-1-1", "cpu-max-all-1"})
+	want := []PipelineStage{
+		{Type: StageGenerateData},
+		{Type: StageLoad},
+		{Type: StageGenerateQueries, QueryType: "single-groupby-1-1-1"},
+		{Type: StageGenerateQueries, QueryType: "cpu-max-all-1"},
+		{Type: StageRunQueries, QueryType: "single-groupby-1-1-1"},
+		{Type: StageRunQueries, QueryType: "cpu-max-all-1"},
+	}
+	if !reflect.DeepEqual(stages, want) {
+		t.Errorf("incorrect stages:\ngot  %v\nwant %v", stages, want)
+	}
+}
+
+func TestSubtaskRecorderResult(t *testing.T) {
+	rec := &subtaskRecorder{stage: PipelineStage{Type: StageRunQueries, QueryType: "lastpoint"}}
+	rec.outputFile = "/tmp/queries.dat"
+	got := rec.result("ok")
+	want := map[string]interface{}{
+		"result":      "ok",
+		"output_file": "/tmp/queries.dat",
+		"query_type":  "lastpoint",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("incorrect result: got %v want %v", got, want)
+	}
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// liveUpdateInterval 限制执行期间写入数据库的频率
+const liveUpdateInterval = time.Second
+
+// progressSampleSize 是估算数据文件行数时读取的字节数
+const progressSampleSize = 4 << 20
+
+var (
+	// time,per. metric/s,metric total,overall metric/s,per. row/s,row total,overall row/s
+	// 1700000000,1000.00,1.000000E+04,1000.00,100.00,1.000000E+03,100.00
+	loadReportRe = regexp.MustCompile(`^(\d+),([\d.]+),([\d.E+-]+),([\d.]+),([\d.]+|-),([\d.E+-]+|-),([\d.]+|-)$`)
+	// After 100 queries with 2 workers:
+	queryAfterRe = regexp.MustCompile(`^After (\d+) queries with (\d+) workers:`)
+	// Interval query rate: 10.00 queries/sec	Overall query rate: 9.00 queries/sec
+	queryRateRe = regexp.MustCompile(`Interval query rate: ([\d.]+) queries/sec\s+Overall query rate: ([\d.]+) queries/sec`)
+)
+
+// progressParser 从命令输出的一行中解析执行进度，ok 为 false 表示该行不含进度
+type progressParser interface {
+	Parse(line string) (progress int, live map[string]interface{}, ok bool)
+}
+
+// loadProgress 解析 CommonBenchmarkRunner.report 按 reporting-period 输出的 CSV 行，
+// 完成百分比由写入行数和数据文件的估算行数得出
+type loadProgress struct {
+	totalRows int64
+}
+
+func newLoadProgress(file string) *loadProgress {
+	return &loadProgress{totalRows: estimateFileRows(file)}
+}
+
+func (p *loadProgress) Parse(line string) (int, map[string]interface{}, bool) {
+	m := loadReportRe.FindStringSubmatch(strings.TrimSpace(line))
+	if m == nil {
+		return 0, nil, false
+	}
+	live := map[string]interface{}{}
+	live["metrics_per_sec"], _ = strconv.ParseFloat(m[2], 64)
+	metricsTotal, _ := strconv.ParseFloat(m[3], 64)
+	live["metrics_total"] = int64(metricsTotal)
+	live["overall_metrics_per_sec"], _ = strconv.ParseFloat(m[4], 64)
+	if m[6] == "-" {
+		return 0, live, true
+	}
+	live["rows_per_sec"], _ = strconv.ParseFloat(m[5], 64)
+	rowsTotal, _ := strconv.ParseFloat(m[6], 64)
+	live["rows_total"] = int64(rowsTotal)
+	live["overall_rows_per_sec"], _ = strconv.ParseFloat(m[7], 64)
+	if p.totalRows > 0 {
+		live["estimated_rows"] = p.totalRows
+	}
+	return percent(int64(rowsTotal), p.totalRows), live, true
+}
+
+// queryProgress 解析 statProcessor 每 print-interval 条查询输出的统计，
+// 总查询数已知时得出完成百分比
+type queryProgress struct {
+	totalQueries int64
+	queries      int64
+	workers      int
+}
+
+func (p *queryProgress) Parse(line string) (int, map[string]interface{}, bool) {
+	if m := queryAfterRe.FindStringSubmatch(line); m != nil {
+		p.queries, _ = strconv.ParseInt(m[1], 10, 64)
+		p.workers, _ = strconv.Atoi(m[2])
+		return 0, nil, false
+	}
+	m := queryRateRe.FindStringSubmatch(line)
+	if m == nil || p.queries == 0 {
+		return 0, nil, false
+	}
+	live := map[string]interface{}{
+		"queries_total": p.queries,
+		"workers":       p.workers,
+	}
+	live["queries_per_sec"], _ = strconv.ParseFloat(m[1], 64)
+	live["overall_queries_per_sec"], _ = strconv.ParseFloat(m[2], 64)
+	if p.totalQueries > 0 {
+		live["expected_queries"] = p.totalQueries
+	}
+	return percent(p.queries, p.totalQueries), live, true
+}
+
+// percent 返回 done/total 的百分比，限制在 5 到 99 之间，100 留给完成的任务
+func percent(done, total int64) int {
+	if total <= 0 {
+		return 0
+	}
+	pct := int(done * 100 / total)
+	if pct < 5 {
+		pct = 5
+	}
+	if pct > 99 {
+		pct = 99
+	}
+	return pct
+}
+
+// estimateFileRows 由文件开头的样本中插入行的密度估算 kwdb 数据文件的插入行数
+func estimateFileRows(file string) int64 {
+	f, err := os.Open(file)
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil || stat.Size() == 0 {
+		return 0
+	}
+
+	var sampled, rows int64
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16<<20)
+	for sampled < progressSampleSize && scanner.Scan() {
+		line := scanner.Text()
+		sampled += int64(len(line)) + 1
+		if strings.HasPrefix(line, "1,") {
+			rows++
+		}
+	}
+	if sampled == 0 || rows == 0 {
+		return 0
+	}
+	if sampled >= stat.Size() {
+		return rows
+	}
+	return int64(float64(rows) * float64(stat.Size()) / float64(sampled))
+}
+
+// liveReporter 将解析出的进度写入任务，写入频率不超过 liveUpdateInterval
+type liveReporter struct {
+	rec    taskRecorder
+	parser progressParser
+	last   time.Time
+}
+
+func (r *liveReporter) onLine(line string) {
+	progress, live, ok := r.parser.Parse(line)
+	if !ok || time.Since(r.last) < liveUpdateInterval {
+		return
+	}
+	r.last = time.Now()
+	live["updated_at"] = r.last.Format(time.RFC3339)
+	r.rec.Report(context.Background(), progress, live)
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadProgressParse(t *testing.T) {
+	p := &loadProgress{totalRows: 2000}
+	if _, _, ok := p.Parse("time,per. metric/s,metric total,overall metric/s,per. row/s,row total,overall row/s"); ok {
+		t.Errorf("parsed the header line")
+	}
+
+	progress, live, ok := p.Parse("1700000000,1000.00,1.000000E+04,900.00,100.00,1.000000E+03,90.00")
+	if !ok {
+		t.Fatalf("failed to parse the report line")
+	}
+	if progress != 50 {
+		t.Errorf("incorrect progress: got %d want 50", progress)
+	}
+	if live["rows_total"] != int64(1000) || live["metrics_total"] != int64(10000) || live["rows_per_sec"] != 100.0 {
+		t.Errorf("unexpected live metrics: %v", live)
+	}
+
+	progress, live, ok = p.Parse("1700000000,1000.00,1.000000E+04,900.00,-,-,-")
+	if !ok || progress != 0 || live["metrics_per_sec"] != 1000.0 {
+		t.Errorf("unexpected result without rows: %d %v %v", progress, live, ok)
+	}
+}
+
+func TestQueryProgressParse(t *testing.T) {
+	p := &queryProgress{totalQueries: 1000}
+	if _, _, ok := p.Parse("After 100 queries with 4 workers:"); ok {
+		t.Errorf("reported progress before the rates")
+	}
+	progress, live, ok := p.Parse("Interval query rate: 50.00 queries/sec\tOverall query rate: 45.00 queries/sec")
+	if !ok {
+		t.Fatalf("failed to parse the rate line")
+	}
+	if progress != 10 {
+		t.Errorf("incorrect progress: got %d want 10", progress)
+	}
+	if live["queries_total"] != int64(100) || live["workers"] != 4 || live["overall_queries_per_sec"] != 45.0 {
+		t.Errorf("unexpected live metrics: %v", live)
+	}
+}
+
+func TestPercent(t *testing.T) {
+	cases := []struct {
+		done, total int64
+		want        int
+	}{
+		{done: 10, total: 0, want: 0},
+		{done: 0, total: 100, want: 5},
+		{done: 50, total: 100, want: 50},
+		{done: 150, total: 100, want: 99},
+	}
+	for _, c := range cases {
+		if got := percent(c.done, c.total); got != c.want {
+			t.Errorf("percent(%d, %d): got %d want %d", c.done, c.total, got, c.want)
+		}
+	}
+}
+
+func TestEstimateFileRows(t *testing.T) {
+	lines := []string{"tags,hostname string", "cpu,usage_user int64", "", "3,cpu,host_0,('host_0')"}
+	for i := 0; i < 10; i++ {
+		lines = append(lines, "1,host_0,1,(1451606400000,58,'host_0')")
+	}
+	file := filepath.Join(t.TempDir(), "data.dat")
+	if err := os.WriteFile(file, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := estimateFileRows(file); got != 10 {
+		t.Errorf("incorrect rows: got %d want 10", got)
+	}
+	if got := estimateFileRows(filepath.Join(t.TempDir(), "missing.dat")); got != 0 {
+		t.Errorf("incorrect rows of a missing file: got %d want 0", got)
+	}
+}
@@ -55,9 +55,39 @@ func (s *StatusService) GetTaskStatus(ctx context.Context, taskID string) (Statu
 		output.Result = json.RawMessage(result.String)
 	}
 
+	metrics, err := s.getLatestMetrics(ctx, taskID)
+	if err != nil {
+		return StatusOutput{}, err
+	}
+	output.Metrics = metrics
+
 	return output, nil
 }
 
+// getLatestMetrics 返回任务最近一次保存在 tsbs_test_results 中的性能指标
+func (s *StatusService) getLatestMetrics(ctx context.Context, taskID string) ([]byte, error) {
+	query := `
+		SELECT metrics
+		FROM tsbs_test_results
+		WHERE task_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT 1
+	`
+
+	var metrics sql.NullString
+	err := s.db.DB.QueryRowContext(ctx, query, taskID).Scan(&metrics)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get metrics: %w", err)
+	}
+	if !metrics.Valid || len(metrics.String) == 0 {
+		return nil, nil
+	}
+	return json.RawMessage(metrics.String), nil
+}
+
 func (s *StatusService) GetSubtaskStatus(ctx context.Context, taskID, subtaskID string) (StatusOutput, error) {
 	query := `
 		SELECT status, progress, result
@@ -88,3 +118,44 @@ func (s *StatusService) GetSubtaskStatus(ctx context.Context, taskID, subtaskID
 
 	return output, nil
 }
+
+// SubtaskStatus 是一个子任务的状态
+type SubtaskStatus struct {
+	SubtaskID   string
+	SubtaskType string
+	Status      string
+	Progress    int
+	Result      []byte
+}
+
+// ListSubtasks 按创建顺序返回任务的所有子任务
+func (s *StatusService) ListSubtasks(ctx context.Context, taskID string) ([]SubtaskStatus, error) {
+	query := `
+		SELECT subtask_id, subtask_type, status, progress, result
+		FROM tsbs_test_subtasks
+		WHERE task_id = $1
+		ORDER BY id
+	`
+
+	rows, err := s.db.DB.QueryContext(ctx, query, taskID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list subtasks: %w", err)
+	}
+	defer rows.Close()
+
+	var subtasks []SubtaskStatus
+	for rows.Next() {
+		var subtask SubtaskStatus
+		var subtaskType sql.NullString
+		var result sql.NullString
+		if err := rows.Scan(&subtask.SubtaskID, &subtaskType, &subtask.Status, &subtask.Progress, &result); err != nil {
+			return nil, fmt.Errorf("failed to scan subtask: %w", err)
+		}
+		subtask.SubtaskType = subtaskType.String
+		if result.Valid && len(result.String) > 0 {
+			subtask.Result = json.RawMessage(result.String)
+		}
+		subtasks = append(subtasks, subtask)
+	}
+	return subtasks, rows.Err()
+}
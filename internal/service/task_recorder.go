@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+)
+
+// taskRecorder 记录命令执行的进度和结果。单独执行的命令记录在 tsbs_test_tasks，
+// 流水线的各阶段记录在 tsbs_test_subtasks
+type taskRecorder interface {
+	// ID 返回任务或子任务的 ID
+	ID() string
+	// TaskID 返回性能指标所属的主任务 ID
+	TaskID() string
+	UpdateProgress(ctx context.Context, progress int) error
+	// Report 在执行期间更新进度和实时指标，progress 为 0 表示进度未知
+	Report(ctx context.Context, progress int, live map[string]interface{}) error
+	Complete(ctx context.Context, result interface{}, outputFile string) error
+	Fail(ctx context.Context, errorMsg string) error
+}
+
+type mainTaskRecorder struct {
+	tasks  *TaskService
+	taskID string
+}
+
+func (s *ExecutionService) newTaskRecorder(taskID string) taskRecorder {
+	return &mainTaskRecorder{tasks: NewTaskService(s.db), taskID: taskID}
+}
+
+func (r *mainTaskRecorder) ID() string {
+	return r.taskID
+}
+
+func (r *mainTaskRecorder) TaskID() string {
+	return r.taskID
+}
+
+func (r *mainTaskRecorder) UpdateProgress(ctx context.Context, progress int) error {
+	return r.tasks.UpdateTaskProgress(ctx, r.taskID, progress)
+}
+
+func (r *mainTaskRecorder) Report(ctx context.Context, progress int, live map[string]interface{}) error {
+	return r.tasks.UpdateTaskLive(ctx, r.taskID, progress, live)
+}
+
+func (r *mainTaskRecorder) Complete(ctx context.Context, result interface{}, outputFile string) error {
+	return r.tasks.CompleteTask(ctx, r.taskID, result, outputFile)
+}
+
+func (r *mainTaskRecorder) Fail(ctx context.Context, errorMsg string) error {
+	return r.tasks.FailTask(ctx, r.taskID, errorMsg)
+}
+
+// subtaskRecorder 记录流水线的一个阶段，同时按阶段换算主任务的进度，
+// 并保留输出文件和错误信息供下一阶段使用
+type subtaskRecorder struct {
+	tasks     *TaskService
+	taskID    string
+	stage     PipelineStage
+	index     int
+	numStages int
+
+	outputFile string
+	failed     bool
+	errorMsg   string
+}
+
+func (r *subtaskRecorder) ID() string {
+	return r.stage.SubtaskID
+}
+
+func (r *subtaskRecorder) TaskID() string {
+	return r.taskID
+}
+
+func (r *subtaskRecorder) UpdateProgress(ctx context.Context, progress int) error {
+	if err := r.tasks.UpdateSubtaskProgress(ctx, r.stage.SubtaskID, progress); err != nil {
+		return err
+	}
+	return r.tasks.UpdateTaskProgress(ctx, r.taskID, (r.index*100+progress)/r.numStages)
+}
+
+func (r *subtaskRecorder) Report(ctx context.Context, progress int, live map[string]interface{}) error {
+	if err := r.tasks.UpdateSubtaskLive(ctx, r.stage.SubtaskID, progress, live); err != nil || progress <= 0 {
+		return err
+	}
+	return r.tasks.UpdateTaskProgress(ctx, r.taskID, (r.index*100+progress)/r.numStages)
+}
+
+func (r *subtaskRecorder) Complete(ctx context.Context, result interface{}, outputFile string) error {
+	r.outputFile = outputFile
+	if err := r.UpdateProgress(ctx, 100); err != nil {
+		return err
+	}
+	return r.tasks.FinishSubtask(ctx, r.stage.SubtaskID, "completed", r.result(result))
+}
+
+func (r *subtaskRecorder) Fail(ctx context.Context, errorMsg string) error {
+	r.failed = true
+	r.errorMsg = errorMsg
+	return r.tasks.FinishSubtask(ctx, r.stage.SubtaskID, "failed", r.result(nil))
+}
+
+func (r *subtaskRecorder) result(result interface{}) map[string]interface{} {
+	m := map[string]interface{}{}
+	if result != nil {
+		m["result"] = result
+	}
+	if r.outputFile != "" {
+		m["output_file"] = r.outputFile
+	}
+	if r.errorMsg != "" {
+		m["error"] = r.errorMsg
+	}
+	if r.stage.QueryType != "" {
+		m["query_type"] = r.stage.QueryType
+	}
+	return m
+}
@@ -5,6 +5,8 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/timescale/tsbs/internal/db"
@@ -98,6 +100,299 @@ func (s *TaskService) FailTask(ctx context.Context, taskID string, errorMsg stri
 	return err
 }
 
+// UpdateTaskLive 在任务执行期间更新进度和 result.live 中的实时指标，progress 为 0 时只更新指标
+func (s *TaskService) UpdateTaskLive(ctx context.Context, taskID string, progress int, live interface{}) error {
+	resultJSON, err := json.Marshal(map[string]interface{}{"live": live})
+	if err != nil {
+		return fmt.Errorf("failed to marshal live metrics: %w", err)
+	}
+
+	if progress <= 0 {
+		query := `
+			UPDATE tsbs_test_tasks 
+			SET result = $1, updated_at = NOW()
+			WHERE task_id = $2
+		`
+		_, err = s.db.DB.ExecContext(ctx, query, resultJSON, taskID)
+		return err
+	}
+
+	query := `
+		UPDATE tsbs_test_tasks 
+		SET progress = $1, result = $2, updated_at = NOW()
+		WHERE task_id = $3
+	`
+
+	_, err = s.db.DB.ExecContext(ctx, query, progress, resultJSON, taskID)
+	return err
+}
+
+// CancelTask 将被取消的任务标记为 cancelled
+func (s *TaskService) CancelTask(ctx context.Context, taskID string) error {
+	query := `
+		UPDATE tsbs_test_tasks 
+		SET status = 'cancelled',
+		    error_message = 'Task cancelled',
+		    completed_at = NOW(),
+		    updated_at = NOW()
+		WHERE task_id = $1
+	`
+
+	_, err := s.db.DB.ExecContext(ctx, query, taskID)
+	return err
+}
+
+// TaskFilter 是 ListTasks 的过滤条件，零值表示不过滤
+type TaskFilter struct {
+	TaskType      string
+	Status        string
+	CreatedBefore time.Time
+	Limit         int
+}
+
+// ListTasks 按创建时间倒序返回符合条件的任务
+func (s *TaskService) ListTasks(ctx context.Context, filter TaskFilter) ([]db.Task, error) {
+	var conditions []string
+	var args []interface{}
+	if filter.TaskType != "" {
+		args = append(args, filter.TaskType)
+		conditions = append(conditions, fmt.Sprintf("task_type = $%d", len(args)))
+	}
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		conditions = append(conditions, fmt.Sprintf("status = $%d", len(args)))
+	}
+	if !filter.CreatedBefore.IsZero() {
+		args = append(args, filter.CreatedBefore)
+		conditions = append(conditions, fmt.Sprintf("created_at < $%d", len(args)))
+	}
+
+	query := `
+		SELECT id, task_id, task_type, status, progress, created_at, updated_at,
+		       started_at, completed_at, error_message, config, result, output_file
+		FROM tsbs_test_tasks
+	`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY created_at DESC, id DESC"
+	if filter.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", filter.Limit)
+	}
+
+	rows, err := s.db.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tasks: %w", err)
+	}
+	defer rows.Close()
+
+	var tasks []db.Task
+	for rows.Next() {
+		var task db.Task
+		if err := rows.Scan(
+			&task.ID, &task.TaskID, &task.TaskType, &task.Status, &task.Progress,
+			&task.CreatedAt, &task.UpdatedAt, &task.StartedAt, &task.CompletedAt,
+			&task.ErrorMessage, &task.Config, &task.Result, &task.OutputFile,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan task: %w", err)
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, rows.Err()
+}
+
+// DeleteTask 删除任务及其子任务和结果，返回它们引用的、且不再被其余任务引用的输出文件和结果文件
+func (s *TaskService) DeleteTask(ctx context.Context, taskID string) ([]string, error) {
+	task, err := s.GetTask(ctx, taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	if task.OutputFile.Valid && task.OutputFile.String != "" {
+		files = append(files, task.OutputFile.String)
+	}
+	// 子任务的输出文件和结果文件保存在 json 中
+	fileQueries := []string{
+		`SELECT result FROM tsbs_test_subtasks WHERE task_id = $1`,
+		`SELECT metrics FROM tsbs_test_results WHERE task_id = $1`,
+	}
+	for _, query := range fileQueries {
+		rows, err := s.db.DB.QueryContext(ctx, query, taskID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get task files: %w", err)
+		}
+		for rows.Next() {
+			var content sql.NullString
+			if err := rows.Scan(&content); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to scan task files: %w", err)
+			}
+			var value map[string]interface{}
+			if content.Valid && json.Unmarshal([]byte(content.String), &value) == nil {
+				for _, key := range []string{"output_file", "results_file"} {
+					if file, ok := value[key].(string); ok && file != "" {
+						files = append(files, file)
+					}
+				}
+			}
+		}
+		rows.Close()
+	}
+
+	tx, err := s.db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	for _, table := range []string{"tsbs_test_results", "tsbs_test_subtasks", "tsbs_test_tasks"} {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE task_id = $1", taskID); err != nil {
+			return nil, fmt.Errorf("failed to delete from %s: %w", table, err)
+		}
+	}
+
+	// 生成的数据和查询文件名由参数决定，其他任务可能仍在使用同一个文件
+	var unreferenced []string
+	seen := make(map[string]bool)
+	for _, file := range files {
+		if seen[file] {
+			continue
+		}
+		seen[file] = true
+		var referenced bool
+		if err := tx.QueryRowContext(ctx, fileReferencedQuery, file).Scan(&referenced); err != nil {
+			return nil, fmt.Errorf("failed to check references of %s: %w", file, err)
+		}
+		if !referenced {
+			unreferenced = append(unreferenced, file)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to delete task: %w", err)
+	}
+
+	return unreferenced, nil
+}
+
+// fileReferencedQuery 检查其余任务和子任务是否仍引用一个文件：作为输出文件、结果文件，
+// 或出现在任务配置中（例如写入任务的数据文件）
+const fileReferencedQuery = `
+	SELECT EXISTS (
+		SELECT 1 FROM tsbs_test_tasks
+		WHERE output_file = $1 OR result->>'output_file' = $1 OR strpos(config::TEXT, $1) > 0
+	) OR EXISTS (
+		SELECT 1 FROM tsbs_test_subtasks
+		WHERE result->>'output_file' = $1 OR result->>'results_file' = $1
+	) OR EXISTS (
+		SELECT 1 FROM tsbs_test_results
+		WHERE metrics->>'results_file' = $1
+	)
+`
+
+// CreateSubtask 在 tsbs_test_subtasks 中创建一个等待执行的子任务
+func (s *TaskService) CreateSubtask(ctx context.Context, taskID, subtaskType string) (string, error) {
+	subtaskID := uuid.New().String()
+
+	query := `
+		INSERT INTO tsbs_test_subtasks (task_id, subtask_id, subtask_type, status, progress)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING subtask_id
+	`
+
+	var result string
+	err := s.db.DB.QueryRowContext(ctx, query, taskID, subtaskID, subtaskType, "pending", 0).Scan(&result)
+	if err != nil {
+		return "", fmt.Errorf("failed to create subtask: %w", err)
+	}
+
+	return result, nil
+}
+
+func (s *TaskService) UpdateSubtaskStatus(ctx context.Context, subtaskID, status string) error {
+	query := `
+		UPDATE tsbs_test_subtasks 
+		SET status = $1, updated_at = NOW()
+		WHERE subtask_id = $2
+	`
+
+	_, err := s.db.DB.ExecContext(ctx, query, status, subtaskID)
+	return err
+}
+
+func (s *TaskService) UpdateSubtaskProgress(ctx context.Context, subtaskID string, progress int) error {
+	query := `
+		UPDATE tsbs_test_subtasks 
+		SET progress = $1, updated_at = NOW()
+		WHERE subtask_id = $2
+	`
+
+	_, err := s.db.DB.ExecContext(ctx, query, progress, subtaskID)
+	return err
+}
+
+// UpdateSubtaskLive 在子任务执行期间更新进度和 result.live 中的实时指标，progress 为 0 时只更新指标
+func (s *TaskService) UpdateSubtaskLive(ctx context.Context, subtaskID string, progress int, live interface{}) error {
+	resultJSON, err := json.Marshal(map[string]interface{}{"live": live})
+	if err != nil {
+		return fmt.Errorf("failed to marshal live metrics: %w", err)
+	}
+
+	if progress <= 0 {
+		query := `
+			UPDATE tsbs_test_subtasks 
+			SET result = $1, updated_at = NOW()
+			WHERE subtask_id = $2
+		`
+		_, err = s.db.DB.ExecContext(ctx, query, resultJSON, subtaskID)
+		return err
+	}
+
+	query := `
+		UPDATE tsbs_test_subtasks 
+		SET progress = $1, result = $2, updated_at = NOW()
+		WHERE subtask_id = $3
+	`
+
+	_, err = s.db.DB.ExecContext(ctx, query, progress, resultJSON, subtaskID)
+	return err
+}
+
+// FinishSubtask 以 status（completed、failed 或 cancelled）结束子任务，子任务表没有
+// 错误信息和输出文件列，它们保存在 result 中
+func (s *TaskService) FinishSubtask(ctx context.Context, subtaskID, status string, result interface{}) error {
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to marshal result: %w", err)
+	}
+
+	query := `
+		UPDATE tsbs_test_subtasks 
+		SET status = $1,
+		    result = $2,
+		    updated_at = NOW()
+		WHERE subtask_id = $3
+	`
+
+	_, err = s.db.DB.ExecContext(ctx, query, status, resultJSON, subtaskID)
+	return err
+}
+
+// SaveResult 将任务的性能指标保存到 tsbs_test_results
+func (s *TaskService) SaveResult(ctx context.Context, taskID, resultType string, metrics interface{}) error {
+	metricsJSON, err := json.Marshal(metrics)
+	if err != nil {
+		return fmt.Errorf("failed to marshal metrics: %w", err)
+	}
+
+	query := `
+		INSERT INTO tsbs_test_results (task_id, result_type, metrics)
+		VALUES ($1, $2, $3)
+	`
+
+	_, err = s.db.DB.ExecContext(ctx, query, taskID, resultType, metricsJSON)
+	return err
+}
+
 func (s *TaskService) GetTask(ctx context.Context, taskID string) (*db.Task, error) {
 	query := `
 		SELECT id, task_id, task_type, status, progress, created_at, updated_at,
@@ -13,7 +13,24 @@ type GenerateDataInput struct {
 	OrderQuantity    *int
 	OutOfOrder       *float64
 	OutOfOrderWindow *string
+	OutOfOrderDelay  *string
 	OutputFile       *string
+
+	Profile             *string
+	ProfileMeasurements *string
+	AnomalyRate         *float64
+	AnomalyTypes        *string
+	AnomalyFile         *string
+
+	DeviceDeathRate   *float64
+	DeviceBirthRate   *float64
+	TagChangeInterval *string
+	TagChangeRatio    *float64
+
+	NullRatio       *float64
+	NullFieldRatios *string
+
+	Cardinality *uint64
 }
 
 type LoadDataInput struct {
@@ -55,6 +72,10 @@ type RunQueriesInput struct {
 	Prepare          *bool
 	QueryType        string
 	ServerConfigName *string
+	// MaxQueries 限制执行的查询数（--max-queries）
+	MaxQueries *int
+	// ExpectedQueries 是查询文件中的查询数，用于计算进度，0 表示未知
+	ExpectedQueries int
 }
 
 // StatusOutput 用于状态查询的输出
@@ -58,6 +58,14 @@ func (l *noFlowBenchmarkRunner) work(b targets.Benchmark, wg *sync.WaitGroup, c
 		atomic.AddUint64(&l.metricCnt, metricCnt)
 		atomic.AddUint64(&l.rowCnt, rowCnt)
 		l.timeToSleep(workerNum, startedWorkAt)
+		l.limitRate(metricCnt, rowCnt)
+	}
+
+	if f, ok := proc.(targets.ProcessorFailureReporter); ok {
+		atomic.AddUint64(&l.failedBatchCnt, f.FailedBatches())
+	}
+	if r, ok := proc.(targets.ProcessorOutOfOrderReporter); ok {
+		l.workerOutOfOrder[workerNum] = r.OutOfOrderStats()
 	}
 
 	// Close proc if necessary
@@ -13,6 +13,7 @@ import (
 
 	"github.com/spf13/pflag"
 	"github.com/timescale/tsbs/load/insertstrategy"
+	"golang.org/x/time/rate"
 )
 
 const (
@@ -47,6 +48,10 @@ type BenchmarkRunnerConfig struct {
 	// deprecated, should not be used in other places other than tsbs_load_xx commands
 	FileName string `yaml:"file" mapstructure:"file" json:"file"`
 	Seed     int64  `yaml:"seed" mapstructure:"seed" json:"seed"`
+
+	// MaxRowRate limits the rows inserted per second by all workers together,
+	// 0 = no limit
+	MaxRowRate uint64 `yaml:"max-row-rate" mapstructure:"max-row-rate" json:"max-row-rate"`
 }
 
 // AddToFlagSet adds command line flags needed by the BenchmarkRunnerConfig to the flag set.
@@ -64,11 +69,14 @@ func (c BenchmarkRunnerConfig) AddToFlagSet(fs *pflag.FlagSet) {
 	fs.String("insert-intervals", "", "Time to wait between each insert, default '' => all workers insert ASAP. '1,2' = worker 1 waits 1s between inserts, worker 2 and others wait 2s")
 	fs.Bool("hash-workers", false, "Whether to consistently hash insert data to the same workers (i.e., the data for a particular host always goes to the same worker)")
 	fs.String("results-file", "", "Write the test results summary json to this file")
+	fs.Uint64("max-row-rate", 0, "Limit the rate of inserted rows (metrics for targets without rows) per second, 0 = no limit")
 }
 
 type BenchmarkRunner interface {
 	DatabaseName() string
 	RunBenchmark(b targets.Benchmark)
+	// Counts returns the number of metrics and rows loaded so far
+	Counts() (metricCount, rowCount uint64)
 }
 
 // CommonBenchmarkRunner is responsible for initializing and storing common
@@ -77,8 +85,15 @@ type CommonBenchmarkRunner struct {
 	BenchmarkRunnerConfig
 	metricCnt      uint64
 	rowCnt         uint64
+	failedBatchCnt uint64
 	initialRand    *rand.Rand
 	sleepRegulator insertstrategy.SleepRegulator
+	rowLimiter     *rate.Limiter
+
+	// outOfOrder merges the out of order stats every worker leaves in
+	// workerOutOfOrder
+	outOfOrder       targets.OutOfOrderStats
+	workerOutOfOrder []targets.OutOfOrderStats
 }
 
 // GetBenchmarkRunnerWithBatchSize returns the singleton CommonBenchmarkRunner for use in a benchmark program
@@ -102,6 +117,9 @@ func GetBenchmarkRunner(c BenchmarkRunnerConfig) BenchmarkRunner {
 			panic(fmt.Sprintf("could not initialize BenchmarkRunner: %v", err))
 		}
 	}
+	if c.MaxRowRate > 0 {
+		loader.rowLimiter = rate.NewLimiter(rate.Limit(c.MaxRowRate), int(loader.BatchSize))
+	}
 	if !c.NoFlowControl {
 		return &loader
 	}
@@ -122,6 +140,11 @@ func (l *CommonBenchmarkRunner) DatabaseName() string {
 	return l.DBName
 }
 
+// Counts returns the number of metrics and rows loaded so far
+func (l *CommonBenchmarkRunner) Counts() (metricCount, rowCount uint64) {
+	return atomic.LoadUint64(&l.metricCnt), atomic.LoadUint64(&l.rowCnt)
+}
+
 func (l *CommonBenchmarkRunner) preRun(b targets.Benchmark) (*sync.WaitGroup, *time.Time) {
 	// Create required DB
 	if b.GetDBCreator() != nil {
@@ -134,6 +157,7 @@ func (l *CommonBenchmarkRunner) preRun(b targets.Benchmark) (*sync.WaitGroup, *t
 	}
 	wg := &sync.WaitGroup{}
 	wg.Add(int(l.Workers))
+	l.workerOutOfOrder = make([]targets.OutOfOrderStats, l.Workers)
 	start := time.Now()
 	return wg, &start
 }
@@ -143,6 +167,9 @@ func (l *CommonBenchmarkRunner) postRun(wg *sync.WaitGroup, start *time.Time) {
 	wg.Wait()
 	end := time.Now()
 	took := end.Sub(*start)
+	for _, s := range l.workerOutOfOrder {
+		l.outOfOrder.Merge(s)
+	}
 	l.summary(took)
 	if l.BenchmarkRunnerConfig.ResultsFile != "" {
 		metricRate := float64(l.metricCnt) / took.Seconds()
@@ -157,6 +184,18 @@ func (l *CommonBenchmarkRunner) saveTestResult(took time.Duration, start time.Ti
 	if l.rowCnt > 0 {
 		totals["rowRate"] = rowRate
 	}
+	totals["failedBatches"] = l.failedBatchCnt
+	if l.outOfOrder.Rows > 0 {
+		outOfOrder, inOrder := l.outOfOrder.MeanBatchTimes()
+		totals["outOfOrder"] = map[string]interface{}{
+			"rows":                   l.outOfOrder.Rows,
+			"maxLatenessMillis":      l.outOfOrder.MaxLateness.Milliseconds(),
+			"batches":                l.outOfOrder.Batches,
+			"meanBatchMillis":        float64(outOfOrder.Nanoseconds()) / 1e6,
+			"inOrderBatches":         l.outOfOrder.InOrderBatches,
+			"meanInOrderBatchMillis": float64(inOrder.Nanoseconds()) / 1e6,
+		}
+	}
 
 	testResult := LoaderTestResult{
 		ResultFormatVersion: LoaderTestResultVersion,
@@ -287,6 +326,14 @@ func (l *CommonBenchmarkRunner) work(b targets.Benchmark, wg *sync.WaitGroup, c
 		atomic.AddUint64(&l.rowCnt, rowCnt)
 		c.sendToScanner()
 		l.timeToSleep(workerNum, startedWorkAt)
+		l.limitRate(metricCnt, rowCnt)
+	}
+
+	if f, ok := proc.(targets.ProcessorFailureReporter); ok {
+		atomic.AddUint64(&l.failedBatchCnt, f.FailedBatches())
+	}
+	if r, ok := proc.(targets.ProcessorOutOfOrderReporter); ok {
+		l.workerOutOfOrder[workerNum] = r.OutOfOrderStats()
 	}
 
 	// Close proc if necessary
@@ -304,6 +351,31 @@ func (l *CommonBenchmarkRunner) timeToSleep(workerNum uint, startedWorkAt time.T
 	}
 }
 
+// limitRate makes the worker wait until the rows of its last batch fit in
+// max-row-rate, metrics are limited instead for targets without rows
+func (l *CommonBenchmarkRunner) limitRate(metricCnt, rowCnt uint64) {
+	if l.rowLimiter == nil {
+		return
+	}
+	n := rowCnt
+	if n == 0 {
+		n = metricCnt
+	}
+	// a reservation can't exceed the burst, so large batches reserve in chunks
+	burst := uint64(l.rowLimiter.Burst())
+	var delay time.Duration
+	now := time.Now()
+	for n > 0 {
+		chunk := n
+		if chunk > burst {
+			chunk = burst
+		}
+		delay = l.rowLimiter.ReserveN(now, int(chunk)).DelayFrom(now)
+		n -= chunk
+	}
+	time.Sleep(delay)
+}
+
 // summary prints the summary of statistics from loading
 func (l *CommonBenchmarkRunner) summary(took time.Duration) {
 	metricRate := float64(l.metricCnt) / took.Seconds()
@@ -313,6 +385,15 @@ func (l *CommonBenchmarkRunner) summary(took time.Duration) {
 		rowRate := float64(l.rowCnt) / float64(took.Seconds())
 		printFn("loaded %d rows in %0.3fsec with %d workers (mean rate %0.2f rows/sec)\n", l.rowCnt, took.Seconds(), l.Workers, rowRate)
 	}
+	if l.failedBatchCnt > 0 {
+		printFn("failed to load %d batches\n", l.failedBatchCnt)
+	}
+	if l.outOfOrder.Rows > 0 {
+		outOfOrder, inOrder := l.outOfOrder.MeanBatchTimes()
+		printFn("loaded %d out of order rows, at most %v late, in %d batches (mean %0.2fms per batch, %0.2fms per batch in order)\n",
+			l.outOfOrder.Rows, l.outOfOrder.MaxLateness, l.outOfOrder.Batches,
+			float64(outOfOrder.Nanoseconds())/1e6, float64(inOrder.Nanoseconds())/1e6)
+	}
 }
 
 // report handles periodic reporting of loading stats
@@ -9,6 +9,8 @@ import (
 	"sync/atomic"
 	"testing"
 	"time"
+
+	"golang.org/x/time/rate"
 )
 
 type testProcessor struct {
@@ -378,13 +380,40 @@ func TestWorkWithSleep(t *testing.T) {
 	}
 }
 
+func TestLimitRate(t *testing.T) {
+	br := &CommonBenchmarkRunner{rowLimiter: rate.NewLimiter(rate.Limit(1000), 100)}
+	start := time.Now()
+	// the first 100 rows are the burst, the next 150 take 150ms
+	br.limitRate(250, 250)
+	if took := time.Since(start); took < 140*time.Millisecond || took > time.Second {
+		t.Errorf("limited 250 rows at 1000 rows/sec in %v, want about 150ms", took)
+	}
+
+	// targets without rows are limited by metrics
+	start = time.Now()
+	br.limitRate(100, 0)
+	if took := time.Since(start); took < 90*time.Millisecond {
+		t.Errorf("limited 100 metrics at 1000 rows/sec in %v, want about 100ms", took)
+	}
+
+	// no limiter, no wait
+	br = &CommonBenchmarkRunner{}
+	start = time.Now()
+	br.limitRate(1000, 1000)
+	if took := time.Since(start); took > 50*time.Millisecond {
+		t.Errorf("unlimited runner waited %v", took)
+	}
+}
+
 func TestSummary(t *testing.T) {
 	cases := []struct {
-		desc    string
-		metrics uint64
-		rows    uint64
-		took    time.Duration
-		want    string
+		desc       string
+		metrics    uint64
+		rows       uint64
+		failed     uint64
+		outOfOrder targets.OutOfOrderStats
+		took       time.Duration
+		want       string
 	}{
 		{
 			desc:    "10 metrics, 0 rows, 1 second",
@@ -414,12 +443,38 @@ func TestSummary(t *testing.T) {
 			took:    time.Second,
 			want:    "\nSummary:\nloaded 10 metrics in 1.000sec with 0 workers (mean rate 10.00 metrics/sec)\nloaded 1 rows in 1.000sec with 0 workers (mean rate 1.00 rows/sec)\n",
 		},
+		{
+			desc:    "failed batches: 10 metrics, 0 rows, 2 failed, 1 second",
+			metrics: 10,
+			rows:    0,
+			failed:  2,
+			took:    time.Second,
+			want:    "\nSummary:\nloaded 10 metrics in 1.000sec with 0 workers (mean rate 10.00 metrics/sec)\nfailed to load 2 batches\n",
+		},
+		{
+			desc:    "out of order rows: 10 metrics, 0 rows, 3 out of order rows, 1 second",
+			metrics: 10,
+			rows:    0,
+			outOfOrder: targets.OutOfOrderStats{
+				Rows:             3,
+				MaxLateness:      time.Minute,
+				Batches:          2,
+				BatchTime:        30 * time.Millisecond,
+				InOrderBatches:   4,
+				InOrderBatchTime: 40 * time.Millisecond,
+			},
+			took: time.Second,
+			want: "\nSummary:\nloaded 10 metrics in 1.000sec with 0 workers (mean rate 10.00 metrics/sec)\n" +
+				"loaded 3 out of order rows, at most 1m0s late, in 2 batches (mean 15.00ms per batch, 10.00ms per batch in order)\n",
+		},
 	}
 
 	for _, c := range cases {
 		br := &CommonBenchmarkRunner{}
 		br.metricCnt = c.metrics
 		br.rowCnt = c.rows
+		br.failedBatchCnt = c.failed
+		br.outOfOrder = c.outOfOrder
 		var b bytes.Buffer
 		printFn = func(s string, args ...interface{}) (n int, err error) {
 			return fmt.Fprintf(&b, s, args...)
@@ -25,9 +25,26 @@ type Tag struct {
 const (
 	errMaxMetricCountValue = "max metric count per host has to be greater than 0"
 	errLogIntervalZero     = "cannot have log interval of 0"
+	errOutOfOrderDelayFmt  = "unknown out of order delay distribution '%s', supports %s and %s"
+	errProfileUseCase      = "value profiles and anomalies only apply to the devops use cases"
+	errChurnUseCase        = "device churn and tag changes only apply to the devops use cases"
+	errChurnRateFmt        = "device death and birth rates must be between 0.0 and 1.0, got %v and %v"
+	errTagChangeRatioFmt   = "tag change ratio must be between 0.0 and 1.0, got %v"
+	errCardinalityUseCase  = "the high cardinality tag only applies to the devops use cases"
+	errCardinalityFmt      = "cardinality must be 0 or at least the scale %d, got %d"
 	defaultLogInterval     = 10 * time.Second
 )
 
+// CardinalityTagKey is the high cardinality tag added to the points of the
+// devops use cases, see -cardinality
+const CardinalityTagKey = "container_id"
+
+// Distributions of the delay of late arriving data points, see -outoforderdelay
+const (
+	OutOfOrderDelayUniform     = "uniform"
+	OutOfOrderDelayExponential = "exponential"
+)
+
 // DataGeneratorConfig is the GeneratorConfig that should be used with a
 // DataGenerator. It includes all the fields from a BaseConfig, as well as some
 // options that are specific to generating the data for database write operations,
@@ -40,6 +57,62 @@ type DataGeneratorConfig struct {
 	InterleavedGroupID    uint          `yaml:"interleaved-generation-group-id" mapstructure:"interleaved-generation-group-id"`
 	InterleavedNumGroups  uint          `yaml:"interleaved-generation-groups" mapstructure:"interleaved-generation-groups"`
 	MaxMetricCountPerHost uint64        `yaml:"max-metric-count" mapstructure:"max-metric-count"`
+
+	// Value profile and anomalies of the devops use cases
+	Profile             string        `yaml:"profile" mapstructure:"profile"`
+	ProfileMeasurements string        `yaml:"profile-measurements" mapstructure:"profile-measurements"`
+	DiurnalAmplitude    float64       `yaml:"diurnal-amplitude" mapstructure:"diurnal-amplitude"`
+	AnomalyRate         float64       `yaml:"anomaly-rate" mapstructure:"anomaly-rate"`
+	AnomalyTypes        string        `yaml:"anomaly-types" mapstructure:"anomaly-types"`
+	AnomalyDuration     time.Duration `yaml:"anomaly-duration" mapstructure:"anomaly-duration"`
+	AnomalyFile         string        `yaml:"anomaly-file" mapstructure:"anomaly-file"`
+
+	// Device churn and tag changes of the devops use cases
+	DeviceDeathRate   float64       `yaml:"device-death-rate" mapstructure:"device-death-rate"`
+	DeviceBirthRate   float64       `yaml:"device-birth-rate" mapstructure:"device-birth-rate"`
+	TagChangeInterval time.Duration `yaml:"tag-change-interval" mapstructure:"tag-change-interval"`
+	TagChangeRatio    float64       `yaml:"tag-change-ratio" mapstructure:"tag-change-ratio"`
+
+	// Sparse fields, the null ratios of the field values
+	NullRatio       float64 `yaml:"null-ratio" mapstructure:"null-ratio"`
+	NullFieldRatios string  `yaml:"null-field-ratios" mapstructure:"null-field-ratios"`
+
+	// Cardinality is the number of distinct values of the high cardinality
+	// tag of the devops use cases
+	Cardinality uint64 `yaml:"cardinality" mapstructure:"cardinality"`
+}
+
+// ProfileConfig returns the value profile set by the profile and anomaly flags
+func (c *DataGeneratorConfig) ProfileConfig() *ProfileConfig {
+	return &ProfileConfig{
+		Features:         splitList(c.Profile),
+		Measurements:     splitList(c.ProfileMeasurements),
+		DiurnalAmplitude: c.DiurnalAmplitude,
+		AnomalyRate:      c.AnomalyRate,
+		AnomalyTypes:     splitList(c.AnomalyTypes),
+		AnomalyDuration:  c.AnomalyDuration,
+		AnomalyFile:      c.AnomalyFile,
+	}
+}
+
+// NullConfig returns the sparse fields set by the null ratio flags
+func (c *DataGeneratorConfig) NullConfig() (*NullConfig, error) {
+	fieldRatios, err := ParseNullFieldRatios(c.NullFieldRatios)
+	if err != nil {
+		return nil, err
+	}
+	return &NullConfig{Ratio: c.NullRatio, FieldRatios: fieldRatios}, nil
+}
+
+// splitList splits a comma separated list, skipping empty entries
+func splitList(s string) []string {
+	var list []string
+	for _, e := range strings.Split(s, ",") {
+		if e = strings.TrimSpace(e); len(e) > 0 {
+			list = append(list, e)
+		}
+	}
+	return list
 }
 
 // Validate checks that the values of the DataGeneratorConfig are reasonable.
@@ -57,12 +130,57 @@ func (c *DataGeneratorConfig) Validate() error {
 		return fmt.Errorf(errLogIntervalZero)
 	}
 
+	if c.OutOfOrderDelay == "" {
+		c.OutOfOrderDelay = OutOfOrderDelayUniform
+	}
+	if c.OutOfOrderDelay != OutOfOrderDelayUniform && c.OutOfOrderDelay != OutOfOrderDelayExponential {
+		return fmt.Errorf(errOutOfOrderDelayFmt, c.OutOfOrderDelay, OutOfOrderDelayUniform, OutOfOrderDelayExponential)
+	}
+
 	err = utils.ValidateGroups(c.InterleavedGroupID, c.InterleavedNumGroups)
 
 	if c.Use == UseCaseDevopsGeneric && c.MaxMetricCountPerHost < 1 {
 		return fmt.Errorf(errMaxMetricCountValue)
 	}
 
+	if pc := c.ProfileConfig(); pc.Enabled() {
+		if c.Use == UseCaseIoT {
+			return fmt.Errorf(errProfileUseCase)
+		}
+		if err := pc.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if c.DeviceDeathRate > 0 || c.DeviceBirthRate > 0 || c.TagChangeInterval > 0 {
+		if c.Use == UseCaseIoT {
+			return fmt.Errorf(errChurnUseCase)
+		}
+		if c.DeviceDeathRate < 0 || c.DeviceDeathRate > 1 || c.DeviceBirthRate < 0 || c.DeviceBirthRate > 1 {
+			return fmt.Errorf(errChurnRateFmt, c.DeviceDeathRate, c.DeviceBirthRate)
+		}
+		if c.TagChangeRatio < 0 || c.TagChangeRatio > 1 {
+			return fmt.Errorf(errTagChangeRatioFmt, c.TagChangeRatio)
+		}
+	}
+
+	if c.Cardinality > 0 {
+		if c.Use == UseCaseIoT {
+			return fmt.Errorf(errCardinalityUseCase)
+		}
+		if c.Cardinality < c.Scale {
+			return fmt.Errorf(errCardinalityFmt, c.Scale, c.Cardinality)
+		}
+	}
+
+	nc, nullErr := c.NullConfig()
+	if nullErr != nil {
+		return nullErr
+	}
+	if err := nc.Validate(); err != nil {
+		return err
+	}
+
 	return err
 }
 
@@ -72,11 +190,26 @@ func (c *DataGeneratorConfig) AddToFlagSet(fs *pflag.FlagSet) {
 	fs.Uint64("initial-scale", 0, "Initial scaling variable specific to the use case (e.g., devices in 'devops'). 0 means to use -scale value")
 	fs.Duration("log-interval", defaultLogInterval, "Duration between data points")
 	fs.Duration("outoforderwindow", 0, "Control the time window range of out of order data and support multiple time unit formats (such as 60s, 1m, 1h, etc.)")
+	fs.String("outoforderdelay", OutOfOrderDelayUniform, "Distribution of the delay of late data points within the out of order window, used by the iot use case (choices: uniform, exponential)")
 	fs.Uint("interleaved-generation-group-id", 0,
 		"Group (0-indexed) to perform round-robin serialization within. Use this to scale up data generation to multiple processes.")
 	fs.Uint("interleaved-generation-groups", 1,
 		"The number of round-robin serialization groups. Use this to scale up data generation to multiple processes.")
 	fs.Uint64("max-metric-count", 100, "Max number of metric fields to generate per host. Used only in devops-generic use-case")
+	fs.String("profile", "", "Comma separated value profile features of the devops use cases (choices: diurnal, skew)")
+	fs.String("profile-measurements", "cpu", "Comma separated measurements the value profile and the anomalies apply to")
+	fs.Float64("diurnal-amplitude", 0.5, "Relative change of the values between the middle and the peak (14:00 UTC) or the low of the day")
+	fs.Float64("anomaly-rate", 0, "Chance of every host to start an anomaly at each of its points, 0 = no anomalies")
+	fs.String("anomaly-types", "spike,step,flatline", "Comma separated types of the injected anomalies")
+	fs.Duration("anomaly-duration", 10*time.Minute, "Duration of the step and flatline anomalies")
+	fs.String("anomaly-file", "", "Write the injected anomalies to this CSV ground truth file")
+	fs.Float64("device-death-rate", 0, "Chance of every host to be decommissioned per hour, 0 = hosts live forever")
+	fs.Float64("device-birth-rate", 0, "Chance of every decommissioned host to be replaced by a newly registered host per hour")
+	fs.Duration("tag-change-interval", 0, "Interval of the tag changes of the hosts (rack, region or service_version), 0 = tags never change")
+	fs.Float64("tag-change-ratio", 0.1, "Ratio of the hosts changing tags at every tag change interval")
+	fs.Float64("null-ratio", 0, "Chance of every field value to be null (left out of the point), 0 = every point carries every field")
+	fs.String("null-field-ratios", "", "Comma separated null ratios of single fields overriding -null-ratio, as field=ratio or measurement.field=ratio")
+	fs.Uint64("cardinality", 0, "Number of distinct values of the high cardinality tag "+CardinalityTagKey+" spread over the hosts of the devops use cases, 0 = no such tag")
 }
 
 const defaultTimeStart = "2016-01-01T00:00:00Z"
@@ -116,6 +249,7 @@ type BaseConfig struct {
 	Orderquantity    int
 	OutOfOrder       float32
 	OutOfOrderWindow time.Duration
+	OutOfOrderDelay  string
 }
 
 func (c *BaseConfig) AddToFlagSet(fs *pflag.FlagSet) {
@@ -0,0 +1,150 @@
+package common
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	"github.com/timescale/tsbs/pkg/data"
+)
+
+// NullConfig leaves out field values of the simulated points, the way real
+// sensors drop readings
+type NullConfig struct {
+	// Ratio is the chance of every field value to be null
+	Ratio float64
+	// FieldRatios override Ratio for single fields, keyed by "field" for the
+	// field in every measurement or by "measurement.field"
+	FieldRatios map[string]float64
+}
+
+// ParseNullFieldRatios parses a comma separated list of field=ratio or
+// measurement.field=ratio entries, see -null-field-ratios
+func ParseNullFieldRatios(s string) (map[string]float64, error) {
+	ratios := map[string]float64{}
+	for _, e := range splitList(s) {
+		kv := strings.SplitN(e, "=", 2)
+		if len(kv) != 2 || len(strings.TrimSpace(kv[0])) == 0 {
+			return nil, fmt.Errorf("invalid null field ratio '%s', expected field=ratio or measurement.field=ratio", e)
+		}
+		ratio, err := strconv.ParseFloat(strings.TrimSpace(kv[1]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid null field ratio '%s': %v", e, err)
+		}
+		ratios[strings.TrimSpace(kv[0])] = ratio
+	}
+	return ratios, nil
+}
+
+// Enabled tells whether any field value may be null
+func (c *NullConfig) Enabled() bool {
+	if c.Ratio > 0 {
+		return true
+	}
+	for _, r := range c.FieldRatios {
+		if r > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks the ranges of the ratios
+func (c *NullConfig) Validate() error {
+	if c.Ratio < 0 || c.Ratio > 1 {
+		return fmt.Errorf("null ratio must be between 0.0 and 1.0, got %v", c.Ratio)
+	}
+	for field, r := range c.FieldRatios {
+		if r < 0 || r > 1 {
+			return fmt.Errorf("null ratio of %s must be between 0.0 and 1.0, got %v", field, r)
+		}
+	}
+	return nil
+}
+
+// Nuller applies a NullConfig to the points of the simulated series
+type Nuller struct {
+	ratio  float64
+	fields map[string]float64
+	// ratios caches the ratio of every field of a measurement, in the order
+	// of the fields of its points
+	ratios map[string][]float64
+	count  uint64
+}
+
+// NewNuller returns nil when the config does not null any value
+func NewNuller(c *NullConfig) (*Nuller, error) {
+	if !c.Enabled() {
+		return nil, nil
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return &Nuller{
+		ratio:  c.Ratio,
+		fields: c.FieldRatios,
+		ratios: map[string][]float64{},
+	}, nil
+}
+
+// fieldRatio returns the chance of a field of measurement to be null, the
+// ratio of the measurement field first, then of the field, then the global
+// one
+func (n *Nuller) fieldRatio(measurement, field string) float64 {
+	if r, ok := n.fields[measurement+"."+field]; ok {
+		return r
+	}
+	if r, ok := n.fields[field]; ok {
+		return r
+	}
+	return n.ratio
+}
+
+// Apply sets the field values of p to nil by their null ratios
+func (n *Nuller) Apply(p *data.Point) {
+	if n == nil {
+		return
+	}
+	measurement := string(p.MeasurementName())
+	keys := p.FieldKeys()
+	ratios, ok := n.ratios[measurement]
+	// the hosts of devops-generic report a varying number of fields
+	if !ok || len(ratios) < len(keys) {
+		ratios = make([]float64, len(keys))
+		for i, k := range keys {
+			ratios[i] = n.fieldRatio(measurement, string(k))
+		}
+		n.ratios[measurement] = ratios
+	}
+	values := p.FieldValues()
+	for i := range values {
+		if ratios[i] > 0 && values[i] != nil && rand.Float64() < ratios[i] {
+			values[i] = nil
+			n.count++
+		}
+	}
+}
+
+// MarkNullable marks the fields of h that may be null
+func (n *Nuller) MarkNullable(h *GeneratedDataHeaders) {
+	if n == nil {
+		return
+	}
+	h.NullableFields = make(map[string][]bool, len(h.FieldKeys))
+	for measurement, keys := range h.FieldKeys {
+		nullable := make([]bool, len(keys))
+		for i, k := range keys {
+			nullable[i] = n.fieldRatio(measurement, k) > 0
+		}
+		h.NullableFields[measurement] = nullable
+	}
+}
+
+// Nulls returns the number of field values set to null
+func (n *Nuller) Nulls() uint64 {
+	if n == nil {
+		return 0
+	}
+	return n.count
+}
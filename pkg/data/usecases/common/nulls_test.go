@@ -0,0 +1,102 @@
+package common
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseNullFieldRatios(t *testing.T) {
+	got, err := ParseNullFieldRatios("usage_user=0.5, cpu.usage_system=1,")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := map[string]float64{"usage_user": 0.5, "cpu.usage_system": 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("incorrect ratios: got %v want %v", got, want)
+	}
+	for _, s := range []string{"usage_user", "=0.5", "usage_user=half"} {
+		if _, err := ParseNullFieldRatios(s); err == nil {
+			t.Errorf("%s: unexpected lack of error", s)
+		}
+	}
+}
+
+func TestNullConfigValidate(t *testing.T) {
+	cases := []struct {
+		desc    string
+		c       NullConfig
+		wantErr bool
+	}{
+		{"global ratio", NullConfig{Ratio: 0.1}, false},
+		{"field ratios", NullConfig{FieldRatios: map[string]float64{"usage_user": 1}}, false},
+		{"ratio above 1", NullConfig{Ratio: 1.5}, true},
+		{"negative field ratio", NullConfig{FieldRatios: map[string]float64{"usage_user": -0.1}}, true},
+	}
+	for _, c := range cases {
+		if err := c.c.Validate(); (err != nil) != c.wantErr {
+			t.Errorf("%s: unexpected error state: got %v want error %v", c.desc, err, c.wantErr)
+		}
+	}
+}
+
+func TestNewNullerDisabled(t *testing.T) {
+	n, err := NewNuller(&NullConfig{FieldRatios: map[string]float64{"usage_user": 0}})
+	if err != nil || n != nil {
+		t.Fatalf("unexpected nuller without null ratios: %v, %v", n, err)
+	}
+	// a nil nuller keeps every field
+	p := profilePoint("cpu", profileStart)
+	n.Apply(p)
+	if p.FieldValues()[0] == nil || p.FieldValues()[1] == nil {
+		t.Errorf("nil nuller left out a field: %v", p.FieldValues())
+	}
+	h := &GeneratedDataHeaders{FieldKeys: map[string][]string{"cpu": {"usage_user"}}}
+	n.MarkNullable(h)
+	if h.NullableFields != nil {
+		t.Errorf("nil nuller marked nullable fields: %v", h.NullableFields)
+	}
+}
+
+func TestNullerApply(t *testing.T) {
+	n, err := NewNuller(&NullConfig{FieldRatios: map[string]float64{"usage_user": 1, "mem.usage_system": 1}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cpu := profilePoint("cpu", profileStart)
+	n.Apply(cpu)
+	if cpu.FieldValues()[0] != nil || cpu.FieldValues()[1] == nil {
+		t.Errorf("incorrect cpu fields: got %v want usage_user only left out", cpu.FieldValues())
+	}
+	mem := profilePoint("mem", profileStart)
+	n.Apply(mem)
+	if mem.FieldValues()[0] != nil || mem.FieldValues()[1] != nil {
+		t.Errorf("incorrect mem fields: got %v want both left out", mem.FieldValues())
+	}
+	if got := n.Nulls(); got != 3 {
+		t.Errorf("incorrect number of nulls: got %d want 3", got)
+	}
+
+	h := &GeneratedDataHeaders{FieldKeys: map[string][]string{
+		"cpu": {"usage_user", "usage_system"},
+		"mem": {"usage_user", "usage_system"},
+	}}
+	n.MarkNullable(h)
+	want := map[string][]bool{"cpu": {true, false}, "mem": {true, true}}
+	if !reflect.DeepEqual(h.NullableFields, want) {
+		t.Errorf("incorrect nullable fields: got %v want %v", h.NullableFields, want)
+	}
+}
+
+func TestNullerRatio(t *testing.T) {
+	n, err := NewNuller(&NullConfig{Ratio: 0.3})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	const points = 10000
+	for i := 0; i < points; i++ {
+		n.Apply(profilePoint("cpu", profileStart))
+	}
+	if ratio := float64(n.Nulls()) / (2 * points); ratio < 0.27 || ratio > 0.33 {
+		t.Errorf("incorrect ratio of nulls: got %v want about 0.3", ratio)
+	}
+}
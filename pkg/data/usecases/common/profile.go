@@ -0,0 +1,318 @@
+package common
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/timescale/tsbs/internal/utils"
+	"github.com/timescale/tsbs/pkg/data"
+)
+
+// Value profile features, see -profile
+const (
+	ProfileDiurnal = "diurnal"
+	ProfileSkew    = "skew"
+)
+
+// Anomaly types, see -anomaly-types
+const (
+	AnomalySpike    = "spike"
+	AnomalyStep     = "step"
+	AnomalyFlatline = "flatline"
+)
+
+const (
+	// diurnalPeakHour is the UTC hour of day the diurnal cycle peaks at, its
+	// low is twelve hours later
+	diurnalPeakHour = 14
+	// skewStdDev is the standard deviation of the log of the per series
+	// baseline factor
+	skewStdDev = 0.4
+	// spikeFactor and stepFactor scale the fields that are not percentages,
+	// which are set to 100 by a spike and raised by stepPercent by a step
+	spikeFactor = 10
+	stepFactor  = 3
+	stepPercent = 50
+)
+
+var (
+	profileFeatures = []string{ProfileDiurnal, ProfileSkew}
+	anomalyTypes    = []string{AnomalySpike, AnomalyStep, AnomalyFlatline}
+)
+
+// ProfileConfig shapes the values of simulated fields with a diurnal cycle, a
+// per series baseline skew and injected anomalies
+type ProfileConfig struct {
+	// Features are the profile features, among diurnal and skew
+	Features []string
+	// Measurements are the measurements the profile applies to
+	Measurements []string
+	// DiurnalAmplitude is the relative change of the values between the
+	// middle and the peak or the low of the day
+	DiurnalAmplitude float64
+	// AnomalyRate is the chance of every series to start an anomaly at each
+	// of its points
+	AnomalyRate     float64
+	AnomalyTypes    []string
+	AnomalyDuration time.Duration
+	// AnomalyFile is the ground truth file the anomalies are recorded to
+	AnomalyFile string
+}
+
+// Enabled tells whether the profile changes any value
+func (c *ProfileConfig) Enabled() bool {
+	return len(c.Features) > 0 || c.AnomalyRate > 0
+}
+
+// Validate checks the features, the anomaly types and the ranges
+func (c *ProfileConfig) Validate() error {
+	for _, f := range c.Features {
+		if !utils.IsIn(f, profileFeatures) {
+			return fmt.Errorf("unknown profile feature '%s', supports %s", f, strings.Join(profileFeatures, ", "))
+		}
+	}
+	for _, t := range c.AnomalyTypes {
+		if !utils.IsIn(t, anomalyTypes) {
+			return fmt.Errorf("unknown anomaly type '%s', supports %s", t, strings.Join(anomalyTypes, ", "))
+		}
+	}
+	if c.DiurnalAmplitude < 0 || c.DiurnalAmplitude > 1 {
+		return fmt.Errorf("diurnal amplitude must be between 0.0 and 1.0, got %v", c.DiurnalAmplitude)
+	}
+	if c.AnomalyRate < 0 || c.AnomalyRate > 1 {
+		return fmt.Errorf("anomaly rate must be between 0.0 and 1.0, got %v", c.AnomalyRate)
+	}
+	if c.AnomalyRate > 0 && len(c.AnomalyTypes) == 0 {
+		return fmt.Errorf("anomaly rate needs at least one anomaly type")
+	}
+	return nil
+}
+
+// anomaly is an anomaly injected into a field of a series from start to end,
+// the same time for spikes
+type anomaly struct {
+	kind  string
+	field int
+	start time.Time
+	end   time.Time
+	// level is the value a flat-line holds
+	level float64
+}
+
+// seriesProfile is the state of the profile of a series, e.g. a host
+type seriesProfile struct {
+	skew      float64
+	anomalies map[string]*anomaly
+}
+
+// Profiler applies a ProfileConfig to the points of the simulated series and
+// writes the injected anomalies to the ground truth file. The points of a
+// series must come in time order.
+type Profiler struct {
+	diurnal      bool
+	skew         bool
+	amplitude    float64
+	measurements map[string]bool
+	rate         float64
+	types        []string
+	duration     time.Duration
+
+	series map[string]*seriesProfile
+	file   *os.File
+	truth  *bufio.Writer
+	count  uint64
+}
+
+// NewProfiler returns nil when the config does not change any value
+func NewProfiler(c *ProfileConfig) (*Profiler, error) {
+	if !c.Enabled() {
+		return nil, nil
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	p := &Profiler{
+		diurnal:      utils.IsIn(ProfileDiurnal, c.Features),
+		skew:         utils.IsIn(ProfileSkew, c.Features),
+		amplitude:    c.DiurnalAmplitude,
+		measurements: map[string]bool{},
+		rate:         c.AnomalyRate,
+		types:        c.AnomalyTypes,
+		duration:     c.AnomalyDuration,
+		series:       map[string]*seriesProfile{},
+	}
+	for _, m := range c.Measurements {
+		p.measurements[m] = true
+	}
+	if c.AnomalyRate > 0 && len(c.AnomalyFile) > 0 {
+		f, err := os.Create(c.AnomalyFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot create anomaly file %s: %v", c.AnomalyFile, err)
+		}
+		p.file = f
+		p.truth = bufio.NewWriter(f)
+		p.truth.WriteString("series,measurement,field,type,start,end\n")
+	}
+	return p, nil
+}
+
+// Apply shapes the field values of p, a point of series
+func (pr *Profiler) Apply(series string, p *data.Point) {
+	if pr == nil {
+		return
+	}
+	measurement := string(p.MeasurementName())
+	if !pr.measurements[measurement] {
+		return
+	}
+	sp, ok := pr.series[series]
+	if !ok {
+		sp = &seriesProfile{skew: 1, anomalies: map[string]*anomaly{}}
+		if pr.skew {
+			sp.skew = math.Exp(rand.NormFloat64() * skewStdDev)
+		}
+		pr.series[series] = sp
+	}
+
+	ts := *p.Timestamp()
+	factor := sp.skew
+	if pr.diurnal {
+		day := float64(ts.UTC().Sub(ts.UTC().Truncate(24*time.Hour))) / float64(24*time.Hour)
+		factor *= 1 + pr.amplitude*math.Cos(2*math.Pi*(day-diurnalPeakHour/24.0))
+	}
+
+	keys := p.FieldKeys()
+	values := p.FieldValues()
+	for i, v := range values {
+		f, ok := toFloat(v)
+		if !ok {
+			continue
+		}
+		values[i] = fromFloat(v, clampField(measurement, string(keys[i]), f*factor))
+	}
+
+	a := sp.anomalies[measurement]
+	if a != nil && ts.After(a.end) {
+		a = nil
+		delete(sp.anomalies, measurement)
+	}
+	if a == nil && pr.rate > 0 && len(values) > 0 && rand.Float64() < pr.rate {
+		a = pr.startAnomaly(series, measurement, ts, keys, values)
+		sp.anomalies[measurement] = a
+	}
+	if a == nil {
+		return
+	}
+	f, ok := toFloat(values[a.field])
+	if !ok {
+		return
+	}
+	key := string(keys[a.field])
+	percent := isPercentField(measurement, key)
+	switch a.kind {
+	case AnomalySpike:
+		if percent {
+			f = 100
+		} else {
+			f *= spikeFactor
+		}
+	case AnomalyStep:
+		if percent {
+			f += stepPercent
+		} else {
+			f *= stepFactor
+		}
+	case AnomalyFlatline:
+		f = a.level
+	}
+	values[a.field] = fromFloat(values[a.field], clampField(measurement, key, f))
+}
+
+// startAnomaly picks the type and the field of a new anomaly and records it
+func (pr *Profiler) startAnomaly(series, measurement string, ts time.Time, keys [][]byte, values []interface{}) *anomaly {
+	a := &anomaly{
+		kind:  pr.types[rand.Intn(len(pr.types))],
+		field: rand.Intn(len(values)),
+		start: ts,
+		end:   ts,
+	}
+	if a.kind != AnomalySpike {
+		a.end = ts.Add(pr.duration)
+	}
+	a.level, _ = toFloat(values[a.field])
+	pr.count++
+	if pr.truth != nil {
+		fmt.Fprintf(pr.truth, "%s,%s,%s,%s,%s,%s\n", series, measurement, keys[a.field], a.kind,
+			a.start.UTC().Format(time.RFC3339Nano), a.end.UTC().Format(time.RFC3339Nano))
+	}
+	return a
+}
+
+// Anomalies returns the number of injected anomalies
+func (pr *Profiler) Anomalies() uint64 {
+	if pr == nil {
+		return 0
+	}
+	return pr.count
+}
+
+// Close writes the buffered anomalies and closes the ground truth file
+func (pr *Profiler) Close() error {
+	if pr == nil || pr.file == nil {
+		return nil
+	}
+	if err := pr.truth.Flush(); err != nil {
+		return err
+	}
+	return pr.file.Close()
+}
+
+// isPercentField tells whether a field is a percentage, bounded by 100: the
+// cpu usage fields and the *_percent fields
+func isPercentField(measurement, field string) bool {
+	return measurement == "cpu" || strings.HasSuffix(field, "_percent")
+}
+
+func clampField(measurement, field string, f float64) float64 {
+	if f < 0 {
+		return 0
+	}
+	if f > 100 && isPercentField(measurement, field) {
+		return 100
+	}
+	return f
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch x := v.(type) {
+	case float64:
+		return x, true
+	case float32:
+		return float64(x), true
+	case int64:
+		return float64(x), true
+	case int:
+		return float64(x), true
+	default:
+		return 0, false
+	}
+}
+
+// fromFloat converts f to the type of the original value v
+func fromFloat(v interface{}, f float64) interface{} {
+	switch v.(type) {
+	case float32:
+		return float32(f)
+	case int64:
+		return int64(f)
+	case int:
+		return int(f)
+	default:
+		return f
+	}
+}
@@ -0,0 +1,199 @@
+package common
+
+import (
+	"encoding/csv"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/timescale/tsbs/pkg/data"
+)
+
+var profileStart = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// profilePoint returns a point of measurement with a float64 and an int64
+// field set to 50
+func profilePoint(measurement string, ts time.Time) *data.Point {
+	p := data.NewPoint()
+	p.SetMeasurementName([]byte(measurement))
+	p.SetTimestamp(&ts)
+	p.AppendField([]byte("usage_user"), float64(50))
+	p.AppendField([]byte("usage_system"), int64(50))
+	return p
+}
+
+func TestProfileConfigValidate(t *testing.T) {
+	cases := []struct {
+		desc    string
+		c       ProfileConfig
+		wantErr bool
+	}{
+		{"diurnal and skew", ProfileConfig{Features: []string{ProfileDiurnal, ProfileSkew}, DiurnalAmplitude: 0.5}, false},
+		{"anomalies", ProfileConfig{AnomalyRate: 0.01, AnomalyTypes: []string{AnomalySpike, AnomalyFlatline}}, false},
+		{"unknown feature", ProfileConfig{Features: []string{"weekly"}}, true},
+		{"unknown anomaly type", ProfileConfig{AnomalyRate: 0.01, AnomalyTypes: []string{"dip"}}, true},
+		{"amplitude above 1", ProfileConfig{Features: []string{ProfileDiurnal}, DiurnalAmplitude: 1.5}, true},
+		{"rate above 1", ProfileConfig{AnomalyRate: 2, AnomalyTypes: []string{AnomalySpike}}, true},
+		{"rate without types", ProfileConfig{AnomalyRate: 0.01}, true},
+	}
+	for _, c := range cases {
+		if err := c.c.Validate(); (err != nil) != c.wantErr {
+			t.Errorf("%s: unexpected error state: got %v want error %v", c.desc, err, c.wantErr)
+		}
+	}
+}
+
+func TestNewProfilerDisabled(t *testing.T) {
+	p, err := NewProfiler(&ProfileConfig{Measurements: []string{"cpu"}})
+	if err != nil || p != nil {
+		t.Fatalf("unexpected profiler without features nor anomalies: %v, %v", p, err)
+	}
+	// a nil profiler keeps the values
+	point := profilePoint("cpu", profileStart)
+	p.Apply("host_0", point)
+	if got := point.FieldValues()[0]; got != float64(50) {
+		t.Errorf("nil profiler changed a value: got %v", got)
+	}
+	if err := p.Close(); err != nil {
+		t.Errorf("unexpected error closing a nil profiler: %v", err)
+	}
+}
+
+func TestProfilerDiurnal(t *testing.T) {
+	p, err := NewProfiler(&ProfileConfig{
+		Features:         []string{ProfileDiurnal},
+		Measurements:     []string{"cpu"},
+		DiurnalAmplitude: 0.5,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	peak := profilePoint("cpu", profileStart.Add(diurnalPeakHour*time.Hour))
+	p.Apply("host_0", peak)
+	low := profilePoint("cpu", profileStart.Add((diurnalPeakHour-12)*time.Hour))
+	p.Apply("host_0", low)
+	if got := peak.FieldValues()[0].(float64); math.Abs(got-75) > 1e-9 {
+		t.Errorf("incorrect value at the peak: got %v want 75", got)
+	}
+	if got := low.FieldValues()[0].(float64); math.Abs(got-25) > 1e-9 {
+		t.Errorf("incorrect value at the low: got %v want 25", got)
+	}
+	if got, ok := peak.FieldValues()[1].(int64); !ok || got != 75 {
+		t.Errorf("incorrect int64 value at the peak: got %v want int64 75", peak.FieldValues()[1])
+	}
+
+	// other measurements keep their values
+	mem := profilePoint("mem", profileStart.Add(diurnalPeakHour*time.Hour))
+	p.Apply("host_0", mem)
+	if got := mem.FieldValues()[0]; got != float64(50) {
+		t.Errorf("profile applied to a measurement not profiled: got %v", got)
+	}
+}
+
+func TestProfilerSkew(t *testing.T) {
+	p, err := NewProfiler(&ProfileConfig{
+		Features:     []string{ProfileSkew},
+		Measurements: []string{"mem"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	factors := map[float64]bool{}
+	for _, host := range []string{"host_0", "host_1", "host_2", "host_3"} {
+		first := profilePoint("mem", profileStart)
+		p.Apply(host, first)
+		second := profilePoint("mem", profileStart.Add(time.Hour))
+		p.Apply(host, second)
+		if first.FieldValues()[0] != second.FieldValues()[0] {
+			t.Errorf("%s: skew changed between points: %v and %v", host, first.FieldValues()[0], second.FieldValues()[0])
+		}
+		factors[first.FieldValues()[0].(float64)] = true
+	}
+	if len(factors) < 2 {
+		t.Errorf("all hosts got the same baseline: %v", factors)
+	}
+}
+
+func TestProfilerAnomalies(t *testing.T) {
+	cases := []struct {
+		kind string
+		// want is the value at the start of the anomaly, wantNext the value
+		// of a point of 20 ten seconds later
+		want     float64
+		wantNext float64
+		// wantCount is the number of anomalies after both points, a spike
+		// ends right away so the next point starts another one
+		wantCount uint64
+	}{
+		{AnomalySpike, 100, 100, 2},
+		{AnomalyStep, 100, 70, 1},
+		{AnomalyFlatline, 50, 50, 1},
+	}
+	for _, c := range cases {
+		file := filepath.Join(t.TempDir(), "anomalies.csv")
+		p, err := NewProfiler(&ProfileConfig{
+			Measurements:    []string{"cpu"},
+			AnomalyRate:     1,
+			AnomalyTypes:    []string{c.kind},
+			AnomalyDuration: time.Minute,
+			AnomalyFile:     file,
+		})
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", c.kind, err)
+		}
+
+		point := singleFieldPoint(profileStart, 50)
+		p.Apply("host_0", point)
+		if got := point.FieldValues()[0].(float64); got != c.want {
+			t.Errorf("%s: incorrect anomalous value: got %v want %v", c.kind, got, c.want)
+		}
+		next := singleFieldPoint(profileStart.Add(10*time.Second), 20)
+		p.Apply("host_0", next)
+		if got := next.FieldValues()[0].(float64); got != c.wantNext {
+			t.Errorf("%s: incorrect value of the next point: got %v want %v", c.kind, got, c.wantNext)
+		}
+		if got := p.Anomalies(); got != c.wantCount {
+			t.Errorf("%s: incorrect number of anomalies: got %d want %d", c.kind, got, c.wantCount)
+		}
+
+		if err := p.Close(); err != nil {
+			t.Fatalf("%s: unexpected error closing: %v", c.kind, err)
+		}
+		f, err := os.Open(file)
+		if err != nil {
+			t.Fatalf("%s: cannot open the anomaly file: %v", c.kind, err)
+		}
+		records, err := csv.NewReader(f).ReadAll()
+		f.Close()
+		if err != nil {
+			t.Fatalf("%s: cannot read the anomaly file: %v", c.kind, err)
+		}
+		if got := uint64(len(records)); got != c.wantCount+1 {
+			t.Fatalf("%s: incorrect number of records: got %d want %d", c.kind, got, c.wantCount+1)
+		}
+		wantEnd := profileStart
+		if c.kind != AnomalySpike {
+			wantEnd = profileStart.Add(time.Minute)
+		}
+		want := []string{"host_0", "cpu", "usage_user", c.kind,
+			profileStart.Format(time.RFC3339Nano), wantEnd.Format(time.RFC3339Nano)}
+		if !reflect.DeepEqual(records[1], want) {
+			t.Errorf("%s: incorrect ground truth record: got %v want %v", c.kind, records[1], want)
+		}
+	}
+}
+
+// singleFieldPoint returns a cpu point with a single usage_user field, the
+// field every anomaly goes to
+func singleFieldPoint(ts time.Time, v float64) *data.Point {
+	p := data.NewPoint()
+	p.SetMeasurementName([]byte("cpu"))
+	p.SetTimestamp(&ts)
+	p.AppendField([]byte("usage_user"), v)
+	return p
+}
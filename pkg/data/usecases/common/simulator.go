@@ -26,6 +26,16 @@ type BaseSimulatorConfig struct {
 	GeneratorConstructor func(i int, start time.Time) Generator
 	// Orderquantity is the batch size for generating data points
 	Orderquantity int
+
+	// OutOfOrder is the ratio of data points that arrive late, delayed by up
+	// to OutOfOrderWindow following the OutOfOrderDelay distribution. Only
+	// the IoT Simulator applies them.
+	OutOfOrder       float64
+	OutOfOrderWindow time.Duration
+	OutOfOrderDelay  string
+
+	// Nuller leaves out field values, nil keeps every field
+	Nuller *Nuller
 }
 
 func calculateEpochs(duration time.Duration, interval time.Duration) uint64 {
@@ -81,6 +91,63 @@ type GeneratedDataHeaders struct {
 	TagTypes  []string
 	TagKeys   []string
 	FieldKeys map[string][]string
+	// FieldTypes holds the type of each field in FieldKeys, per measurement.
+	// Only targets with typed schemas (e.g. kwdb) write it out.
+	FieldTypes map[string][]string
+	// MeasurementTagKeys and MeasurementTagTypes hold the tags a measurement
+	// appends on top of TagKeys (e.g. path and fstype for devops disk).
+	MeasurementTagKeys  map[string][]string
+	MeasurementTagTypes map[string][]string
+	// NullableFields marks the fields in FieldKeys whose values may be
+	// missing from the points, per measurement. It is nil when every point
+	// carries every field.
+	NullableFields map[string][]bool
+}
+
+// AddMeasurement samples the supplied measurement once and records its field
+// keys, field types and measurement specific tags in the headers.
+func (h *GeneratedDataHeaders) AddMeasurement(sm SimulatedMeasurement) {
+	if h.FieldKeys == nil {
+		h.FieldKeys = make(map[string][]string)
+	}
+	if h.FieldTypes == nil {
+		h.FieldTypes = make(map[string][]string)
+	}
+	if h.MeasurementTagKeys == nil {
+		h.MeasurementTagKeys = make(map[string][]string)
+		h.MeasurementTagTypes = make(map[string][]string)
+	}
+
+	point := data.NewPoint()
+	sm.ToPoint(point)
+	name := string(point.MeasurementName())
+
+	fieldKeys := point.FieldKeys()
+	fieldValues := point.FieldValues()
+	keys := make([]string, len(fieldKeys))
+	types := make([]string, len(fieldKeys))
+	for i, k := range fieldKeys {
+		keys[i] = string(k)
+		if fieldValues[i] != nil {
+			types[i] = reflect.TypeOf(fieldValues[i]).String()
+		}
+	}
+	h.FieldKeys[name] = keys
+	h.FieldTypes[name] = types
+
+	tagKeys := point.TagKeys()
+	if len(tagKeys) == 0 {
+		return
+	}
+	tagValues := point.TagValues()
+	keys = make([]string, len(tagKeys))
+	types = make([]string, len(tagKeys))
+	for i, k := range tagKeys {
+		keys[i] = string(k)
+		types[i] = reflect.TypeOf(tagValues[i]).String()
+	}
+	h.MeasurementTagKeys[name] = keys
+	h.MeasurementTagTypes[name] = types
 }
 
 // Simulator simulates a use case.
@@ -242,11 +309,18 @@ func (s *BaseSimulator) TagTypes() []string {
 }
 
 func (s *BaseSimulator) Headers() *GeneratedDataHeaders {
-	return &GeneratedDataHeaders{
-		TagTypes:  s.TagTypes(),
-		TagKeys:   s.TagKeys(),
-		FieldKeys: s.Fields(),
+	if len(s.generators) <= 0 {
+		panic("cannot get headers because no Generators added")
+	}
+
+	headers := &GeneratedDataHeaders{
+		TagTypes: s.TagTypes(),
+		TagKeys:  s.TagKeys(),
+	}
+	for _, sm := range s.generators[0].Measurements() {
+		headers.AddMeasurement(sm)
 	}
+	return headers
 }
 
 // TODO(rrk) - Can probably turn this logic into a separate interface and implement other
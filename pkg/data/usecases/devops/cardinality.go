@@ -0,0 +1,51 @@
+package devops
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/timescale/tsbs/pkg/data/usecases/common"
+)
+
+// cardinalityValuePrefix prefixes the values of the high cardinality tag
+const cardinalityValuePrefix = "container_"
+
+var cardinalityTagKey = []byte(common.CardinalityTagKey)
+
+// cardinality spreads the values of the high cardinality tag over the hosts.
+// The value k belongs to the host at index k % hosts and every host rotates
+// through its values, one per reading, so all the values show up once the
+// hosts made as many readings as they have values.
+type cardinality struct {
+	values   uint64
+	hosts    uint64
+	start    time.Time
+	interval time.Duration
+}
+
+// newCardinality returns nil when the points get no high cardinality tag.
+// Every host gets at least one value.
+func newCardinality(values uint64, hosts int, start time.Time, interval time.Duration) *cardinality {
+	if values == 0 {
+		return nil
+	}
+	if values < uint64(hosts) {
+		values = uint64(hosts)
+	}
+	return &cardinality{
+		values:   values,
+		hosts:    uint64(hosts),
+		start:    start,
+		interval: interval,
+	}
+}
+
+// value returns the value of the tag of the reading of the host at index i
+// at ts
+func (c *cardinality) value(i int, ts time.Time) string {
+	host := uint64(i)
+	// the number of values of the host
+	n := (c.values - host + c.hosts - 1) / c.hosts
+	reading := uint64(ts.Sub(c.start) / c.interval)
+	return cardinalityValuePrefix + strconv.FormatUint(host+(reading%n)*c.hosts, 10)
+}
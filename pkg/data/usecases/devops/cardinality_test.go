@@ -0,0 +1,84 @@
+package devops
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/timescale/tsbs/pkg/data"
+	"github.com/timescale/tsbs/pkg/data/usecases/common"
+)
+
+func TestNewCardinalityDisabled(t *testing.T) {
+	if c := newCardinality(0, 10, testTime, time.Minute); c != nil {
+		t.Errorf("high cardinality tag enabled without cardinality: %v", c)
+	}
+	// every host gets a value of its own
+	if c := newCardinality(3, 10, testTime, time.Minute); c.values != 10 {
+		t.Errorf("incorrect number of values: got %d want 10", c.values)
+	}
+}
+
+func TestCardinalityValue(t *testing.T) {
+	c := newCardinality(7, 3, testTime, time.Minute)
+	cases := []struct {
+		host    int
+		reading int
+		want    string
+	}{
+		{0, 0, "container_0"},
+		{0, 1, "container_3"},
+		{0, 2, "container_6"},
+		{0, 3, "container_0"},
+		{1, 0, "container_1"},
+		{1, 1, "container_4"},
+		{1, 2, "container_1"},
+		{2, 1, "container_5"},
+	}
+	for _, tc := range cases {
+		ts := testTime.Add(time.Duration(tc.reading) * time.Minute)
+		if got := c.value(tc.host, ts); got != tc.want {
+			t.Errorf("host %d reading %d: incorrect value: got %s want %s", tc.host, tc.reading, got, tc.want)
+		}
+	}
+}
+
+func TestCardinalitySimulator(t *testing.T) {
+	c := &CPUOnlySimulatorConfig{
+		Start:           testTime,
+		End:             testTime.Add(10 * time.Minute),
+		InitHostCount:   3,
+		HostCount:       3,
+		HostConstructor: NewHostCPUOnly,
+
+		Cardinality: 7,
+	}
+	s := c.NewSimulator(time.Minute, 0).(*CPUOnlySimulator)
+	keys := s.TagKeys()
+	if keys[len(keys)-1] != common.CardinalityTagKey || len(s.TagTypes()) != len(keys) {
+		t.Fatalf("incorrect tag keys: got %v", keys)
+	}
+
+	values := map[string]bool{}
+	for p := data.NewPoint(); !s.Finished(); p.Reset() {
+		if !s.Next(p) {
+			continue
+		}
+		if len(p.TagKeys()) != len(keys) {
+			t.Fatalf("incorrect number of tags: got %d want %d", len(p.TagKeys()), len(keys))
+		}
+		host := p.GetTagValue(MachineTagKeys[0]).(string)
+		value := p.GetTagValue(cardinalityTagKey).(string)
+		// the values of a host are the ones at its index modulo the hosts
+		var h, k int
+		fmt.Sscanf(host, "host_%d", &h)
+		fmt.Sscanf(value, cardinalityValuePrefix+"%d", &k)
+		if k%3 != h {
+			t.Errorf("value %s of the wrong host %s", value, host)
+		}
+		values[value] = true
+	}
+	if len(values) != 7 {
+		t.Errorf("incorrect number of values: got %d want 7", len(values))
+	}
+}
@@ -0,0 +1,127 @@
+package devops
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/timescale/tsbs/pkg/data/usecases/common"
+)
+
+// Kinds of tag changes of a host
+const (
+	tagChangeRack = iota
+	tagChangeRegion
+	tagChangeServiceVersion
+	tagChangeKinds
+)
+
+// churn decommissions hosts, registers new hosts in their place and moves
+// hosts between racks and regions or upgrades their service over time. The
+// changes of a host apply from its next reading on.
+type churn struct {
+	// death and birth are the chances per reading of a live host to be
+	// decommissioned and of a decommissioned one to be replaced
+	death float64
+	birth float64
+	// every tagInterval, a tagRatio of the hosts changes tags
+	tagInterval time.Duration
+	tagRatio    float64
+
+	start     time.Time
+	construct func(ctx *HostContext) Host
+	nextID    int
+	dead      []bool
+	period    []int64
+
+	deaths     uint64
+	births     uint64
+	tagChanges uint64
+}
+
+// newChurn returns nil when the hosts neither churn nor change tags. The
+// rates of c are per hour and get scaled to the interval of the readings.
+func newChurn(c *commonDevopsSimulatorConfig, interval time.Duration, hosts int) *churn {
+	if c.DeathRate <= 0 && c.BirthRate <= 0 && c.TagChangeInterval <= 0 {
+		return nil
+	}
+	perReading := func(rate float64) float64 {
+		if p := rate * float64(interval) / float64(time.Hour); p < 1 {
+			return p
+		}
+		return 1
+	}
+	return &churn{
+		death:       perReading(c.DeathRate),
+		birth:       perReading(c.BirthRate),
+		tagInterval: c.TagChangeInterval,
+		tagRatio:    c.TagChangeRatio,
+		start:       c.Start,
+		construct:   c.HostConstructor,
+		nextID:      hosts,
+		dead:        make([]bool, hosts),
+		period:      make([]int64, hosts),
+	}
+}
+
+// alive tells whether the host at index i reports readings
+func (c *churn) alive(i int) bool {
+	return c == nil || !c.dead[i]
+}
+
+// update rolls the churn of the host at index i after its reading at ts
+func (c *churn) update(hosts []Host, i int, ts time.Time) {
+	if c == nil {
+		return
+	}
+	if c.dead[i] {
+		if c.birth > 0 && rand.Float64() < c.birth {
+			old := &hosts[i]
+			hosts[i] = c.construct(&HostContext{c.nextID, ts, old.GenericMetricCount, old.EpochsToLive})
+			c.nextID++
+			c.dead[i] = false
+			if c.tagInterval > 0 {
+				c.period[i] = int64(ts.Sub(c.start) / c.tagInterval)
+			}
+			c.births++
+		}
+		return
+	}
+	if c.death > 0 && rand.Float64() < c.death {
+		c.dead[i] = true
+		c.deaths++
+		return
+	}
+	if c.tagInterval <= 0 {
+		return
+	}
+	if period := int64(ts.Sub(c.start) / c.tagInterval); period > c.period[i] {
+		c.period[i] = period
+		if rand.Float64() < c.tagRatio {
+			changeTags(&hosts[i])
+			c.tagChanges++
+		}
+	}
+}
+
+// changeTags moves the host to another rack or region, or changes the
+// version of its service
+func changeTags(h *Host) {
+	switch rand.Intn(tagChangeKinds) {
+	case tagChangeRack:
+		for rack := h.Rack; rack == h.Rack; {
+			h.Rack = getStringRandomInt(machineRackChoicesPerDatacenter)
+		}
+	case tagChangeRegion:
+		r := randomRegionSliceChoice(regions)
+		for r.Name == h.Region {
+			r = randomRegionSliceChoice(regions)
+		}
+		h.Region = r.Name
+		h.Datacenter = common.RandomStringSliceChoice(r.Datacenters)
+		h.Rack = getStringRandomInt(machineRackChoicesPerDatacenter)
+	case tagChangeServiceVersion:
+		for version := h.ServiceVersion; version == h.ServiceVersion; {
+			h.ServiceVersion = getStringRandomInt(machineServiceVersionChoices)
+		}
+	}
+}
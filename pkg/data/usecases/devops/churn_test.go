@@ -0,0 +1,114 @@
+package devops
+
+import (
+	"testing"
+	"time"
+
+	"github.com/timescale/tsbs/pkg/data"
+)
+
+func churnConf(death, birth float64, tagInterval time.Duration, tagRatio float64) *DevopsSimulatorConfig {
+	return &DevopsSimulatorConfig{
+		Start:           testTime,
+		End:             testTime.Add(time.Hour),
+		InitHostCount:   10,
+		HostCount:       10,
+		HostConstructor: NewHostCPUOnly,
+
+		DeathRate:         death,
+		BirthRate:         birth,
+		TagChangeInterval: tagInterval,
+		TagChangeRatio:    tagRatio,
+	}
+}
+
+func TestNewChurnDisabled(t *testing.T) {
+	s := churnConf(0, 0, 0, 0.5).NewSimulator(time.Minute, 0).(*DevopsSimulator)
+	if s.churn != nil {
+		t.Errorf("churn enabled without rates nor tag change interval")
+	}
+	if !s.churn.alive(0) {
+		t.Errorf("host not alive without churn")
+	}
+}
+
+func TestChurnDeathAndBirth(t *testing.T) {
+	s := churnConf(60, 0, 0, 0).NewSimulator(time.Minute, 0).(*DevopsSimulator)
+	if s.churn.death != 1 {
+		t.Fatalf("incorrect death chance per reading: got %v want 1", s.churn.death)
+	}
+
+	// every host reports once, then is decommissioned
+	written := 0
+	for p := data.NewPoint(); !s.Finished(); p.Reset() {
+		if s.Next(p) {
+			written++
+		}
+	}
+	if written != 10 {
+		t.Errorf("incorrect number of readings of decommissioned hosts: got %d want 10", written)
+	}
+	if deaths, births, _ := s.Churn(); deaths != 10 || births != 0 {
+		t.Errorf("incorrect churn: got %d deaths and %d births want 10 and 0", deaths, births)
+	}
+
+	// hosts are replaced right after they are decommissioned
+	s = churnConf(60, 60, 0, 0).NewSimulator(time.Minute, 0).(*DevopsSimulator)
+	names := map[string]bool{}
+	for p := data.NewPoint(); !s.Finished(); p.Reset() {
+		if s.Next(p) {
+			names[p.GetTagValue(MachineTagKeys[0]).(string)] = true
+		}
+	}
+	deaths, births, _ := s.Churn()
+	if births == 0 || births > deaths {
+		t.Errorf("incorrect churn: got %d deaths and %d births", deaths, births)
+	}
+	// hosts registered at the last readings never report
+	if len(names) <= 10 || len(names) > 10+int(births) {
+		t.Errorf("incorrect number of hosts: got %d want more than 10 and up to %d", len(names), 10+births)
+	}
+	if !names["host_10"] {
+		t.Errorf("first registered host host_10 not reporting")
+	}
+}
+
+func TestChurnTagChanges(t *testing.T) {
+	s := churnConf(0, 0, 10*time.Minute, 1).NewSimulator(time.Minute, 0).(*DevopsSimulator)
+	racks := map[string]map[string]bool{}
+	for p := data.NewPoint(); !s.Finished(); p.Reset() {
+		if !s.Next(p) {
+			continue
+		}
+		name := p.GetTagValue(MachineTagKeys[0]).(string)
+		if racks[name] == nil {
+			racks[name] = map[string]bool{}
+		}
+		key := p.GetTagValue(MachineTagKeys[1]).(string) + "," + p.GetTagValue(MachineTagKeys[3]).(string) + "," +
+			p.GetTagValue(MachineTagKeys[8]).(string)
+		racks[name][key] = true
+	}
+	// an hour of readings crosses five tag change intervals
+	if _, _, changes := s.Churn(); changes != 10*5 {
+		t.Errorf("incorrect number of tag changes: got %d want %d", changes, 10*5)
+	}
+	for name, tags := range racks {
+		if len(tags) < 2 {
+			t.Errorf("%s: tags never changed: %v", name, tags)
+		}
+	}
+}
+
+func TestChangeTags(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		h := NewHostCPUOnly(NewHostCtx(0, testTime))
+		before := h
+		changeTags(&h)
+		if h.Region == before.Region && h.Rack == before.Rack && h.ServiceVersion == before.ServiceVersion {
+			t.Fatalf("tags did not change: %+v", h)
+		}
+		if h.Name != before.Name || h.OS != before.OS || h.Team != before.Team {
+			t.Fatalf("unexpected change of the identity of the host: %+v", h)
+		}
+	}
+}
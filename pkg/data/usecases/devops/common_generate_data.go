@@ -3,8 +3,6 @@ package devops
 import (
 	"github.com/timescale/tsbs/pkg/data"
 	"github.com/timescale/tsbs/pkg/data/usecases/common"
-	"strconv"
-	"strings"
 	"time"
 )
 
@@ -39,6 +37,24 @@ type commonDevopsSimulatorConfig struct {
 
 	OutOfOrder       float32
 	OutOfOrderWindow int
+
+	// Profiler shapes the values of the hosts, nil keeps the random walks
+	Profiler *common.Profiler
+
+	// DeathRate and BirthRate are the chances per hour of a host to be
+	// decommissioned and of a decommissioned host to be replaced by a new
+	// one. Every TagChangeInterval, a TagChangeRatio of the hosts changes tags.
+	DeathRate         float64
+	BirthRate         float64
+	TagChangeInterval time.Duration
+	TagChangeRatio    float64
+
+	// Nuller leaves out field values of the hosts, nil keeps every field
+	Nuller *common.Nuller
+
+	// Cardinality is the number of distinct values of the high cardinality
+	// tag, 0 leaves the tag out
+	Cardinality uint64
 }
 
 func NewHostCtx(id int, start time.Time) *HostContext {
@@ -76,6 +92,11 @@ type commonDevopsSimulator struct {
 	queueCounter  int           // 计数器
 	queueSize     int           // 队列大小
 	emptyingQueue bool
+
+	profiler    *common.Profiler
+	churn       *churn
+	nuller      *common.Nuller
+	cardinality *cardinality
 }
 
 // Finished tells whether we have simulated all the necessary points
@@ -95,6 +116,9 @@ func (s *commonDevopsSimulator) TagKeys() []string {
 	for i, t := range MachineTagKeys {
 		tagKeysAsStr[i] = string(t)
 	}
+	if s.cardinality != nil {
+		tagKeysAsStr = append(tagKeysAsStr, common.CardinalityTagKey)
+	}
 	return tagKeysAsStr
 }
 
@@ -103,16 +127,30 @@ func (s *commonDevopsSimulator) TagTypes() []string {
 	for i := 0; i < len(MachineTagKeys); i++ {
 		types[i] = machineTagType.String()
 	}
+	if s.cardinality != nil {
+		types = append(types, machineTagType.String())
+	}
 	return types
 }
 
 func (d *commonDevopsSimulator) Headers() *common.GeneratedDataHeaders {
-	return &common.GeneratedDataHeaders{
-		TagTypes:  d.TagTypes(),
-		TagKeys:   d.TagKeys(),
-		FieldKeys: d.Fields(),
+	return d.headers(d.hosts[0].SimulatedMeasurements)
+}
+
+// headers describes the machine tags together with the fields and the
+// measurement specific tags of the supplied measurements
+func (s *commonDevopsSimulator) headers(measurements []common.SimulatedMeasurement) *common.GeneratedDataHeaders {
+	headers := &common.GeneratedDataHeaders{
+		TagTypes: s.TagTypes(),
+		TagKeys:  s.TagKeys(),
+	}
+	for _, sm := range measurements {
+		headers.AddMeasurement(sm)
 	}
+	s.nuller.MarkNullable(headers)
+	return headers
 }
+
 func (s *commonDevopsSimulator) fields(measurements []common.SimulatedMeasurement) map[string][]string {
 	fields := make(map[string][]string)
 	for _, sm := range measurements {
@@ -129,11 +167,36 @@ func (s *commonDevopsSimulator) fields(measurements []common.SimulatedMeasuremen
 	return fields
 }
 
+// Close writes out the anomalies injected by the profiler, if any
+func (s *commonDevopsSimulator) Close() error {
+	return s.profiler.Close()
+}
+
+// Anomalies returns the number of anomalies injected by the profiler
+func (s *commonDevopsSimulator) Anomalies() uint64 {
+	return s.profiler.Anomalies()
+}
+
+// Churn returns the number of decommissioned hosts, of hosts registered in
+// their place and of tag changes
+func (s *commonDevopsSimulator) Churn() (deaths, births, tagChanges uint64) {
+	if s.churn == nil {
+		return 0, 0, 0
+	}
+	return s.churn.deaths, s.churn.births, s.churn.tagChanges
+}
+
+// Nulls returns the number of field values left out
+func (s *commonDevopsSimulator) Nulls() uint64 {
+	return s.nuller.Nulls()
+}
+
 func (s *commonDevopsSimulator) populatePoint(p *data.Point, measureIdx int) bool {
 	host := &s.hosts[s.hostIndex]
 
-	parts := strings.Split(host.Name, "_")
-	p.Hostnumber, _ = strconv.Atoi(parts[1])
+	// hosts registered in place of decommissioned ones keep the number of
+	// the host they replace
+	p.Hostnumber = int(s.hostIndex)
 
 	// Populate host-specific tags:
 	p.AppendTag(MachineTagKeys[0], host.Name)
@@ -146,11 +209,27 @@ func (s *commonDevopsSimulator) populatePoint(p *data.Point, measureIdx int) boo
 	p.AppendTag(MachineTagKeys[7], host.Service)
 	p.AppendTag(MachineTagKeys[8], host.ServiceVersion)
 	p.AppendTag(MachineTagKeys[9], host.ServiceEnvironment)
+	// the high cardinality tag follows the host tags, its value depends on
+	// the time of the reading which ToPoint sets
+	cardinalityTag := len(p.TagKeys())
+	if s.cardinality != nil {
+		p.AppendTag(cardinalityTagKey, "")
+	}
 
 	// Populate measurement-specific tags and fields:
 	host.SimulatedMeasurements[measureIdx].ToPoint(p)
+	if s.cardinality != nil {
+		p.TagValues()[cardinalityTag] = s.cardinality.value(int(s.hostIndex), *p.Timestamp())
+	}
 
-	ret := s.hostIndex < s.epochHosts
+	ret := s.hostIndex < s.epochHosts && s.churn.alive(int(s.hostIndex))
+	if ret {
+		s.profiler.Apply(host.Name, p)
+		s.nuller.Apply(p)
+	}
+	if s.hostIndex < s.epochHosts && measureIdx == len(host.SimulatedMeasurements)-1 {
+		s.churn.update(s.hosts, int(s.hostIndex), *p.Timestamp())
+	}
 	s.madePoints++
 	s.hostIndex++
 	return ret
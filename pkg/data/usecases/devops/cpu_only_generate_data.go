@@ -22,11 +22,7 @@ func (d *CPUOnlySimulator) Fields() map[string][]string {
 }
 
 func (d *CPUOnlySimulator) Headers() *common.GeneratedDataHeaders {
-	return &common.GeneratedDataHeaders{
-		TagTypes:  d.TagTypes(),
-		TagKeys:   d.TagKeys(),
-		FieldKeys: d.Fields(),
-	}
+	return d.headers(d.hosts[0].SimulatedMeasurements[:1])
 }
 
 // Next advances a Point to the next state in the generator.
@@ -152,6 +148,9 @@ type CPUOnlySimulatorConfig commonDevopsSimulatorConfig
 
 // NewSimulator produces a Simulator that conforms to the given SimulatorConfig over the specified interval
 func (c *CPUOnlySimulatorConfig) NewSimulator(interval time.Duration, limit uint64) common.Simulator {
+	if c.Orderquantity <= 0 {
+		c.Orderquantity = int(c.HostCount) // default to all hosts
+	}
 	hostInfos := make([]Host, c.HostCount)
 	for i := 0; i < len(hostInfos); {
 		for j := i; j < c.Orderquantity+i && j < int(c.HostCount); j++ {
@@ -195,6 +194,10 @@ func (c *CPUOnlySimulatorConfig) NewSimulator(interval time.Duration, limit uint
 		Orderquantity:  c.Orderquantity,
 		OutOfOrder:     c.OutOfOrder,
 		queueSize:      qsize,
+		profiler:       c.Profiler,
+		churn:          newChurn((*commonDevopsSimulatorConfig)(c), interval, len(hostInfos)),
+		nuller:         c.Nuller,
+		cardinality:    newCardinality(c.Cardinality, len(hostInfos), c.Start, interval),
 	}, c.Start}
 
 	return sim
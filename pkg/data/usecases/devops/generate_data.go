@@ -51,11 +51,7 @@ func (s *DevopsSimulator) TagTypes() []string {
 }
 
 func (d *DevopsSimulator) Headers() *common.GeneratedDataHeaders {
-	return &common.GeneratedDataHeaders{
-		TagTypes:  d.TagTypes(),
-		TagKeys:   d.TagKeys(),
-		FieldKeys: d.Fields(),
-	}
+	return d.headers(d.hosts[0].SimulatedMeasurements)
 }
 
 // DevopsSimulatorConfig is used to create a DevopsSimulator.
@@ -89,6 +85,10 @@ func (d *DevopsSimulatorConfig) NewSimulator(interval time.Duration, limit uint6
 			timestampStart: d.Start,
 			timestampEnd:   d.End,
 			interval:       interval,
+			profiler:       d.Profiler,
+			churn:          newChurn((*commonDevopsSimulatorConfig)(d), interval, len(hostInfos)),
+			nuller:         d.Nuller,
+			cardinality:    newCardinality(d.Cardinality, len(hostInfos), d.Start, interval),
 		},
 		simulatedMeasurementIndex: 0,
 	}
@@ -51,6 +51,10 @@ func (c *GenericMetricsSimulatorConfig) NewSimulator(interval time.Duration, lim
 			timestampStart: c.Start,
 			timestampEnd:   c.End,
 			interval:       interval,
+			profiler:       c.Profiler,
+			churn:          newChurn((*commonDevopsSimulatorConfig)(c.DevopsSimulatorConfig), interval, len(hostInfos)),
+			nuller:         c.Nuller,
+			cardinality:    newCardinality(c.Cardinality, len(hostInfos), c.Start, interval),
 		},
 	}
 
@@ -61,21 +65,22 @@ func (c *GenericMetricsSimulatorConfig) NewSimulator(interval time.Duration, lim
 // Since each host has different number of fields (we use zipf distribution to assign # fields) we search
 // for the host with the max number of fields
 func (gms *GenericMetricsSimulator) Fields() map[string][]string {
+	return gms.fields(gms.maxMetricsHost().SimulatedMeasurements[:1])
+}
+
+func (gms *GenericMetricsSimulator) Headers() *common.GeneratedDataHeaders {
+	return gms.headers(gms.maxMetricsHost().SimulatedMeasurements[:1])
+}
+
+// maxMetricsHost returns the host with the max number of generic metrics
+func (gms *GenericMetricsSimulator) maxMetricsHost() *Host {
 	maxIndex := 0
 	for i, h := range gms.hosts {
 		if h.GenericMetricCount > gms.hosts[maxIndex].GenericMetricCount {
 			maxIndex = i
 		}
 	}
-	return gms.fields(gms.hosts[maxIndex].SimulatedMeasurements[:1])
-}
-
-func (gms *GenericMetricsSimulator) Headers() *common.GeneratedDataHeaders {
-	return &common.GeneratedDataHeaders{
-		TagKeys:   gms.TagKeys(),
-		TagTypes:  gms.TagTypes(),
-		FieldKeys: gms.Fields(),
-	}
+	return &gms.hosts[maxIndex]
 }
 
 // Next advances a Point to the next state in the generator.
@@ -0,0 +1,129 @@
+package iot
+
+import (
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/timescale/tsbs/pkg/data"
+	"github.com/timescale/tsbs/pkg/data/usecases/common"
+)
+
+// lateEntry is an entry held back until the simulated clock reaches due
+type lateEntry struct {
+	point *data.Point
+	due   time.Time
+}
+
+// lateArrivals delays a ratio of the entries of the base Simulator, like a
+// truck that delivers its readings after a connectivity gap. A late entry is
+// held until the entries generated after it reach its timestamp plus a delay
+// drawn from the delay distribution, capped at the window. Unlike the random
+// out of order batches of the batch config, the amount of disorder is set by
+// the out of order flags.
+type lateArrivals struct {
+	ratio       float64
+	window      time.Duration
+	exponential bool
+
+	// clock is the timestamp of the newest entry of the base Simulator
+	clock    time.Time
+	pending  []lateEntry
+	held     *data.Point
+	draining bool
+}
+
+func newLateArrivals(ratio float64, window time.Duration, delay string) *lateArrivals {
+	return &lateArrivals{
+		ratio:       ratio,
+		window:      window,
+		exponential: delay == common.OutOfOrderDelayExponential,
+	}
+}
+
+// delay draws the delay of a late entry. Exponential delays have a mean of a
+// quarter of the window, so most late entries arrive shortly after their time.
+func (l *lateArrivals) delay() time.Duration {
+	var d time.Duration
+	if l.exponential {
+		d = time.Duration(rand.ExpFloat64() * float64(l.window) / 4)
+	} else {
+		d = time.Duration(rand.Float64() * float64(l.window))
+	}
+	if d > l.window {
+		d = l.window
+	}
+	if d <= 0 {
+		d = 1
+	}
+	return d
+}
+
+// hold queues a late entry, keeping the pending entries sorted by due time
+func (l *lateArrivals) hold(p *data.Point, due time.Time) {
+	i := sort.Search(len(l.pending), func(i int) bool { return l.pending[i].due.After(due) })
+	l.pending = append(l.pending, lateEntry{})
+	copy(l.pending[i+1:], l.pending[i:])
+	l.pending[i] = lateEntry{point: p, due: due}
+}
+
+// next populates p with the next entry, either a late entry that is due or
+// the next entry of base. The base Simulator restarts the time for every
+// batch of Orderquantity generators, so the pending entries are released
+// before the first entry of the next batch, and all of them at the end.
+func (l *lateArrivals) next(base common.Simulator, p *data.Point) bool {
+	for {
+		if len(l.pending) > 0 && (l.draining || !l.pending[0].due.After(l.clock)) {
+			p.Copy(l.pending[0].point)
+			l.pending = l.pending[1:]
+			return true
+		}
+		l.draining = false
+
+		entry := l.held
+		l.held = nil
+		if entry == nil {
+			if base.Finished() {
+				if len(l.pending) == 0 {
+					return false
+				}
+				l.draining = true
+				continue
+			}
+			entry = data.NewPoint()
+			if !base.Next(entry) {
+				if len(l.pending) == 0 {
+					return false
+				}
+				l.draining = true
+				continue
+			}
+		}
+
+		// release the entries due by the time of this one first, so none
+		// arrives more than its delay late
+		ts := *entry.Timestamp()
+		if len(l.pending) > 0 && (ts.Before(l.clock) || !l.pending[0].due.After(ts)) {
+			l.held = entry
+			if ts.Before(l.clock) {
+				l.draining = true
+			} else {
+				l.clock = ts
+			}
+			continue
+		}
+		l.clock = ts
+
+		if rand.Float64() < l.ratio {
+			l.hold(entry, ts.Add(l.delay()))
+			continue
+		}
+		p.Copy(entry)
+		return true
+	}
+}
+
+// finished tells whether no entries are pending
+func (l *lateArrivals) finished() bool {
+	return len(l.pending) == 0 && l.held == nil
+}
@@ -0,0 +1,82 @@
+package iot
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/timescale/tsbs/pkg/data"
+	"github.com/timescale/tsbs/pkg/data/usecases/common"
+)
+
+func lateArrivalsConfig(ratio float64, window time.Duration, delay string) *SimulatorConfig {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	return &SimulatorConfig{
+		Start: start,
+		End:   start.Add(time.Hour),
+
+		InitGeneratorScale:   6,
+		GeneratorScale:       6,
+		GeneratorConstructor: NewTruck,
+		Orderquantity:        3,
+		OutOfOrder:           ratio,
+		OutOfOrderWindow:     window,
+		OutOfOrderDelay:      delay,
+	}
+}
+
+// seriesKey identifies the series of a point: its measurement and truck
+func seriesKey(p *data.Point) string {
+	return fmt.Sprintf("%s,%v", p.MeasurementName(), p.GetTagValue([]byte("name")))
+}
+
+func TestLateArrivals(t *testing.T) {
+	window := 2 * time.Minute
+	for _, delay := range []string{common.OutOfOrderDelayUniform, common.OutOfOrderDelayExponential} {
+		sim := lateArrivalsConfig(0.2, window, delay).NewSimulator(10*time.Second, 0)
+		base := (*common.BaseSimulatorConfig)(lateArrivalsConfig(0, 0, "")).NewSimulator(10*time.Second, 0)
+
+		want := 0
+		for p := data.NewPoint(); base.Next(p); p = data.NewPoint() {
+			want++
+		}
+
+		newest := map[string]time.Time{}
+		seen := map[string]bool{}
+		got, late := 0, 0
+		for p := data.NewPoint(); sim.Next(p); p = data.NewPoint() {
+			got++
+			key := seriesKey(p)
+			ts := *p.Timestamp()
+			id := fmt.Sprintf("%s,%d", key, ts.UnixNano())
+			if seen[id] {
+				t.Errorf("%s: entry %s generated twice", delay, id)
+			}
+			seen[id] = true
+			if n, ok := newest[key]; ok && ts.Before(n) {
+				late++
+				if lateness := n.Sub(ts); lateness > window {
+					t.Errorf("%s: entry %s is %v late, more than the window %v", delay, id, lateness, window)
+				}
+				continue
+			}
+			newest[key] = ts
+		}
+		if !sim.Finished() {
+			t.Errorf("%s: simulator not finished after the last entry", delay)
+		}
+		if got != want {
+			t.Errorf("%s: incorrect number of entries: got %d want %d", delay, got, want)
+		}
+		if ratio := float64(late) / float64(got); ratio < 0.1 || ratio > 0.25 {
+			t.Errorf("%s: incorrect ratio of late entries: got %0.2f want about 0.2", delay, ratio)
+		}
+	}
+}
+
+func TestLateArrivalsInOrder(t *testing.T) {
+	sim := lateArrivalsConfig(0, time.Minute, common.OutOfOrderDelayUniform).NewSimulator(10*time.Second, 0).(*Simulator)
+	if sim.late != nil {
+		t.Errorf("late arrivals enabled without an out of order ratio")
+	}
+}
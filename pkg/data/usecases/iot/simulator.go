@@ -28,12 +28,17 @@ func (sc *SimulatorConfig) NewSimulator(interval time.Duration, limit uint64) co
 		}
 	}
 
-	return &Simulator{
+	sim := &Simulator{
 		base:            s,
 		batchSize:       defaultBatchSize,
 		configGenerator: newBatchConfig,
 		maxFieldCount:   maxFieldCount,
 	}
+	if sc.OutOfOrder > 0 && sc.OutOfOrderWindow > 0 {
+		sim.late = newLateArrivals(sc.OutOfOrder, sc.OutOfOrderWindow, sc.OutOfOrderDelay)
+	}
+	sim.nuller = sc.Nuller
+	return sim
 }
 
 // Simulator is responsible for simulating entries for the IoT use case.
@@ -55,6 +60,12 @@ type Simulator struct {
 	// insert index consistent.
 	offset        int
 	Orderquantity int
+
+	// late replaces the batch configs with a set ratio of late entries when
+	// the out of order flags are given
+	late *lateArrivals
+	// nuller leaves out field values when the null ratio flags are given
+	nuller *common.Nuller
 }
 
 // Fields returns the fields of an entry.
@@ -74,6 +85,9 @@ func (s Simulator) TagTypes() []string {
 
 // Finished checks if the simulator is done.
 func (s Simulator) Finished() bool {
+	if s.late != nil {
+		return s.base.Finished() && s.late.finished()
+	}
 	return s.base.Finished() && len(s.currBatch) == 0 && !s.pendingOutOfOrderItems()
 }
 
@@ -81,6 +95,17 @@ func (s Simulator) Finished() bool {
 // If the current pregenerated batch is empty, it tries to generate a new one
 // in order to populate the next entry.
 func (s *Simulator) Next(p *data.Point) bool {
+	if !s.next(p) {
+		return false
+	}
+	s.nuller.Apply(p)
+	return true
+}
+
+func (s *Simulator) next(p *data.Point) bool {
+	if s.late != nil {
+		return s.late.next(s.base, p)
+	}
 	if s.batchSize == 0 { //todo 一批条目默认大小
 		return s.base.Next(p)
 	}
@@ -95,11 +120,14 @@ func (s *Simulator) Next(p *data.Point) bool {
 }
 
 func (s *Simulator) Headers() *common.GeneratedDataHeaders {
-	return &common.GeneratedDataHeaders{
-		TagTypes:  s.TagTypes(),
-		TagKeys:   s.TagKeys(),
-		FieldKeys: s.Fields(),
-	}
+	headers := s.base.Headers()
+	s.nuller.MarkNullable(headers)
+	return headers
+}
+
+// Nulls returns the number of field values left out
+func (s *Simulator) Nulls() uint64 {
+	return s.nuller.Nulls()
 }
 
 // pendingOutOfOrderItems returns whether the simulator has pending
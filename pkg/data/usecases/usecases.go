@@ -10,17 +10,22 @@ import (
 )
 
 const errCannotParseTimeFmt = "cannot parse time from string '%s': %v"
-const errCannotUsecaseType = "kwdb cannot support this use-case '%s', currently only supports cpu-only in devops"
-const errCannotIotOfOrder = "kwdb IOT cannot support outoforder"
+const errCannotUsecaseType = "kwdb cannot support this use-case '%s', currently supports cpu-only, devops, devops-generic and iot"
+const errOutOfOrderRatio = "outoforder must be between 0.0 and 1.0, got %v"
+const errIotOutOfOrderWindow = "iot outoforder needs an outoforderwindow greater than 0"
+const errOutOfOrderWindowRange = "outoforderwindow %v exceeds the time range"
 
 func GetSimulatorConfig(dgc *common.DataGeneratorConfig) (common.SimulatorConfig, error) {
 	var ret common.SimulatorConfig
 	var err error
-	if dgc.Format == "kwdb" && dgc.Use != common.UseCaseCPUOnly && dgc.Use != common.UseCaseIoT {
+	if dgc.Format == "kwdb" && dgc.Use == common.UseCaseCPUSingle {
 		return nil, fmt.Errorf(errCannotUsecaseType, dgc.Use)
 	}
-	if dgc.Format == "kwdb" && dgc.Use == common.UseCaseIoT && (dgc.OutOfOrder != 0 || dgc.OutOfOrderWindow != 0) {
-		return nil, fmt.Errorf(errCannotIotOfOrder)
+	if dgc.OutOfOrder < 0 || dgc.OutOfOrder > 1 {
+		return nil, fmt.Errorf(errOutOfOrderRatio, dgc.OutOfOrder)
+	}
+	if dgc.Use == common.UseCaseIoT && dgc.OutOfOrder > 0 && dgc.OutOfOrderWindow <= 0 {
+		return nil, fmt.Errorf(errIotOutOfOrderWindow)
 	}
 	tsStart, err := utils.ParseUTCTime(dgc.TimeStart)
 	if err != nil {
@@ -30,6 +35,28 @@ func GetSimulatorConfig(dgc *common.DataGeneratorConfig) (common.SimulatorConfig
 	if err != nil {
 		return nil, fmt.Errorf(errCannotParseTimeFmt, dgc.TimeEnd, err)
 	}
+	if dgc.Use == common.UseCaseIoT && dgc.OutOfOrderWindow > tsEnd.Sub(tsStart) {
+		return nil, fmt.Errorf(errOutOfOrderWindowRange, dgc.OutOfOrderWindow)
+	}
+
+	// the value profile only applies to the devops use cases, Validate
+	// rejects it for iot
+	var profiler *common.Profiler
+	if dgc.Use != common.UseCaseIoT {
+		profiler, err = common.NewProfiler(dgc.ProfileConfig())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	nullConfig, err := dgc.NullConfig()
+	if err != nil {
+		return nil, err
+	}
+	nuller, err := common.NewNuller(nullConfig)
+	if err != nil {
+		return nil, err
+	}
 
 	switch dgc.Use {
 	case common.UseCaseDevops:
@@ -40,6 +67,15 @@ func GetSimulatorConfig(dgc *common.DataGeneratorConfig) (common.SimulatorConfig
 			InitHostCount:   dgc.InitialScale,
 			HostCount:       dgc.Scale,
 			HostConstructor: devops.NewHost,
+			Profiler:        profiler,
+
+			DeathRate:         dgc.DeviceDeathRate,
+			BirthRate:         dgc.DeviceBirthRate,
+			TagChangeInterval: dgc.TagChangeInterval,
+			TagChangeRatio:    dgc.TagChangeRatio,
+
+			Nuller:      nuller,
+			Cardinality: dgc.Cardinality,
 		}
 	case common.UseCaseIoT:
 		ret = &iot.SimulatorConfig{
@@ -50,6 +86,11 @@ func GetSimulatorConfig(dgc *common.DataGeneratorConfig) (common.SimulatorConfig
 			GeneratorScale:       dgc.Scale,
 			GeneratorConstructor: iot.NewTruck,
 			Orderquantity:        dgc.Orderquantity,
+			OutOfOrder:           float64(dgc.OutOfOrder),
+			OutOfOrderWindow:     dgc.OutOfOrderWindow,
+			OutOfOrderDelay:      dgc.OutOfOrderDelay,
+
+			Nuller: nuller,
 		}
 	case common.UseCaseCPUOnly:
 		ret = &devops.CPUOnlySimulatorConfig{
@@ -62,6 +103,15 @@ func GetSimulatorConfig(dgc *common.DataGeneratorConfig) (common.SimulatorConfig
 			Orderquantity:    dgc.Orderquantity,
 			OutOfOrder:       dgc.OutOfOrder,
 			OutOfOrderWindow: int(dgc.OutOfOrderWindow / dgc.LogInterval),
+			Profiler:         profiler,
+
+			DeathRate:         dgc.DeviceDeathRate,
+			BirthRate:         dgc.DeviceBirthRate,
+			TagChangeInterval: dgc.TagChangeInterval,
+			TagChangeRatio:    dgc.TagChangeRatio,
+
+			Nuller:      nuller,
+			Cardinality: dgc.Cardinality,
 		}
 	case common.UseCaseCPUSingle:
 		ret = &devops.CPUOnlySimulatorConfig{
@@ -71,6 +121,15 @@ func GetSimulatorConfig(dgc *common.DataGeneratorConfig) (common.SimulatorConfig
 			InitHostCount:   dgc.InitialScale,
 			HostCount:       dgc.Scale,
 			HostConstructor: devops.NewHostCPUSingle,
+			Profiler:        profiler,
+
+			DeathRate:         dgc.DeviceDeathRate,
+			BirthRate:         dgc.DeviceBirthRate,
+			TagChangeInterval: dgc.TagChangeInterval,
+			TagChangeRatio:    dgc.TagChangeRatio,
+
+			Nuller:      nuller,
+			Cardinality: dgc.Cardinality,
 		}
 	case common.UseCaseDevopsGeneric:
 		if dgc.InitialScale == dgc.Scale {
@@ -86,6 +145,15 @@ func GetSimulatorConfig(dgc *common.DataGeneratorConfig) (common.SimulatorConfig
 				HostCount:       dgc.Scale,
 				HostConstructor: devops.NewHostGenericMetrics,
 				MaxMetricCount:  dgc.MaxMetricCountPerHost,
+				Profiler:        profiler,
+
+				DeathRate:         dgc.DeviceDeathRate,
+				BirthRate:         dgc.DeviceBirthRate,
+				TagChangeInterval: dgc.TagChangeInterval,
+				TagChangeRatio:    dgc.TagChangeRatio,
+
+				Nuller:      nuller,
+				Cardinality: dgc.Cardinality,
 			},
 		}
 	default:
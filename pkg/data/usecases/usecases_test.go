@@ -45,3 +45,218 @@ func TestGetSimulatorConfig(t *testing.T) {
 		t.Errorf("unexpected lack of error for bogus use case")
 	}
 }
+
+func TestGetSimulatorConfigOutOfOrder(t *testing.T) {
+	cases := []struct {
+		desc    string
+		ratio   float32
+		window  time.Duration
+		wantErr bool
+	}{
+		{desc: "ratio with a window", ratio: 0.25, window: time.Minute},
+		{desc: "ratio without a window", ratio: 0.25, wantErr: true},
+		{desc: "window exceeding the time range", ratio: 0.25, window: 2 * time.Hour, wantErr: true},
+		{desc: "ratio above 1", ratio: 1.5, window: time.Minute, wantErr: true},
+	}
+	for _, c := range cases {
+		dgc := &common.DataGeneratorConfig{
+			BaseConfig: common.BaseConfig{
+				Format:           "kwdb",
+				Use:              common.UseCaseIoT,
+				Scale:            1,
+				TimeStart:        "2020-01-01T00:00:00Z",
+				TimeEnd:          "2020-01-01T01:00:00Z",
+				OutOfOrder:       c.ratio,
+				OutOfOrderWindow: c.window,
+				OutOfOrderDelay:  common.OutOfOrderDelayExponential,
+			},
+			InitialScale: 1,
+			LogInterval:  defaultLogInterval,
+		}
+		scfg, err := GetSimulatorConfig(dgc)
+		if (err != nil) != c.wantErr {
+			t.Errorf("%s: incorrect error: %v", c.desc, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		sc := scfg.(*iot.SimulatorConfig)
+		if sc.OutOfOrder != float64(c.ratio) || sc.OutOfOrderWindow != c.window || sc.OutOfOrderDelay != common.OutOfOrderDelayExponential {
+			t.Errorf("%s: out of order settings not passed to the iot config: %+v", c.desc, sc)
+		}
+	}
+}
+
+func TestGetSimulatorConfigProfile(t *testing.T) {
+	cases := []struct {
+		desc     string
+		profile  string
+		wantErr  bool
+		profiler bool
+	}{
+		{desc: "diurnal and skew", profile: "diurnal,skew", profiler: true},
+		{desc: "no profile", profile: ""},
+		{desc: "unknown profile feature", profile: "weekly", wantErr: true},
+	}
+	for _, c := range cases {
+		dgc := &common.DataGeneratorConfig{
+			BaseConfig: common.BaseConfig{
+				Use:       common.UseCaseCPUOnly,
+				Scale:     1,
+				TimeStart: "2020-01-01T00:00:00Z",
+				TimeEnd:   "2020-01-01T01:00:00Z",
+			},
+			InitialScale:        1,
+			LogInterval:         defaultLogInterval,
+			Profile:             c.profile,
+			ProfileMeasurements: "cpu",
+			DiurnalAmplitude:    0.5,
+		}
+		scfg, err := GetSimulatorConfig(dgc)
+		if (err != nil) != c.wantErr {
+			t.Errorf("%s: incorrect error: %v", c.desc, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if got := scfg.(*devops.CPUOnlySimulatorConfig).Profiler != nil; got != c.profiler {
+			t.Errorf("%s: incorrect profiler in the cpu-only config: got %v want %v", c.desc, got, c.profiler)
+		}
+	}
+}
+
+func TestGetSimulatorConfigChurn(t *testing.T) {
+	cases := []struct {
+		desc    string
+		use     string
+		wantErr bool
+	}{
+		{desc: "device churn with devops", use: common.UseCaseDevops},
+		{desc: "device churn with iot", use: common.UseCaseIoT, wantErr: true},
+	}
+	for _, c := range cases {
+		dgc := &common.DataGeneratorConfig{
+			BaseConfig: common.BaseConfig{
+				Format:    "kwdb",
+				Use:       c.use,
+				Scale:     1,
+				TimeStart: "2020-01-01T00:00:00Z",
+				TimeEnd:   "2020-01-01T01:00:00Z",
+			},
+			InitialScale:         1,
+			InterleavedNumGroups: 1,
+			LogInterval:          defaultLogInterval,
+			DeviceDeathRate:      0.1,
+			DeviceBirthRate:      0.5,
+			TagChangeInterval:    time.Hour,
+			TagChangeRatio:       0.2,
+		}
+		if err := dgc.Validate(); (err != nil) != c.wantErr {
+			t.Errorf("%s: incorrect validation error: %v", c.desc, err)
+			continue
+		}
+		if c.wantErr {
+			continue
+		}
+		scfg, err := GetSimulatorConfig(dgc)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", c.desc, err)
+			continue
+		}
+		sc := scfg.(*devops.DevopsSimulatorConfig)
+		if sc.DeathRate != 0.1 || sc.BirthRate != 0.5 || sc.TagChangeInterval != time.Hour || sc.TagChangeRatio != 0.2 {
+			t.Errorf("%s: churn settings not passed to the devops config: %+v", c.desc, sc)
+		}
+	}
+}
+
+func TestGetSimulatorConfigNulls(t *testing.T) {
+	cases := []struct {
+		desc        string
+		use         string
+		fieldRatios string
+		ratio       float64
+		wantErr     bool
+	}{
+		{desc: "null field ratios with iot", use: common.UseCaseIoT, fieldRatios: "readings.fuel_state=0.2"},
+		{desc: "null ratio with cpu-only", use: common.UseCaseCPUOnly, ratio: 0.1},
+		{desc: "null field ratio without a ratio", use: common.UseCaseCPUOnly, fieldRatios: "fuel_state", ratio: 0.1, wantErr: true},
+	}
+	for _, c := range cases {
+		dgc := &common.DataGeneratorConfig{
+			BaseConfig: common.BaseConfig{
+				Use:       c.use,
+				Scale:     1,
+				TimeStart: "2020-01-01T00:00:00Z",
+				TimeEnd:   "2020-01-01T01:00:00Z",
+			},
+			InitialScale:    1,
+			LogInterval:     defaultLogInterval,
+			NullFieldRatios: c.fieldRatios,
+			NullRatio:       c.ratio,
+		}
+		scfg, err := GetSimulatorConfig(dgc)
+		if (err != nil) != c.wantErr {
+			t.Errorf("%s: incorrect error: %v", c.desc, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		var nuller *common.Nuller
+		switch sc := scfg.(type) {
+		case *iot.SimulatorConfig:
+			nuller = sc.Nuller
+		case *devops.CPUOnlySimulatorConfig:
+			nuller = sc.Nuller
+		}
+		if nuller == nil {
+			t.Errorf("%s: null ratios not passed to the %s config", c.desc, c.use)
+		}
+	}
+}
+
+func TestGetSimulatorConfigCardinality(t *testing.T) {
+	cases := []struct {
+		desc        string
+		use         string
+		cardinality uint64
+		wantErr     bool
+	}{
+		{desc: "cardinality with cpu-only", use: common.UseCaseCPUOnly, cardinality: 1000},
+		{desc: "cardinality below the scale", use: common.UseCaseCPUOnly, cardinality: 5, wantErr: true},
+		{desc: "cardinality with iot", use: common.UseCaseIoT, cardinality: 1000, wantErr: true},
+	}
+	for _, c := range cases {
+		dgc := &common.DataGeneratorConfig{
+			BaseConfig: common.BaseConfig{
+				Format:    "kwdb",
+				Use:       c.use,
+				Scale:     10,
+				TimeStart: "2020-01-01T00:00:00Z",
+				TimeEnd:   "2020-01-01T01:00:00Z",
+			},
+			InitialScale:         10,
+			InterleavedNumGroups: 1,
+			LogInterval:          defaultLogInterval,
+			Cardinality:          c.cardinality,
+		}
+		if err := dgc.Validate(); (err != nil) != c.wantErr {
+			t.Errorf("%s: incorrect validation error: %v", c.desc, err)
+			continue
+		}
+		if c.wantErr {
+			continue
+		}
+		scfg, err := GetSimulatorConfig(dgc)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", c.desc, err)
+			continue
+		}
+		if got := scfg.(*devops.CPUOnlySimulatorConfig).Cardinality; got != c.cardinality {
+			t.Errorf("%s: cardinality not passed to the cpu-only config: got %d want %d", c.desc, got, c.cardinality)
+		}
+	}
+}
@@ -2,13 +2,16 @@ package query
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
 	"runtime/pprof"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/spf13/pflag"
@@ -39,6 +42,33 @@ type BenchmarkRunnerConfig struct {
 	PrewarmQueries   bool   `mapstructure:"prewarm-queries"`
 	ResultsFile      string `mapstructure:"results-file"`
 	Prepare          bool
+
+	// LatencyTimeSeries is the file to write the per interval throughput and
+	// latency percentiles of every label to, in LatencyFormat csv or json
+	LatencyTimeSeries string        `mapstructure:"latency-timeseries"`
+	LatencyFormat     string        `mapstructure:"latency-format"`
+	LatencyInterval   time.Duration `mapstructure:"latency-interval"`
+
+	// OpenLoopRate sends the queries at this rate per second, regardless of how
+	// fast they complete, with constant or poisson Arrival times
+	OpenLoopRate float64 `mapstructure:"open-loop-rate"`
+	Arrival      string  `mapstructure:"arrival"`
+	ArrivalSeed  int64   `mapstructure:"arrival-seed"`
+
+	// MaxErrors is the number of failed queries to tolerate before aborting,
+	// failed queries are counted per label instead of their latency
+	MaxErrors uint64 `mapstructure:"max-errors"`
+
+	// ReplayFile is a captured SQL workload to run instead of the query file,
+	// at ReplaySpeed times the pacing of its timestamps or 0 = as fast as possible
+	ReplayFile  string  `mapstructure:"replay-file"`
+	ReplaySpeed float64 `mapstructure:"replay-speed"`
+
+	// ColdCacheCmd and ColdCacheSQL are run before every query or label batch
+	// of ColdCacheScope, to report the queries with cold caches separately
+	ColdCacheCmd   string `mapstructure:"cold-cache-cmd"`
+	ColdCacheSQL   string `mapstructure:"cold-cache-sql"`
+	ColdCacheScope string `mapstructure:"cold-cache-scope"`
 }
 
 // AddToFlagSet adds command line flags needed by the BenchmarkRunnerConfig to the flag set.
@@ -56,6 +86,18 @@ func (c BenchmarkRunnerConfig) AddToFlagSet(fs *pflag.FlagSet) {
 	fs.Int("debug", 0, "Whether to print debug messages.")
 	fs.String("file", "", "File name to read queries from")
 	fs.String("results-file", "", "Write the test results summary json to this file")
+	fs.String("latency-timeseries", "", "Write the throughput and the p50/p95/p99 latencies of every query label per interval to this file")
+	fs.String("latency-format", "csv", "Format of the latency time series: csv or json (one json object per line)")
+	fs.Duration("latency-interval", time.Second, "Interval of the latency time series")
+	fs.Float64("open-loop-rate", 0, "Send queries at this rate per second regardless of how fast they complete, measuring latency from the intended send time, 0 = closed loop")
+	fs.String("arrival", arrivalConstant, "Arrival times of the open loop queries: constant or poisson")
+	fs.Int64("arrival-seed", 0, "Seed of the poisson arrival times, 0 = current time")
+	fs.String("replay-file", "", "Replay a captured SQL workload instead of the query file: one statement per line, optionally after a timestamp and a tab")
+	fs.Float64("replay-speed", 1, "Speed multiplier of the pacing of the replayed workload, 0 = as fast as possible")
+	fs.String("cold-cache-cmd", "", "Local shell command run before cold queries, e.g. to restart the database or drop the OS caches")
+	fs.String("cold-cache-sql", "", "SQL statement run on the connection of the worker before cold queries")
+	fs.String("cold-cache-scope", coldScopeQuery, "Run the cold cache hook before every query or before the first query of every label batch: query or label")
+	fs.Uint64("max-errors", 0, "Number of failed or timed out queries to count before aborting the run, 0 = abort on the first error")
 	fs.String("query-type", "", "")
 	fs.Bool("prepare", false, "")
 	fs.String("compress", "off", "")
@@ -69,6 +111,11 @@ type BenchmarkRunner struct {
 	sp      statProcessor
 	scanner *scanner
 	ch      chan Query
+
+	openLoop  *openLoop
+	coldCache *coldCache
+	// errCount is the number of failed queries, accessed atomically
+	errCount uint64
 }
 
 // NewBenchmarkRunner creates a new instance of BenchmarkRunner which is
@@ -93,6 +140,13 @@ func (b *BenchmarkRunner) SetLimit(limit uint64) {
 	b.Limit = limit
 }
 
+// SetStatListener sets fn to be called with the label and latency (in ms) of
+// every query counted in the stats, e.g. to report the latencies over time next
+// to a concurrent load. fn runs on the stats goroutine and must not block.
+func (b *BenchmarkRunner) SetStatListener(fn func(label []byte, latencyMs float64)) {
+	b.sp.getArgs().onStat = fn
+}
+
 // DoPrintResponses indicates whether responses for queries should be printed
 func (b *BenchmarkRunner) DoPrintResponses() bool {
 	return b.PrintResponses
@@ -120,6 +174,13 @@ type Processor interface {
 	ProcessQuery(q Query, isWarm bool) ([]*Stat, error)
 }
 
+// WarmProcessor is implemented by processors that run the warm run of
+// --prewarm-queries differently from the first run of a query
+type WarmProcessor interface {
+	// ProcessWarmQuery runs a query again right after its first run
+	ProcessWarmQuery(q Query) ([]*Stat, error)
+}
+
 // GetBufferedReader returns the buffered Reader that should be used by the loader
 func (b *BenchmarkRunner) GetBufferedReader() *bufio.Reader {
 	if b.br == nil {
@@ -152,27 +213,76 @@ func (b *BenchmarkRunner) Run(queryPool *sync.Pool, processorCreateFn ProcessorC
 	}
 	b.ch = make(chan Query, b.Workers)
 
+	// (Optional) run the cold cache hook before the queries:
+	if len(b.ColdCacheCmd) > 0 || len(b.ColdCacheSQL) > 0 {
+		var err error
+		b.coldCache, err = newColdCache(b.ColdCacheCmd, b.ColdCacheSQL, b.ColdCacheScope)
+		if err != nil {
+			panic(err)
+		}
+		spArgs.coldCache = true
+	}
+
+	// (Optional) write the latency time series while the queries run:
+	stopTimeSeries := func() {}
+	if len(b.LatencyTimeSeries) > 0 {
+		spArgs.timeSeries, stopTimeSeries = startLatencyTimeSeries(b.LatencyTimeSeries, b.LatencyFormat, b.LatencyInterval)
+	}
+
 	// Launch the stats processor:
 	go b.sp.process(b.Workers)
 
 	rateLimiter := getRateLimiter(b.LimitRPS, b.Workers)
 
+	// (Optional) schedule the queries at a fixed rate instead of sending the
+	// next one when a worker is free:
+	if b.OpenLoopRate > 0 && len(b.ReplayFile) > 0 && b.ReplaySpeed > 0 {
+		panic("the open loop rate and the pacing of the replayed workload exclude each other, set --replay-speed=0")
+	}
+	if b.OpenLoopRate > 0 {
+		seed := b.ArrivalSeed
+		if seed == 0 {
+			seed = time.Now().UnixNano()
+		}
+		var err error
+		b.openLoop, err = newOpenLoop(b.OpenLoopRate, b.Arrival, seed)
+		if err != nil {
+			panic(err)
+		}
+	}
+
 	// Launch query processors
 	var wg sync.WaitGroup
 	for i := 0; i < int(b.Workers); i++ {
 		wg.Add(1)
-		go b.processorHandler(&wg, rateLimiter, queryPool, processorCreateFn(), i)
+		if b.openLoop != nil {
+			go b.openLoopHandler(&wg, queryPool, processorCreateFn(), i)
+		} else {
+			go b.processorHandler(&wg, rateLimiter, queryPool, processorCreateFn(), i)
+		}
 	}
 
 	// Read in jobs, closing the job channel when done:
 	// Wall clock start time
 	wallStart := time.Now()
-	b.scanner.setReader(b.GetBufferedReader()).scan(queryPool, b.ch)
+	if b.openLoop != nil {
+		go b.openLoop.schedule(b.ch, wallStart)
+	}
+	var replay *replayScanner
+	if len(b.ReplayFile) > 0 {
+		f := openReplayFile(b.ReplayFile)
+		replay = newReplayScanner(f, &b.Limit, b.ReplaySpeed)
+		replay.scan(queryPool, b.ch)
+		f.Close()
+	} else {
+		b.scanner.setReader(b.GetBufferedReader()).scan(queryPool, b.ch)
+	}
 	close(b.ch)
 
 	// Block for workers to finish sending requests, closing the stats channel when done:
 	wg.Wait()
 	b.sp.CloseAndWait()
+	stopTimeSeries()
 
 	// Wall clock end time
 	wallEnd := time.Now()
@@ -181,6 +291,12 @@ func (b *BenchmarkRunner) Run(queryPool *sync.Pool, processorCreateFn ProcessorC
 	if err != nil {
 		log.Fatal(err)
 	}
+	if b.openLoop != nil {
+		fmt.Print(b.openLoop.summary())
+	}
+	if replay != nil {
+		fmt.Print(replay.summary())
+	}
 
 	// (Optional) create a memory profile:
 	if len(b.MemProfile) > 0 {
@@ -207,6 +323,12 @@ func (b *BenchmarkRunner) saveTestResult(took time.Duration, start time.Time, en
 		DurationMillis:      took.Milliseconds(),
 		Totals:              b.sp.GetTotalsMap(),
 	}
+	if b.openLoop != nil {
+		testResult.Totals["openLoop"] = b.openLoop.totals()
+	}
+	if b.coldCache != nil {
+		testResult.Totals["coldCache"] = b.coldCache.totals()
+	}
 
 	_, _ = fmt.Printf("Saving results json file to %s\n", b.BenchmarkRunnerConfig.ResultsFile)
 	file, err := json.MarshalIndent(testResult, "", " ")
@@ -226,27 +348,71 @@ func (b *BenchmarkRunner) processorHandler(wg *sync.WaitGroup, rateLimiter *rate
 		r := rateLimiter.Reserve()
 		time.Sleep(r.Delay())
 
-		stats, err := processor.ProcessQuery(query, b.Prepare)
-		if err != nil {
-			panic(err)
+		b.runQuery(processor, query, 0)
+		queryPool.Put(query)
+	}
+	wg.Done()
+}
+
+// openLoopHandler runs the queries scheduled by the open loop, their latency
+// includes the time they waited for this worker past their intended send time
+func (b *BenchmarkRunner) openLoopHandler(wg *sync.WaitGroup, queryPool *sync.Pool, processor Processor, workerNum int) {
+	processor.Init(workerNum)
+	for job := range b.openLoop.jobs {
+		wait := b.openLoop.started(job)
+		b.runQuery(processor, job.q, wait)
+		queryPool.Put(job.q)
+	}
+	wg.Done()
+}
+
+// runQuery runs query on processor and sends its stats, with wait added to
+// its latencies
+func (b *BenchmarkRunner) runQuery(processor Processor, query Query, wait time.Duration) {
+	cold, release := false, func() {}
+	if b.coldCache != nil {
+		cold, release = b.coldCache.acquire(processor, query)
+	}
+	stats, err := processor.ProcessQuery(query, b.Prepare)
+	release()
+	if err != nil {
+		stats = b.queryError(query, err)
+	} else {
+		addWait(stats, wait)
+		for _, s := range stats {
+			s.isCold = cold
 		}
-		b.sp.send(stats)
-
-		// If PrewarmQueries is set, we run the query as 'cold' first (see above),
-		// then we immediately run it a second time and report that as the 'warm' stat.
-		// This guarantees that the warm stat will reflect optimal cache performance.
-		spArgs := b.sp.getArgs()
-		if spArgs.prewarmQueries {
-			// Warm run
+	}
+	b.sp.send(stats)
+
+	// If PrewarmQueries is set, we run the query as 'cold' first (see above),
+	// then we immediately run it a second time and report that as the 'warm' stat.
+	// This guarantees that the warm stat will reflect optimal cache performance.
+	spArgs := b.sp.getArgs()
+	if spArgs.prewarmQueries {
+		// Warm run
+		if wp, ok := processor.(WarmProcessor); ok {
+			stats, err = wp.ProcessWarmQuery(query)
+		} else {
 			stats, err = processor.ProcessQuery(query, true)
-			if err != nil {
-				panic(err)
-			}
-			b.sp.sendWarm(stats)
 		}
-		queryPool.Put(query)
+		if err != nil {
+			stats = b.queryError(query, err)
+		}
+		b.sp.sendWarm(stats)
 	}
-	wg.Done()
+}
+
+// queryError counts a failed query and returns its error stat, it panics once
+// more than MaxErrors queries failed. Processors return errors wrapping
+// context.DeadlineExceeded for queries that timed out.
+func (b *BenchmarkRunner) queryError(query Query, err error) []*Stat {
+	n := atomic.AddUint64(&b.errCount, 1)
+	if n > b.MaxErrors {
+		panic(fmt.Errorf("aborting after %d failed queries (max-errors %d): %w", n, b.MaxErrors, err))
+	}
+	log.Printf("query %d (%s) failed: %v", query.GetID(), query.HumanLabelName(), err)
+	return []*Stat{getErrorStat(query.HumanLabelName(), errors.Is(err, context.DeadlineExceeded))}
 }
 
 func getRateLimiter(limitRPS uint64, workers uint) *rate.Limiter {
@@ -1,6 +1,9 @@
 package query
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"golang.org/x/time/rate"
 	"io/ioutil"
 	"math"
@@ -339,6 +342,86 @@ func (mp *mockProcessor) ProcessQuery(q Query, isWarm bool) ([]*Stat, error) {
 	return mp.processRes, mp.processErr
 }
 
+// mockWarmProcessor samples every 2nd first run of a label, like the
+// EXPLAIN ANALYZE sampling of the KWDB runner
+type mockWarmProcessor struct {
+	mockProcessor
+	counts  map[string]uint64
+	sampled []uint64
+	warm    int
+}
+
+func (mp *mockWarmProcessor) ProcessQuery(q Query, isWarm bool) ([]*Stat, error) {
+	label := string(q.HumanLabelName())
+	if mp.counts[label]%2 == 0 {
+		mp.sampled = append(mp.sampled, q.GetID())
+	}
+	mp.counts[label]++
+	return []*Stat{GetStat().Init(q.HumanLabelName(), 1)}, nil
+}
+
+func (mp *mockWarmProcessor) ProcessWarmQuery(q Query) ([]*Stat, error) {
+	mp.warm++
+	return []*Stat{GetStat().Init(q.HumanLabelName(), 1)}, nil
+}
+
+func TestRunQueryPrewarm(t *testing.T) {
+	sent := 0
+	sp := &mockStatProcessor{
+		args:   &statProcessorArgs{prewarmQueries: true},
+		onSend: func(stats []*Stat) { sent += len(stats) },
+	}
+	b := &BenchmarkRunner{}
+	b.sp = sp
+	p := &mockWarmProcessor{counts: map[string]uint64{}}
+	for i := uint64(0); i < 4; i++ {
+		q := &testQuery{HumanLabel: []byte("label"), ID: i}
+		b.runQuery(p, q, 0)
+	}
+	if p.warm != 4 {
+		t.Errorf("incorrect warm runs: got %d want 4", p.warm)
+	}
+	// the warm runs do not advance the sampling of the first runs
+	if !reflect.DeepEqual(p.sampled, []uint64{0, 2}) {
+		t.Errorf("incorrect sampled queries: got %v want %v", p.sampled, []uint64{0, 2})
+	}
+	if sent != 8 {
+		t.Errorf("incorrect number of stats: got %d want 8", sent)
+	}
+}
+
+func TestRunQueryErrors(t *testing.T) {
+	var sent []*Stat
+	sp := &mockStatProcessor{
+		args:   &statProcessorArgs{},
+		onSend: func(stats []*Stat) { sent = append(sent, stats...) },
+	}
+	b := &BenchmarkRunner{BenchmarkRunnerConfig: BenchmarkRunnerConfig{MaxErrors: 2}}
+	b.sp = sp
+	q := &testQuery{HumanLabel: []byte("label")}
+
+	p := &mockProcessor{processErr: fmt.Errorf("query timed out: %w", context.DeadlineExceeded)}
+	b.runQuery(p, q, 0)
+	p.processErr = errors.New("syntax error")
+	b.runQuery(p, q, 0)
+	if len(sent) != 2 {
+		t.Fatalf("incorrect number of stats: got %d want 2", len(sent))
+	}
+	if !sent[0].isError || !sent[0].isTimeout || string(sent[0].label) != "label" {
+		t.Errorf("incorrect stat of timed out query: %+v", sent[0])
+	}
+	if !sent[1].isError || sent[1].isTimeout {
+		t.Errorf("incorrect stat of failed query: %+v", sent[1])
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf("expected a panic after more than max-errors failed queries")
+		}
+	}()
+	b.runQuery(p, q, 0)
+}
+
 func TestGetRateLimiter(t *testing.T) {
 	type args struct {
 		limitRPS uint64
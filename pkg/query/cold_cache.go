@@ -0,0 +1,100 @@
+package query
+
+import (
+	"fmt"
+	"os/exec"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	coldScopeQuery = "query"
+	coldScopeLabel = "label"
+
+	// coldLabelSuffix marks the stats of the queries run right after a hook
+	coldLabelSuffix = " (cold)"
+)
+
+// HookProcessor is implemented by processors that can run the statement of
+// the cold cache hook on their connection
+type HookProcessor interface {
+	RunHook(sql string) error
+}
+
+// coldCache runs a hook, a local shell command and/or a SQL statement, before
+// every query or before the first query of every label batch, so the query
+// runs with cold caches. The hook waits for the running queries and the cold
+// query runs alone right after it, so no query of another worker runs during
+// the hook or warms the caches before the cold query.
+type coldCache struct {
+	cmd   string
+	sql   string
+	scope string
+
+	// mu orders the queries of all workers, so the label batches and the
+	// locks follow the order in which the queries are acquired
+	mu        sync.Mutex
+	lastLabel string
+	rw        sync.RWMutex
+	hooks     uint64
+}
+
+func newColdCache(cmd, sql, scope string) (*coldCache, error) {
+	if scope != coldScopeQuery && scope != coldScopeLabel {
+		return nil, fmt.Errorf("unknown cold cache scope '%s', supports %s and %s", scope, coldScopeQuery, coldScopeLabel)
+	}
+	return &coldCache{cmd: cmd, sql: sql, scope: scope}, nil
+}
+
+// acquire runs the hook when it is due before query, and returns whether the
+// query runs cold and the function to call when it completed. A cold query
+// keeps the other workers waiting until it completed.
+func (c *coldCache) acquire(processor Processor, query Query) (bool, func()) {
+	label := string(query.HumanLabelName())
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	due := c.scope == coldScopeQuery || label != c.lastLabel
+	c.lastLabel = label
+
+	if !due {
+		c.rw.RLock()
+		return false, c.rw.RUnlock
+	}
+	c.rw.Lock()
+	if err := c.run(processor); err != nil {
+		c.rw.Unlock()
+		panic(err)
+	}
+	return true, c.rw.Unlock
+}
+
+func (c *coldCache) run(processor Processor) error {
+	atomic.AddUint64(&c.hooks, 1)
+	if len(c.cmd) > 0 {
+		out, err := exec.Command("sh", "-c", c.cmd).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("cold cache command failed: %v\n%s", err, out)
+		}
+	}
+	if len(c.sql) > 0 {
+		hp, ok := processor.(HookProcessor)
+		if !ok {
+			return fmt.Errorf("the processor %T cannot run a cold cache statement", processor)
+		}
+		if err := hp.RunHook(c.sql); err != nil {
+			return fmt.Errorf("cold cache statement failed: %v", err)
+		}
+	}
+	return nil
+}
+
+// totals returns the cold cache settings and the number of hooks for the
+// results file
+func (c *coldCache) totals() map[string]interface{} {
+	return map[string]interface{}{
+		"cmd":   c.cmd,
+		"sql":   c.sql,
+		"scope": c.scope,
+		"hooks": atomic.LoadUint64(&c.hooks),
+	}
+}
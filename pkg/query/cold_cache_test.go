@@ -0,0 +1,173 @@
+package query
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type hookProcessor struct {
+	mockProcessor
+	hooks []string
+	err   error
+}
+
+func (p *hookProcessor) RunHook(sql string) error {
+	p.hooks = append(p.hooks, sql)
+	return p.err
+}
+
+func TestNewColdCache(t *testing.T) {
+	if _, err := newColdCache("true", "", "batch"); err == nil {
+		t.Errorf("expected error for unknown scope")
+	}
+}
+
+func TestColdCacheScope(t *testing.T) {
+	a := &testQuery{HumanLabel: []byte("a")}
+	b := &testQuery{HumanLabel: []byte("b")}
+	queries := []*testQuery{a, a, b, b, a}
+	cases := []struct {
+		scope string
+		want  []bool
+	}{
+		{scope: coldScopeQuery, want: []bool{true, true, true, true, true}},
+		{scope: coldScopeLabel, want: []bool{true, false, true, false, true}},
+	}
+	for _, c := range cases {
+		p := &hookProcessor{}
+		cc, err := newColdCache("", "SELECT 1", c.scope)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, q := range queries {
+			cold, release := cc.acquire(p, q)
+			release()
+			if cold != c.want[i] {
+				t.Errorf("%s: query %d: got cold %v want %v", c.scope, i, cold, c.want[i])
+			}
+		}
+		hooks := 0
+		for _, cold := range c.want {
+			if cold {
+				hooks++
+			}
+		}
+		if len(p.hooks) != hooks || cc.totals()["hooks"] != uint64(hooks) {
+			t.Errorf("%s: incorrect number of hooks: got %d want %d", c.scope, len(p.hooks), hooks)
+		}
+	}
+}
+
+func TestColdCacheWorkers(t *testing.T) {
+	a := &testQuery{HumanLabel: []byte("a")}
+	b := &testQuery{HumanLabel: []byte("b")}
+	for _, scope := range []string{coldScopeQuery, coldScopeLabel} {
+		p := &hookProcessor{}
+		cc, err := newColdCache("", "SELECT 1", scope)
+		if err != nil {
+			t.Fatal(err)
+		}
+		queries := make(chan *testQuery, 40)
+		for i := 0; i < cap(queries); i++ {
+			if i < cap(queries)/2 {
+				queries <- a
+			} else {
+				queries <- b
+			}
+		}
+		close(queries)
+
+		var running, cold, overlaps int64
+		var wg sync.WaitGroup
+		for w := 0; w < 4; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for q := range queries {
+					isCold, release := cc.acquire(p, q)
+					n := atomic.AddInt64(&running, 1)
+					time.Sleep(time.Millisecond)
+					// a cold query runs alone from its start to its end
+					if isCold {
+						atomic.AddInt64(&cold, 1)
+						if n != 1 || atomic.LoadInt64(&running) != 1 {
+							atomic.AddInt64(&overlaps, 1)
+						}
+					}
+					atomic.AddInt64(&running, -1)
+					release()
+				}
+			}()
+		}
+		wg.Wait()
+
+		if overlaps != 0 {
+			t.Errorf("%s: %d cold queries ran with other queries", scope, overlaps)
+		}
+		if len(p.hooks) != int(cold) {
+			t.Errorf("%s: incorrect number of hooks: got %d want %d", scope, len(p.hooks), cold)
+		}
+		want := int64(cap(queries))
+		if scope == coldScopeLabel {
+			// the workers may take the queries of the second label before
+			// the last ones of the first, which starts another batch
+			want = 2
+		}
+		if cold < want {
+			t.Errorf("%s: incorrect number of cold queries: got %d want at least %d", scope, cold, want)
+		}
+	}
+}
+
+func TestColdCacheRun(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "hook")
+	cc, err := newColdCache("echo dropped >> "+out, "", coldScopeQuery)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cc.run(&mockProcessor{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	b, err := os.ReadFile(out)
+	if err != nil || string(b) != "dropped\n" {
+		t.Errorf("command was not run: %q, %v", b, err)
+	}
+
+	cc, _ = newColdCache("echo broken; exit 3", "", coldScopeQuery)
+	if err := cc.run(&mockProcessor{}); err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Errorf("expected error with the output of the command, got %v", err)
+	}
+	cc, _ = newColdCache("", "SELECT 1", coldScopeQuery)
+	if err := cc.run(&mockProcessor{}); err == nil {
+		t.Errorf("expected error for a processor that cannot run statements")
+	}
+	if err := cc.run(&hookProcessor{err: errors.New("denied")}); err == nil {
+		t.Errorf("expected error of the statement")
+	}
+}
+
+func TestRunQueryColdCache(t *testing.T) {
+	var sent []*Stat
+	sp := &mockStatProcessor{
+		args:   &statProcessorArgs{},
+		onSend: func(stats []*Stat) { sent = append(sent, stats...) },
+	}
+	b := &BenchmarkRunner{}
+	b.sp = sp
+	b.coldCache, _ = newColdCache("", "SELECT 1", coldScopeLabel)
+	p := &hookProcessor{}
+	q := &testQuery{HumanLabel: []byte("label")}
+	for i := 0; i < 2; i++ {
+		p.processRes = []*Stat{GetStat().Init(q.HumanLabel, 1)}
+		b.runQuery(p, q, 0)
+	}
+	if len(sent) != 2 || !sent[0].isCold || sent[1].isCold {
+		t.Errorf("only the first query of the label should be cold: %+v", sent)
+	}
+}
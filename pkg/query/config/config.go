@@ -50,7 +50,7 @@ func (c *QueryGeneratorConfig) Validate() error {
 func (c *QueryGeneratorConfig) AddToFlagSet(fs *pflag.FlagSet) {
 	c.BaseConfig.AddToFlagSet(fs)
 	fs.Uint64("queries", 1000, "Number of queries to generate.")
-	fs.String("query-type", "", "Query type. (Choices are in the use case matrix.)")
+	fs.String("query-type", "", "Query type, or a weighted mix of query types such as lastpoint:50,high-cpu-1:30 written interleaved into one file. (Choices are in the use case matrix.)")
 
 	fs.Uint("interleaved-generation-group-id", 0,
 		"Group (0-indexed) to perform round-robin serialization within. Use this to scale up data generation to multiple processes.")
@@ -0,0 +1,212 @@
+package query
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+)
+
+const (
+	latencyFormatCSV  = "csv"
+	latencyFormatJSON = "json"
+
+	// latencyCSVHeader follows the columns of the load runner's report, one
+	// line per label and interval
+	latencyCSVHeader = "time,label,per. query/s,query total,overall query/s,p50 ms,p95 ms,p99 ms,max ms"
+)
+
+// latencyPoint is one line of the latency time series in json format, the
+// percentiles are omitted for intervals without queries
+type latencyPoint struct {
+	Time        int64    `json:"time"`
+	Label       string   `json:"label"`
+	Rate        float64  `json:"rate"`
+	Total       uint64   `json:"total"`
+	OverallRate float64  `json:"overall_rate"`
+	P50         *float64 `json:"p50_ms,omitempty"`
+	P95         *float64 `json:"p95_ms,omitempty"`
+	P99         *float64 `json:"p99_ms,omitempty"`
+	Max         *float64 `json:"max_ms,omitempty"`
+}
+
+// intervalGroup holds the latencies of a label in the current interval and
+// its number of queries over the whole run
+type intervalGroup struct {
+	hist  *hdrhistogram.Histogram
+	count uint64
+	total uint64
+}
+
+// latencyTimeSeries writes the throughput and the latency percentiles of every
+// label per interval, from histograms that are reset after each interval
+type latencyTimeSeries struct {
+	w      io.Writer
+	format string
+
+	mu       sync.Mutex
+	groups   map[string]*intervalGroup
+	start    time.Time
+	prevTime time.Time
+}
+
+func newLatencyTimeSeries(w io.Writer, format string) (*latencyTimeSeries, error) {
+	if format != latencyFormatCSV && format != latencyFormatJSON {
+		return nil, fmt.Errorf("unknown latency time series format '%s', supports %s and %s", format, latencyFormatCSV, latencyFormatJSON)
+	}
+	return &latencyTimeSeries{w: w, format: format, groups: make(map[string]*intervalGroup)}, nil
+}
+
+// record adds the latency of a query, in ms, to the current interval
+func (ts *latencyTimeSeries) record(label string, value float64) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	g, ok := ts.groups[label]
+	if !ok {
+		g = &intervalGroup{hist: hdrhistogram.New(1, 3600000000, 3)}
+		ts.groups[label] = g
+	}
+	_ = g.hist.RecordValue(int64(value * hdrScaleFactor))
+	g.count++
+	g.total++
+}
+
+// begin starts the timeline and writes the header
+func (ts *latencyTimeSeries) begin(now time.Time) error {
+	ts.start = now
+	ts.prevTime = now
+	if ts.format == latencyFormatCSV {
+		_, err := fmt.Fprintln(ts.w, latencyCSVHeader)
+		return err
+	}
+	return nil
+}
+
+// flush writes the lines of the interval ending at now and starts the next one
+func (ts *latencyTimeSeries) flush(now time.Time) error {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	took := now.Sub(ts.prevTime).Seconds()
+	sinceStart := now.Sub(ts.start).Seconds()
+	ts.prevTime = now
+
+	labels := make([]string, 0, len(ts.groups))
+	for label := range ts.groups {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	for _, label := range labels {
+		g := ts.groups[label]
+		p := latencyPoint{
+			Time:        now.Unix(),
+			Label:       label,
+			Rate:        float64(g.count) / took,
+			Total:       g.total,
+			OverallRate: float64(g.total) / sinceStart,
+		}
+		if g.count > 0 {
+			p.P50 = latencyMs(g.hist.ValueAtQuantile(50))
+			p.P95 = latencyMs(g.hist.ValueAtQuantile(95))
+			p.P99 = latencyMs(g.hist.ValueAtQuantile(99))
+			p.Max = latencyMs(g.hist.Max())
+		}
+		if err := ts.write(p); err != nil {
+			return err
+		}
+		g.hist.Reset()
+		g.count = 0
+	}
+	// make every interval visible to readers of the file right away
+	if f, ok := ts.w.(interface{ Flush() error }); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+func (ts *latencyTimeSeries) write(p latencyPoint) error {
+	if ts.format == latencyFormatJSON {
+		b, err := json.Marshal(p)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(ts.w, string(b))
+		return err
+	}
+	percentiles := "-,-,-,-"
+	if p.P50 != nil {
+		percentiles = fmt.Sprintf("%0.2f,%0.2f,%0.2f,%0.2f", *p.P50, *p.P95, *p.P99, *p.Max)
+	}
+	_, err := fmt.Fprintf(ts.w, "%d,%s,%0.2f,%d,%0.2f,%s\n", p.Time, csvField(p.Label), p.Rate, p.Total, p.OverallRate, percentiles)
+	return err
+}
+
+// run flushes an interval every period until done is closed, then flushes the
+// last partial interval
+func (ts *latencyTimeSeries) run(period time.Duration, done <-chan struct{}) {
+	if err := ts.begin(time.Now()); err != nil {
+		panic(fmt.Sprintf("cannot write latency time series: %v", err))
+	}
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			if err := ts.flush(now); err != nil {
+				panic(fmt.Sprintf("cannot write latency time series: %v", err))
+			}
+		case <-done:
+			if err := ts.flush(time.Now()); err != nil {
+				panic(fmt.Sprintf("cannot write latency time series: %v", err))
+			}
+			return
+		}
+	}
+}
+
+// startLatencyTimeSeries opens file and starts writing the latency time
+// series to it, the returned function writes the last interval and closes it
+func startLatencyTimeSeries(file, format string, period time.Duration) (*latencyTimeSeries, func()) {
+	if period <= 0 {
+		panic("latency interval must be positive")
+	}
+	f, err := os.Create(file)
+	if err != nil {
+		panic(fmt.Sprintf("cannot create latency time series file %s: %v", file, err))
+	}
+	w := bufio.NewWriter(f)
+	ts, err := newLatencyTimeSeries(w, format)
+	if err != nil {
+		panic(err)
+	}
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		ts.run(period, done)
+		close(stopped)
+	}()
+	return ts, func() {
+		close(done)
+		<-stopped
+		f.Close()
+	}
+}
+
+func latencyMs(v int64) *float64 {
+	ms := float64(v) / hdrScaleFactor
+	return &ms
+}
+
+// csvField quotes labels that contain a comma or a quote
+func csvField(s string) string {
+	if !strings.ContainsAny(s, ",\"") {
+		return s
+	}
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
@@ -0,0 +1,79 @@
+package query
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLatencyTimeSeriesCSV(t *testing.T) {
+	var buf bytes.Buffer
+	ts, err := newLatencyTimeSeries(&buf, latencyFormatCSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Unix(1000, 0)
+	if err := ts.begin(start); err != nil {
+		t.Fatal(err)
+	}
+	ts.record("b, label", 1)
+	ts.record("a", 2)
+	ts.record("a", 4)
+	if err := ts.flush(start.Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	ts.record("a", 6)
+	if err := ts.flush(start.Add(2 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		latencyCSVHeader,
+		"1001,a,2.00,2,2.00,2.00,4.00,4.00,4.00",
+		`1001,"b, label",1.00,1,1.00,1.00,1.00,1.00,1.00`,
+		"1002,a,1.00,3,1.50,6.00,6.00,6.00,6.00",
+		`1002,"b, label",0.00,1,0.50,-,-,-,-`,
+	}
+	got := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(got) != len(want) {
+		t.Fatalf("incorrect number of lines: got\n%s", buf.String())
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("line %d: got %q want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestLatencyTimeSeriesJSON(t *testing.T) {
+	var buf bytes.Buffer
+	ts, err := newLatencyTimeSeries(&buf, latencyFormatJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Unix(1000, 0)
+	if err := ts.begin(start); err != nil {
+		t.Fatal(err)
+	}
+	ts.record("a", 2)
+	if err := ts.flush(start.Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if err := ts.flush(start.Add(2 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{"time":1001,"label":"a","rate":1,"total":1,"overall_rate":1,"p50_ms":2,"p95_ms":2,"p99_ms":2,"max_ms":2}
+{"time":1002,"label":"a","rate":0,"total":1,"overall_rate":0.5}
+`
+	if got := buf.String(); got != want {
+		t.Errorf("incorrect output: got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestLatencyTimeSeriesFormat(t *testing.T) {
+	if _, err := newLatencyTimeSeries(&bytes.Buffer{}, "xml"); err == nil {
+		t.Errorf("expected error for unknown format")
+	}
+}
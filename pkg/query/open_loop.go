@@ -0,0 +1,150 @@
+package query
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const (
+	arrivalConstant = "constant"
+	arrivalPoisson  = "poisson"
+
+	// defaultOpenLoopQueueSize is the number of scheduled queries that may wait
+	// for a worker before the scheduler blocks
+	defaultOpenLoopQueueSize = 100000
+)
+
+// scheduledQuery is a query with the time it was supposed to be sent at
+type scheduledQuery struct {
+	q        Query
+	intended time.Time
+}
+
+// openLoop schedules queries at a fixed rate, independent of how fast the
+// workers complete them. The latency of a query is measured from its intended
+// send time, so the time it waited for a free worker is included and overload
+// is not hidden by the workers slowing down the sending (coordinated omission).
+type openLoop struct {
+	rate     float64
+	arrival  string
+	interval time.Duration
+	rand     *rand.Rand
+	jobs     chan scheduledQuery
+
+	mu         sync.Mutex
+	depth      int
+	maxDepth   int
+	scheduled  uint64
+	missed     uint64
+	waitTotal  time.Duration
+	maxWait    time.Duration
+	startedAll uint64
+}
+
+func newOpenLoop(rate float64, arrival string, seed int64) (*openLoop, error) {
+	if rate <= 0 {
+		return nil, fmt.Errorf("open loop rate must be positive, got %f", rate)
+	}
+	if arrival != arrivalConstant && arrival != arrivalPoisson {
+		return nil, fmt.Errorf("unknown arrival distribution '%s', supports %s and %s", arrival, arrivalConstant, arrivalPoisson)
+	}
+	return &openLoop{
+		rate:     rate,
+		arrival:  arrival,
+		interval: time.Duration(float64(time.Second) / rate),
+		rand:     rand.New(rand.NewSource(seed)),
+		jobs:     make(chan scheduledQuery, defaultOpenLoopQueueSize),
+	}, nil
+}
+
+// next returns the time between two queries, exponentially distributed
+// around the mean interval for poisson arrivals
+func (o *openLoop) next() time.Duration {
+	if o.arrival == arrivalPoisson {
+		return time.Duration(o.rand.ExpFloat64() * float64(o.interval))
+	}
+	return o.interval
+}
+
+// schedule sends the queries of in to the workers at their intended send
+// times, starting at start, and closes the jobs channel when in is closed
+func (o *openLoop) schedule(in <-chan Query, start time.Time) {
+	intended := start
+	for q := range in {
+		if d := time.Until(intended); d > 0 {
+			time.Sleep(d)
+		}
+		o.mu.Lock()
+		o.scheduled++
+		o.depth++
+		if o.depth > o.maxDepth {
+			o.maxDepth = o.depth
+		}
+		o.mu.Unlock()
+		o.jobs <- scheduledQuery{q: q, intended: intended}
+		intended = intended.Add(o.next())
+	}
+	close(o.jobs)
+}
+
+// started records that a worker took a query off the queue and returns how
+// long it waited past its intended send time. A query that waited longer than
+// the mean interval missed its deadline: the next query was already due.
+func (o *openLoop) started(job scheduledQuery) time.Duration {
+	wait := time.Since(job.intended)
+	if wait < 0 {
+		wait = 0
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.depth--
+	o.startedAll++
+	o.waitTotal += wait
+	if wait > o.maxWait {
+		o.maxWait = wait
+	}
+	if wait > o.interval {
+		o.missed++
+	}
+	return wait
+}
+
+// totals returns the open loop stats for the results file
+func (o *openLoop) totals() map[string]interface{} {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	meanWait := 0.0
+	if o.startedAll > 0 {
+		meanWait = float64(o.waitTotal.Nanoseconds()) / float64(o.startedAll) / 1e6
+	}
+	return map[string]interface{}{
+		"rate":           o.rate,
+		"arrival":        o.arrival,
+		"scheduled":      o.scheduled,
+		"missedDeadline": o.missed,
+		"maxQueueDepth":  o.maxDepth,
+		"meanQueueWait":  meanWait,
+		"maxQueueWait":   float64(o.maxWait.Nanoseconds()) / 1e6,
+	}
+}
+
+// summary describes the open loop stats of the run
+func (o *openLoop) summary() string {
+	t := o.totals()
+	missedPct := 0.0
+	if o.scheduled > 0 {
+		missedPct = float64(o.missed) / float64(o.scheduled) * 100
+	}
+	return fmt.Sprintf("Open loop: %d queries scheduled at %0.2f queries/sec (%s arrivals), missed deadlines: %d (%0.2f%%), max queue depth: %d, queue wait mean: %0.2fms, max: %0.2fms\n",
+		o.scheduled, o.rate, o.arrival, o.missed, missedPct, o.maxDepth, t["meanQueueWait"], t["maxQueueWait"])
+}
+
+// addWait adds the time a query waited for a worker to its stats
+func addWait(stats []*Stat, wait time.Duration) {
+	ms := float64(wait.Nanoseconds()) / 1e6
+	for _, s := range stats {
+		s.value += ms
+	}
+}
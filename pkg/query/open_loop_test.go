@@ -0,0 +1,143 @@
+package query
+
+import (
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNewOpenLoop(t *testing.T) {
+	if _, err := newOpenLoop(0, arrivalConstant, 1); err == nil {
+		t.Errorf("expected error for zero rate")
+	}
+	if _, err := newOpenLoop(10, "bursty", 1); err == nil {
+		t.Errorf("expected error for unknown arrival")
+	}
+	o, err := newOpenLoop(4, arrivalConstant, 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if o.interval != 250*time.Millisecond {
+		t.Errorf("incorrect interval: got %v want %v", o.interval, 250*time.Millisecond)
+	}
+}
+
+func TestOpenLoopScheduleConstant(t *testing.T) {
+	o, err := newOpenLoop(1000, arrivalConstant, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := make(chan Query, 5)
+	for i := 0; i < 5; i++ {
+		in <- &testQuery{}
+	}
+	close(in)
+	start := time.Now()
+	o.schedule(in, start)
+
+	i := 0
+	for job := range o.jobs {
+		want := start.Add(time.Duration(i) * time.Millisecond)
+		if !job.intended.Equal(want) {
+			t.Errorf("query %d: incorrect intended time: got %v want %v", i, job.intended.Sub(start), want.Sub(start))
+		}
+		i++
+	}
+	if i != 5 {
+		t.Errorf("incorrect number of scheduled queries: got %d want 5", i)
+	}
+	// nothing took the queries off the queue
+	if o.maxDepth != 5 || o.scheduled != 5 {
+		t.Errorf("incorrect queue stats: max depth %d, scheduled %d", o.maxDepth, o.scheduled)
+	}
+	if elapsed := time.Since(start); elapsed < 4*time.Millisecond {
+		t.Errorf("queries were sent before their intended time: took %v", elapsed)
+	}
+}
+
+func TestOpenLoopPoissonMean(t *testing.T) {
+	o, err := newOpenLoop(100, arrivalPoisson, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 10000
+	var total time.Duration
+	for i := 0; i < n; i++ {
+		total += o.next()
+	}
+	mean := float64(total) / float64(n)
+	if math.Abs(mean-float64(o.interval))/float64(o.interval) > 0.05 {
+		t.Errorf("incorrect mean interval: got %v want %v", time.Duration(mean), o.interval)
+	}
+}
+
+func TestOpenLoopStarted(t *testing.T) {
+	o, err := newOpenLoop(100, arrivalConstant, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o.depth = 2
+	o.scheduled = 2
+	onTime := o.started(scheduledQuery{intended: time.Now().Add(time.Second)})
+	if onTime != 0 {
+		t.Errorf("early query should not wait: got %v", onTime)
+	}
+	late := o.started(scheduledQuery{intended: time.Now().Add(-50 * time.Millisecond)})
+	if late < 50*time.Millisecond {
+		t.Errorf("incorrect wait of late query: got %v", late)
+	}
+	if o.missed != 1 {
+		t.Errorf("incorrect missed deadlines: got %d want 1", o.missed)
+	}
+	if o.depth != 0 {
+		t.Errorf("incorrect queue depth: got %d want 0", o.depth)
+	}
+	totals := o.totals()
+	if totals["missedDeadline"] != uint64(1) || totals["maxQueueWait"].(float64) < 50 {
+		t.Errorf("incorrect totals: %v", totals)
+	}
+}
+
+func TestAddWait(t *testing.T) {
+	stats := []*Stat{{value: 1}, {value: 2.5}}
+	addWait(stats, 1500*time.Microsecond)
+	if stats[0].value != 2.5 || stats[1].value != 4 {
+		t.Errorf("incorrect latencies: got %f, %f", stats[0].value, stats[1].value)
+	}
+}
+
+func TestOpenLoopHandler(t *testing.T) {
+	qLimit := 17
+	p1 := &testProcessor{}
+	p2 := &testProcessor{}
+	b := NewBenchmarkRunner(BenchmarkRunnerConfig{})
+	o, err := newOpenLoop(10000, arrivalPoisson, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.openLoop = o
+	b.ch = make(chan Query, 2)
+
+	var wg sync.WaitGroup
+	qPool := &testQueryPool
+	wg.Add(2)
+	go b.openLoopHandler(&wg, qPool, p1, 0)
+	go b.openLoopHandler(&wg, qPool, p2, 5)
+	go o.schedule(b.ch, time.Now())
+	for i := 0; i < qLimit; i++ {
+		b.ch <- qPool.Get().(*testQuery)
+	}
+	close(b.ch)
+	wg.Wait()
+
+	if p1.wNum != 0 || p2.wNum != 5 {
+		t.Errorf("Init() not called: got %d, %d", p1.wNum, p2.wNum)
+	}
+	if p1.count+p2.count != qLimit {
+		t.Errorf("total queries wrong: want %d got %d", qLimit, p1.count+p2.count)
+	}
+	if o.scheduled != uint64(qLimit) || o.depth != 0 {
+		t.Errorf("incorrect queue stats: scheduled %d, depth %d", o.scheduled, o.depth)
+	}
+}
@@ -0,0 +1,324 @@
+package query
+
+import (
+	"bufio"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Formats of query files
+const (
+	FileFormatGob  = "gob"
+	FileFormatJSON = "json"
+)
+
+// jsonQuery is a query in a JSON-lines query file, one object per line. The
+// fields that do not apply to a query type are omitted, e.g. the HTTP queries
+// have a method and a path instead of sql.
+type jsonQuery struct {
+	Label       string `json:"label"`
+	Description string `json:"description"`
+	Table       string `json:"table,omitempty"`
+	QueryType   string `json:"query_type,omitempty"`
+	SQL         string `json:"sql,omitempty"`
+	// Prepare marks a prepared KWDB query, whose Args replace the sql
+	Prepare bool     `json:"prepare,omitempty"`
+	Args    []string `json:"args,omitempty"`
+
+	Method         string `json:"method,omitempty"`
+	Path           string `json:"path,omitempty"`
+	Body           string `json:"body,omitempty"`
+	RawQuery       string `json:"raw_query,omitempty"`
+	StartTimestamp int64  `json:"start_timestamp,omitempty"`
+	EndTimestamp   int64  `json:"end_timestamp,omitempty"`
+}
+
+// jsonConverter is implemented by the query types that can be read from and
+// written to JSON-lines query files
+type jsonConverter interface {
+	toJSON() *jsonQuery
+	fromJSON(jq *jsonQuery)
+}
+
+// queryDecoder decodes the queries of a query file
+type queryDecoder interface {
+	decode(q Query) error
+}
+
+type gobQueryDecoder struct {
+	dec *gob.Decoder
+}
+
+func (d *gobQueryDecoder) decode(q Query) error {
+	return d.dec.Decode(q)
+}
+
+type jsonQueryDecoder struct {
+	dec *json.Decoder
+}
+
+func (d *jsonQueryDecoder) decode(q Query) error {
+	c, ok := q.(jsonConverter)
+	if !ok {
+		return fmt.Errorf("query type %T cannot be read from JSON-lines", q)
+	}
+	jq := &jsonQuery{}
+	if err := d.dec.Decode(jq); err != nil {
+		return err
+	}
+	c.fromJSON(jq)
+	return nil
+}
+
+// newQueryDecoder returns the decoder of the format of the query file of r.
+// JSON-lines files start with an object, which a gob stream never does.
+func newQueryDecoder(r io.Reader) queryDecoder {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	if skip, ok := jsonStart(br); ok {
+		// the JSON decoder skips whitespace but not a byte order mark
+		br.Discard(skip)
+		return &jsonQueryDecoder{dec: json.NewDecoder(br)}
+	}
+	return &gobQueryDecoder{dec: gob.NewDecoder(br)}
+}
+
+// utf8BOM is the byte order mark some editors write at the start of a file
+const utf8BOM = "\xef\xbb\xbf"
+
+// jsonStart reports whether br starts with a JSON object, i.e. '{' and '"'
+// after an optional UTF-8 byte order mark and whitespace, and returns the
+// number of bytes before the object. Whitespace may start a gob stream, but
+// a gob stream never continues with '{'.
+func jsonStart(br *bufio.Reader) (int, bool) {
+	n := 0
+	if start, err := br.Peek(len(utf8BOM)); err == nil && string(start) == utf8BOM {
+		n = len(utf8BOM)
+	}
+	skip := -1
+	for {
+		b, err := br.Peek(n + 1)
+		if err != nil {
+			return 0, false
+		}
+		switch c := b[n]; {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+		case c == '{' && skip < 0:
+			skip = n
+		case c == '"' && skip >= 0:
+			return skip, true
+		default:
+			return 0, false
+		}
+		n++
+	}
+}
+
+// queryEncoder encodes queries in the format of a query file
+type queryEncoder interface {
+	encode(q Query) error
+}
+
+type gobQueryEncoder struct {
+	enc *gob.Encoder
+}
+
+func (e *gobQueryEncoder) encode(q Query) error {
+	return e.enc.Encode(q)
+}
+
+type jsonQueryEncoder struct {
+	enc *json.Encoder
+}
+
+func (e *jsonQueryEncoder) encode(q Query) error {
+	c, ok := q.(jsonConverter)
+	if !ok {
+		return fmt.Errorf("query type %T cannot be written as JSON-lines", q)
+	}
+	return e.enc.Encode(c.toJSON())
+}
+
+func newQueryEncoder(w io.Writer, format string) (queryEncoder, error) {
+	switch format {
+	case FileFormatGob:
+		return &gobQueryEncoder{enc: gob.NewEncoder(w)}, nil
+	case FileFormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		return &jsonQueryEncoder{enc: enc}, nil
+	default:
+		return nil, fmt.Errorf("unknown query file format '%s', supports %s and %s", format, FileFormatGob, FileFormatJSON)
+	}
+}
+
+// ConvertQueries reads the queries of r, a gob or JSON-lines query file of
+// the query type of pool, and writes them to w in format. It returns the
+// number of converted queries.
+func ConvertQueries(r io.Reader, w io.Writer, pool *sync.Pool, format string) (uint64, error) {
+	enc, err := newQueryEncoder(w, format)
+	if err != nil {
+		return 0, err
+	}
+	dec := newQueryDecoder(r)
+	n := uint64(0)
+	for {
+		q := pool.Get().(Query)
+		err := dec.decode(q)
+		if err == io.EOF {
+			q.Release()
+			return n, nil
+		}
+		if err != nil {
+			q.Release()
+			return n, fmt.Errorf("cannot read query %d: %v", n, err)
+		}
+		err = enc.encode(q)
+		q.Release()
+		if err != nil {
+			return n, fmt.Errorf("cannot write query %d: %v", n, err)
+		}
+		n++
+	}
+}
+
+func (q *Kwdb) toJSON() *jsonQuery {
+	jq := &jsonQuery{
+		Label:       string(q.HumanLabel),
+		Description: string(q.HumanDescription),
+		Table:       string(q.Hypertable),
+		QueryType:   q.Querytype,
+		Prepare:     q.Prepare,
+	}
+	// the sql of a prepared query holds the comma separated arguments of the
+	// statement of its query type
+	if q.Prepare {
+		if len(q.SqlQuery) > 0 {
+			jq.Args = strings.Split(string(q.SqlQuery), ",")
+		}
+	} else {
+		jq.SQL = string(q.SqlQuery)
+	}
+	return jq
+}
+
+func (q *Kwdb) fromJSON(jq *jsonQuery) {
+	q.HumanLabel = []byte(jq.Label)
+	q.HumanDescription = []byte(jq.Description)
+	q.Hypertable = []byte(jq.Table)
+	q.Querytype = jq.QueryType
+	q.Prepare = jq.Prepare
+	if q.Prepare {
+		q.SqlQuery = []byte(strings.Join(jq.Args, ","))
+	} else {
+		q.SqlQuery = []byte(jq.SQL)
+	}
+}
+
+func (q *TimescaleDB) toJSON() *jsonQuery {
+	return &jsonQuery{
+		Label:       string(q.HumanLabel),
+		Description: string(q.HumanDescription),
+		Table:       string(q.Hypertable),
+		SQL:         string(q.SqlQuery),
+	}
+}
+
+func (q *TimescaleDB) fromJSON(jq *jsonQuery) {
+	q.HumanLabel = []byte(jq.Label)
+	q.HumanDescription = []byte(jq.Description)
+	q.Hypertable = []byte(jq.Table)
+	q.SqlQuery = []byte(jq.SQL)
+}
+
+func (q *ClickHouse) toJSON() *jsonQuery {
+	return &jsonQuery{
+		Label:       string(q.HumanLabel),
+		Description: string(q.HumanDescription),
+		Table:       string(q.Table),
+		SQL:         string(q.SqlQuery),
+	}
+}
+
+func (q *ClickHouse) fromJSON(jq *jsonQuery) {
+	q.HumanLabel = []byte(jq.Label)
+	q.HumanDescription = []byte(jq.Description)
+	q.Table = []byte(jq.Table)
+	q.SqlQuery = []byte(jq.SQL)
+}
+
+func (q *CrateDB) toJSON() *jsonQuery {
+	return &jsonQuery{
+		Label:       string(q.HumanLabel),
+		Description: string(q.HumanDescription),
+		Table:       string(q.Table),
+		SQL:         string(q.SqlQuery),
+	}
+}
+
+func (q *CrateDB) fromJSON(jq *jsonQuery) {
+	q.HumanLabel = []byte(jq.Label)
+	q.HumanDescription = []byte(jq.Description)
+	q.Table = []byte(jq.Table)
+	q.SqlQuery = []byte(jq.SQL)
+}
+
+func (q *Timestream) toJSON() *jsonQuery {
+	return &jsonQuery{
+		Label:       string(q.HumanLabel),
+		Description: string(q.HumanDescription),
+		Table:       string(q.Table),
+		SQL:         string(q.SqlQuery),
+	}
+}
+
+func (q *Timestream) fromJSON(jq *jsonQuery) {
+	q.HumanLabel = []byte(jq.Label)
+	q.HumanDescription = []byte(jq.Description)
+	q.Table = []byte(jq.Table)
+	q.SqlQuery = []byte(jq.SQL)
+}
+
+func (q *SiriDB) toJSON() *jsonQuery {
+	return &jsonQuery{
+		Label:       string(q.HumanLabel),
+		Description: string(q.HumanDescription),
+		SQL:         string(q.SqlQuery),
+	}
+}
+
+func (q *SiriDB) fromJSON(jq *jsonQuery) {
+	q.HumanLabel = []byte(jq.Label)
+	q.HumanDescription = []byte(jq.Description)
+	q.SqlQuery = []byte(jq.SQL)
+}
+
+func (q *HTTP) toJSON() *jsonQuery {
+	return &jsonQuery{
+		Label:          string(q.HumanLabel),
+		Description:    string(q.HumanDescription),
+		Method:         string(q.Method),
+		Path:           string(q.Path),
+		Body:           string(q.Body),
+		RawQuery:       string(q.RawQuery),
+		StartTimestamp: q.StartTimestamp,
+		EndTimestamp:   q.EndTimestamp,
+	}
+}
+
+func (q *HTTP) fromJSON(jq *jsonQuery) {
+	q.HumanLabel = []byte(jq.Label)
+	q.HumanDescription = []byte(jq.Description)
+	q.Method = []byte(jq.Method)
+	q.Path = []byte(jq.Path)
+	q.Body = []byte(jq.Body)
+	q.RawQuery = []byte(jq.RawQuery)
+	q.StartTimestamp = jq.StartTimestamp
+	q.EndTimestamp = jq.EndTimestamp
+}
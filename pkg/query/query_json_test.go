@@ -0,0 +1,196 @@
+package query
+
+import (
+	"bufio"
+	"bytes"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestConvertQueriesRoundTrip(t *testing.T) {
+	queries := []*Kwdb{
+		{
+			Querytype:        "high-cpu-1",
+			HumanLabel:       []byte("KWDB CPU over threshold, 1 host(s)"),
+			HumanDescription: []byte("KWDB CPU over threshold, 1 host(s): 2016-01-01T00:00:00Z"),
+			Hypertable:       []byte("cpu"),
+			SqlQuery:         []byte("SELECT * FROM benchmark.cpu WHERE hostname='host_1' AND usage_user > 90.0"),
+		},
+		{
+			Querytype:  "high-cpu-1",
+			Prepare:    true,
+			HumanLabel: []byte("KWDB CPU over threshold, 1 host(s)"),
+			Hypertable: []byte("cpu"),
+			SqlQuery:   []byte("host_1,1451606400000,1451649600000"),
+		},
+		{
+			Querytype:  "lastpoint",
+			Prepare:    true,
+			HumanLabel: []byte("KWDB last row per host"),
+			Hypertable: []byte("cpu"),
+			SqlQuery:   []byte{},
+		},
+	}
+	var gobFile bytes.Buffer
+	enc, err := newQueryEncoder(&gobFile, FileFormatGob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, q := range queries {
+		if err := enc.encode(q); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := gobFile.String()
+
+	var jsonFile bytes.Buffer
+	n, err := ConvertQueries(&gobFile, &jsonFile, &KwdbPool, FileFormatJSON)
+	if err != nil {
+		t.Fatalf("cannot convert to json: %v", err)
+	}
+	if n != uint64(len(queries)) {
+		t.Errorf("incorrect number of queries: got %d want %d", n, len(queries))
+	}
+	lines := strings.Split(strings.TrimSpace(jsonFile.String()), "\n")
+	if len(lines) != len(queries) {
+		t.Fatalf("expected one line per query, got:\n%s", jsonFile.String())
+	}
+	wantLine := `{"label":"KWDB CPU over threshold, 1 host(s)","description":"","table":"cpu","query_type":"high-cpu-1","prepare":true,"args":["host_1","1451606400000","1451649600000"]}`
+	if lines[1] != wantLine {
+		t.Errorf("incorrect json line:\ngot  %s\nwant %s", lines[1], wantLine)
+	}
+
+	var back bytes.Buffer
+	if _, err := ConvertQueries(&jsonFile, &back, &KwdbPool, FileFormatGob); err != nil {
+		t.Fatalf("cannot convert to gob: %v", err)
+	}
+	if back.String() != want {
+		t.Errorf("gob file changed in the round trip")
+	}
+}
+
+func TestConvertQueriesHTTP(t *testing.T) {
+	in := `{"label":"Influx max cpu","description":"d","method":"GET","path":"/query","raw_query":"q=SELECT","start_timestamp":1,"end_timestamp":2}` + "\n"
+	var gobFile bytes.Buffer
+	if _, err := ConvertQueries(strings.NewReader(in), &gobFile, &HTTPPool, FileFormatGob); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if _, err := ConvertQueries(&gobFile, &out, &HTTPPool, FileFormatJSON); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != in {
+		t.Errorf("incorrect round trip:\ngot  %s\nwant %s", out.String(), in)
+	}
+}
+
+func TestConvertQueriesErrors(t *testing.T) {
+	pool := &sync.Pool{New: func() interface{} { return &testQuery{} }}
+	in := `{"label":"a","description":"b","sql":"SELECT 1"}`
+	if _, err := ConvertQueries(strings.NewReader(in), &bytes.Buffer{}, pool, FileFormatGob); err == nil {
+		t.Errorf("expected error for a query type without JSON-lines support")
+	}
+	if _, err := ConvertQueries(strings.NewReader(in), &bytes.Buffer{}, &KwdbPool, "xml"); err == nil {
+		t.Errorf("expected error for unknown format")
+	}
+	if _, err := ConvertQueries(strings.NewReader(in+"\n{bad"), &bytes.Buffer{}, &KwdbPool, FileFormatGob); err == nil {
+		t.Errorf("expected error for malformed line")
+	}
+}
+
+// releasedQuery counts the queries released back to their pool
+type releasedQuery struct {
+	Kwdb
+	released *int
+}
+
+func (q *releasedQuery) Release() { *q.released++ }
+
+func TestConvertQueriesRelease(t *testing.T) {
+	cases := []struct {
+		desc    string
+		in      string
+		wantErr bool
+	}{
+		{desc: "eof", in: `{"label":"a","description":"","sql":"SELECT 1"}` + "\n"},
+		{desc: "malformed line", in: `{"label":"a","description":"","sql":"SELECT 1"}` + "\n{bad", wantErr: true},
+	}
+	for _, c := range cases {
+		made, released := 0, 0
+		pool := &sync.Pool{New: func() interface{} {
+			made++
+			return &releasedQuery{released: &released}
+		}}
+		_, err := ConvertQueries(strings.NewReader(c.in), &bytes.Buffer{}, pool, FileFormatGob)
+		if gotErr := err != nil; gotErr != c.wantErr {
+			t.Errorf("%s: incorrect error: %v", c.desc, err)
+		}
+		if released != made {
+			t.Errorf("%s: %d of %d queries released", c.desc, released, made)
+		}
+	}
+}
+
+func TestNewQueryDecoder(t *testing.T) {
+	line := `{"label":"a","description":"","sql":"SELECT 1"}` + "\n"
+	var gobFile bytes.Buffer
+	if _, err := ConvertQueries(strings.NewReader(line), &gobFile, &KwdbPool, FileFormatGob); err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		desc     string
+		in       string
+		wantJSON bool
+	}{
+		{desc: "json", in: line, wantJSON: true},
+		{desc: "leading whitespace", in: " \n\t" + line, wantJSON: true},
+		{desc: "byte order mark", in: utf8BOM + line, wantJSON: true},
+		{desc: "byte order mark and whitespace", in: utf8BOM + "\r\n" + line, wantJSON: true},
+		{desc: "space inside the object", in: `{ "label":"a","description":"","sql":"SELECT 1"}` + "\n", wantJSON: true},
+		{desc: "gob", in: gobFile.String()},
+		{desc: "brace without a key", in: "{}\n"},
+		{desc: "empty", in: ""},
+	}
+	for _, c := range cases {
+		dec := newQueryDecoder(strings.NewReader(c.in))
+		if _, gotJSON := dec.(*jsonQueryDecoder); gotJSON != c.wantJSON {
+			t.Errorf("%s: incorrect decoder %T", c.desc, dec)
+			continue
+		}
+		if !c.wantJSON || c.in == "" {
+			continue
+		}
+		q := &Kwdb{}
+		if err := dec.decode(q); err != nil {
+			t.Errorf("%s: cannot decode: %v", c.desc, err)
+		} else if string(q.SqlQuery) != "SELECT 1" {
+			t.Errorf("%s: incorrect sql: got %s", c.desc, q.SqlQuery)
+		}
+	}
+}
+
+func TestScannerJSONLines(t *testing.T) {
+	in := `{"label":"a","description":"","table":"cpu","query_type":"lastpoint","sql":"SELECT 1"}
+{"label":"b","description":"","table":"cpu","query_type":"lastpoint","sql":"SELECT 2"}
+
+{"label":"c","description":"","table":"cpu","query_type":"lastpoint","sql":"SELECT 3"}
+`
+	limit := uint64(0)
+	c := make(chan Query, 3)
+	newScanner(&limit).setReader(bufio.NewReader(strings.NewReader(in))).scan(&KwdbPool, c)
+	close(c)
+	var got []string
+	for q := range c {
+		k := q.(*Kwdb)
+		got = append(got, string(k.HumanLabel)+":"+string(k.SqlQuery))
+		if k.GetID() != uint64(len(got)-1) {
+			t.Errorf("incorrect id: got %d want %d", k.GetID(), len(got)-1)
+		}
+	}
+	want := []string{"a:SELECT 1", "b:SELECT 2", "c:SELECT 3"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("incorrect queries: got %v want %v", got, want)
+	}
+}
@@ -0,0 +1,183 @@
+package query
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// replayTimeFormats are the formats of the timestamps of a captured workload,
+// besides unix epoch seconds or milliseconds
+var replayTimeFormats = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999-07:00",
+}
+
+var (
+	fingerprintString  = regexp.MustCompile(`'(?:[^']|'')*'`)
+	fingerprintNumber  = regexp.MustCompile(`\b\d+(?:\.\d+)?(?:[eE][-+]?\d+)?\b`)
+	fingerprintList    = regexp.MustCompile(`\(\s*\?(?:\s*,\s*\?)*\s*\)`)
+	fingerprintSpace   = regexp.MustCompile(`\s+`)
+	fingerprintPrepare = regexp.MustCompile(`\$\d+`)
+)
+
+// fingerprint normalizes a statement to group its latencies with those of the
+// statements that only differ in their literals, e.g.
+// SELECT * FROM cpu WHERE hostname IN ('host_1','host_2') AND usage_user > 90
+// becomes SELECT * FROM cpu WHERE hostname IN (?) AND usage_user > ?
+func fingerprint(sql string) string {
+	s := fingerprintString.ReplaceAllString(sql, "?")
+	s = fingerprintPrepare.ReplaceAllString(s, "?")
+	s = fingerprintNumber.ReplaceAllString(s, "?")
+	s = fingerprintSpace.ReplaceAllString(strings.TrimSpace(s), " ")
+	return fingerprintList.ReplaceAllString(s, "(?)")
+}
+
+// replayStatement is a statement of a captured workload with the time it was
+// captured at, zero when the line had no timestamp
+type replayStatement struct {
+	at  time.Time
+	sql string
+}
+
+// parseReplayLine parses a line of a captured workload: a statement, or a
+// timestamp and a statement separated by a tab. A line whose text before the
+// first tab is not a timestamp is a statement holding a tab. Blank lines and
+// -- comments are skipped.
+func parseReplayLine(line string) (replayStatement, bool) {
+	line = strings.TrimSpace(line)
+	if len(line) == 0 || strings.HasPrefix(line, "--") {
+		return replayStatement{}, false
+	}
+	st := replayStatement{sql: line}
+	if i := strings.IndexByte(line, '\t'); i >= 0 {
+		if at, err := parseReplayTime(strings.TrimSpace(line[:i])); err == nil {
+			st.at = at
+			st.sql = strings.TrimSpace(line[i+1:])
+		}
+	}
+	st.sql = strings.TrimSpace(strings.TrimSuffix(st.sql, ";"))
+	return st, len(st.sql) > 0
+}
+
+func parseReplayTime(s string) (time.Time, error) {
+	// 13 digits are milliseconds since the epoch, seconds until the year 5138
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		if len(s) >= 13 {
+			return time.Unix(0, n*int64(time.Millisecond)), nil
+		}
+		return time.Unix(n, 0), nil
+	}
+	if n, err := strconv.ParseFloat(s, 64); err == nil {
+		return time.Unix(0, int64(n*1e9)), nil
+	}
+	for _, f := range replayTimeFormats {
+		if t, err := time.Parse(f, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unknown timestamp format '%s'", s)
+}
+
+// replayScanner reads a captured SQL workload and sends its statements to the
+// workers as queries labeled by their fingerprint. With a positive speed the
+// statements keep the pacing of their timestamps, divided by speed, otherwise
+// they are sent as fast as the workers take them.
+type replayScanner struct {
+	r     io.Reader
+	limit *uint64
+	speed float64
+
+	// maxLag is the most a statement was sent after its replay time
+	maxLag time.Duration
+	late   uint64
+}
+
+func newReplayScanner(r io.Reader, limit *uint64, speed float64) *replayScanner {
+	return &replayScanner{r: r, limit: limit, speed: speed}
+}
+
+// scan reads the statements and places them into c as queries of pool, which
+// must be of a query type that can be read from JSON-lines
+func (s *replayScanner) scan(pool *sync.Pool, c chan Query) {
+	br := bufio.NewReaderSize(s.r, defaultReadSize)
+	var first time.Time
+	var start time.Time
+	n := uint64(0)
+	for {
+		if *s.limit > 0 && n >= *s.limit {
+			break
+		}
+		line, err := br.ReadString('\n')
+		if err != nil && err != io.EOF {
+			log.Fatal(err)
+		}
+		st, ok := parseReplayLine(line)
+		if ok {
+			q := pool.Get().(Query)
+			conv, isConv := q.(jsonConverter)
+			if !isConv {
+				log.Fatalf("query type %T cannot replay a workload", q)
+			}
+			label := fingerprint(st.sql)
+			conv.fromJSON(&jsonQuery{Label: label, Description: st.sql, SQL: st.sql})
+			if conv.toJSON().SQL != st.sql {
+				log.Fatalf("query type %T cannot replay SQL statements", q)
+			}
+
+			// pace the statements by their timestamps, relative to the first one
+			var due time.Time
+			if s.speed > 0 && !st.at.IsZero() {
+				if first.IsZero() {
+					first = st.at
+					start = time.Now()
+				}
+				due = start.Add(time.Duration(float64(st.at.Sub(first)) / s.speed))
+				if d := time.Until(due); d > 0 {
+					time.Sleep(d)
+				}
+			}
+			q.SetID(n)
+			c <- q
+			if !due.IsZero() {
+				lag := time.Since(due)
+				if lag > s.maxLag {
+					s.maxLag = lag
+				}
+				if lag > time.Millisecond {
+					s.late++
+				}
+			}
+			n++
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+}
+
+// summary describes how far the replay fell behind the pacing of the workload
+func (s *replayScanner) summary() string {
+	if s.speed <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("Replay at %0.2fx speed: %d statements sent more than 1ms late, max lag: %0.2fms\n",
+		s.speed, s.late, float64(s.maxLag.Nanoseconds())/1e6)
+}
+
+// openReplayFile opens the captured workload of --replay-file
+func openReplayFile(file string) *os.File {
+	f, err := os.Open(file)
+	if err != nil {
+		panic(fmt.Sprintf("cannot open replay file %s: %v", file, err))
+	}
+	return f
+}
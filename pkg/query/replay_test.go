@@ -0,0 +1,101 @@
+package query
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFingerprint(t *testing.T) {
+	cases := []struct {
+		in   string
+		want string
+	}{
+		{
+			in:   "SELECT * FROM benchmark.cpu WHERE hostname IN ('host_1', 'host_2','host_3') AND usage_user > 90.5",
+			want: "SELECT * FROM benchmark.cpu WHERE hostname IN (?) AND usage_user > ?",
+		},
+		{
+			in:   "SELECT time_bucket(k_timestamp, '60s'),\n\tmax(usage_user) FROM cpu2 WHERE k_timestamp >= '2016-01-01 00:00:00' LIMIT 5",
+			want: "SELECT time_bucket(k_timestamp, ?), max(usage_user) FROM cpu2 WHERE k_timestamp >= ? LIMIT ?",
+		},
+		{
+			in:   "SELECT name FROM t WHERE name = 'it''s' AND id = $1",
+			want: "SELECT name FROM t WHERE name = ? AND id = ?",
+		},
+	}
+	for _, c := range cases {
+		if got := fingerprint(c.in); got != c.want {
+			t.Errorf("incorrect fingerprint of %q:\ngot  %s\nwant %s", c.in, got, c.want)
+		}
+	}
+}
+
+func TestParseReplayLine(t *testing.T) {
+	cases := []struct {
+		in  string
+		ok  bool
+		at  time.Time
+		sql string
+	}{
+		{in: "SELECT 1;", ok: true, sql: "SELECT 1"},
+		{in: "  ", ok: false},
+		{in: "-- dashboard", ok: false},
+		{in: "2024-05-01T10:00:00.5Z\tSELECT 1", ok: true, at: time.Date(2024, 5, 1, 10, 0, 0, 5e8, time.UTC), sql: "SELECT 1"},
+		{in: "2024-05-01 10:00:01\tSELECT 2", ok: true, at: time.Date(2024, 5, 1, 10, 0, 1, 0, time.UTC), sql: "SELECT 2"},
+		{in: "1714557600\tSELECT 3", ok: true, at: time.Unix(1714557600, 0), sql: "SELECT 3"},
+		{in: "1714557600250\tSELECT 4", ok: true, at: time.Unix(1714557600, 25e7), sql: "SELECT 4"},
+		// statements holding a tab have no timestamp
+		{in: "SELECT\t* FROM cpu", ok: true, sql: "SELECT\t* FROM cpu"},
+		{in: "\tSELECT 5;", ok: true, sql: "SELECT 5"},
+		{in: "1714557600\tSELECT\t6", ok: true, at: time.Unix(1714557600, 0), sql: "SELECT\t6"},
+	}
+	for _, c := range cases {
+		st, ok := parseReplayLine(c.in)
+		if ok != c.ok || st.sql != c.sql || !st.at.Equal(c.at) {
+			t.Errorf("%q: got %v %q %v, want %v %q %v", c.in, ok, st.sql, st.at, c.ok, c.sql, c.at)
+		}
+	}
+}
+
+func TestReplayScannerScan(t *testing.T) {
+	in := "1000.0\tSELECT * FROM cpu WHERE hostname = 'host_1'\n" +
+		"\n" +
+		"1000.1\tSELECT * FROM cpu WHERE hostname = 'host_2';\n" +
+		"1000.2\tSELECT count(*) FROM cpu"
+	limit := uint64(0)
+	c := make(chan Query, 3)
+	s := newReplayScanner(strings.NewReader(in), &limit, 10)
+	start := time.Now()
+	s.scan(&KwdbPool, c)
+	took := time.Since(start)
+	close(c)
+
+	// 200ms of workload at 10x speed
+	if took < 20*time.Millisecond || took > time.Second {
+		t.Errorf("incorrect pacing: replay took %v, want about 20ms", took)
+	}
+	var labels []string
+	for q := range c {
+		k := q.(*Kwdb)
+		labels = append(labels, string(k.HumanLabel))
+		if k.Prepare || strings.HasSuffix(string(k.SqlQuery), ";") {
+			t.Errorf("incorrect query: %+v", k)
+		}
+	}
+	want := []string{
+		"SELECT * FROM cpu WHERE hostname = ?",
+		"SELECT * FROM cpu WHERE hostname = ?",
+		"SELECT count(*) FROM cpu",
+	}
+	if strings.Join(labels, "|") != strings.Join(want, "|") {
+		t.Errorf("incorrect labels: got %v want %v", labels, want)
+	}
+
+	limit = 2
+	c = make(chan Query, 3)
+	newReplayScanner(strings.NewReader(in), &limit, 0).scan(&KwdbPool, c)
+	if len(c) != 2 {
+		t.Errorf("limit not respected: got %d queries want 2", len(c))
+	}
+}
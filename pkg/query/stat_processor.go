@@ -5,10 +5,12 @@ import (
 	"bytes"
 	"fmt"
 	"github.com/HdrHistogram/hdrhistogram-go"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
 	"regexp"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -30,7 +32,13 @@ type statProcessorArgs struct {
 	burnIn           uint64  // burnIn is the number of statistics to ignore before analyzing
 	printInterval    uint64  // printInterval is how often print intermediate stats (number of queries)
 	hdrLatenciesFile string  // hdrLatenciesFile is the filename to Write the High Dynamic Range (HDR) Histogram of Response Latencies to
-
+	// onStat is called with every complete query stat after the burn-in, if set
+	onStat func(label []byte, value float64)
+	// timeSeries records the stats after the burn-in per interval, if set
+	timeSeries *latencyTimeSeries
+	// coldCache reports the queries run after the cold cache hook as cold
+	// queries, and under their label with a (cold) suffix
+	coldCache bool
 }
 
 // statProcessor is used to collect, analyze, and print query execution statistics.
@@ -42,6 +50,17 @@ type defaultStatProcessor struct {
 	startTime   time.Time
 	endTime     time.Time
 	statMapping map[string]*statGroup
+	// errorMapping counts the failed queries per label and of all queries
+	errorMapping map[string]*errorCount
+	// skipMapping counts the queries run without being timed per label and
+	// of all queries
+	skipMapping map[string]uint64
+}
+
+// errorCount is the number of failed queries of a label, timeouts included
+type errorCount struct {
+	errors   uint64
+	timeouts uint64
 }
 
 func newStatProcessor(args *statProcessorArgs) statProcessor {
@@ -86,10 +105,12 @@ func (sp *defaultStatProcessor) process(workers uint) {
 		allQueriesLabel: newStatGroup(*sp.args.limit),
 	}
 	// Only needed when differentiating between cold & warm
-	if sp.args.prewarmQueries {
+	if sp.args.prewarmQueries || sp.args.coldCache {
 		sp.statMapping[labelColdQueries] = newStatGroup(*sp.args.limit)
 		sp.statMapping[labelWarmQueries] = newStatGroup(*sp.args.limit)
 	}
+	sp.errorMapping = make(map[string]*errorCount)
+	sp.skipMapping = make(map[string]uint64)
 
 	i := uint64(0)
 	sp.startTime = time.Now()
@@ -108,17 +129,54 @@ func (sp *defaultStatProcessor) process(workers uint) {
 				log.Fatal(err)
 			}
 		}
-		if _, ok := sp.statMapping[string(stat.label)]; !ok {
-			sp.statMapping[string(stat.label)] = newStatGroup(*sp.args.limit)
+		if stat.isError {
+			sp.countError(string(stat.label), stat.isTimeout)
+			sp.countError(allQueriesLabel, stat.isTimeout)
+			if !sp.args.prewarmQueries || !stat.isWarm {
+				i++
+			}
+			statPool.Put(stat)
+			continue
+		}
+		if stat.isSkipped {
+			sp.countSkipped(string(stat.label))
+			sp.countSkipped(allQueriesLabel)
+			if !sp.args.prewarmQueries || !stat.isWarm {
+				i++
+			}
+			statPool.Put(stat)
+			continue
+		}
+		label := string(stat.label)
+		if stat.isCold {
+			label += coldLabelSuffix
+		}
+		if _, ok := sp.statMapping[label]; !ok {
+			sp.statMapping[label] = newStatGroup(*sp.args.limit)
 		}
 
-		sp.statMapping[string(stat.label)].push(stat.value)
+		sp.statMapping[label].push(stat.value)
+		if sp.args.timeSeries != nil {
+			sp.args.timeSeries.record(label, stat.value)
+		}
 
 		if !stat.isPartial {
 			sp.statMapping[allQueriesLabel].push(stat.value)
+			if sp.args.timeSeries != nil {
+				sp.args.timeSeries.record(allQueriesLabel, stat.value)
+			}
+			if sp.args.onStat != nil {
+				sp.args.onStat(stat.label, stat.value)
+			}
 
 			// Only needed when differentiating between cold & warm
-			if sp.args.prewarmQueries {
+			if sp.args.coldCache {
+				if stat.isCold {
+					sp.statMapping[labelColdQueries].push(stat.value)
+				} else {
+					sp.statMapping[labelWarmQueries].push(stat.value)
+				}
+			} else if sp.args.prewarmQueries {
 				if stat.isWarm {
 					sp.statMapping[labelWarmQueries].push(stat.value)
 				} else {
@@ -175,6 +233,14 @@ func (sp *defaultStatProcessor) process(workers uint) {
 	if err != nil {
 		log.Fatal(err)
 	}
+	err = writeErrorMap(os.Stdout, sp.errorMapping)
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = writeSkipMap(os.Stdout, sp.skipMapping)
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	if len(sp.args.hdrLatenciesFile) > 0 {
 		_, _ = fmt.Printf("Saving High Dynamic Range (HDR) Histogram of Response Latencies to %s\n", sp.args.hdrLatenciesFile)
@@ -194,6 +260,74 @@ func (sp *defaultStatProcessor) process(workers uint) {
 	sp.wg.Done()
 }
 
+func (sp *defaultStatProcessor) countError(label string, timeout bool) {
+	c, ok := sp.errorMapping[label]
+	if !ok {
+		c = &errorCount{}
+		sp.errorMapping[label] = c
+	}
+	c.errors++
+	if timeout {
+		c.timeouts++
+	}
+}
+
+// writeErrorMap writes the failed queries per label, if there were any
+func writeErrorMap(w io.Writer, errors map[string]*errorCount) error {
+	if len(errors) == 0 {
+		return nil
+	}
+	maxKeyLength := 0
+	keys := make([]string, 0, len(errors))
+	for k := range errors {
+		if len(k) > maxKeyLength {
+			maxKeyLength = len(k)
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	if _, err := fmt.Fprintln(w, "Failed queries:"); err != nil {
+		return err
+	}
+	for _, k := range keys {
+		_, err := fmt.Fprintf(w, "%-*s: errors: %d, timeouts: %d\n", maxKeyLength, k, errors[k].errors, errors[k].timeouts)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (sp *defaultStatProcessor) countSkipped(label string) {
+	sp.skipMapping[label]++
+}
+
+// writeSkipMap writes the queries run without being timed per label, if there
+// were any
+func writeSkipMap(w io.Writer, skipped map[string]uint64) error {
+	if len(skipped) == 0 {
+		return nil
+	}
+	maxKeyLength := 0
+	keys := make([]string, 0, len(skipped))
+	for k := range skipped {
+		if len(k) > maxKeyLength {
+			maxKeyLength = len(k)
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	if _, err := fmt.Fprintln(w, "Skipped queries (not timed):"); err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if _, err := fmt.Fprintf(w, "%-*s: %d\n", maxKeyLength, k, skipped[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func generateQuantileMap(hist *hdrhistogram.Histogram) (int64, map[string]float64) {
 	ops := hist.TotalCount()
 	q0 := 0.0
@@ -238,6 +372,18 @@ func (sp *defaultStatProcessor) GetTotalsMap() map[string]interface{} {
 		quantiles[stripRegex(label)] = all
 	}
 	totals["overallQuantiles"] = quantiles
+	// count the failed queries, the quantiles only include the successful ones
+	errors := make(map[string]interface{})
+	for label, c := range sp.errorMapping {
+		errors[stripRegex(label)] = map[string]uint64{"errors": c.errors, "timeouts": c.timeouts}
+	}
+	totals["errors"] = errors
+	// count the queries run without being timed, they are not in the quantiles
+	skipped := make(map[string]interface{})
+	for label, n := range sp.skipMapping {
+		skipped[stripRegex(label)] = n
+	}
+	totals["skipped"] = skipped
 	return totals
 }
 
@@ -1,6 +1,7 @@
 package query
 
 import (
+	"strings"
 	"testing"
 	"time"
 )
@@ -77,3 +78,72 @@ func TestStatProcessorSendWarm(t *testing.T) {
 		t.Errorf("empty stat array changed channel length: got %d want %d", got, wantLen)
 	}
 }
+
+func TestStatProcessorErrors(t *testing.T) {
+	limit := uint64(0)
+	sp := &defaultStatProcessor{
+		args:         &statProcessorArgs{limit: &limit},
+		statMapping:  map[string]*statGroup{labelAllQueries: newStatGroup(0)},
+		errorMapping: make(map[string]*errorCount),
+	}
+	sp.countError("a", false)
+	sp.countError("a", true)
+	sp.countError("b, c", false)
+
+	var b strings.Builder
+	if err := writeErrorMap(&b, sp.errorMapping); err != nil {
+		t.Fatal(err)
+	}
+	want := "Failed queries:\na   : errors: 2, timeouts: 1\nb, c: errors: 1, timeouts: 0\n"
+	if got := b.String(); got != want {
+		t.Errorf("incorrect output:\ngot\n%s\nwant\n%s", got, want)
+	}
+
+	errors := sp.GetTotalsMap()["errors"].(map[string]interface{})
+	if got := errors["b_c"].(map[string]uint64); got["errors"] != 1 || got["timeouts"] != 0 {
+		t.Errorf("incorrect totals of b, c: %v", got)
+	}
+	if got := errors["a"].(map[string]uint64); got["errors"] != 2 || got["timeouts"] != 1 {
+		t.Errorf("incorrect totals of a: %v", got)
+	}
+
+	b.Reset()
+	if err := writeErrorMap(&b, map[string]*errorCount{}); err != nil || b.Len() > 0 {
+		t.Errorf("expected no output without errors, got %q", b.String())
+	}
+}
+
+func TestStatProcessorSkipped(t *testing.T) {
+	if s := GetSkippedStat([]byte("a")); !s.isSkipped || s.isError || string(s.label) != "a" {
+		t.Errorf("incorrect skipped stat: %+v", s)
+	}
+	limit := uint64(0)
+	sp := &defaultStatProcessor{
+		args:         &statProcessorArgs{limit: &limit},
+		statMapping:  map[string]*statGroup{labelAllQueries: newStatGroup(0)},
+		errorMapping: make(map[string]*errorCount),
+		skipMapping:  make(map[string]uint64),
+	}
+	sp.countSkipped("a")
+	sp.countSkipped("a")
+	sp.countSkipped("b, c")
+
+	var b strings.Builder
+	if err := writeSkipMap(&b, sp.skipMapping); err != nil {
+		t.Fatal(err)
+	}
+	want := "Skipped queries (not timed):\na   : 2\nb, c: 1\n"
+	if got := b.String(); got != want {
+		t.Errorf("incorrect output:\ngot\n%s\nwant\n%s", got, want)
+	}
+
+	skipped := sp.GetTotalsMap()["skipped"].(map[string]interface{})
+	if skipped["a"] != uint64(2) || skipped["b_c"] != uint64(1) {
+		t.Errorf("incorrect skipped totals: %v", skipped)
+	}
+
+	b.Reset()
+	if err := writeSkipMap(&b, map[string]uint64{}); err != nil || b.Len() > 0 {
+		t.Errorf("expected no output without skipped queries, got %q", b.String())
+	}
+}
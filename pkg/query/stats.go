@@ -20,6 +20,15 @@ type Stat struct {
 	value     float64
 	isWarm    bool
 	isPartial bool
+	// isError marks a query that failed, isTimeout one that failed because it
+	// timed out, their value is not a latency
+	isError   bool
+	isTimeout bool
+	// isCold marks a query run right after the cold cache hook
+	isCold bool
+	// isSkipped marks a query that ran without being timed, e.g. whose plan
+	// was captured instead, its value is not a latency
+	isSkipped bool
 }
 
 var statPool = &sync.Pool{
@@ -43,6 +52,22 @@ func GetPartialStat() *Stat {
 	return s
 }
 
+// getErrorStat returns a Stat from the pool counting a failed query of label
+func getErrorStat(label []byte, timeout bool) *Stat {
+	s := GetStat().Init(label, 0)
+	s.isError = true
+	s.isTimeout = timeout
+	return s
+}
+
+// GetSkippedStat returns a Stat from the pool counting a query of label that
+// ran without being timed, so that it is counted apart from the latencies
+func GetSkippedStat(label []byte) *Stat {
+	s := GetStat().Init(label, 0)
+	s.isSkipped = true
+	return s
+}
+
 // Init safely initializes a Stat while minimizing heap allocations.
 func (s *Stat) Init(label []byte, value float64) *Stat {
 	s.label = s.label[:0] // clear
@@ -57,6 +82,10 @@ func (s *Stat) reset() *Stat {
 	s.value = 0.0
 	s.isWarm = false
 	s.isPartial = false
+	s.isError = false
+	s.isTimeout = false
+	s.isCold = false
+	s.isSkipped = false
 	return s
 }
 
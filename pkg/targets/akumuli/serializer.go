@@ -2,7 +2,6 @@ package akumuli
 
 import (
 	"encoding/binary"
-	"errors"
 	"fmt"
 	"github.com/timescale/tsbs/pkg/data"
 	"github.com/timescale/tsbs/pkg/data/serialize"
@@ -43,14 +42,27 @@ func (s *Serializer) Serialize(p *data.Point, w io.Writer) (err error) {
 	buf = append(buf, placeholderText...)
 	buf = append(buf, "+"...)
 
-	// Series name
+	// Series name, fields missing from the point (nil values) are left out
+	// so the series is named after the fields present
 	fieldKeys := p.FieldKeys()
+	fieldValues := p.FieldValues()
+	present := 0
+	for i := 0; i < len(fieldValues); i++ {
+		if fieldValues[i] != nil {
+			present++
+		}
+	}
 	measurementName := p.MeasurementName()
+	written := 0
 	for i := 0; i < len(fieldKeys); i++ {
+		if fieldValues[i] == nil {
+			continue
+		}
 		buf = append(buf, measurementName...)
 		buf = append(buf, '.')
 		buf = append(buf, fieldKeys[i]...)
-		if i+1 < len(fieldKeys) {
+		written++
+		if written < present {
 			buf = append(buf, '|')
 		} else {
 			buf = append(buf, ' ')
@@ -80,38 +92,30 @@ func (s *Serializer) Serialize(p *data.Point, w io.Writer) (err error) {
 			binary.LittleEndian.PutUint32(buf[:4], id)
 		} else {
 			// Shortcut
-			s.index++
-			tmp := make([]byte, 0, 1024)
-			tmp = append(tmp, placeholderText...)
-			tmp = append(tmp, "*2\n"...)
-			tmp = append(tmp, buf[HeaderLength:]...)
-			tmp = append(tmp, '\n')
-			tmp = append(tmp, fmt.Sprintf(":%d\n", s.index)...)
-			s.book[series] = s.index
-			// Update cue
-			binary.LittleEndian.PutUint16(tmp[4:6], uint16(len(tmp)))
-			binary.LittleEndian.PutUint16(tmp[6:HeaderLength], uint16(0))
-			binary.LittleEndian.PutUint32(tmp[:4], s.index)
-			binary.LittleEndian.PutUint32(buf[:4], s.index)
-			_, err = w.Write(tmp)
+			id, err := s.register(series, w)
 			if err != nil {
 				return err
 			}
+			binary.LittleEndian.PutUint32(buf[:4], id)
 			deferPoint = true
 			buf = buf[:HeaderLength]
-			buf = append(buf, fmt.Sprintf(":%d", s.index)...)
+			buf = append(buf, fmt.Sprintf(":%d", id)...)
 		}
 	} else {
-		// Replace the series name with the value from the book
-		if id, ok := s.book[series]; ok {
-			buf = buf[:HeaderLength]
-			buf = append(buf, fmt.Sprintf(":%d", id)...)
-			binary.LittleEndian.PutUint16(buf[4:6], uint16(len(buf)))
-			binary.LittleEndian.PutUint16(buf[6:HeaderLength], uint16(0))
-			binary.LittleEndian.PutUint32(buf[:4], id)
-		} else {
-			return errors.New("unexpected series name")
+		// Replace the series name with the value from the book. Series
+		// that show up late, e.g. points missing other fields, are
+		// registered right before their point
+		id, ok := s.book[series]
+		if !ok {
+			if id, err = s.register(series, w); err != nil {
+				return err
+			}
 		}
+		buf = buf[:HeaderLength]
+		buf = append(buf, fmt.Sprintf(":%d", id)...)
+		binary.LittleEndian.PutUint16(buf[4:6], uint16(len(buf)))
+		binary.LittleEndian.PutUint16(buf[6:HeaderLength], uint16(0))
+		binary.LittleEndian.PutUint32(buf[:4], id)
 	}
 
 	buf = append(buf, '\n')
@@ -122,10 +126,12 @@ func (s *Serializer) Serialize(p *data.Point, w io.Writer) (err error) {
 	buf = append(buf, '\n')
 
 	// Values
-	fieldValues := p.FieldValues()
-	buf = append(buf, fmt.Sprintf("*%d\n", len(fieldValues))...)
+	buf = append(buf, fmt.Sprintf("*%d\n", present)...)
 	for i := 0; i < len(fieldValues); i++ {
 		v := fieldValues[i]
+		if v == nil {
+			continue
+		}
 		switch v.(type) {
 		case int, int64:
 			buf = append(buf, ':')
@@ -138,7 +144,7 @@ func (s *Serializer) Serialize(p *data.Point, w io.Writer) (err error) {
 
 	// Update cue
 	binary.LittleEndian.PutUint16(buf[4:6], uint16(len(buf)))
-	binary.LittleEndian.PutUint16(buf[6:HeaderLength], uint16(len(fieldValues)))
+	binary.LittleEndian.PutUint16(buf[6:HeaderLength], uint16(present))
 	if deferPoint {
 		s.deferred = append(s.deferred, buf...)
 		return nil
@@ -146,3 +152,21 @@ func (s *Serializer) Serialize(p *data.Point, w io.Writer) (err error) {
 	_, err = w.Write(buf)
 	return err
 }
+
+// register adds series to the book and writes its id mapping
+func (s *Serializer) register(series string, w io.Writer) (uint32, error) {
+	s.index++
+	tmp := make([]byte, 0, 1024)
+	tmp = append(tmp, placeholderText...)
+	tmp = append(tmp, "*2\n"...)
+	tmp = append(tmp, series...)
+	tmp = append(tmp, '\n')
+	tmp = append(tmp, fmt.Sprintf(":%d\n", s.index)...)
+	s.book[series] = s.index
+	// Update cue, the placeholder is the header of the record
+	binary.LittleEndian.PutUint16(tmp[4:6], uint16(len(tmp)))
+	binary.LittleEndian.PutUint16(tmp[6:len(placeholderText)], uint16(0))
+	binary.LittleEndian.PutUint32(tmp[:4], s.index)
+	_, err := w.Write(tmp)
+	return s.index, err
+}
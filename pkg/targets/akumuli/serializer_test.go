@@ -78,3 +78,35 @@ func TestAkumuliSerializerSerialize(t *testing.T) {
 		}
 	}
 }
+
+func TestAkumuliSerializerSerializeMissingField(t *testing.T) {
+	serializer := NewAkumuliSerializer()
+	buf := new(bytes.Buffer)
+	// the first point is deferred until its series shows up again
+	for i := 0; i < 2; i++ {
+		if err := serializer.Serialize(serialize.TestPointWithNilField(), buf); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	got := buf.String()
+	for _, want := range []string{"+cpu.usage_guest_nice \n", "*1\n+38.24311829"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Output incorrect: expected %q in %q", want, got)
+		}
+	}
+	if strings.Contains(got, "big_usage_guest") {
+		t.Errorf("Output incorrect: missing field in %q", got)
+	}
+
+	// series showing up after the book is closed are registered on the fly
+	buf.Reset()
+	if err := serializer.Serialize(serialize.TestPointDefault(), buf); err != nil {
+		t.Fatalf("unexpected error for a late series: %v", err)
+	}
+	got = buf.String()
+	for _, want := range []string{"*2\n+cpu.usage_guest_nice  hostname=host_0 region=eu-west-1 datacenter=eu-west-1b\n:2\n", ":2\n:1451606400000000000"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Output incorrect: expected %q in %q", want, got)
+		}
+	}
+}
@@ -2,9 +2,11 @@ package kwdb
 
 import (
 	"bytes"
+	"fmt"
 	"math"
 
 	"github.com/timescale/tsbs/pkg/data/source"
+	"github.com/timescale/tsbs/pkg/data/usecases/common"
 	"github.com/timescale/tsbs/pkg/targets"
 )
 
@@ -12,6 +14,7 @@ var (
 	KWDBINSERT     = "insert"
 	KWDBPREPARE    = "prepare"
 	KWDBPREPAREIOT = "prepareiot"
+	KWDBCOPY       = "copy"
 )
 
 func NewBenchmark(dbName string, opts *LoadingOptions, dataSourceConfig *source.DataSourceConfig) (targets.Benchmark, error) {
@@ -40,7 +43,11 @@ func (b *benchmark) GetDataSource() targets.DataSource {
 }
 
 func (b *benchmark) GetBatchFactory() targets.BatchFactory {
-	return &factory{}
+	// the rows are only checked for disorder when the stats are requested
+	if !b.opts.OutOfOrderStats {
+		return &factory{}
+	}
+	return &factory{disorder: newDisorderTracker()}
 }
 
 func (b *benchmark) GetPointIndexer(maxPartitions uint) targets.PointIndexer {
@@ -66,16 +73,27 @@ func (b *benchmark) GetPointIndexer(maxPartitions uint) targets.PointIndexer {
 }
 
 func (b *benchmark) GetProcessor() targets.Processor {
+	var p kwdbProcessor
 	switch b.opts.Type {
 	case KWDBINSERT:
-		return newProcessorInsert(b.opts, b.dbName)
+		p = newProcessorInsert(b.opts, b.dbName, b.ds.Headers())
 	case KWDBPREPARE:
-		return newProcessorPrepare(b.opts, b.dbName)
+		// the prepared statements of cpu have the fixed tags of cpu-only
+		if headers := b.ds.Headers(); headers != nil && hasCardinalityTag(headers.TagKeys) {
+			panic(fmt.Sprintf("kwdb %s does not support the high cardinality tag %s, please use %s or %s", KWDBPREPARE, common.CardinalityTagKey, KWDBINSERT, KWDBCOPY))
+		}
+		p = newProcessorPrepare(b.opts, b.dbName)
 	case KWDBPREPAREIOT:
-		return newProcessorPrepareiot(b.opts, b.dbName)
+		p = newProcessorPrepareiot(b.opts, b.dbName)
+	case KWDBCOPY:
+		p = newProcessorCopy(b.opts, b.dbName, b.ds.Headers())
 	default:
 		return nil
 	}
+	if !b.opts.OutOfOrderStats {
+		return p
+	}
+	return &outOfOrderProcessor{kwdbProcessor: p}
 }
 
 func (b *benchmark) GetDBCreator() targets.DBCreator {
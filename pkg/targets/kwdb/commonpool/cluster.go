@@ -0,0 +1,270 @@
+package commonpool
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	// StrategyRoundRobin assigns worker i to node i % nodes
+	StrategyRoundRobin = "round-robin"
+	// StrategyPartition assigns contiguous blocks of workers to each node, so
+	// that a node receives the devices of neighbouring workers
+	StrategyPartition = "partition"
+)
+
+// Node is a kwdb node of a cluster
+type Node struct {
+	Host string
+	Port int
+}
+
+func (n Node) String() string {
+	return fmt.Sprintf("%s:%d", n.Host, n.Port)
+}
+
+// ParseHosts parses a comma separated list of host[:port], nodes without a
+// port use defaultPort
+func ParseHosts(hosts string, defaultPort int) ([]Node, error) {
+	var nodes []Node
+	for _, h := range strings.Split(hosts, ",") {
+		h = strings.TrimSpace(h)
+		if len(h) == 0 {
+			continue
+		}
+		node := Node{Host: h, Port: defaultPort}
+		if i := strings.LastIndexByte(h, ':'); i >= 0 {
+			port, err := strconv.Atoi(h[i+1:])
+			if err != nil {
+				return nil, fmt.Errorf("invalid port in host '%s': %v", h, err)
+			}
+			node = Node{Host: h[:i], Port: port}
+		}
+		nodes = append(nodes, node)
+	}
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("no hosts in '%s'", hosts)
+	}
+	return nodes, nil
+}
+
+// Cluster spreads the connections of the workers over the nodes of a kwdb
+// cluster. Nodes that fail to connect or to answer a health check are marked
+// dead and skipped until they answer again.
+type Cluster struct {
+	user     string
+	password string
+	certdir  string
+	nodes    []Node
+	strategy string
+
+	mu   sync.RWMutex
+	dead []bool
+
+	// done is closed by Close and stops the health check
+	key       string
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+var clusterMap = sync.Map{}
+
+// GetCluster returns the shared cluster of the nodes, creating it on first
+// use. A health check runs every healthCheckInterval, 0 disables it.
+func GetCluster(user, password, certdir string, nodes []Node, strategy string, healthCheckInterval time.Duration) (*Cluster, error) {
+	if strategy != StrategyRoundRobin && strategy != StrategyPartition {
+		return nil, fmt.Errorf("unknown hosts strategy '%s', supports %s and %s", strategy, StrategyRoundRobin, StrategyPartition)
+	}
+	names := make([]string, len(nodes))
+	for i, node := range nodes {
+		names[i] = node.String()
+	}
+	key := fmt.Sprintf("%s:%s@%s/%s?%s", user, password, strings.Join(names, ","), certdir, strategy)
+	if v, ok := clusterMap.Load(key); ok {
+		return v.(*Cluster), nil
+	}
+
+	c := &Cluster{
+		user:     user,
+		password: password,
+		certdir:  certdir,
+		nodes:    nodes,
+		strategy: strategy,
+		dead:     make([]bool, len(nodes)),
+		key:      key,
+		done:     make(chan struct{}),
+	}
+	if v, loaded := clusterMap.LoadOrStore(key, c); loaded {
+		return v.(*Cluster), nil
+	}
+	if healthCheckInterval > 0 {
+		go c.healthCheckLoop(healthCheckInterval)
+	}
+	return c, nil
+}
+
+// healthCheckLoop checks the nodes every interval until the cluster is closed
+func (c *Cluster) healthCheckLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.HealthCheck(interval)
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// Done returns a channel closed once the cluster is closed
+func (c *Cluster) Done() <-chan struct{} {
+	return c.done
+}
+
+// Close stops the health check and forgets the cluster, the next GetCluster
+// of its nodes creates a new one. The connections already handed out stay
+// usable.
+func (c *Cluster) Close() {
+	c.closeOnce.Do(func() {
+		if c.done != nil {
+			close(c.done)
+		}
+		clusterMap.CompareAndDelete(c.key, c)
+	})
+}
+
+// CloseClusters closes all the shared clusters
+func CloseClusters() {
+	clusterMap.Range(func(_, v interface{}) bool {
+		v.(*Cluster).Close()
+		return true
+	})
+}
+
+// pick returns the node of a worker according to the strategy
+func (c *Cluster) pick(workerNum, workers int) int {
+	if c.strategy == StrategyPartition && workers > 0 {
+		return workerNum * len(c.nodes) / workers % len(c.nodes)
+	}
+	return workerNum % len(c.nodes)
+}
+
+// GetConnection returns a connection of the worker to the node picked by the
+// strategy, or to the next alive node when that one is dead
+func (c *Cluster) GetConnection(workerNum, workers int) (*Conn, error) {
+	conn := &Conn{cluster: c, node: c.pick(workerNum, workers)}
+	if err := c.connect(conn, conn.node); err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+// reconnect connects a lost connection to its node again, or to the next
+// alive node when its node is dead
+func (c *Cluster) reconnect(conn *Conn) error {
+	return c.connect(conn, conn.node)
+}
+
+// connect tries the nodes in order starting at first, skipping dead nodes
+func (c *Cluster) connect(conn *Conn, first int) error {
+	var lastErr error
+	for i := 0; i < len(c.nodes); i++ {
+		n := (first + i) % len(c.nodes)
+		if c.isDead(n) {
+			continue
+		}
+		node := c.nodes[n]
+		p, err := getPool(c.user, c.password, node.Host, c.certdir, node.Port)
+		if err == nil {
+			conn.Connection, err = p.Get()
+		}
+		if err != nil {
+			log.Printf("kwdb node %s is dead,err :%s", node, err)
+			c.setDead(n, true)
+			lastErr = err
+			continue
+		}
+		conn.pool = p
+		conn.node = n
+		return nil
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("all %d nodes are dead", len(c.nodes))
+	}
+	return lastErr
+}
+
+// HealthCheck pings every node once, dead nodes that answer are used again.
+// The nodes are probed at the same time on connections of their own, a node
+// that does not answer within timeout fails the check.
+func (c *Cluster) HealthCheck(timeout time.Duration) {
+	var wg sync.WaitGroup
+	for n := range c.nodes {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			c.check(n, timeout)
+		}(n)
+	}
+	wg.Wait()
+}
+
+// check probes node n and marks it dead or alive
+func (c *Cluster) check(n int, timeout time.Duration) {
+	node := c.nodes[n]
+	err := c.probe(node, timeout)
+	dead := err != nil
+	if dead != c.isDead(n) {
+		if dead {
+			log.Printf("kwdb node %s failed the health check,err :%s", node, err)
+		} else {
+			log.Printf("kwdb node %s is alive again", node)
+		}
+	}
+	c.setDead(n, dead)
+}
+
+// probe connects to node and pings it, both within timeout
+func (c *Cluster) probe(node Node, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	conn, err := pgx.Connect(ctx, connString(c.user, c.password, node.Host, c.certdir, node.Port))
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+	return conn.Ping(ctx)
+}
+
+// Alive returns the nodes that are not marked dead
+func (c *Cluster) Alive() []Node {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var alive []Node
+	for n, node := range c.nodes {
+		if !c.dead[n] {
+			alive = append(alive, node)
+		}
+	}
+	return alive
+}
+
+func (c *Cluster) isDead(n int) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.dead[n]
+}
+
+func (c *Cluster) setDead(n int, dead bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.dead[n] = dead
+}
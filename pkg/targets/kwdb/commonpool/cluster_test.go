@@ -0,0 +1,115 @@
+package commonpool
+
+import (
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseHosts(t *testing.T) {
+	nodes, err := ParseHosts("10.0.0.1:26257, 10.0.0.2,10.0.0.3:26258,", 26000)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []Node{{"10.0.0.1", 26257}, {"10.0.0.2", 26000}, {"10.0.0.3", 26258}}
+	if !reflect.DeepEqual(nodes, want) {
+		t.Errorf("incorrect nodes: got %v want %v", nodes, want)
+	}
+
+	for _, hosts := range []string{"", " , ", "10.0.0.1:port"} {
+		if _, err := ParseHosts(hosts, 26257); err == nil {
+			t.Errorf("expected an error for '%s'", hosts)
+		}
+	}
+}
+
+func TestClusterPick(t *testing.T) {
+	nodes := []Node{{"a", 1}, {"b", 1}, {"c", 1}}
+	cases := []struct {
+		strategy string
+		want     []int
+	}{
+		{strategy: StrategyRoundRobin, want: []int{0, 1, 2, 0, 1, 2}},
+		{strategy: StrategyPartition, want: []int{0, 0, 1, 1, 2, 2}},
+	}
+	for _, c := range cases {
+		cluster := &Cluster{nodes: nodes, strategy: c.strategy, dead: make([]bool, len(nodes))}
+		var got []int
+		for worker := 0; worker < len(c.want); worker++ {
+			got = append(got, cluster.pick(worker, len(c.want)))
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: incorrect nodes: got %v want %v", c.strategy, got, c.want)
+		}
+	}
+}
+
+func TestClusterAllDead(t *testing.T) {
+	nodes := []Node{{"a", 1}, {"b", 1}}
+	cluster := &Cluster{nodes: nodes, strategy: StrategyRoundRobin, dead: []bool{true, true}}
+	if _, err := cluster.GetConnection(0, 1); err == nil {
+		t.Errorf("expected an error when all nodes are dead")
+	}
+	if alive := cluster.Alive(); len(alive) != 0 {
+		t.Errorf("expected no alive nodes, got %v", alive)
+	}
+	if _, err := GetCluster("root", "", "", nodes, "random", 0); err == nil {
+		t.Errorf("expected an error for an unknown strategy")
+	}
+}
+
+func TestClusterClose(t *testing.T) {
+	nodes := []Node{{"10.255.255.1", 26257}}
+	c, err := GetCluster("root", "", "", nodes, StrategyRoundRobin, time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if same, _ := GetCluster("root", "", "", nodes, StrategyRoundRobin, time.Hour); same != c {
+		t.Errorf("cluster of the same nodes not shared")
+	}
+
+	CloseClusters()
+	select {
+	case <-c.Done():
+	default:
+		t.Fatalf("cluster not closed")
+	}
+	// closing again is a no-op, the nodes get a new cluster
+	c.Close()
+	next, _ := GetCluster("root", "", "", nodes, StrategyRoundRobin, 0)
+	if next == c {
+		t.Errorf("closed cluster still shared")
+	}
+	next.Close()
+}
+
+func TestClusterHealthCheckTimeout(t *testing.T) {
+	// a node that accepts connections but never answers
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+	addr := l.Addr().(*net.TCPAddr)
+	nodes := []Node{{"127.0.0.1", addr.Port}, {"127.0.0.1", addr.Port}}
+	cluster := &Cluster{user: "root", nodes: nodes, strategy: StrategyRoundRobin, dead: make([]bool, len(nodes))}
+
+	start := time.Now()
+	cluster.HealthCheck(200 * time.Millisecond)
+	if took := time.Since(start); took > 2*time.Second {
+		t.Errorf("health check not bounded by its timeout, took %v", took)
+	}
+	if alive := cluster.Alive(); len(alive) != 0 {
+		t.Errorf("expected the silent nodes dead, got alive %v", alive)
+	}
+}
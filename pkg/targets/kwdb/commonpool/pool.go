@@ -26,6 +26,7 @@ func NewConnectorPool(user, password, host, certdir string, port int) (*Connecto
 		MaxIdle:     10000,
 		Factory:     a.factory,
 		Close:       a.close,
+		Ping:        a.ping,
 		IdleTimeout: -1,
 	}
 	p, err := pool.NewChannelPool(poolConfig)
@@ -40,27 +41,31 @@ func (a *ConnectorPool) factory() (interface{}, error) {
 	thread.Lock()
 	defer thread.Unlock()
 
-	url := ""
-	if len(a.certdir) == 0 {
-		url = fmt.Sprintf("dbname=defaultdb host=%s port=%d user=%s password=%s default_query_exec_mode=simple_protocol", a.host, a.port, a.user, a.password)
-	} else {
-		url = fmt.Sprintf("dbname=defaultdb host=%s port=%d user=%s password=%s sslmode=verify-ca sslcert=%s/client.root.crt sslkey=%s/client.root.key sslrootcert=%s/ca.crt default_query_exec_mode=simple_protocol",
-			a.host, a.port, a.user, a.password, a.certdir, a.certdir, a.certdir)
-	}
+	return pgx.Connect(context.Background(), connString(a.user, a.password, a.host, a.certdir, a.port))
+}
 
-	return pgx.Connect(context.Background(), url)
+// connString returns the connection string of a kwdb node
+func connString(user, password, host, certdir string, port int) string {
+	if len(certdir) == 0 {
+		return fmt.Sprintf("dbname=defaultdb host=%s port=%d user=%s password=%s default_query_exec_mode=simple_protocol", host, port, user, password)
+	}
+	return fmt.Sprintf("dbname=defaultdb host=%s port=%d user=%s password=%s sslmode=verify-ca sslcert=%s/client.root.crt sslkey=%s/client.root.key sslrootcert=%s/ca.crt default_query_exec_mode=simple_protocol",
+		host, port, user, password, certdir, certdir, certdir)
 }
 
 func (a *ConnectorPool) close(v interface{}) error {
 	if v != nil {
 		thread.Lock()
 		defer thread.Unlock()
-		conn := v.(*pgx.Conn)
-		a.Close(conn)
+		return v.(*pgx.Conn).Close(context.Background())
 	}
 	return nil
 }
 
+func (a *ConnectorPool) ping(v interface{}) error {
+	return v.(*pgx.Conn).Ping(context.Background())
+}
+
 func (a *ConnectorPool) Get() (*pgx.Conn, error) {
 	v, err := a.pool.Get()
 	if err != nil {
@@ -85,29 +90,81 @@ func (a *ConnectorPool) verifyPassword(password string) bool {
 	return password == a.password
 }
 
+// connectionMap holds one shared ConnectorPool per DSN
 var connectionMap = sync.Map{}
 
 type Conn struct {
 	Connection *pgx.Conn
 	pool       *ConnectorPool
+	// cluster and node are set for connections of a Cluster, to reconnect
+	// to another node when the node of the connection is dead
+	cluster *Cluster
+	node    int
 }
 
+// Put returns the connection to its pool, lost connections are closed
 func (c *Conn) Put() error {
-	return c.pool.Put(nil)
+	if c.Connection == nil {
+		return nil
+	}
+	conn := c.Connection
+	c.Connection = nil
+	if conn.IsClosed() {
+		return c.pool.Close(conn)
+	}
+	return c.pool.Put(conn)
 }
 
-func GetConnection(user, password, host, certdir string, port int) (*Conn, error) {
+// Reconnect replaces a lost connection with a new one from the pool. The
+// connections of a Cluster move to another node if their node is dead.
+func (c *Conn) Reconnect() error {
+	if c.Connection != nil {
+		_ = c.pool.Close(c.Connection)
+		c.Connection = nil
+	}
+	if c.cluster != nil {
+		return c.cluster.reconnect(c)
+	}
+	conn, err := c.pool.Get()
+	if err != nil {
+		return err
+	}
+	c.Connection = conn
+	return nil
+}
+
+func dsn(user, password, host, certdir string, port int) string {
+	return fmt.Sprintf("%s:%s@%s:%d/%s", user, password, host, port, certdir)
+}
+
+// getPool returns the shared pool of a DSN, creating it on first use
+func getPool(user, password, host, certdir string, port int) (*ConnectorPool, error) {
+	key := dsn(user, password, host, certdir, port)
+	if v, ok := connectionMap.Load(key); ok {
+		return v.(*ConnectorPool), nil
+	}
 	newPool, err := NewConnectorPool(user, password, host, certdir, port)
 	if err != nil {
 		return nil, err
 	}
-	connectionMap.Store(user, newPool)
-	c, err := newPool.Get()
+	if v, loaded := connectionMap.LoadOrStore(key, newPool); loaded {
+		newPool.Release()
+		return v.(*ConnectorPool), nil
+	}
+	return newPool, nil
+}
+
+func GetConnection(user, password, host, certdir string, port int) (*Conn, error) {
+	p, err := getPool(user, password, host, certdir, port)
+	if err != nil {
+		return nil, err
+	}
+	c, err := p.Get()
 	if err != nil {
 		return nil, err
 	}
 	return &Conn{
 		Connection: c,
-		pool:       newPool,
+		pool:       p,
 	}, nil
 }
@@ -0,0 +1,22 @@
+package kwdb
+
+import (
+	"github.com/timescale/tsbs/pkg/targets/kwdb/commonpool"
+)
+
+// Connect returns a connection for the worker. With --hosts the workers are
+// spread over the nodes of the list, otherwise they all use --host.
+func (o *LoadingOptions) Connect(workerNum int) (*commonpool.Conn, error) {
+	if len(o.Hosts) == 0 {
+		return commonpool.GetConnection(o.User, o.Pass, o.Host, o.CertDir, o.Port)
+	}
+	nodes, err := commonpool.ParseHosts(o.Hosts, o.Port)
+	if err != nil {
+		return nil, err
+	}
+	cluster, err := commonpool.GetCluster(o.User, o.Pass, o.CertDir, nodes, o.HostsStrategy, o.HealthCheckInterval)
+	if err != nil {
+		return nil, err
+	}
+	return cluster.GetConnection(workerNum, o.Workers)
+}
@@ -4,6 +4,7 @@ import "C"
 import (
 	"context"
 	"fmt"
+	"github.com/timescale/tsbs/pkg/data/usecases/common"
 	"github.com/timescale/tsbs/pkg/targets"
 	"github.com/timescale/tsbs/pkg/targets/kwdb/commonpool"
 	"log"
@@ -14,16 +15,24 @@ import (
 var fatal = log.Fatalf
 
 type dbCreator struct {
-	opts *LoadingOptions
-	ds   targets.DataSource
-	db   *commonpool.Conn
+	opts    *LoadingOptions
+	ds      targets.DataSource
+	db      *commonpool.Conn
+	headers *common.GeneratedDataHeaders
+	retry   *retryPolicy
 }
 
 var IOTPRE = []string{"readings", "diagnostics"}
 var DEVOPSPRE = []string{"cpu", "diskio", "disk", "kernel", "mem", "net", "nginx", "postgresl", "redis"}
 
 func (d *dbCreator) Init() {
-	db, err := commonpool.GetConnection(d.opts.User, d.opts.Pass, d.opts.Host, d.opts.CertDir, d.opts.Port)
+	// read the headers before all else
+	d.headers = d.ds.Headers()
+	d.retry = newRetryPolicy(d.opts)
+	if d.opts.DryRun {
+		return
+	}
+	db, err := d.opts.Connect(0)
 	if err != nil {
 		panic(fmt.Sprintf("kwdb can not get connection %s", err.Error()))
 	}
@@ -34,65 +43,147 @@ func (d *dbCreator) DBExists(dbName string) bool {
 	return true
 }
 
+// exec runs a DDL statement under the retry policy, in dry-run mode the
+// statement is only printed. Errors containing ignoreErr are ignored when
+// ignoreErr is not empty.
+func (d *dbCreator) exec(ctx context.Context, sql, ignoreErr string) error {
+	if d.opts.DryRun {
+		fmt.Printf("%s;\n", strings.TrimSuffix(sql, ";"))
+		return nil
+	}
+	err := d.retry.run(d.db, func() error {
+		_, err := d.db.Connection.Exec(ctx, sql)
+		return err
+	})
+	if err != nil && len(ignoreErr) > 0 && strings.Contains(err.Error(), ignoreErr) {
+		return nil
+	}
+	return err
+}
+
 func (d *dbCreator) CreateDB(dbName string) error {
 	ctx := context.Background()
+	if d.opts.Case != "cpu-only" && d.opts.Case != "iot" && !isDevopsCase(d.opts.Case) {
+		panic(fmt.Sprintf("kwdb cannot support this use-case '%s', currently supports cpu-only, devops, devops-generic and iot", d.opts.Case))
+	}
+	if d.headers == nil && isDevopsCase(d.opts.Case) {
+		panic(fmt.Sprintf("kwdb use-case '%s' needs the header block of the data file, please regenerate the data", d.opts.Case))
+	}
+
 	// 创建时序数据库
 	sql := fmt.Sprintf("create ts database %s partition interval 1d;", dbName)
-	_, err := d.db.Connection.Exec(ctx, sql)
-	if err != nil && !strings.Contains(err.Error(), "already exists") {
+	if err := d.exec(ctx, sql, "already exists"); err != nil {
 		panic(fmt.Sprintf("kwdb create database failed,err :%s", err))
 	}
 
-	if d.opts.Case == "cpu-only" {
-		sql := fmt.Sprintf("create table %s.cpu (k_timestamp timestamp not null,usage_user bigint not null,usage_system bigint not null,usage_idle bigint not null,usage_nice bigint not null,"+
-			"usage_iowait bigint not null,usage_irq bigint not null,usage_softirq bigint not null,usage_steal bigint not null,usage_guest bigint not null,usage_guest_nice bigint not null) "+
-			"tags (hostname char(30) not null,region char(30),datacenter char(30),rack char(30),os char(30),arch char(30),team char(30),service char(30),"+
-			"service_version char(30),service_environment char(30)) primary tags(hostname)", dbName)
-		_, err = d.db.Connection.Exec(ctx, sql)
-		if err != nil && !strings.Contains(err.Error(), "already exists") {
-			panic(fmt.Sprintf("kwdb create table failed,err :%s", err))
+	if d.headers == nil {
+		// data files written before the header block
+		for _, table := range legacyTables(dbName, d.opts.Case) {
+			if !d.opts.DryRun {
+				fmt.Printf("create table %s\n", table.name)
+			}
+			if err := d.exec(ctx, table.sql, "already exists"); err != nil {
+				panic(fmt.Sprintf("kwdb create table %s failed,err :%s", table.name, err))
+			}
 		}
+	} else {
+		// hosts of devops-generic report a varying number of metrics
+		nullable := d.opts.Case == "devops-generic"
+		for _, table := range tableNames(d.headers) {
+			if !d.opts.DryRun {
+				fmt.Printf("create table %s\n", table)
+			}
+			sql := createTableSQL(dbName, table, d.headers, nullable)
+			if err := d.exec(ctx, sql, "already exists"); err != nil {
+				panic(fmt.Sprintf("kwdb create table %s failed,err :%s", table, err))
+			}
+		}
+	}
 
-		if d.opts.Partition {
-			sqlpartition := fmt.Sprintf("alter table %s.cpu partition by hashpoint(partition p0 values from (0) to (666), partition p1 values from (666) to (1332), partition p2 values from (1332) to (2000));", dbName)
-			_, err = d.db.Connection.Exec(ctx, sqlpartition)
+	if d.opts.Case == "cpu-only" && d.opts.Partition {
+		sqlpartition := fmt.Sprintf("alter table %s.cpu partition by hashpoint(partition p0 values from (0) to (666), partition p1 values from (666) to (1332), partition p2 values from (1332) to (2000));", dbName)
+		_ = d.exec(ctx, sqlpartition, "")
 
-			sqlpartition = fmt.Sprintf("ALTER PARTITION p0 OF TABLE %s.cpu CONFIGURE ZONE USING lease_preferences = '[[+region=NODE1]]',constraints = '{\"+region=NODE1\":1}',num_replicas=3;"+
-				"ALTER PARTITION p1 OF TABLE %s.cpu CONFIGURE ZONE USING lease_preferences = '[[+region=NODE2]]',constraints = '{\"+region=NODE2\":1}',num_replicas=3;"+
-				"ALTER PARTITION p2 OF TABLE %s.cpu CONFIGURE ZONE USING lease_preferences = '[[+region=NODE3]]',constraints = '{\"+region=NODE3\":1}',num_replicas=3;", dbName, dbName, dbName)
-			_, err = d.db.Connection.Exec(ctx, sqlpartition)
-			if err != nil {
-				panic(fmt.Sprintf("kwdb alter partition failed,err :%s", err))
-			}
+		sqlpartition = fmt.Sprintf("ALTER PARTITION p0 OF TABLE %s.cpu CONFIGURE ZONE USING lease_preferences = '[[+region=NODE1]]',constraints = '{\"+region=NODE1\":1}',num_replicas=3;"+
+			"ALTER PARTITION p1 OF TABLE %s.cpu CONFIGURE ZONE USING lease_preferences = '[[+region=NODE2]]',constraints = '{\"+region=NODE2\":1}',num_replicas=3;"+
+			"ALTER PARTITION p2 OF TABLE %s.cpu CONFIGURE ZONE USING lease_preferences = '[[+region=NODE3]]',constraints = '{\"+region=NODE3\":1}',num_replicas=3;", dbName, dbName, dbName)
+		if err := d.exec(ctx, sqlpartition, ""); err != nil {
+			panic(fmt.Sprintf("kwdb alter partition failed,err :%s", err))
+		}
+		if !d.opts.DryRun {
 			// 暂停一分钟
 			time.Sleep(1 * time.Minute)
 		}
-	} else if d.opts.Case == "iot" {
-		fmt.Println("create iot tables")
-		readings := fmt.Sprintf("create table %s.readings (k_timestamp timestamp NOT NULL,latitude FLOAT8 NOT NULL,longitude FLOAT8 NOT NULL,elevation FLOAT8 NOT NULL,velocity FLOAT8 NOT NULL,heading FLOAT8 NOT NULL,grade FLOAT8 NOT NULL,fuel_consumption FLOAT8 NOT NULL) tags (name VARCHAR(30) NOT NULL,fleet VARCHAR(30),driver VARCHAR(30),model VARCHAR(30),device_version VARCHAR(30),load_capacity FLOAT8,fuel_capacity FLOAT8,nominal_fuel_consumption FLOAT8) primary tags(name)", dbName)
-		_, err := d.db.Connection.Exec(ctx, readings)
-		if err != nil && !strings.Contains(err.Error(), "already exists") {
-			panic(fmt.Sprintf("kwdb create table readings failed,err :%s", err))
+	}
+	return nil
+}
+
+type legacyTable struct {
+	name string
+	sql  string
+}
+
+// legacyTables returns the fixed tables of the cpu-only and iot data files
+// written without the header block
+func legacyTables(dbName, useCase string) []legacyTable {
+	switch useCase {
+	case "cpu-only":
+		return []legacyTable{{"cpu", fmt.Sprintf("create table %s.cpu (k_timestamp timestamp not null,usage_user bigint not null,usage_system bigint not null,usage_idle bigint not null,usage_nice bigint not null,"+
+			"usage_iowait bigint not null,usage_irq bigint not null,usage_softirq bigint not null,usage_steal bigint not null,usage_guest bigint not null,usage_guest_nice bigint not null) "+
+			"tags (hostname char(30) not null,region char(30),datacenter char(30),rack char(30),os char(30),arch char(30),team char(30),service char(30),"+
+			"service_version char(30),service_environment char(30)) primary tags(hostname)", dbName)}}
+	case "iot":
+		return []legacyTable{
+			{"readings", fmt.Sprintf("create table %s.readings (k_timestamp timestamp NOT NULL,latitude FLOAT8 NOT NULL,longitude FLOAT8 NOT NULL,elevation FLOAT8 NOT NULL,velocity FLOAT8 NOT NULL,heading FLOAT8 NOT NULL,grade FLOAT8 NOT NULL,fuel_consumption FLOAT8 NOT NULL) tags (name VARCHAR(30) NOT NULL,fleet VARCHAR(30),driver VARCHAR(30),model VARCHAR(30),device_version VARCHAR(30),load_capacity FLOAT8,fuel_capacity FLOAT8,nominal_fuel_consumption FLOAT8) primary tags(name)", dbName)},
+			{"diagnostics", fmt.Sprintf("create table %s.diagnostics (k_timestamp timestamp NOT NULL,fuel_state FLOAT8 NOT NULL,current_load FLOAT8 NOT NULL,status INT8 NOT NULL) tags (name VARCHAR(30) NOT NULL,fleet VARCHAR(30),driver VARCHAR(30),model VARCHAR(30),device_version VARCHAR(30),load_capacity FLOAT8,fuel_capacity FLOAT8,nominal_fuel_consumption FLOAT8) primary tags(name)", dbName)},
 		}
+	}
+	return nil
+}
 
-		diagnostics := fmt.Sprintf("create table %s.diagnostics (k_timestamp timestamp NOT NULL,fuel_state FLOAT8 NOT NULL,current_load FLOAT8 NOT NULL,status INT8 NOT NULL) tags (name VARCHAR(30) NOT NULL,fleet VARCHAR(30),driver VARCHAR(30),model VARCHAR(30),device_version VARCHAR(30),load_capacity FLOAT8,fuel_capacity FLOAT8,nominal_fuel_consumption FLOAT8) primary tags(name)", dbName)
-		_, err = d.db.Connection.Exec(ctx, diagnostics)
-		if err != nil && !strings.Contains(err.Error(), "already exists") {
-			panic(fmt.Sprintf("kwdb create table diagnostics failed,err :%s", err))
+// legacyHeaders returns the columns of the fixed tables of legacyTables, nil
+// for the use cases that need the header block
+func legacyHeaders(useCase string) *common.GeneratedDataHeaders {
+	switch useCase {
+	case "cpu-only":
+		fields := []string{"usage_user", "usage_system", "usage_idle", "usage_nice", "usage_iowait", "usage_irq", "usage_softirq", "usage_steal", "usage_guest", "usage_guest_nice"}
+		return &common.GeneratedDataHeaders{
+			TagKeys:    []string{"hostname", "region", "datacenter", "rack", "os", "arch", "team", "service", "service_version", "service_environment"},
+			TagTypes:   []string{"string", "string", "string", "string", "string", "string", "string", "string", "string", "string"},
+			FieldKeys:  map[string][]string{"cpu": fields},
+			FieldTypes: map[string][]string{"cpu": repeatType("int64", len(fields))},
+		}
+	case "iot":
+		return &common.GeneratedDataHeaders{
+			TagKeys:  []string{"name", "fleet", "driver", "model", "device_version", "load_capacity", "fuel_capacity", "nominal_fuel_consumption"},
+			TagTypes: []string{"string", "string", "string", "string", "string", "float64", "float64", "float64"},
+			FieldKeys: map[string][]string{
+				"readings":    {"latitude", "longitude", "elevation", "velocity", "heading", "grade", "fuel_consumption"},
+				"diagnostics": {"fuel_state", "current_load", "status"},
+			},
+			FieldTypes: map[string][]string{
+				"readings":    repeatType("float64", 7),
+				"diagnostics": {"float64", "float64", "int64"},
+			},
 		}
-	} else {
-		panic(fmt.Sprintf("kwdb cannot support this use-case '%s', currently only supports cpu-only in devops", d.opts.Case))
 	}
 	return nil
 }
 
+func repeatType(typ string, n int) []string {
+	types := make([]string, n)
+	for i := range types {
+		types[i] = typ
+	}
+	return types
+}
+
 func (d *dbCreator) RemoveOldDB(dbName string) error {
 	//str := strings.Split(dbName, "_")
 	ctx := context.Background()
 
 	sql := fmt.Sprintf("drop database %s", dbName)
-	_, err := d.db.Connection.Exec(ctx, sql)
-	if err != nil && !strings.Contains(err.Error(), "does not exist") {
+	if err := d.exec(ctx, sql, "does not exist"); err != nil {
 		panic(fmt.Sprintf("kwdb drop database failed,err :%s", err))
 	}
 	return nil
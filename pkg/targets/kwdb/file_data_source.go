@@ -11,6 +11,14 @@ import (
 	"github.com/timescale/tsbs/pkg/targets"
 )
 
+const (
+	tagsKey = "tags"
+	// tagMarker marks a measurement specific tag in the header block
+	tagMarker = "tag"
+	// nullMarker marks a field whose values may be NULL in the header block
+	nullMarker = "null"
+)
+
 func newFileDataSource(fileName string) targets.DataSource {
 	br := load.GetBufferedReader(fileName)
 
@@ -18,24 +26,122 @@ func newFileDataSource(fileName string) targets.DataSource {
 }
 
 type fileDataSource struct {
-	scanner *bufio.Scanner
-	headers *common.GeneratedDataHeaders
+	scanner     *bufio.Scanner
+	headers     *common.GeneratedDataHeaders
+	headersRead bool
+	// pending holds the first data line of files without a header block
+	pending    string
+	hasPending bool
 }
 
+// Headers reads the header block at the top of the data file. The first line
+// holds the tags and their types, the following lines hold one measurement
+// each with its typed fields, nullable ones marked, and measurement specific
+// tags, and a blank line separates the block from the data, e.g.
+//
+//	tags,hostname string,region string
+//	disk,total int64,used_percent int64 null,path string tag
+//
+// Files generated before the header block existed start with data right
+// away, in which case nil is returned.
 func (d *fileDataSource) Headers() *common.GeneratedDataHeaders {
-	return nil
-}
+	if d.headersRead {
+		return d.headers
+	}
+	d.headersRead = true
 
-func (d *fileDataSource) NextItem() data.LoadedPoint {
 	ok := d.scanner.Scan()
 	if !ok && d.scanner.Err() == nil { // nothing scanned & no error = EOF
-		return data.LoadedPoint{}
+		return nil
 	} else if !ok {
 		fatal("scan error: %v", d.scanner.Err())
-		return data.LoadedPoint{}
+		return nil
+	}
+	line := strings.TrimSpace(d.scanner.Text())
+	if !strings.HasPrefix(line, tagsKey+",") {
+		d.pending = line
+		d.hasPending = true
+		return nil
+	}
+
+	headers := &common.GeneratedDataHeaders{
+		FieldKeys:           make(map[string][]string),
+		FieldTypes:          make(map[string][]string),
+		MeasurementTagKeys:  make(map[string][]string),
+		MeasurementTagTypes: make(map[string][]string),
+		NullableFields:      make(map[string][]bool),
+	}
+	for _, tag := range strings.Split(line, ",")[1:] {
+		key, typ := splitHeaderEntry(tag)
+		headers.TagKeys = append(headers.TagKeys, key)
+		headers.TagTypes = append(headers.TagTypes, typ)
+	}
+
+	for {
+		ok = d.scanner.Scan()
+		if !ok && d.scanner.Err() == nil {
+			fatal("ended too soon, header block is not terminated")
+			return nil
+		} else if !ok {
+			fatal("scan error: %v", d.scanner.Err())
+			return nil
+		}
+		line = strings.TrimSpace(d.scanner.Text())
+		if len(line) == 0 {
+			break
+		}
+		entries := strings.Split(line, ",")
+		measurement := entries[0]
+		for _, entry := range entries[1:] {
+			key, typ := splitHeaderEntry(entry)
+			if strings.HasSuffix(entry, " "+tagMarker) {
+				headers.MeasurementTagKeys[measurement] = append(headers.MeasurementTagKeys[measurement], key)
+				headers.MeasurementTagTypes[measurement] = append(headers.MeasurementTagTypes[measurement], typ)
+				continue
+			}
+			headers.FieldKeys[measurement] = append(headers.FieldKeys[measurement], key)
+			headers.FieldTypes[measurement] = append(headers.FieldTypes[measurement], typ)
+			headers.NullableFields[measurement] = append(headers.NullableFields[measurement],
+				strings.HasSuffix(entry, " "+nullMarker))
+		}
+	}
+	d.headers = headers
+	return d.headers
+}
+
+// splitHeaderEntry splits a "key type" header entry, the type is empty for
+// untyped entries
+func splitHeaderEntry(entry string) (string, string) {
+	parts := strings.Fields(entry)
+	switch len(parts) {
+	case 0:
+		return "", ""
+	case 1:
+		return parts[0], ""
+	default:
+		return parts[0], parts[1]
+	}
+}
+
+func (d *fileDataSource) NextItem() data.LoadedPoint {
+	if !d.headersRead {
+		d.Headers()
+	}
+	var line string
+	if d.hasPending {
+		line = d.pending
+		d.hasPending = false
+	} else {
+		ok := d.scanner.Scan()
+		if !ok && d.scanner.Err() == nil { // nothing scanned & no error = EOF
+			return data.LoadedPoint{}
+		} else if !ok {
+			fatal("scan error: %v", d.scanner.Err())
+			return data.LoadedPoint{}
+		}
+		line = d.scanner.Text()
 	}
 	p := &point{}
-	line := d.scanner.Text()
 	p.sqlType = line[0]
 	switch line[0] {
 	case Insert:
@@ -55,6 +161,11 @@ func (d *fileDataSource) NextItem() data.LoadedPoint {
 		p.template = parts[1] //cpu
 		p.device = parts[2]   //host_0
 		p.sql = parts[3]      //tags (tagValue)
+	case Modify:
+		parts := strings.SplitN(line, ",", 4)
+		p.template = parts[1] //cpu
+		p.device = parts[2]   //host_0
+		p.sql = parts[3]      //(primary tag=value,tag=value...)
 	default:
 		panic(line)
 	}
@@ -2,6 +2,7 @@ package kwdb
 
 import (
 	"bytes"
+	"time"
 
 	"github.com/blagojts/viper"
 	"github.com/spf13/pflag"
@@ -9,6 +10,7 @@ import (
 	"github.com/timescale/tsbs/pkg/data/source"
 	"github.com/timescale/tsbs/pkg/targets"
 	"github.com/timescale/tsbs/pkg/targets/constants"
+	"github.com/timescale/tsbs/pkg/targets/kwdb/commonpool"
 )
 
 func NewTarget() targets.ImplementedTarget {
@@ -22,6 +24,9 @@ func (t *kwdbTarget) TargetSpecificFlags(flagPrefix string, flagSet *pflag.FlagS
 	flagSet.String(flagPrefix+"user", "root", "User to connect to kwdb")
 	flagSet.String(flagPrefix+"pass", "", "Password for user connecting to kwdb")
 	flagSet.String(flagPrefix+"host", "", "kwdb host")
+	flagSet.String(flagPrefix+"hosts", "", "Comma separated host:port list of the cluster nodes, overrides host")
+	flagSet.String(flagPrefix+"hosts-strategy", commonpool.StrategyRoundRobin, "How workers are spread over hosts: round-robin or partition")
+	flagSet.Duration(flagPrefix+"health-check-interval", 10*time.Second, "Interval of the health check of hosts, 0 disables it")
 	flagSet.Int(flagPrefix+"port", 26257, "kwdb client Port")
 	flagSet.String(flagPrefix+"dbname", "benchmark", "kwdb db name")
 	flagSet.String(flagPrefix+"insert-type", "9091", "kwdb insert type")
@@ -29,6 +34,13 @@ func (t *kwdbTarget) TargetSpecificFlags(flagPrefix string, flagSet *pflag.FlagS
 	flagSet.Int(flagPrefix+"preparesize", 1000, "Prepare batch size ")
 	flagSet.String(flagPrefix+"certdir", "", "Dir of cert files")
 	flagSet.String(flagPrefix+"partition", "true", "alter table partition by hashpoint p0 p1 p2")
+	flagSet.Bool(flagPrefix+"dry-run", false, "Print the DDL built from the data file headers and exit without loading")
+	flagSet.Int(flagPrefix+"retry-max-attempts", 3, "Max attempts of a failed statement, 1 disables retries")
+	flagSet.Duration(flagPrefix+"retry-backoff", time.Second, "Wait before the first retry, doubled after every attempt")
+	flagSet.Duration(flagPrefix+"retry-max-backoff", 30*time.Second, "Max wait between two retries")
+	flagSet.String(flagPrefix+"retry-sqlstates", defaultRetrySQLStates, "Comma separated SQLSTATEs to retry, lost connections are always retried")
+	flagSet.Bool(flagPrefix+"continue-on-error", false, "Skip statements that failed after all retries and count their batches as failed instead of aborting")
+	flagSet.Bool(flagPrefix+"out-of-order-stats", false, "Count the rows arriving after a newer row of their device and time their batches, late rows are only measured then")
 }
 
 func (t *kwdbTarget) TargetName() string {
@@ -37,7 +49,7 @@ func (t *kwdbTarget) TargetName() string {
 
 func (t *kwdbTarget) Serializer() serialize.PointSerializer {
 	return &Serializer{
-		tableMap:   map[string]struct{}{},
+		tableMap:   map[string]string{},
 		superTable: map[string]*Table{},
 		tmpBuf:     &bytes.Buffer{},
 	}
@@ -0,0 +1,79 @@
+package kwdb
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/timescale/tsbs/pkg/targets"
+)
+
+// disorderTracker keeps the newest timestamp of every device seen by the
+// scan, to spot the rows that arrive after newer rows of their device. It is
+// only used by the scan goroutine.
+type disorderTracker struct {
+	newest map[string]int64
+}
+
+func newDisorderTracker() *disorderTracker {
+	return &disorderTracker{newest: map[string]int64{}}
+}
+
+// observe records the row of device and returns how far it is behind the
+// newest row of the device, if it is
+func (d *disorderTracker) observe(device, sql string) (time.Duration, bool) {
+	if d == nil {
+		return 0, false
+	}
+	ts, ok := rowTimestamp(sql)
+	if !ok {
+		return 0, false
+	}
+	newest, seen := d.newest[device]
+	if seen && ts < newest {
+		return time.Duration(newest-ts) * time.Millisecond, true
+	}
+	d.newest[device] = ts
+	return 0, false
+}
+
+// rowTimestamp parses the millisecond timestamp leading the values of an
+// insert row, e.g. (1451606400000,58,2,'host_0')
+func rowTimestamp(sql string) (int64, bool) {
+	end := strings.IndexByte(sql, ',')
+	if len(sql) < 2 || sql[0] != '(' || end < 0 {
+		return 0, false
+	}
+	ts, err := strconv.ParseInt(sql[1:end], 10, 64)
+	return ts, err == nil
+}
+
+// kwdbProcessor is implemented by all the insert types of the loader
+type kwdbProcessor interface {
+	targets.ProcessorCloser
+	FailedBatches() uint64
+}
+
+// outOfOrderProcessor times the batches of the processor of an insert type,
+// to compare the batches holding out of order rows with those in order
+type outOfOrderProcessor struct {
+	kwdbProcessor
+	stats targets.OutOfOrderStats
+}
+
+func (p *outOfOrderProcessor) ProcessBatch(b targets.Batch, doLoad bool) (metricCount, rowCount uint64) {
+	// the processors may reset the batch
+	batch := b.(*hypertableArr)
+	lateRows, lateness := batch.lateRows, batch.maxLateness
+	start := time.Now()
+	metricCount, rowCount = p.kwdbProcessor.ProcessBatch(b, doLoad)
+	if doLoad {
+		p.stats.AddBatch(lateRows, lateness, time.Since(start))
+	}
+	return metricCount, rowCount
+}
+
+// OutOfOrderStats returns the out of order stats of the processed batches
+func (p *outOfOrderProcessor) OutOfOrderStats() targets.OutOfOrderStats {
+	return p.stats
+}
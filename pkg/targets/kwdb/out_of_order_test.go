@@ -0,0 +1,77 @@
+package kwdb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/timescale/tsbs/pkg/data"
+)
+
+func TestRowTimestamp(t *testing.T) {
+	cases := []struct {
+		sql  string
+		want int64
+		ok   bool
+	}{
+		{sql: "(1451606400000,58,2,'host_0')", want: 1451606400000, ok: true},
+		{sql: "(1451606410000,30.32363,91.43075,'truck_0')", want: 1451606410000, ok: true},
+		{sql: "('host_0','eu-west-1')", ok: false},
+		{sql: "", ok: false},
+	}
+	for _, c := range cases {
+		got, ok := rowTimestamp(c.sql)
+		if ok != c.ok || got != c.want {
+			t.Errorf("rowTimestamp(%q): got %d, %v want %d, %v", c.sql, got, ok, c.want, c.ok)
+		}
+	}
+}
+
+func TestHypertableArrOutOfOrder(t *testing.T) {
+	f := &factory{disorder: newDisorderTracker()}
+	b := f.New().(*hypertableArr)
+	rows := []struct {
+		device string
+		sql    string
+	}{
+		{"readings_truck_0", "(1451606400000,1.5,'truck_0')"},
+		{"readings_truck_0", "(1451606420000,1.5,'truck_0')"},
+		{"readings_truck_1", "(1451606400000,1.5,'truck_1')"},
+		{"readings_truck_0", "(1451606410000,1.5,'truck_0')"},
+		{"readings_truck_1", "(1451606370000,1.5,'truck_1')"},
+	}
+	for _, r := range rows {
+		b.Append(data.NewLoadedPoint(&point{sqlType: Insert, device: r.device, fieldCount: 2, sql: r.sql}))
+	}
+	if b.lateRows != 2 {
+		t.Errorf("incorrect late rows: got %d want %d", b.lateRows, 2)
+	}
+	if b.maxLateness != 30*time.Second {
+		t.Errorf("incorrect max lateness: got %v want %v", b.maxLateness, 30*time.Second)
+	}
+
+	// the devices keep their newest timestamp across batches
+	b.Reset()
+	b.Append(data.NewLoadedPoint(&point{sqlType: Insert, device: "readings_truck_0", fieldCount: 2, sql: "(1451606415000,1.5,'truck_0')"}))
+	if b.lateRows != 1 || b.maxLateness != 5*time.Second {
+		t.Errorf("incorrect late rows after reset: got %d, %v want %d, %v", b.lateRows, b.maxLateness, 1, 5*time.Second)
+	}
+}
+
+func TestGetBatchFactoryOutOfOrderStats(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		b := &benchmark{opts: &LoadingOptions{OutOfOrderStats: enabled}}
+		batch := b.GetBatchFactory().New().(*hypertableArr)
+		batch.Append(data.NewLoadedPoint(&point{sqlType: Insert, device: "readings_truck_0", fieldCount: 2, sql: "(1451606420000,1.5,'truck_0')"}))
+		batch.Append(data.NewLoadedPoint(&point{sqlType: Insert, device: "readings_truck_0", fieldCount: 2, sql: "(1451606410000,1.5,'truck_0')"}))
+		if got := batch.disorder != nil; got != enabled {
+			t.Errorf("out-of-order-stats=%v: incorrect tracker: got %v want %v", enabled, got, enabled)
+		}
+		want := uint64(0)
+		if enabled {
+			want = 1
+		}
+		if batch.lateRows != want {
+			t.Errorf("out-of-order-stats=%v: incorrect late rows: got %d want %d", enabled, batch.lateRows, want)
+		}
+	}
+}
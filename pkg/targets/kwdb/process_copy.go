@@ -0,0 +1,207 @@
+package kwdb
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/timescale/tsbs/pkg/data/usecases/common"
+	"github.com/timescale/tsbs/pkg/targets"
+	"github.com/timescale/tsbs/pkg/targets/kwdb/commonpool"
+)
+
+// copyProcessor streams the rows of a batch to kwdb with COPY FROM STDIN, one
+// copy per super table and field count. Tags are still inserted with insert
+// statements, since a sub table has to exist before rows are copied into it.
+type copyProcessor struct {
+	opts    *LoadingOptions
+	dbName  string
+	sci     *syncCSI
+	_db     *commonpool.Conn
+	headers *common.GeneratedDataHeaders
+	retry   *retryPolicy
+}
+
+func newProcessorCopy(opts *LoadingOptions, dbName string, headers *common.GeneratedDataHeaders) *copyProcessor {
+	// the data files without the header block have the fixed tables
+	if headers == nil {
+		headers = legacyHeaders(opts.Case)
+	}
+	if headers == nil {
+		panic(fmt.Sprintf("kwdb insert-type copy of use-case '%s' needs the header block of the data file, please regenerate the data", opts.Case))
+	}
+	return &copyProcessor{opts: opts, dbName: dbName, sci: globalSCI, headers: headers, retry: newRetryPolicy(opts)}
+}
+
+func (p *copyProcessor) Init(workerNum int, doLoad, _ bool) {
+	if !doLoad {
+		return
+	}
+	var err error
+	p._db, err = p.opts.Connect(workerNum)
+	if err != nil {
+		panic(err)
+	}
+}
+
+type copyKey struct {
+	table      string
+	fieldCount int
+}
+
+func (p *copyProcessor) ProcessBatch(b targets.Batch, doLoad bool) (metricCount, rowCount uint64) {
+	batches := b.(*hypertableArr)
+	rowCnt := uint64(0)
+	metricCnt := batches.totalMetric
+	if !doLoad {
+		for _, sqls := range batches.m {
+			rowCnt += uint64(len(sqls))
+		}
+		return metricCnt, rowCnt
+	}
+
+	if p.opts.DoCreate && len(batches.createSql) > 0 {
+		insertTags(p._db, p.retry, p.dbName, p.headers, p.sci, batches.createSql)
+	}
+
+	rows := make(map[copyKey][][]interface{})
+	metrics := make(map[copyKey]uint64)
+	for device, sqls := range batches.m {
+		rowCnt += uint64(len(sqls))
+		if p.opts.DoCreate {
+			p.sci.wait(device)
+		}
+		table := superTableOf(device)
+		for _, sql := range sqls {
+			values, err := parseCopyRow(sql, p.headers.FieldTypes[table])
+			if err != nil {
+				// the row is skipped with its values, counted like totalMetric
+				p.retry.failRows(uint64(batches.fields[device]+1), 1, "kwdb copy %s row failed,err :%s", table, err)
+				continue
+			}
+			// the values are the timestamp, the fields and the primary tag
+			key := copyKey{table: table, fieldCount: len(values) - 2}
+			rows[key] = append(rows[key], values)
+			metrics[key] += uint64(len(values) - 1)
+		}
+	}
+
+	for key, values := range rows {
+		fields := p.headers.FieldKeys[key.table]
+		if key.fieldCount < len(fields) {
+			fields = fields[:key.fieldCount]
+		}
+		columns := make([]string, 0, len(fields)+2)
+		columns = append(columns, "k_timestamp")
+		columns = append(columns, fields...)
+		columns = append(columns, primaryTag(key.table, p.headers.TagKeys))
+		err := p.retry.run(p._db, func() error {
+			_, err := p._db.Connection.CopyFrom(context.Background(), pgx.Identifier{p.dbName, key.table}, columns, pgx.CopyFromRows(values))
+			return err
+		})
+		if err != nil {
+			p.retry.failRows(metrics[key], uint64(len(values)), "kwdb copy %s data failed,err :%s", key.table, err)
+		}
+	}
+	updateTags(p._db, p.retry, p.dbName, batches.modifySql)
+	batches.Reset()
+	// the rows of failed statements are not counted as loaded
+	return p.retry.batchDone(metricCnt, rowCnt)
+}
+
+// FailedBatches returns the number of batches with statements skipped with
+// continue-on-error
+func (p *copyProcessor) FailedBatches() uint64 {
+	return p.retry.failedBatches
+}
+
+func (p *copyProcessor) Close(doLoad bool) {
+	if doLoad {
+		p._db.Put()
+	}
+}
+
+// parseCopyRow converts an insert row of the data file, (ts,field...,'tag'),
+// to the values of a copy row, typed after the field types of the headers
+func parseCopyRow(sql string, fieldTypes []string) ([]interface{}, error) {
+	parts := splitRow(strings.TrimSuffix(strings.TrimPrefix(sql, "("), ")"))
+	values := make([]interface{}, len(parts))
+
+	ts, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("parse timestamp '%s': %v", parts[0], err)
+	}
+	values[0] = time.UnixMilli(ts).UTC()
+
+	last := len(parts) - 1
+	for i := 1; i < last; i++ {
+		typ := ""
+		if i-1 < len(fieldTypes) {
+			typ = fieldTypes[i-1]
+		}
+		if values[i], err = parseCopyValue(parts[i], typ); err != nil {
+			return nil, err
+		}
+	}
+	// the primary tag is a string, NULL when the point has none
+	values[last], _ = parseCopyValue(parts[last], "string")
+	return values, nil
+}
+
+// splitRow splits the values of an insert row on the commas outside of
+// quoted strings, in which a quote is escaped by doubling it
+func splitRow(row string) []string {
+	var parts []string
+	quoted := false
+	start := 0
+	for i := 0; i < len(row); i++ {
+		switch row[i] {
+		case '\'':
+			// an escaped quote opens and closes the string right away
+			quoted = !quoted
+		case ',':
+			if !quoted {
+				parts = append(parts, row[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, row[start:])
+}
+
+// unquote returns the string of a quoted value of an insert row
+func unquote(s string) string {
+	if len(s) >= 2 && s[0] == '\'' && s[len(s)-1] == '\'' {
+		s = s[1 : len(s)-1]
+	}
+	return strings.ReplaceAll(s, "''", "'")
+}
+
+func parseCopyValue(s, typ string) (interface{}, error) {
+	if strings.EqualFold(s, "null") {
+		return nil, nil
+	}
+	var v interface{}
+	var err error
+	switch kwdbType(typ) {
+	case "bigint":
+		v, err = strconv.ParseInt(s, 10, 64)
+	case "int":
+		var i int64
+		i, err = strconv.ParseInt(s, 10, 32)
+		v = int32(i)
+	case "bool":
+		v, err = strconv.ParseBool(s)
+	case "float8":
+		v, err = strconv.ParseFloat(s, 64)
+	default:
+		v = unquote(s)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse value '%s' as %s: %v", s, typ, err)
+	}
+	return v, nil
+}
@@ -0,0 +1,121 @@
+package kwdb
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseCopyRow(t *testing.T) {
+	cases := []struct {
+		desc       string
+		sql        string
+		fieldTypes []string
+		want       []interface{}
+	}{
+		{
+			desc:       "cpu",
+			sql:        "(1451606400000,58,2,'host_0')",
+			fieldTypes: []string{"int64", "int64"},
+			want:       []interface{}{time.UnixMilli(1451606400000).UTC(), int64(58), int64(2), "host_0"},
+		},
+		{
+			desc:       "readings",
+			sql:        "(1451606410000,30.32363,91.43075,'truck_0')",
+			fieldTypes: []string{"float64", "float64"},
+			want:       []interface{}{time.UnixMilli(1451606410000).UTC(), 30.32363, 91.43075, "truck_0"},
+		},
+		{
+			desc:       "null field",
+			sql:        "(1451606400000,NULL,7,'host_1')",
+			fieldTypes: []string{"float64", "int64"},
+			want:       []interface{}{time.UnixMilli(1451606400000).UTC(), nil, int64(7), "host_1"},
+		},
+		{
+			desc:       "comma in a quoted field",
+			sql:        "(1451606400000,'a,b',7,'host_1')",
+			fieldTypes: []string{"string", "int64"},
+			want:       []interface{}{time.UnixMilli(1451606400000).UTC(), "a,b", int64(7), "host_1"},
+		},
+		{
+			desc:       "comma in the primary tag",
+			sql:        "(1451606400000,58,'host,1')",
+			fieldTypes: []string{"int64"},
+			want:       []interface{}{time.UnixMilli(1451606400000).UTC(), int64(58), "host,1"},
+		},
+		{
+			desc:       "escaped quotes",
+			sql:        "(1451606400000,'it''s, ok','''')",
+			fieldTypes: []string{"string"},
+			want:       []interface{}{time.UnixMilli(1451606400000).UTC(), "it's, ok", "'"},
+		},
+		{
+			desc:       "null primary tag",
+			sql:        "(1451606400000,58,NULL)",
+			fieldTypes: []string{"int64"},
+			want:       []interface{}{time.UnixMilli(1451606400000).UTC(), int64(58), nil},
+		},
+		{
+			desc:       "quoted NULL string",
+			sql:        "(1451606400000,'NULL','NULL')",
+			fieldTypes: []string{"string"},
+			want:       []interface{}{time.UnixMilli(1451606400000).UTC(), "NULL", "NULL"},
+		},
+	}
+	for _, c := range cases {
+		got, err := parseCopyRow(c.sql, c.fieldTypes)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", c.desc, err)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: incorrect values: got %v want %v", c.desc, got, c.want)
+		}
+	}
+}
+
+func TestParseCopyRowErrors(t *testing.T) {
+	cases := []struct {
+		desc       string
+		sql        string
+		fieldTypes []string
+	}{
+		{desc: "malformed timestamp", sql: "(2016-01-01,58,'host_0')", fieldTypes: []string{"int64"}},
+		{desc: "malformed bigint", sql: "(1451606400000,5.8,'host_0')", fieldTypes: []string{"int64"}},
+		{desc: "malformed float", sql: "(1451606400000,abc,'truck_0')", fieldTypes: []string{"float64"}},
+		{desc: "malformed bool", sql: "(1451606400000,yes,'host_0')", fieldTypes: []string{"bool"}},
+	}
+	for _, c := range cases {
+		if _, err := parseCopyRow(c.sql, c.fieldTypes); err == nil {
+			t.Errorf("%s: expected error", c.desc)
+		}
+	}
+}
+
+func TestNewProcessorCopyWithoutHeaders(t *testing.T) {
+	cases := []struct {
+		useCase string
+		table   string
+		columns int
+		primary string
+	}{
+		{useCase: "cpu-only", table: "cpu", columns: 10, primary: "hostname"},
+		{useCase: "iot", table: "readings", columns: 7, primary: "name"},
+		{useCase: "iot", table: "diagnostics", columns: 3, primary: "name"},
+	}
+	for _, c := range cases {
+		p := newProcessorCopy(&LoadingOptions{Case: c.useCase}, "benchmark", nil)
+		if got := len(p.headers.FieldKeys[c.table]); got != c.columns || len(p.headers.FieldTypes[c.table]) != c.columns {
+			t.Errorf("%s %s: incorrect fields: got %d want %d", c.useCase, c.table, got, c.columns)
+		}
+		if got := primaryTag(c.table, p.headers.TagKeys); got != c.primary {
+			t.Errorf("%s %s: incorrect primary tag: got %s want %s", c.useCase, c.table, got, c.primary)
+		}
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf("expected a panic for devops without headers")
+		}
+	}()
+	newProcessorCopy(&LoadingOptions{Case: "devops"}, "benchmark", nil)
+}
@@ -7,14 +7,11 @@ import (
 	"strings"
 	"sync"
 
+	"github.com/timescale/tsbs/pkg/data/usecases/common"
 	"github.com/timescale/tsbs/pkg/targets"
 	"github.com/timescale/tsbs/pkg/targets/kwdb/commonpool"
 )
 
-type syncCSI struct {
-	m sync.Map //table:ctx
-}
-
 const (
 	Size1M            = 1 * 1024 * 1024
 	LenReadings       = 115
@@ -31,17 +28,104 @@ type Ctx struct {
 
 var globalSCI = &syncCSI{}
 
+// deviceShards is the number of shards of the device tracker. The devices
+// spread over the shards by the hash of their name, so that workers rarely
+// wait on each other with millions of devices.
+const deviceShards = 256
+
+// syncCSI tracks the devices whose tags are inserted or being inserted, the
+// rows of a device wait on its Ctx until its tags are in the database
+type syncCSI struct {
+	shards [deviceShards]deviceShard
+}
+
+type deviceShard struct {
+	mu sync.Mutex
+	m  map[string]*Ctx
+}
+
+// tagsInserted is shared by all the devices whose tags are in the database,
+// so that they do not keep a context each
+var tagsInserted = func() *Ctx {
+	c, cancel := context.WithCancel(context.Background())
+	cancel()
+	return &Ctx{c: c, cancel: cancel}
+}()
+
+// shard returns the shard of the device, by the FNV-1a hash of its name
+func (s *syncCSI) shard(device string) *deviceShard {
+	h := uint32(2166136261)
+	for i := 0; i < len(device); i++ {
+		h ^= uint32(device[i])
+		h *= 16777619
+	}
+	return &s.shards[h%deviceShards]
+}
+
+// load returns the Ctx of a known device
+func (s *syncCSI) load(device string) (*Ctx, bool) {
+	sh := s.shard(device)
+	sh.mu.Lock()
+	ctx, ok := sh.m[device]
+	sh.mu.Unlock()
+	return ctx, ok
+}
+
+// loadOrCreate returns the Ctx of the device, registering a new one when the
+// device is unknown
+func (s *syncCSI) loadOrCreate(device string) *Ctx {
+	sh := s.shard(device)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	if ctx, ok := sh.m[device]; ok {
+		return ctx
+	}
+	if sh.m == nil {
+		sh.m = make(map[string]*Ctx)
+	}
+	c, cancel := context.WithCancel(context.Background())
+	ctx := &Ctx{c: c, cancel: cancel}
+	sh.m[device] = ctx
+	return ctx
+}
+
+// release marks the tags of the device inserted and wakes up its rows
+func (s *syncCSI) release(device string) {
+	sh := s.shard(device)
+	sh.mu.Lock()
+	if sh.m == nil {
+		sh.m = make(map[string]*Ctx)
+	}
+	ctx := sh.m[device]
+	sh.m[device] = tagsInserted
+	sh.mu.Unlock()
+	if ctx != nil && ctx != tagsInserted {
+		ctx.cancel()
+	}
+}
+
+// wait blocks until the tags of the device are inserted
+func (s *syncCSI) wait(device string) {
+	<-s.loadOrCreate(device).c.Done()
+}
+
 type processorInsert struct {
-	opts   *LoadingOptions
-	dbName string
-	sci    *syncCSI
-	_db    *commonpool.Conn
-	wg     *sync.WaitGroup
-	buf    *bytes.Buffer
+	opts    *LoadingOptions
+	dbName  string
+	sci     *syncCSI
+	_db     *commonpool.Conn
+	wg      *sync.WaitGroup
+	buf     *bytes.Buffer
+	headers *common.GeneratedDataHeaders
+	retry   *retryPolicy
 }
 
-func newProcessorInsert(opts *LoadingOptions, dbName string) *processorInsert {
-	return &processorInsert{opts: opts, dbName: dbName, sci: globalSCI, wg: &sync.WaitGroup{}, buf: &bytes.Buffer{}}
+func newProcessorInsert(opts *LoadingOptions, dbName string, headers *common.GeneratedDataHeaders) *processorInsert {
+	// the rows of the devops use cases go to the columns of the header block
+	if headers == nil && isDevopsCase(opts.Case) {
+		panic(fmt.Sprintf("kwdb use-case '%s' needs the header block of the data file to insert its rows, please regenerate the data", opts.Case))
+	}
+	return &processorInsert{opts: opts, dbName: dbName, sci: globalSCI, wg: &sync.WaitGroup{}, buf: &bytes.Buffer{}, headers: headers, retry: newRetryPolicy(opts)}
 }
 
 func (p *processorInsert) Init(proNum int, doLoad, _ bool) {
@@ -50,7 +134,7 @@ func (p *processorInsert) Init(proNum int, doLoad, _ bool) {
 	}
 	p.buf.Grow(Size1M)
 	var err error
-	p._db, err = commonpool.GetConnection(p.opts.User, p.opts.Pass, p.opts.Host, p.opts.CertDir, p.opts.Port)
+	p._db, err = p.opts.Connect(proNum)
 
 	if err != nil {
 		panic(err)
@@ -70,17 +154,13 @@ func (p *processorInsert) ProcessBatch(b targets.Batch, doLoad bool) (metricCoun
 	}
 	p.buf.Reset()
 	var deviceNum int
-	if p.opts.Case == "cpu-only" {
+	// the batch is reset once its rows are inserted, the tag changes of
+	// its devices follow them
+	modifySql := batches.modifySql
+	if p.opts.Case == "cpu-only" && !p.hasCardinalityTag() {
 		if p.opts.DoCreate && len(batches.createSql) > 0 {
-			deviceContexts := make(map[string]*Ctx)
 			for _, row := range batches.createSql {
-				c, cancel := context.WithCancel(context.Background())
-				ctx := &Ctx{
-					c:      c,
-					cancel: cancel,
-				}
-				actual, _ := p.sci.m.LoadOrStore(row.device, ctx)
-				deviceContexts[row.device] = actual.(*Ctx)
+				p.sci.loadOrCreate(row.device)
 			}
 
 			var sqlBuilder strings.Builder
@@ -94,13 +174,12 @@ func (p *processorInsert) ProcessBatch(b targets.Batch, doLoad bool) (metricCoun
 			}
 
 			sql := sqlBuilder.String()
-			_, err := p._db.Connection.Exec(context.Background(), sql)
-			if err != nil {
-				panic(fmt.Sprintf("kwdb insert data failed,err :%s", err))
+			if err := p.retry.exec(p._db, sql); err != nil {
+				p.retry.fail("kwdb insert data failed,err :%s", err)
 			}
 
-			for _, ctx := range deviceContexts {
-				ctx.cancel()
+			for _, row := range batches.createSql {
+				p.sci.release(row.device)
 			}
 		}
 
@@ -109,43 +188,41 @@ func (p *processorInsert) ProcessBatch(b targets.Batch, doLoad bool) (metricCoun
 		sql1 := fmt.Sprintf("insert into %s.cpu (k_timestamp,%s,hostname) values", p.dbName, tagsname)
 		sql2 := sql1
 		cnt1, cnt2 := 0, 0
+		// the rows and values of each statement, not loaded when it fails
+		var rows1, rows2, metrics1, metrics2 uint64
 		for hostname, sqls := range batches.m {
 			rowCnt += uint64(len(sqls))
 			// var csvSQL string
 			csvSQL := strings.Join(sqls, ",")
-			v, ok := p.sci.m.Load(hostname)
+			ctx, ok := p.sci.load(hostname)
 			// fmt.Println(hostname)
 			if ok {
-				<-v.(*Ctx).c.Done()
+				<-ctx.c.Done()
 				sql1 += csvSQL + ","
 				cnt1++
+				rows1 += uint64(len(sqls))
+				metrics1 += batches.metrics(hostname)
 			} else {
 				// wait for allTag data inserted
-				allTagC, allTagCancel := context.WithCancel(context.Background())
-				allTagCtx := &Ctx{
-					c:      allTagC,
-					cancel: allTagCancel,
-				}
-				allTagActual, _ := p.sci.m.LoadOrStore(hostname, allTagCtx)
-				<-allTagActual.(*Ctx).c.Done()
+				p.sci.wait(hostname)
 
 				sql2 += csvSQL + ","
 				cnt2++
+				rows2 += uint64(len(sqls))
+				metrics2 += batches.metrics(hostname)
 			}
 		}
 		if cnt1+cnt2 == len(batches.m) {
 			if cnt1 != 0 {
 				sql1 = sql1[:len(sql1)-1]
-				_, err := p._db.Connection.Exec(context.Background(), sql1)
-				if err != nil {
-					panic(fmt.Sprintf("kwdb insert data failed!,err :%s", err))
+				if err := p.retry.exec(p._db, sql1); err != nil {
+					p.retry.failRows(metrics1, rows1, "kwdb insert data failed!,err :%s", err)
 				}
 			}
 			if cnt2 != 0 {
 				sql2 = sql2[:len(sql2)-1]
-				_, err2 := p._db.Connection.Exec(context.Background(), sql2)
-				if err2 != nil {
-					panic(fmt.Sprintf("kwdb insert data failed!,err :%s", err2))
+				if err := p.retry.exec(p._db, sql2); err != nil {
+					p.retry.failRows(metrics2, rows2, "kwdb insert data failed!,err :%s", err)
 				}
 			}
 		}
@@ -159,12 +236,6 @@ func (p *processorInsert) ProcessBatch(b targets.Batch, doLoad bool) (metricCoun
 			lenbr, lenbd := br.Len(), bd.Len()
 			batcheslen := len(batches.createSql)
 			for i, row := range batches.createSql {
-				c, cancel := context.WithCancel(context.Background())
-				ctx := &Ctx{
-					c:      c,
-					cancel: cancel,
-				}
-				actual, _ := p.sci.m.LoadOrStore(row.device, ctx)
 				if row.template == "readings" {
 					br.WriteString(row.sql)
 					if i < batcheslen-1 {
@@ -176,20 +247,18 @@ func (p *processorInsert) ProcessBatch(b targets.Batch, doLoad bool) (metricCoun
 						bd.WriteString(",")
 					}
 				}
-				actual.(*Ctx).cancel()
+				p.sci.release(row.device)
 				continue
 			}
 			if batches.createSql != nil {
 				if br.Len() > lenbr {
-					_, err := p._db.Connection.Exec(context.Background(), br.String())
-					if err != nil {
-						panic(fmt.Sprintf("kwdb insert readings data failed,err :%s", err))
+					if err := p.retry.exec(p._db, br.String()); err != nil {
+						p.retry.fail("kwdb insert readings data failed,err :%s", err)
 					}
 				}
 				if bd.Len() > lenbd {
-					_, err := p._db.Connection.Exec(context.Background(), bd.String())
-					if err != nil {
-						panic(fmt.Sprintf("kwdb insert diagnostics data failed,err :%s", err))
+					if err := p.retry.exec(p._db, bd.String()); err != nil {
+						p.retry.fail("kwdb insert diagnostics data failed,err :%s", err)
 					}
 				}
 
@@ -203,61 +272,169 @@ func (p *processorInsert) ProcessBatch(b targets.Batch, doLoad bool) (metricCoun
 		fmt.Fprintf(&b3, readingsPrefix, p.dbName)
 		fmt.Fprintf(&b4, diagnosticsPrefix, p.dbName)
 		cnt1, cnt2 := 0, 0
+		// the rows and values of each statement, not loaded when it fails
+		var rows, metrics [4]uint64
 		for hostname, sqls := range batches.m {
 			rowCnt += uint64(len(sqls))
 			csvSQL := strings.Join(sqls, ",")
-			v, ok := p.sci.m.Load(hostname)
+			ctx, ok := p.sci.load(hostname)
+			i := 0
 			if ok {
-				<-v.(*Ctx).c.Done()
+				<-ctx.c.Done()
 				if strings.HasPrefix(hostname, readingsSuffix) {
 					b1.WriteString(csvSQL)
 				} else { //means diagnostics
 					b2.WriteString(csvSQL)
+					i = 1
 				}
 				cnt1 += len(sqls)
 			} else {
 				// wait for allTag data inserted
-				allTagC, allTagCancel := context.WithCancel(context.Background())
-				allTagCtx := &Ctx{
-					c:      allTagC,
-					cancel: allTagCancel,
-				}
-				allTagActual, _ := p.sci.m.LoadOrStore(hostname, allTagCtx)
-				<-allTagActual.(*Ctx).c.Done()
+				p.sci.wait(hostname)
 
 				if strings.HasPrefix(hostname, readingsSuffix) {
 					b3.WriteString(csvSQL)
+					i = 2
 				} else { //means diagnostics
 					b4.WriteString(csvSQL)
+					i = 3
 				}
 				cnt2++
 			}
+			rows[i] += uint64(len(sqls))
+			metrics[i] += batches.metrics(hostname)
 		}
 		if cnt1+cnt2 == int(batches.cnt) {
-			execSQL := func(sqlStr strings.Builder, expectedLen int, sqlType string) {
+			execSQL := func(sqlStr strings.Builder, expectedLen int, sqlType string, i int) {
 				if sqlStr.Len() != expectedLen {
-					_, err := p._db.Connection.Exec(context.Background(), sqlStr.String())
-					if err != nil {
+					if err := p.retry.exec(p._db, sqlStr.String()); err != nil {
 						fmt.Println(expectedLen, sqlStr.Len())
-						panic(fmt.Sprintf("kwdb insert %s data failed! err: %s", sqlType, err))
+						p.retry.failRows(metrics[i], rows[i], "kwdb insert %s data failed! err: %s", sqlType, err)
 					}
 				}
 			}
 
-			execSQL(b1, LenReadings+len(p.dbName), "readings1")
-			execSQL(b2, LenDiagnostics+len(p.dbName), "diagnostics1")
+			execSQL(b1, LenReadings+len(p.dbName), "readings1", 0)
+			execSQL(b2, LenDiagnostics+len(p.dbName), "diagnostics1", 1)
 
 			if cnt2 != 0 {
-				execSQL(b3, LenReadings+len(p.dbName), "readings2")
-				execSQL(b4, LenDiagnostics+len(p.dbName), "diagnostics2")
+				execSQL(b3, LenReadings+len(p.dbName), "readings2", 2)
+				execSQL(b4, LenDiagnostics+len(p.dbName), "diagnostics2", 3)
 			}
 		}
 
+		batches.Reset()
+	} else if isDevopsCase(p.opts.Case) || p.opts.Case == "cpu-only" {
+		// cpu-only data with the high cardinality tag has one more tag than
+		// the fixed statements above
+		rowCnt = p.insertByTable(batches)
 		batches.Reset()
 	}
+	updateTags(p._db, p.retry, p.dbName, modifySql)
+
+	// the rows of failed statements are not counted as loaded
+	return p.retry.batchDone(metricCnt+uint64(deviceNum)*20, rowCnt+uint64(deviceNum))
 
-	return metricCnt + uint64(deviceNum)*20, rowCnt + uint64(deviceNum)
+}
+
+// insertByTable routes the rows of a batch to the super table of their
+// device, with one insert statement per super table and field count. The
+// columns come from the header block of the data file.
+func (p *processorInsert) insertByTable(batches *hypertableArr) uint64 {
+	if p.opts.DoCreate && len(batches.createSql) > 0 {
+		insertTags(p._db, p.retry, p.dbName, p.headers, p.sci, batches.createSql)
+	}
+
+	type tableRows struct {
+		table      string
+		fieldCount int
+	}
+	rowCnt := uint64(0)
+	rows := make(map[tableRows][]string)
+	metrics := make(map[tableRows]uint64)
+	for device, sqls := range batches.m {
+		rowCnt += uint64(len(sqls))
+		if p.opts.DoCreate {
+			p.sci.wait(device)
+		}
+		// hosts of devops-generic report a varying number of fields, so
+		// count them per device
+		key := tableRows{table: superTableOf(device), fieldCount: batches.fields[device]}
+		rows[key] = append(rows[key], sqls...)
+		metrics[key] += batches.metrics(device)
+	}
+
+	for key, values := range rows {
+		fields := p.headers.FieldKeys[key.table]
+		if key.fieldCount < len(fields) {
+			fields = fields[:key.fieldCount]
+		}
+		sql := fmt.Sprintf("insert into %s.%s (k_timestamp,%s,%s) values %s", p.dbName, key.table,
+			strings.Join(fields, ","), primaryTag(key.table, p.headers.TagKeys), strings.Join(values, ","))
+		if err := p.retry.exec(p._db, sql); err != nil {
+			p.retry.failRows(metrics[key], uint64(len(values)), "kwdb insert %s data failed!,err :%s", key.table, err)
+		}
+	}
+	return rowCnt
+}
+
+// insertTags inserts the tag rows of a batch with one insert statement per
+// super table, then releases the devices waiting for their tags
+func insertTags(db *commonpool.Conn, retry *retryPolicy, dbName string, headers *common.GeneratedDataHeaders, sci *syncCSI, createSql []*point) {
+	tagRows := make(map[string][]string)
+	for _, row := range createSql {
+		sci.loadOrCreate(row.device)
+		tagRows[row.template] = append(tagRows[row.template], row.sql)
+	}
+
+	for table, rows := range tagRows {
+		tagKeys, _ := tableTags(table, headers)
+		sql := fmt.Sprintf("insert into %s.%s (%s) values %s", dbName, table, strings.Join(tagKeys, ","), strings.Join(rows, ","))
+		if err := retry.exec(db, sql); err != nil {
+			retry.fail("kwdb insert %s tags failed,err :%s", table, err)
+		}
+	}
+
+	for _, row := range createSql {
+		sci.release(row.device)
+	}
+}
+
+// updateTags applies the tag changes of a batch. A change names its device
+// by the primary tag in its first key=value pair, the others set the tags.
+func updateTags(db *commonpool.Conn, retry *retryPolicy, dbName string, modifySql []*point) {
+	for _, row := range modifySql {
+		sql, ok := updateTagsSql(dbName, row)
+		if !ok {
+			continue
+		}
+		if err := retry.exec(db, sql); err != nil {
+			retry.fail("kwdb update %s tags failed,err :%s", row.template, err)
+		}
+	}
+}
+
+// updateTagsSql returns the update statement of a tag change record, false
+// when it changes no tag. The primary tag pair leads the record, its value may
+// hold commas.
+func updateTagsSql(dbName string, row *point) (string, bool) {
+	pairs := splitRow(strings.TrimSuffix(strings.TrimPrefix(row.sql, "("), ")"))
+	set := strings.Join(pairs[1:], ",")
+	if len(strings.TrimSpace(set)) == 0 {
+		return "", false
+	}
+	return fmt.Sprintf("update %s.%s set %s where %s", dbName, row.template, set, pairs[0]), true
+}
+
+// hasCardinalityTag reports whether the data file has the high cardinality tag
+func (p *processorInsert) hasCardinalityTag() bool {
+	return p.headers != nil && hasCardinalityTag(p.headers.TagKeys)
+}
 
+// FailedBatches returns the number of batches with statements skipped with
+// continue-on-error
+func (p *processorInsert) FailedBatches() uint64 {
+	return p.retry.failedBatches
 }
 
 func (p *processorInsert) Close(doLoad bool) {
@@ -0,0 +1,97 @@
+package kwdb
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSyncCSI(t *testing.T) {
+	s := &syncCSI{}
+	if _, ok := s.load("host_0"); ok {
+		t.Fatalf("unexpected known device")
+	}
+
+	// rows of a device wait until its tags are inserted
+	ctx := s.loadOrCreate("host_0")
+	if s.loadOrCreate("host_0") != ctx {
+		t.Errorf("device registered twice")
+	}
+	waited := make(chan struct{})
+	go func() {
+		s.wait("host_0")
+		close(waited)
+	}()
+	select {
+	case <-waited:
+		t.Fatalf("rows did not wait for the tags")
+	case <-time.After(10 * time.Millisecond):
+	}
+	s.release("host_0")
+	select {
+	case <-waited:
+	case <-time.After(time.Second):
+		t.Fatalf("rows still wait for the inserted tags")
+	}
+
+	// the devices with inserted tags share a single context
+	s.release("host_1")
+	if c0, _ := s.load("host_0"); c0 != tagsInserted || s.loadOrCreate("host_1") != tagsInserted {
+		t.Errorf("devices with inserted tags keep contexts of their own")
+	}
+}
+
+func TestSyncCSIConcurrent(t *testing.T) {
+	s := &syncCSI{}
+	const devices = 10000
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := w; i < devices; i += 8 {
+				device := fmt.Sprintf("container_%d", i)
+				s.loadOrCreate(device)
+				s.release(device)
+				s.wait(device)
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	count := 0
+	for i := range s.shards {
+		count += len(s.shards[i].m)
+	}
+	if count != devices {
+		t.Errorf("incorrect number of devices: got %d want %d", count, devices)
+	}
+}
+
+func TestNewProcessorInsertHeaders(t *testing.T) {
+	// cpu-only and iot rows go to fixed columns, data files without the
+	// header block still load
+	newProcessorInsert(&LoadingOptions{Case: "cpu-only"}, "benchmark", nil)
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("unexpected lack of panic for devops without headers")
+		}
+	}()
+	newProcessorInsert(&LoadingOptions{Case: "devops"}, "benchmark", nil)
+}
+
+func TestBatchFieldCount(t *testing.T) {
+	// the field count comes from the insert line, not from the commas of
+	// the values
+	input := "1,host_0,3,(1451606400000,58,2,'host,0')\n"
+	d := &fileDataSource{scanner: bufio.NewScanner(strings.NewReader(input)), headersRead: true}
+	batch := (&factory{disorder: newDisorderTracker()}).New().(*hypertableArr)
+	batch.Append(d.NextItem())
+	if got := batch.fields["host_0"]; got != 2 {
+		t.Errorf("incorrect number of fields: got %d want 2", got)
+	}
+}
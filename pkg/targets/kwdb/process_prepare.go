@@ -43,7 +43,17 @@ func (fa *fixedArgList) Append(value []byte) {
 	fa.writePos++
 }
 
+// AppendNull writes a NULL argument, the slot gets a new buffer when a value
+// is emplaced into it again
+func (fa *fixedArgList) AppendNull() {
+	fa.args[fa.writePos] = nil
+	fa.writePos++
+}
+
 func (fa *fixedArgList) Emplace(value uint64) {
+	if len(fa.args[fa.writePos]) < 8 {
+		fa.args[fa.writePos] = make([]byte, 8)
+	}
 	binary.BigEndian.PutUint64(fa.args[fa.writePos], value)
 	fa.writePos++
 }
@@ -70,17 +80,25 @@ type prepareProcessor struct {
 	buffer     map[string]*fixedArgList // tableName, fixedArgList
 	buffInited bool
 	formatBuf  []int16
+
+	retry *retryPolicy
 }
 
 func newProcessorPrepare(opts *LoadingOptions, dbName string) *prepareProcessor {
-	return &prepareProcessor{
+	p := &prepareProcessor{
 		opts:        opts,
 		dbName:      dbName,
 		sci:         globalSCI,
 		preparedSql: make(map[string]struct{}),
 		buffer:      make(map[string]*fixedArgList),
 		formatBuf:   make([]int16, opts.Preparesize*12),
+		retry:       newRetryPolicy(opts),
+	}
+	// prepared statements do not survive a reconnect
+	p.retry.onReconnect = func() {
+		p.preparedSql = make(map[string]struct{})
 	}
+	return p
 }
 
 func (p *prepareProcessor) Init(workerNum int, doLoad, _ bool) {
@@ -104,7 +122,7 @@ func (p *prepareProcessor) Init(workerNum int, doLoad, _ bool) {
 	p.workerIndex = workerNum
 
 	var err error
-	p._db, err = commonpool.GetConnection(p.opts.User, p.opts.Pass, p.opts.Host, p.opts.CertDir, p.opts.Port)
+	p._db, err = p.opts.Connect(workerNum)
 	if err != nil {
 		panic(err)
 	}
@@ -148,7 +166,6 @@ func (p *prepareProcessor) ProcessBatch(b targets.Batch, doLoad bool) (metricCou
 		p.buffInited = true
 	}
 	tableBuffer := p.buffer["cpu"]
-	_, cpuPrepared := p.preparedSql["cpu"]
 
 	// join args and execute
 	for _, args := range batches.m {
@@ -159,22 +176,33 @@ func (p *prepareProcessor) ProcessBatch(b targets.Batch, doLoad bool) (metricCou
 
 			// check buffer is full
 			if tableBuffer.Length() == tableBuffer.Capacity() {
-				// init prepareStmt
-				if !cpuPrepared {
-					p.createPrepareSql("cpu")
-					p.preparedSql["cpu"] = struct{}{}
-					cpuPrepared = true
+				err := p.retry.run(p._db, func() error {
+					// init prepareStmt
+					if _, ok := p.preparedSql["cpu"]; !ok {
+						if err := p.createPrepareSql("cpu"); err != nil {
+							return err
+						}
+						p.preparedSql["cpu"] = struct{}{}
+					}
+					return p.execPrepareStmt("cpu", tableBuffer.args)
+				})
+				if err != nil {
+					// the buffer holds Preparesize rows of 12 arguments,
+					// the timestamp, the fields and the hostname
+					rows := uint64(p.opts.Preparesize)
+					p.retry.failRows(rows*11, rows, "kwdb prepare insert data failed,err :%s", err)
 				}
-
-				p.execPrepareStmt("cpu", tableBuffer.args)
 				// reuse buffer: reset tableBuffer's write position
 				tableBuffer.Reset()
 			}
 		}
 	}
 
+	updateTags(p._db, p.retry, p.dbName, batches.modifySql)
+
 	// batches.Reset()
-	return metricCnt + uint64(deviceNums)*20, rowCnt + uint64(deviceNums)
+	// the rows of failed statements are not counted as loaded
+	return p.retry.batchDone(metricCnt+uint64(deviceNums)*20, rowCnt+uint64(deviceNums))
 }
 
 func (p *prepareProcessor) parseCPURowIntoBuffer(s string, tableBuffer *fixedArgList) {
@@ -188,7 +216,9 @@ func (p *prepareProcessor) parseCPURowIntoBuffer(s string, tableBuffer *fixedArg
 		}
 
 		v := s[start:pos]
-		if fieldIdx < 11 {
+		if fieldIdx > 0 && fieldIdx < 11 && v == nullValue {
+			tableBuffer.AppendNull()
+		} else if fieldIdx < 11 {
 			num, ok := fastParseInt(v)
 			if !ok {
 				num, _ = strconv.ParseInt(v, 10, 64)
@@ -224,6 +254,12 @@ func (p *prepareProcessor) parseCPURowIntoBuffer(s string, tableBuffer *fixedArg
 	}
 }
 
+// FailedBatches returns the number of batches with statements skipped with
+// continue-on-error
+func (p *prepareProcessor) FailedBatches() uint64 {
+	return p.retry.failedBatches
+}
+
 func (p *prepareProcessor) Close(doLoad bool) {
 	if doLoad {
 		p._db.Put()
@@ -237,14 +273,9 @@ func (p *prepareProcessor) createDeviceAndAttribute(createSql []*point) int {
 		deviceNums += 1
 		switch row.sqlType {
 		case CreateTemplateTable:
-			c, cancel := context.WithCancel(context.Background())
-			ctx := &Ctx{
-				c:      c,
-				cancel: cancel,
-			}
-			actual, _ := p.sci.m.LoadOrStore(row.template, ctx)
+			p.sci.loadOrCreate(row.template)
 			sql := fmt.Sprintf("create table %s.%s %s", p.opts.DBName, row.template, row.sql)
-			_, err := p._db.Connection.Exec(ctx.c, sql)
+			_, err := p._db.Connection.Exec(context.Background(), sql)
 			if err != nil {
 				panic(fmt.Sprintf("kwdb create device failed,err :%s", err))
 			}
@@ -252,7 +283,7 @@ func (p *prepareProcessor) createDeviceAndAttribute(createSql []*point) int {
 			if err != nil && !strings.Contains(err.Error(), "already exists") {
 				panic(fmt.Sprintf("kwdb create device failed,err :%s", err))
 			}
-			actual.(*Ctx).cancel()
+			p.sci.release(row.template)
 		case CreateTable:
 			sql += row.sql + ","
 			continue
@@ -263,29 +294,24 @@ func (p *prepareProcessor) createDeviceAndAttribute(createSql []*point) int {
 	}
 	if createSql != nil {
 		sql = sql[:len(sql)-1]
-		_, err := p._db.Connection.Exec(context.Background(), sql)
-		if err != nil {
-			panic(fmt.Sprintf("kwdb prepare insert data failed,err :%s", err))
+		if err := p.retry.exec(p._db, sql); err != nil {
+			p.retry.fail("kwdb prepare insert data failed,err :%s", err)
 		}
 	}
 
 	return deviceNums
 }
 
-func (p *prepareProcessor) createPrepareSql(deviecName string) {
+func (p *prepareProcessor) createPrepareSql(deviecName string) error {
 	var insertsql strings.Builder
 	query := fmt.Sprintf("insert into %s.cpu (k_timestamp,usage_user,usage_system,usage_idle,usage_nice,usage_iowait,usage_irq,usage_softirq,usage_steal,usage_guest,usage_guest_nice,hostname) values ", p.opts.DBName)
 	insertsql.WriteString(query)
 	sql := insertsql.String() + p.prepareStmt.String()
-	_, err1 := p._db.Connection.Prepare(context.Background(), "insertall"+deviecName, sql)
-	if err1 != nil {
-		panic(fmt.Sprintf("kwdb Prepare failed,err :%s, sql :%s", err1, sql))
-	}
+	_, err := p._db.Connection.Prepare(context.Background(), "insertall"+deviecName, sql)
+	return err
 }
 
-func (p *prepareProcessor) execPrepareStmt(tableName string, args [][]byte) {
+func (p *prepareProcessor) execPrepareStmt(tableName string, args [][]byte) error {
 	res := p._db.Connection.PgConn().ExecPrepared(context.Background(), "insertall"+tableName, args, p.formatBuf, nil).Read()
-	if res.Err != nil {
-		panic(res.Err)
-	}
+	return res.Err
 }
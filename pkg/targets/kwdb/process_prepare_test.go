@@ -0,0 +1,43 @@
+package kwdb
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+func TestParseRowsNull(t *testing.T) {
+	cpu := newFixedArgList(12)
+	cpu.Init()
+	(&prepareProcessor{}).parseCPURowIntoBuffer("1451606400000,58,NULL,24,61,22,63,6,44,80,38,'host_0'", cpu)
+	if cpu.Length() != 12 {
+		t.Fatalf("incorrect number of cpu arguments: got %d want 12", cpu.Length())
+	}
+	if cpu.args[2] != nil {
+		t.Errorf("NULL field not encoded as a NULL argument: %v", cpu.args[2])
+	}
+	if got := binary.BigEndian.Uint64(cpu.args[1]); got != 58 {
+		t.Errorf("incorrect cpu field: got %d want 58", got)
+	}
+	if string(cpu.args[11]) != "host_0" {
+		t.Errorf("incorrect hostname: got %s", cpu.args[11])
+	}
+
+	// the slot of a NULL gets a buffer again for the next row
+	cpu.Reset()
+	(&prepareProcessor{}).parseCPURowIntoBuffer("1451606410000,58,7,24,61,22,63,6,44,80,38,'host_0'", cpu)
+	if got := binary.BigEndian.Uint64(cpu.args[2]); got != 7 {
+		t.Errorf("incorrect cpu field after a NULL: got %d want 7", got)
+	}
+
+	readings := newFixedArgList(9)
+	readings.Init()
+	s := "1451606400000,30.5,NULL,NULL,1.5,2.5,3.5,4.5,'truck_0'"
+	(&prepareProcessoriot{}).parseReadingsRow(s, len(s), readings)
+	if readings.args[2] != nil || readings.args[3] != nil {
+		t.Errorf("NULL fields not encoded as NULL arguments: %v, %v", readings.args[2], readings.args[3])
+	}
+	if got := math.Float64frombits(binary.BigEndian.Uint64(readings.args[1])); got != 30.5 {
+		t.Errorf("incorrect readings field: got %v want 30.5", got)
+	}
+}
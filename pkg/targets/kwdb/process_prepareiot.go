@@ -39,10 +39,12 @@ type prepareProcessoriot struct {
 	formatBufReadings    []int16
 	formatBufDiagnostics []int16
 	tables               map[string]string
+
+	retry *retryPolicy
 }
 
 func newProcessorPrepareiot(opts *LoadingOptions, dbName string) *prepareProcessoriot {
-	return &prepareProcessoriot{
+	p := &prepareProcessoriot{
 		opts:                 opts,
 		dbName:               dbName,
 		sci:                  globalSCI,
@@ -56,7 +58,13 @@ func newProcessorPrepareiot(opts *LoadingOptions, dbName string) *prepareProcess
 			"diagnostics": fmt.Sprintf("insert into %s.diagnostics (name, fleet, driver, model, "+
 				"device_version, load_capacity, fuel_capacity, nominal_fuel_consumption) values", opts.DBName),
 		},
+		retry: newRetryPolicy(opts),
+	}
+	// prepared statements do not survive a reconnect
+	p.retry.onReconnect = func() {
+		p.preparedSql = make(map[string]struct{})
 	}
+	return p
 }
 
 func (p *prepareProcessoriot) Init(workerNum int, doLoad, _ bool) {
@@ -80,7 +88,7 @@ func (p *prepareProcessoriot) Init(workerNum int, doLoad, _ bool) {
 	p.workerIndex = workerNum
 
 	var err error
-	p._db, err = commonpool.GetConnection(p.opts.User, p.opts.Pass, p.opts.Host, p.opts.CertDir, p.opts.Port)
+	p._db, err = p.opts.Connect(workerNum)
 	if err != nil {
 		panic(err)
 	}
@@ -363,18 +371,29 @@ func (p *prepareProcessoriot) ProcessBatch(b targets.Batch, doLoad bool) (metric
 
 			// check buffer is full
 			if tableBuffer.Length() == tableBuffer.Capacity() {
-				_, ok := p.preparedSql[tableType]
-				if !ok {
-					p.createPrepareSql(tableType)
-					p.preparedSql[tableType] = struct{}{}
+				err := p.retry.run(p._db, func() error {
+					if _, ok := p.preparedSql[tableType]; !ok {
+						if err := p.createPrepareSql(tableType); err != nil {
+							return err
+						}
+						p.preparedSql[tableType] = struct{}{}
+					}
+					return p.execPrepareStmt(tableType, tableBuffer.args)
+				})
+				if err != nil {
+					// the buffer holds Preparesize rows, whose arguments
+					// are the timestamp, the fields and the name
+					rows := uint64(p.opts.Preparesize)
+					p.retry.failRows(rows*uint64(getPreparesize(tableName)-1), rows, "kwdb prepare insert %s data failed, err: %s", tableType, err)
 				}
-				p.execPrepareStmt(tableType, tableBuffer.args)
 				tableBuffer.Reset()
 			}
 		}
 	}
+	updateTags(p._db, p.retry, p.dbName, batches.modifySql)
 
-	return metricCnt, rowCnt
+	// the rows of failed statements are not counted as loaded
+	return p.retry.batchDone(metricCnt, rowCnt)
 }
 
 // parseReadingsRow
@@ -386,14 +405,16 @@ func (p *prepareProcessoriot) parseReadingsRow(s string, sLen int, tableBuffer *
 		if pos == sLen || s[pos] == ',' {
 			v := s[start:pos]
 
-			switch fieldIdx {
-			case 0:
+			switch {
+			case fieldIdx > 0 && v == nullValue:
+				tableBuffer.AppendNull()
+			case fieldIdx == 0:
 				num, ok := fastParseInt(v)
 				if !ok {
 					num, _ = strconv.ParseInt(v, 10, 64)
 				}
 				tableBuffer.Emplace(uint64(num*1000) - microsecFromUnixEpochToY2K + 8*3600*1000000)
-			case 8:
+			case fieldIdx == 8:
 				if q1 := strings.IndexByte(v, '\''); q1 >= 0 {
 					if q2 := strings.IndexByte(v[q1+1:], '\''); q2 >= 0 {
 						tableBuffer.Append([]byte(v[q1+1 : q1+1+q2]))
@@ -422,20 +443,22 @@ func (p *prepareProcessoriot) parseDiagnosticsRow(s string, sLen int, tableBuffe
 		if pos == sLen || s[pos] == ',' {
 			v := s[start:pos]
 
-			switch fieldIdx {
-			case 0:
+			switch {
+			case fieldIdx > 0 && v == nullValue:
+				tableBuffer.AppendNull()
+			case fieldIdx == 0:
 				num, ok := fastParseInt(v)
 				if !ok {
 					num, _ = strconv.ParseInt(v, 10, 64)
 				}
 				tableBuffer.Emplace(uint64(num*1000) - microsecFromUnixEpochToY2K + 8*3600*1000000)
-			case 3:
+			case fieldIdx == 3:
 				num, ok := fastParseInt(v)
 				if !ok {
 					num, _ = strconv.ParseInt(v, 10, 64)
 				}
 				tableBuffer.Emplace(uint64(num))
-			case 4:
+			case fieldIdx == 4:
 				if q1 := strings.IndexByte(v, '\''); q1 >= 0 {
 					if q2 := strings.IndexByte(v[q1+1:], '\''); q2 >= 0 {
 						tableBuffer.Append([]byte(v[q1+1 : q1+1+q2]))
@@ -464,6 +487,12 @@ func getPreparesize(tableName string) int {
 	return -1
 }
 
+// FailedBatches returns the number of batches with statements skipped with
+// continue-on-error
+func (p *prepareProcessoriot) FailedBatches() uint64 {
+	return p.retry.failedBatches
+}
+
 func (p *prepareProcessoriot) Close(doLoad bool) {
 	if doLoad {
 		p._db.Put()
@@ -479,18 +508,13 @@ func (p *prepareProcessoriot) createDeviceAndAttribute(createSql []*point) {
 	for _, row := range createSql {
 		switch row.sqlType {
 		case CreateTemplateTable:
-			c, cancel := context.WithCancel(context.Background())
-			ctx := &Ctx{
-				c:      c,
-				cancel: cancel,
-			}
-			actual, _ := p.sci.m.LoadOrStore(row.template, ctx)
+			p.sci.loadOrCreate(row.template)
 			sql := fmt.Sprintf("create table %s.%s %s", p.opts.DBName, row.template, row.sql)
-			_, err := p._db.Connection.Exec(ctx.c, sql)
+			_, err := p._db.Connection.Exec(context.Background(), sql)
 			if err != nil && !strings.Contains(err.Error(), "already exists") {
 				panic(fmt.Sprintf("kwdb create device failed, err: %s", err))
 			}
-			actual.(*Ctx).cancel()
+			p.sci.release(row.template)
 
 		case CreateTable:
 			if sql, ok := p.tables[row.template]; ok {
@@ -506,47 +530,35 @@ func (p *prepareProcessoriot) createDeviceAndAttribute(createSql []*point) {
 	for tableName, sql := range p.tables {
 		if len(sql) != Len_diagnostics && len(sql) != Len_reading && len(sql) > 0 {
 			sql = sql[:len(sql)-1]
-			_, err := p._db.Connection.Exec(context.Background(), sql)
-			if err != nil {
-				panic(fmt.Sprintf("kwdb prepare insert data failed for %s, err: %s", tableName, err))
+			if err := p.retry.exec(p._db, sql); err != nil {
+				p.retry.fail("kwdb prepare insert data failed for %s, err: %s", tableName, err)
 			}
 		}
 	}
 }
 
-func (p *prepareProcessoriot) createPrepareSql(deviecName string) {
+func (p *prepareProcessoriot) createPrepareSql(deviecName string) error {
 	var insertsql strings.Builder
 	if strings.HasPrefix(deviecName, "readings") {
 		query := fmt.Sprintf("insert into %s.readings (k_timestamp,latitude,longitude,elevation,velocity,heading,grade,fuel_consumption,name) values ", p.opts.DBName)
 		insertsql.WriteString(query)
 		sql := insertsql.String() + p.prepareStmtReadings.String()
-		_, err1 := p._db.Connection.Prepare(context.Background(), "insertallreadings", sql)
-		if err1 != nil {
-			panic(fmt.Sprintf("265:kwdb Prepare failed,err :%s, sql :%s", err1, sql))
-		}
+		_, err := p._db.Connection.Prepare(context.Background(), "insertallreadings", sql)
+		return err
 	} else if strings.HasPrefix(deviecName, "diagnostics") {
 		query := fmt.Sprintf("insert into %s.diagnostics (k_timestamp,fuel_state,current_load,status,name) values ", p.opts.DBName)
 		insertsql.WriteString(query)
 		sql := insertsql.String() + p.prepareStmtDiagnostics.String()
-		_, err1 := p._db.Connection.Prepare(context.Background(), "insertalldiagnostics", sql)
-		if err1 != nil {
-			panic(fmt.Sprintf("265:kwdb Prepare failed,err :%s, sql :%s", err1, sql))
-		}
+		_, err := p._db.Connection.Prepare(context.Background(), "insertalldiagnostics", sql)
+		return err
 	} else {
 		panic(fmt.Sprintf("unknown table %s", deviecName))
 	}
 }
 
-func (p *prepareProcessoriot) execPrepareStmt(tableName string, args [][]byte) {
+func (p *prepareProcessoriot) execPrepareStmt(tableName string, args [][]byte) error {
 	if tableName == "readings" {
-		res := p._db.Connection.PgConn().ExecPrepared(context.Background(), "insertallreadings", args, p.formatBufReadings, []int16{}).Read()
-		if res.Err != nil {
-			panic(res.Err)
-		}
-	} else {
-		res := p._db.Connection.PgConn().ExecPrepared(context.Background(), "insertalldiagnostics", args, p.formatBufDiagnostics, []int16{}).Read()
-		if res.Err != nil {
-			panic(res.Err)
-		}
+		return p._db.Connection.PgConn().ExecPrepared(context.Background(), "insertallreadings", args, p.formatBufReadings, []int16{}).Read().Err
 	}
+	return p._db.Connection.PgConn().ExecPrepared(context.Background(), "insertalldiagnostics", args, p.formatBufDiagnostics, []int16{}).Read().Err
 }
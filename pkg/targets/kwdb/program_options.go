@@ -1,5 +1,7 @@
 package kwdb
 
+import "time"
+
 type LoadingOptions struct {
 	User        string
 	Pass        string
@@ -14,4 +16,19 @@ type LoadingOptions struct {
 	Preparesize int
 	CertDir     string
 	Partition   bool
+	DryRun      bool
+	// retry policy of failed statements
+	RetryMaxAttempts int
+	RetryBackoff     time.Duration
+	RetryMaxBackoff  time.Duration
+	RetrySQLStates   string
+	ContinueOnError  bool
+	// OutOfOrderStats tracks the newest row of every device to count and
+	// time the rows arriving late
+	OutOfOrderStats bool
+	// Hosts is a comma separated host:port list of the cluster nodes, the
+	// workers are spread over them according to HostsStrategy
+	Hosts               string
+	HostsStrategy       string
+	HealthCheckInterval time.Duration
 }
@@ -0,0 +1,149 @@
+package kwdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/timescale/tsbs/pkg/targets/kwdb/commonpool"
+)
+
+// defaultRetrySQLStates are the SQLSTATEs worth retrying: serialization
+// failures and ambiguous results (e.g. after a lease transfer), lost
+// connections and node shutdowns
+const defaultRetrySQLStates = "40001,40003,08000,08003,08006,57P01"
+
+// retryPolicy retries failed statements with an exponential backoff and
+// counts the batches that still failed afterwards. Every worker owns its own
+// policy.
+type retryPolicy struct {
+	maxAttempts     int
+	backoff         time.Duration
+	maxBackoff      time.Duration
+	sqlStates       map[string]struct{}
+	continueOnError bool
+	// onReconnect is called after a lost connection was replaced, e.g. to
+	// prepare the statements again
+	onReconnect func()
+
+	batchFailed   bool
+	failedBatches uint64
+
+	// failedMetrics and failedRows are the values and rows of the failed
+	// statements of the current batch, they are not counted as loaded
+	failedMetrics uint64
+	failedRows    uint64
+}
+
+func newRetryPolicy(opts *LoadingOptions) *retryPolicy {
+	r := &retryPolicy{
+		maxAttempts:     opts.RetryMaxAttempts,
+		backoff:         opts.RetryBackoff,
+		maxBackoff:      opts.RetryMaxBackoff,
+		sqlStates:       map[string]struct{}{},
+		continueOnError: opts.ContinueOnError,
+	}
+	if r.maxAttempts < 1 {
+		r.maxAttempts = 1
+	}
+	if r.maxBackoff < r.backoff {
+		r.maxBackoff = r.backoff
+	}
+	sqlStates := opts.RetrySQLStates
+	if len(sqlStates) == 0 {
+		sqlStates = defaultRetrySQLStates
+	}
+	for _, state := range strings.Split(sqlStates, ",") {
+		if state = strings.TrimSpace(state); len(state) > 0 {
+			r.sqlStates[state] = struct{}{}
+		}
+	}
+	return r
+}
+
+// retryable tells whether a failed statement is worth another attempt: either
+// its SQLSTATE is in the list or the connection was lost
+func (r *retryPolicy) retryable(conn *commonpool.Conn, err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		_, ok := r.sqlStates[pgErr.Code]
+		return ok
+	}
+	return connLost(conn)
+}
+
+func connLost(conn *commonpool.Conn) bool {
+	return conn.Connection != nil && conn.Connection.IsClosed()
+}
+
+// run calls fn until it succeeds, fails with an error that is not retryable or
+// runs out of attempts, reconnecting in between when the connection was lost
+func (r *retryPolicy) run(conn *commonpool.Conn, fn func() error) error {
+	backoff := r.backoff
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= r.maxAttempts || !r.retryable(conn, err) {
+			return err
+		}
+		log.Printf("kwdb attempt %d/%d failed, retrying in %s,err :%s", attempt, r.maxAttempts, backoff, err)
+		time.Sleep(backoff)
+		if backoff *= 2; backoff > r.maxBackoff {
+			backoff = r.maxBackoff
+		}
+
+		if connLost(conn) {
+			if err := conn.Reconnect(); err != nil {
+				log.Printf("kwdb reconnect failed,err :%s", err)
+				continue
+			}
+			if r.onReconnect != nil {
+				r.onReconnect()
+			}
+		}
+	}
+}
+
+// exec runs a statement under the retry policy
+func (r *retryPolicy) exec(conn *commonpool.Conn, sql string) error {
+	return r.run(conn, func() error {
+		_, err := conn.Connection.Exec(context.Background(), sql)
+		return err
+	})
+}
+
+// fail handles a statement without rows, e.g. of tags, that failed
+// permanently: the load is aborted unless continue-on-error is set, in which
+// case the batch is marked failed
+func (r *retryPolicy) fail(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	if !r.continueOnError {
+		panic(msg)
+	}
+	log.Printf("%s, skipping the statement", msg)
+	r.batchFailed = true
+}
+
+// failRows is fail for a statement of rows with metrics values, which are not
+// counted as loaded
+func (r *retryPolicy) failRows(metrics, rows uint64, format string, args ...interface{}) {
+	r.fail(format, args...)
+	r.failedMetrics += metrics
+	r.failedRows += rows
+}
+
+// batchDone ends a batch and returns its metrics and rows less those of its
+// failed statements, the rows of the other statements were loaded
+func (r *retryPolicy) batchDone(metrics, rows uint64) (uint64, uint64) {
+	if r.batchFailed {
+		r.failedBatches++
+		r.batchFailed = false
+	}
+	metrics -= min(metrics, r.failedMetrics)
+	rows -= min(rows, r.failedRows)
+	r.failedMetrics, r.failedRows = 0, 0
+	return metrics, rows
+}
@@ -0,0 +1,91 @@
+package kwdb
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/timescale/tsbs/pkg/targets/kwdb/commonpool"
+)
+
+func TestNewRetryPolicy(t *testing.T) {
+	r := newRetryPolicy(&LoadingOptions{RetryBackoff: time.Second, RetrySQLStates: " 40001, XX000,"})
+	if r.maxAttempts != 1 {
+		t.Errorf("incorrect max attempts: got %d want %d", r.maxAttempts, 1)
+	}
+	if r.maxBackoff != time.Second {
+		t.Errorf("incorrect max backoff: got %s want %s", r.maxBackoff, time.Second)
+	}
+	if len(r.sqlStates) != 2 {
+		t.Errorf("incorrect sqlstates: got %v", r.sqlStates)
+	}
+
+	r = newRetryPolicy(&LoadingOptions{})
+	if _, ok := r.sqlStates["40001"]; !ok {
+		t.Errorf("default sqlstates missing 40001: got %v", r.sqlStates)
+	}
+}
+
+func TestRetryPolicyRun(t *testing.T) {
+	r := newRetryPolicy(&LoadingOptions{RetryMaxAttempts: 3, RetrySQLStates: "40001"})
+	conn := &commonpool.Conn{}
+
+	cases := []struct {
+		desc string
+		errs []error
+		want int
+	}{
+		{desc: "success", errs: []error{nil}, want: 1},
+		{desc: "not retryable", errs: []error{&pgconn.PgError{Code: "23505"}}, want: 1},
+		{desc: "retryable then success", errs: []error{&pgconn.PgError{Code: "40001"}, nil}, want: 2},
+		{desc: "attempts used up", errs: []error{&pgconn.PgError{Code: "40001"}, &pgconn.PgError{Code: "40001"}, &pgconn.PgError{Code: "40001"}}, want: 3},
+	}
+	for _, c := range cases {
+		attempts := 0
+		err := r.run(conn, func() error {
+			err := c.errs[attempts]
+			attempts++
+			return err
+		})
+		if attempts != c.want {
+			t.Errorf("%s: incorrect attempts: got %d want %d", c.desc, attempts, c.want)
+		}
+		if !errors.Is(err, c.errs[len(c.errs)-1]) {
+			t.Errorf("%s: incorrect error: got %v want %v", c.desc, err, c.errs[len(c.errs)-1])
+		}
+	}
+}
+
+func TestRetryPolicyFail(t *testing.T) {
+	r := newRetryPolicy(&LoadingOptions{ContinueOnError: true})
+	r.fail("kwdb insert tags failed,err :%s", "boom")
+	r.failRows(20, 2, "kwdb insert data failed,err :%s", "boom")
+	r.failRows(10, 1, "kwdb insert data failed,err :%s", "boom")
+	// the rows of the other statements of the batch were loaded
+	if metrics, rows := r.batchDone(100, 10); metrics != 70 || rows != 7 {
+		t.Errorf("incorrect failed batch counts: got %d, %d want %d, %d", metrics, rows, 70, 7)
+	}
+	if metrics, rows := r.batchDone(100, 10); metrics != 100 || rows != 10 {
+		t.Errorf("incorrect next batch counts: got %d, %d want %d, %d", metrics, rows, 100, 10)
+	}
+	if r.failedBatches != 1 {
+		t.Errorf("incorrect failed batches: got %d want %d", r.failedBatches, 1)
+	}
+	// a batch whose tags failed is counted as failed with all of its rows
+	r.fail("kwdb insert tags failed,err :%s", "boom")
+	if metrics, rows := r.batchDone(100, 10); metrics != 100 || rows != 10 {
+		t.Errorf("incorrect tag failure counts: got %d, %d want %d, %d", metrics, rows, 100, 10)
+	}
+	if r.failedBatches != 2 {
+		t.Errorf("incorrect failed batches: got %d want %d", r.failedBatches, 2)
+	}
+
+	r = newRetryPolicy(&LoadingOptions{})
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected a panic without continue-on-error")
+		}
+	}()
+	r.fail("kwdb insert data failed,err :%s", "boom")
+}
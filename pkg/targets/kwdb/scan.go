@@ -5,6 +5,7 @@ import (
 	"github.com/timescale/tsbs/pkg/data"
 	"github.com/timescale/tsbs/pkg/targets"
 	"sync"
+	"time"
 )
 
 type Node2chan struct {
@@ -72,9 +73,20 @@ var GlobalTable = sync.Map{}
 
 type hypertableArr struct {
 	createSql   []*point
+	modifySql   []*point
 	m           map[string][]string
 	totalMetric uint64
 	cnt         uint
+
+	// fields holds the number of fields of the rows of every device, from
+	// the count column of their insert lines
+	fields map[string]int
+
+	// lateRows counts the rows older than a row of their device appended
+	// before, the latest of them maxLateness behind
+	disorder    *disorderTracker
+	lateRows    uint64
+	maxLateness time.Duration
 }
 
 func (ha *hypertableArr) Len() uint {
@@ -85,24 +97,50 @@ func (ha *hypertableArr) Append(item data.LoadedPoint) {
 	that := item.Data.(*point)
 	if that.sqlType == Insert {
 		ha.m[that.device] = append(ha.m[that.device], that.sql)
+		// the count column counts the timestamp too
+		ha.fields[that.device] = that.fieldCount - 1
 		ha.totalMetric += uint64(that.fieldCount)
 		ha.cnt++
+		if lateness, late := ha.disorder.observe(that.device, that.sql); late {
+			ha.lateRows++
+			if lateness > ha.maxLateness {
+				ha.maxLateness = lateness
+			}
+		}
+	} else if that.sqlType == Modify {
+		ha.modifySql = append(ha.modifySql, that)
 	} else {
 		ha.createSql = append(ha.createSql, that)
 	}
 }
 
+// metrics returns the values of the rows of device, counted like totalMetric
+// with the timestamp
+func (ha *hypertableArr) metrics(device string) uint64 {
+	return uint64(len(ha.m[device]) * (ha.fields[device] + 1))
+}
+
 func (ha *hypertableArr) Reset() {
 	ha.m = map[string][]string{}
+	ha.fields = map[string]int{}
 	ha.cnt = 0
 	ha.createSql = ha.createSql[:0]
+	ha.modifySql = ha.modifySql[:0]
+	ha.lateRows = 0
+	ha.maxLateness = 0
 }
 
-type factory struct{}
+// factory creates the batches, which share the disorder tracker of the scan,
+// nil unless the out of order stats are requested
+type factory struct {
+	disorder *disorderTracker
+}
 
 func (f *factory) New() targets.Batch {
 	return &hypertableArr{
-		m:   map[string][]string{},
-		cnt: 0,
+		m:        map[string][]string{},
+		fields:   map[string]int{},
+		cnt:      0,
+		disorder: f.disorder,
 	}
 }
@@ -0,0 +1,131 @@
+package kwdb
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/timescale/tsbs/pkg/data/usecases/common"
+)
+
+// isDevopsCase reports whether the use case has one super table per devops
+// measurement
+func isDevopsCase(useCase string) bool {
+	return useCase == "devops" || useCase == "devops-generic"
+}
+
+// kwdbType maps the type of a header entry to a kwdb column type
+func kwdbType(typ string) string {
+	switch typ {
+	case "int64":
+		return "bigint"
+	case "int", "int32":
+		return "int"
+	case "float32", "float64", "":
+		return "float8"
+	case "bool":
+		return "bool"
+	case "string", "[]uint8":
+		return "char(30)"
+	default:
+		panic(fmt.Sprintf("kwdb unknown header type '%s'", typ))
+	}
+}
+
+// kwdbTagType maps the type of a tag to a kwdb column type, the string tags
+// of the iot tables stay varchar as in their original DDL
+func kwdbTagType(table, typ string) string {
+	if typ == "string" || typ == "[]uint8" {
+		for _, iot := range IOTPRE {
+			if table == iot {
+				return "varchar(30)"
+			}
+		}
+	}
+	return kwdbType(typ)
+}
+
+// tableTags returns the tag keys and types of a super table: the common tags
+// of the data file followed by the tags specific to the measurement
+func tableTags(table string, headers *common.GeneratedDataHeaders) ([]string, []string) {
+	keys := append(append([]string{}, headers.TagKeys...), headers.MeasurementTagKeys[table]...)
+	types := append(append([]string{}, headers.TagTypes...), headers.MeasurementTagTypes[table]...)
+	return keys, types
+}
+
+// tableNames returns the super tables described by the headers, sorted
+func tableNames(headers *common.GeneratedDataHeaders) []string {
+	tables := make([]string, 0, len(headers.FieldKeys))
+	for table := range headers.FieldKeys {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	return tables
+}
+
+// primaryTag returns the primary tag of a super table, the tag naming its sub
+// tables in tbRuleMap or the first tag for tables without a rule. The high
+// cardinality tag takes over when the data file has it.
+func primaryTag(table string, tagKeys []string) string {
+	if rule, ok := tbRuleMap[table]; ok {
+		if hasCardinalityTag(tagKeys) {
+			return common.CardinalityTagKey
+		}
+		return rule.tag
+	}
+	return tagKeys[0]
+}
+
+// hasCardinalityTag reports whether the tags include the high cardinality tag
+// of the devops use cases, see -cardinality of tsbs_generate_data
+func hasCardinalityTag(tagKeys []string) bool {
+	for _, key := range tagKeys {
+		if key == common.CardinalityTagKey {
+			return true
+		}
+	}
+	return false
+}
+
+// createTableSQL builds the create table statement of a super table from the
+// headers of the data file. The field columns are nullable when nullable is
+// set or when the headers mark them so.
+func createTableSQL(dbName, table string, headers *common.GeneratedDataHeaders, nullable bool) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "create table %s.%s (k_timestamp timestamp not null", dbName, table)
+	fieldTypes := headers.FieldTypes[table]
+	nullableFields := headers.NullableFields[table]
+	for i, field := range headers.FieldKeys[table] {
+		typ := ""
+		if i < len(fieldTypes) {
+			typ = fieldTypes[i]
+		}
+		b.WriteByte(',')
+		b.WriteString(convertKeywords(field))
+		b.WriteByte(' ')
+		b.WriteString(kwdbType(typ))
+		if !nullable && (i >= len(nullableFields) || !nullableFields[i]) {
+			b.WriteString(NotNull)
+		}
+	}
+
+	b.WriteString(") tags (")
+	tagKeys, tagTypes := tableTags(table, headers)
+	if len(tagKeys) == 0 {
+		panic(fmt.Sprintf("kwdb table %s has no tags in the header block", table))
+	}
+	primary := primaryTag(table, tagKeys)
+	for i, tag := range tagKeys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(convertKeywords(tag))
+		b.WriteByte(' ')
+		b.WriteString(kwdbTagType(table, tagTypes[i]))
+		if tag == primary {
+			b.WriteString(NotNull)
+		}
+	}
+	fmt.Fprintf(&b, ") primary tags(%s)", convertKeywords(primary))
+	return b.String()
+}
@@ -0,0 +1,181 @@
+package kwdb
+
+import (
+	"bufio"
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testDevopsHeader = "tags,hostname string,region string\n" +
+	"cpu,usage_user int64,usage_system int64 null\n" +
+	"disk,total int64,used_percent float64,path string tag\n" +
+	"\n" +
+	"3,disk,disk_host_0,('host_0','eu-west-1','/dev/sda5')\n"
+
+func TestFileDataSourceHeaders(t *testing.T) {
+	ds := &fileDataSource{scanner: bufio.NewScanner(bytes.NewBufferString(testDevopsHeader))}
+	headers := ds.Headers()
+	if headers == nil {
+		t.Fatal("expected headers to be read")
+	}
+	if got, want := headers.TagKeys, []string{"hostname", "region"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("incorrect tag keys: got %v want %v", got, want)
+	}
+	if got, want := headers.FieldKeys["disk"], []string{"total", "used_percent"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("incorrect disk fields: got %v want %v", got, want)
+	}
+	if got, want := headers.FieldTypes["disk"], []string{"int64", "float64"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("incorrect disk field types: got %v want %v", got, want)
+	}
+	if got, want := headers.MeasurementTagKeys["disk"], []string{"path"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("incorrect disk tags: got %v want %v", got, want)
+	}
+	if got, want := headers.FieldTypes["cpu"], []string{"int64", "int64"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("incorrect cpu field types: got %v want %v", got, want)
+	}
+	if got, want := headers.NullableFields["cpu"], []bool{false, true}; !reflect.DeepEqual(got, want) {
+		t.Errorf("incorrect nullable cpu fields: got %v want %v", got, want)
+	}
+
+	p := ds.NextItem().Data.(*point)
+	if p.sqlType != CreateTable || p.template != "disk" || p.device != "disk_host_0" {
+		t.Errorf("incorrect first point after headers: %+v", p)
+	}
+}
+
+func TestFileDataSourceWithoutHeaders(t *testing.T) {
+	input := "1,host_0,11,(1451606400000,58,2,24,61,22,63,6,44,80,38,'host_0')\n"
+	ds := &fileDataSource{scanner: bufio.NewScanner(bytes.NewBufferString(input))}
+	if headers := ds.Headers(); headers != nil {
+		t.Errorf("expected no headers, got %v", headers)
+	}
+	p := ds.NextItem().Data.(*point)
+	if p.sqlType != Insert || p.device != "host_0" || p.fieldCount != 11 {
+		t.Errorf("incorrect first point: %+v", p)
+	}
+	if item := ds.NextItem(); item.Data != nil {
+		t.Errorf("expected EOF, got %v", item.Data)
+	}
+}
+
+func TestCreateTableSQL(t *testing.T) {
+	ds := &fileDataSource{scanner: bufio.NewScanner(bytes.NewBufferString(testDevopsHeader))}
+	headers := ds.Headers()
+
+	cases := []struct {
+		table    string
+		nullable bool
+		want     string
+	}{
+		{
+			table: "cpu",
+			want: "create table benchmark.cpu (k_timestamp timestamp not null,usage_user bigint not null,usage_system bigint) " +
+				"tags (hostname char(30) not null,region char(30)) primary tags(hostname)",
+		},
+		{
+			table:    "disk",
+			nullable: true,
+			want: "create table benchmark.disk (k_timestamp timestamp not null,total bigint,used_percent float8) " +
+				"tags (hostname char(30) not null,region char(30),path char(30)) primary tags(hostname)",
+		},
+	}
+	for _, c := range cases {
+		if got := createTableSQL("benchmark", c.table, headers, c.nullable); got != c.want {
+			t.Errorf("incorrect sql for %s:\ngot  %s\nwant %s", c.table, got, c.want)
+		}
+	}
+}
+
+func TestSuperTableOf(t *testing.T) {
+	cases := map[string]string{
+		"host_0":                 "cpu",
+		"diskio_host_1":          "diskio",
+		"disk_host_1":            "disk",
+		"postgresl_host_2":       "postgresl",
+		"generic_metrics_host_3": "generic_metrics",
+		"readings_truck_4":       "readings",
+	}
+	for subTable, want := range cases {
+		if got := superTableOf(subTable); got != want {
+			t.Errorf("incorrect super table for %s: got %s want %s", subTable, got, want)
+		}
+	}
+}
+
+func TestSortByPrefix(t *testing.T) {
+	rules := map[string]*tbNameRule{
+		"cpu":     {tag: "hostname"},
+		"mem":     {tag: "hostname", prefix: "mem_"},
+		"disk":    {tag: "hostname", prefix: "disk_"},
+		"disk_io": {tag: "hostname", prefix: "disk_io_"},
+		"net":     {tag: "hostname", prefix: "net_"},
+	}
+	want := []string{"disk_io", "disk", "mem", "net"}
+	if got := sortByPrefix(rules); !reflect.DeepEqual(got, want) {
+		t.Errorf("incorrect super table order: got %v want %v", got, want)
+	}
+}
+
+func TestCreateTableSQLPrimaryTag(t *testing.T) {
+	input := "tags,fleet string,name string,load_capacity float32\n" +
+		"readings,latitude float64\n" +
+		"weather,temperature float64\n" +
+		"\n"
+	ds := &fileDataSource{scanner: bufio.NewScanner(bytes.NewBufferString(input))}
+	headers := ds.Headers()
+
+	cases := map[string]string{
+		// the primary tag comes from tbRuleMap
+		"readings": "create table benchmark.readings (k_timestamp timestamp not null,latitude float8 not null) " +
+			"tags (fleet varchar(30),name varchar(30) not null,load_capacity float8) primary tags(name)",
+		// tables without a rule use their first tag
+		"weather": "create table benchmark.weather (k_timestamp timestamp not null,temperature float8 not null) " +
+			"tags (fleet char(30) not null,name char(30),load_capacity float8) primary tags(fleet)",
+	}
+	for table, want := range cases {
+		if got := createTableSQL("benchmark", table, headers, false); got != want {
+			t.Errorf("incorrect sql for %s:\ngot  %s\nwant %s", table, got, want)
+		}
+	}
+}
+
+func TestCreateTableSQLCardinality(t *testing.T) {
+	input := "tags,hostname string,region string,container_id string\n" +
+		"cpu,usage_user int64\n" +
+		"disk,used int64\n" +
+		"\n"
+	ds := &fileDataSource{scanner: bufio.NewScanner(bytes.NewBufferString(input))}
+	headers := ds.Headers()
+
+	// the high cardinality tag is the primary tag of the devops tables
+	want := "create table benchmark.disk (k_timestamp timestamp not null,used bigint not null) " +
+		"tags (hostname char(30),region char(30),container_id char(30) not null) primary tags(container_id)"
+	if got := createTableSQL("benchmark", "disk", headers, false); got != want {
+		t.Errorf("incorrect sql:\ngot  %s\nwant %s", got, want)
+	}
+	if got := primaryTag("weather", headers.TagKeys); got != "hostname" {
+		t.Errorf("incorrect primary tag of a table without a rule: got %s want hostname", got)
+	}
+}
+
+func TestLegacyTables(t *testing.T) {
+	// data files without the header block keep the fixed tables of cpu-only and iot
+	cases := map[string][]string{
+		"cpu-only": {"cpu"},
+		"iot":      {"readings", "diagnostics"},
+		"devops":   nil,
+	}
+	for useCase, want := range cases {
+		tables := legacyTables("benchmark", useCase)
+		if len(tables) != len(want) {
+			t.Fatalf("%s: incorrect number of tables: got %d want %d", useCase, len(tables), len(want))
+		}
+		for i, table := range tables {
+			if table.name != want[i] || !strings.HasPrefix(table.sql, "create table benchmark."+want[i]+" (") {
+				t.Errorf("%s: incorrect table %d: %+v", useCase, i, table)
+			}
+		}
+	}
+}
@@ -4,20 +4,29 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"sort"
 	"strconv"
 	"strings"
 
 	"github.com/timescale/tsbs/pkg/data"
+	"github.com/timescale/tsbs/pkg/data/usecases/common"
 )
 
 type Serializer struct {
-	tmpBuf     *bytes.Buffer
-	tableMap   map[string]struct{}
+	tmpBuf *bytes.Buffer
+	// tableMap holds the tag values of every sub table created so far joined
+	// by tagSeparator, one string per sub table keeps it small with high
+	// cardinality
+	tableMap   map[string]string
 	superTable map[string]*Table
 }
 
 var nothing = struct{}{}
 
+// tagSeparator joins the known tag values of a sub table, tag values may
+// contain commas but no NUL
+const tagSeparator = "\x00"
+
 type Table struct {
 	columns    map[string]struct{}
 	tags       map[string]struct{}
@@ -54,6 +63,9 @@ func FastFormat(buf *bytes.Buffer, v interface{}) string {
 		buf.WriteByte('\'')
 		//return "varchar(30)"
 		return "char(30)"
+	case nil:
+		buf.WriteString(nullValue)
+		return ""
 	default:
 		panic(fmt.Sprintf("unknown field type for %#v", v))
 	}
@@ -80,6 +92,8 @@ const (
 	CreateTable         = '3'
 	Modify              = '4'
 	NotNull             = " not null"
+	// nullValue is the value of the fields missing from a point
+	nullValue = "NULL"
 )
 
 type tbNameRule struct {
@@ -103,6 +117,88 @@ var tbRuleMap = map[string]*tbNameRule{
 		prefix:   "diagnostics_",
 		nilValue: "diagnostics_truck_null",
 	},
+	// the other devops measurements share the hostname with cpu, so their
+	// sub tables are prefixed with the measurement name
+	"diskio": {
+		tag:      "hostname",
+		prefix:   "diskio_",
+		nilValue: "diskio_host_null",
+	},
+	"disk": {
+		tag:      "hostname",
+		prefix:   "disk_",
+		nilValue: "disk_host_null",
+	},
+	"kernel": {
+		tag:      "hostname",
+		prefix:   "kernel_",
+		nilValue: "kernel_host_null",
+	},
+	"mem": {
+		tag:      "hostname",
+		prefix:   "mem_",
+		nilValue: "mem_host_null",
+	},
+	"net": {
+		tag:      "hostname",
+		prefix:   "net_",
+		nilValue: "net_host_null",
+	},
+	"nginx": {
+		tag:      "hostname",
+		prefix:   "nginx_",
+		nilValue: "nginx_host_null",
+	},
+	"postgresl": {
+		tag:      "hostname",
+		prefix:   "postgresl_",
+		nilValue: "postgresl_host_null",
+	},
+	"redis": {
+		tag:      "hostname",
+		prefix:   "redis_",
+		nilValue: "redis_host_null",
+	},
+	"generic_metrics": {
+		tag:      "hostname",
+		prefix:   "generic_metrics_",
+		nilValue: "generic_metrics_host_null",
+	},
+}
+
+// prefixedSuperTables holds the super tables of tbRuleMap with a sub table
+// prefix, longest prefix first
+var prefixedSuperTables = sortByPrefix(tbRuleMap)
+
+// sortByPrefix returns the super tables of rules with a prefix, longest prefix
+// first and by name for prefixes of the same length
+func sortByPrefix(rules map[string]*tbNameRule) []string {
+	var tables []string
+	for superTable, rule := range rules {
+		if len(rule.prefix) > 0 {
+			tables = append(tables, superTable)
+		}
+	}
+	sort.Slice(tables, func(i, j int) bool {
+		pi, pj := rules[tables[i]].prefix, rules[tables[j]].prefix
+		if len(pi) != len(pj) {
+			return len(pi) > len(pj)
+		}
+		return tables[i] < tables[j]
+	})
+	return tables
+}
+
+// superTableOf returns the super table a sub table belongs to, based on the
+// longest matching prefix in tbRuleMap. Sub tables without a prefix belong to
+// cpu.
+func superTableOf(subTable string) string {
+	for _, superTable := range prefixedSuperTables {
+		if strings.HasPrefix(subTable, tbRuleMap[superTable].prefix) {
+			return superTable
+		}
+	}
+	return "cpu"
 }
 
 func (s *Serializer) Serialize(p *data.Point, w io.Writer) error {
@@ -126,13 +222,15 @@ func (s *Serializer) Serialize(p *data.Point, w io.Writer) error {
 	}
 
 	rule := tbRuleMap[superTable]
-	fixedName := ""
+	// pk is the primary tag, the tag of the rule unless the point has the
+	// high cardinality tag which then names the sub tables
+	pk := -1
 	for i, value := range tValues {
 		tType := FastFormat(s.tmpBuf, value)
-		if rule != nil && len(fixedName) == 0 && string(tKeys[i]) == rule.tag {
-			str, is := value.(string)
-			if is {
-				fixedName = str
+		if rule != nil {
+			key := string(tKeys[i])
+			if key == common.CardinalityTagKey || (pk < 0 && key == rule.tag) {
+				pk = i
 			}
 		}
 		tagKeys = append(tagKeys, convertKeywords(string(tKeys[i])))
@@ -141,6 +239,14 @@ func (s *Serializer) Serialize(p *data.Point, w io.Writer) error {
 		s.tmpBuf.Reset()
 	}
 
+	fixedName := ""
+	if pk >= 0 {
+		if str, is := tValues[pk].(string); is {
+			fixedName = str
+		}
+	} else {
+		pk = 0
+	}
 	subTable := ""
 	if rule != nil {
 		if len(fixedName) != 0 {
@@ -206,15 +312,36 @@ func (s *Serializer) Serialize(p *data.Point, w io.Writer) error {
 		}
 		s.superTable[superTable] = table
 	}
-	_, exist = s.tableMap[subTable]
+	joined := strings.Join(tagValues, tagSeparator)
+	known, exist := s.tableMap[subTable]
 	if !exist {
 		fmt.Fprintf(w, "%c,%s,%s,(%s)\n", CreateTable, superTable, subTable, strings.Join(tagValues, ","))
-		s.tableMap[subTable] = nothing
+		s.tableMap[subTable] = joined
+	} else if known != joined {
+		// the first pair names the device by its primary tag, the others
+		// are the new tag values. A point with fewer tags than the known
+		// ones changes none of them.
+		if changed := changedTags(strings.Split(known, tagSeparator), tagKeys, tagValues); len(changed) > 0 {
+			fmt.Fprintf(w, "%c,%s,%s,(%s=%s,%s)\n", Modify, superTable, subTable, tagKeys[pk], tagValues[pk], strings.Join(changed, ","))
+			s.tableMap[subTable] = joined
+		}
 	}
-	fmt.Fprintf(w, "%c,%s,%d,(%d,%s,%s)\n", Insert, subTable, len(fieldValues)+1, p.TimestampInUnixMs(), strings.Join(fieldValues, ","), tagValues[0])
+	fmt.Fprintf(w, "%c,%s,%d,(%d,%s,%s)\n", Insert, subTable, len(fieldValues)+1, p.TimestampInUnixMs(), strings.Join(fieldValues, ","), tagValues[pk])
 	return nil
 }
 
+// changedTags returns the tags whose values differ from the known ones, as
+// key=value pairs
+func changedTags(known, tagKeys, tagValues []string) []string {
+	var changed []string
+	for i, v := range tagValues {
+		if i >= len(known) || known[i] != v {
+			changed = append(changed, tagKeys[i]+"="+v)
+		}
+	}
+	return changed
+}
+
 var keyWords = map[string]bool{
 	// "port": true,
 }
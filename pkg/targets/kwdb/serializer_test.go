@@ -0,0 +1,165 @@
+package kwdb
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/timescale/tsbs/pkg/data"
+	"github.com/timescale/tsbs/pkg/data/usecases/common"
+)
+
+func cpuPoint(rack, version string, ts time.Time) *data.Point {
+	p := data.NewPoint()
+	p.SetMeasurementName([]byte("cpu"))
+	p.SetTimestamp(&ts)
+	p.AppendTag([]byte("hostname"), "host_1")
+	p.AppendTag([]byte("rack"), rack)
+	p.AppendTag([]byte("service_version"), version)
+	p.AppendField([]byte("usage_user"), int64(58))
+	return p
+}
+
+func TestSerializerModify(t *testing.T) {
+	s := (&kwdbTarget{}).Serializer()
+	start := time.Unix(1451606400, 0)
+	var buf bytes.Buffer
+	for i, tags := range [][]string{{"12", "0"}, {"12", "0"}, {"47", "1"}, {"47", "1"}, {"3", "1"}} {
+		p := cpuPoint(tags[0], tags[1], start.Add(time.Duration(i)*10*time.Second))
+		if err := s.Serialize(p, &buf); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	want := []string{
+		"3,cpu,host_1,('host_1','12','0')",
+		"1,host_1,2,(1451606400000,58,'host_1')",
+		"1,host_1,2,(1451606410000,58,'host_1')",
+		"4,cpu,host_1,(hostname='host_1',rack='47',service_version='1')",
+		"1,host_1,2,(1451606420000,58,'host_1')",
+		"1,host_1,2,(1451606430000,58,'host_1')",
+		"4,cpu,host_1,(hostname='host_1',rack='3')",
+		"1,host_1,2,(1451606440000,58,'host_1')",
+	}
+	if got := strings.Split(strings.TrimSpace(buf.String()), "\n"); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("incorrect output:\ngot\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	// the loader keeps the tag changes apart from the rows and the tags of
+	// new devices
+	d := &fileDataSource{scanner: bufio.NewScanner(&buf), headersRead: true}
+	batch := (&factory{disorder: newDisorderTracker()}).New().(*hypertableArr)
+	for item := d.NextItem(); item.Data != nil; item = d.NextItem() {
+		batch.Append(item)
+	}
+	if batch.Len() != 5 || len(batch.createSql) != 1 || len(batch.modifySql) != 2 {
+		t.Fatalf("incorrect batch: got %d rows, %d creates and %d modifies want 5, 1 and 2",
+			batch.Len(), len(batch.createSql), len(batch.modifySql))
+	}
+	if m := batch.modifySql[1]; m.template != "cpu" || m.device != "host_1" || m.sql != "(hostname='host_1',rack='3')" {
+		t.Errorf("incorrect tag change: %+v", m)
+	}
+}
+
+func TestSerializerModifyComma(t *testing.T) {
+	s := (&kwdbTarget{}).Serializer()
+	start := time.Unix(1451606400, 0)
+	var buf bytes.Buffer
+	for i, tags := range [][]string{{"12,5", "0"}, {"12,5", "1"}} {
+		p := cpuPoint(tags[0], tags[1], start.Add(time.Duration(i)*10*time.Second))
+		if err := s.Serialize(p, &buf); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	want := []string{
+		"3,cpu,host_1,('host_1','12,5','0')",
+		"1,host_1,2,(1451606400000,58,'host_1')",
+		"4,cpu,host_1,(hostname='host_1',service_version='1')",
+		"1,host_1,2,(1451606410000,58,'host_1')",
+	}
+	if got := strings.Split(strings.TrimSpace(buf.String()), "\n"); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("incorrect output:\ngot\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestSerializerFewerTags(t *testing.T) {
+	s := (&kwdbTarget{}).Serializer()
+	start := time.Unix(1451606400, 0)
+	var buf bytes.Buffer
+	p := cpuPoint("12", "0", start)
+	if err := s.Serialize(p, &buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// a point without the last tag changes none of the known tags
+	p = data.NewPoint()
+	p.SetMeasurementName([]byte("cpu"))
+	ts := start.Add(10 * time.Second)
+	p.SetTimestamp(&ts)
+	p.AppendTag([]byte("hostname"), "host_1")
+	p.AppendTag([]byte("rack"), "12")
+	p.AppendField([]byte("usage_user"), int64(58))
+	if err := s.Serialize(p, &buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{
+		"3,cpu,host_1,('host_1','12','0')",
+		"1,host_1,2,(1451606400000,58,'host_1')",
+		"1,host_1,2,(1451606410000,58,'host_1')",
+	}
+	if got := strings.TrimSpace(buf.String()); got != strings.Join(want, "\n") {
+		t.Errorf("incorrect output:\ngot\n%s\nwant\n%s", got, strings.Join(want, "\n"))
+	}
+
+	// a tag change record without new values updates nothing
+	if sql, ok := updateTagsSql("benchmark", &point{template: "cpu", sql: "(hostname='host_1',)"}); ok {
+		t.Errorf("unexpected update of no tags: %s", sql)
+	}
+	sql, ok := updateTagsSql("benchmark", &point{template: "cpu", sql: "(hostname='host_1',rack='3')"})
+	if want := "update benchmark.cpu set rack='3' where hostname='host_1'"; !ok || sql != want {
+		t.Errorf("incorrect update: got %q want %q", sql, want)
+	}
+	// the commas of a quoted primary tag value do not end the where clause
+	sql, ok = updateTagsSql("benchmark", &point{template: "cpu", sql: "(hostname='host,1',rack='3',os='a,b')"})
+	if want := "update benchmark.cpu set rack='3',os='a,b' where hostname='host,1'"; !ok || sql != want {
+		t.Errorf("incorrect update of a primary tag with a comma: got %q want %q", sql, want)
+	}
+}
+
+func TestSerializerNull(t *testing.T) {
+	s := (&kwdbTarget{}).Serializer()
+	p := cpuPoint("12", "0", time.Unix(1451606400, 0))
+	p.AppendField([]byte("usage_system"), nil)
+	var buf bytes.Buffer
+	if err := s.Serialize(p, &buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if want := "1,host_1,3,(1451606400000,58,NULL,'host_1')"; lines[len(lines)-1] != want {
+		t.Errorf("incorrect insert: got %s want %s", lines[len(lines)-1], want)
+	}
+}
+
+func TestSerializerCardinality(t *testing.T) {
+	s := (&kwdbTarget{}).Serializer()
+	start := time.Unix(1451606400, 0)
+	var buf bytes.Buffer
+	for i, container := range []string{"container_0", "container_3", "container_0"} {
+		p := cpuPoint("12", "0", start.Add(time.Duration(i)*10*time.Second))
+		p.AppendTag([]byte(common.CardinalityTagKey), container)
+		if err := s.Serialize(p, &buf); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	// the containers name the sub tables and end the rows
+	want := []string{
+		"3,cpu,container_0,('host_1','12','0','container_0')",
+		"1,container_0,2,(1451606400000,58,'container_0')",
+		"3,cpu,container_3,('host_1','12','0','container_3')",
+		"1,container_3,2,(1451606410000,58,'container_3')",
+		"1,container_0,2,(1451606420000,58,'container_0')",
+	}
+	if got := strings.TrimSpace(buf.String()); got != strings.Join(want, "\n") {
+		t.Errorf("incorrect output:\ngot\n%s\nwant\n%s", got, strings.Join(want, "\n"))
+	}
+}
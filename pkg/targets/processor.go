@@ -1,5 +1,7 @@
 package targets
 
+import "time"
+
 // Processor is a type that processes the work for a loading worker
 type Processor interface {
 	// Init does per-worker setup needed before receiving data
@@ -14,3 +16,72 @@ type ProcessorCloser interface {
 	// Close cleans up after a Processor
 	Close(doLoad bool)
 }
+
+// ProcessorFailureReporter is a Processor that can skip batches it failed to
+// process instead of aborting, and reports how many it skipped
+type ProcessorFailureReporter interface {
+	Processor
+	// FailedBatches returns the number of batches that failed
+	FailedBatches() uint64
+}
+
+// ProcessorOutOfOrderReporter is a Processor that tracks the rows loaded after
+// newer rows of their series, and how long the batches holding them took
+// compared to the batches in order
+type ProcessorOutOfOrderReporter interface {
+	Processor
+	// OutOfOrderStats returns the out of order stats of the processed batches
+	OutOfOrderStats() OutOfOrderStats
+}
+
+// OutOfOrderStats counts the out of order rows and the batches holding them
+type OutOfOrderStats struct {
+	Rows        uint64
+	MaxLateness time.Duration
+	Batches     uint64
+	BatchTime   time.Duration
+	// InOrderBatches and InOrderBatchTime cover the batches without out of
+	// order rows
+	InOrderBatches   uint64
+	InOrderBatchTime time.Duration
+}
+
+// AddBatch adds a processed batch holding rows out of order, the latest
+// of which was lateness behind the newest row of its series
+func (s *OutOfOrderStats) AddBatch(rows uint64, lateness, took time.Duration) {
+	if rows == 0 {
+		s.InOrderBatches++
+		s.InOrderBatchTime += took
+		return
+	}
+	s.Rows += rows
+	s.Batches++
+	s.BatchTime += took
+	if lateness > s.MaxLateness {
+		s.MaxLateness = lateness
+	}
+}
+
+// Merge adds the stats of another processor
+func (s *OutOfOrderStats) Merge(o OutOfOrderStats) {
+	s.Rows += o.Rows
+	s.Batches += o.Batches
+	s.BatchTime += o.BatchTime
+	s.InOrderBatches += o.InOrderBatches
+	s.InOrderBatchTime += o.InOrderBatchTime
+	if o.MaxLateness > s.MaxLateness {
+		s.MaxLateness = o.MaxLateness
+	}
+}
+
+// MeanBatchTimes returns the mean time of the batches with and without out of
+// order rows
+func (s *OutOfOrderStats) MeanBatchTimes() (outOfOrder, inOrder time.Duration) {
+	if s.Batches > 0 {
+		outOfOrder = s.BatchTime / time.Duration(s.Batches)
+	}
+	if s.InOrderBatches > 0 {
+		inOrder = s.InOrderBatchTime / time.Duration(s.InOrderBatches)
+	}
+	return outOfOrder, inOrder
+}
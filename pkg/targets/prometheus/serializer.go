@@ -51,11 +51,11 @@ func (ps *Serializer) Serialize(p *data.Point, w io.Writer) error {
 		}
 	}
 	series := make([]prompb.TimeSeries, len(p.FieldKeys()))
-	err := convertToPromSeries(p, series)
+	n, err := convertToPromSeries(p, series)
 	if err != nil {
 		return fmt.Errorf("could not serialize point\n%v", err)
 	}
-	for _, ts := range series {
+	for _, ts := range series[:n] {
 		protoBytes, err := proto.Marshal(&ts)
 		if err != nil {
 			return err
@@ -74,12 +74,14 @@ func (ps *Serializer) Serialize(p *data.Point, w io.Writer) error {
 	return nil
 }
 
-// Each point field will become a new TimeSeries with added field key as a label
-func convertToPromSeries(p *data.Point, buffer []prompb.TimeSeries) error {
+// Each point field will become a new TimeSeries with added field key as a label.
+// Fields missing from the point (nil values) are skipped, the number of
+// TimeSeries written to buffer is returned.
+func convertToPromSeries(p *data.Point, buffer []prompb.TimeSeries) (int, error) {
 	bufLen := len(buffer)
 	requiredPlaces := len(p.FieldKeys())
 	if requiredPlaces > bufLen {
-		return fmt.Errorf("supplied buffer has insufficient space; need %d; got %d",
+		return 0, fmt.Errorf("supplied buffer has insufficient space; need %d; got %d",
 			requiredPlaces, bufLen,
 		)
 	}
@@ -108,7 +110,11 @@ func convertToPromSeries(p *data.Point, buffer []prompb.TimeSeries) error {
 	})
 
 	tsMs := p.TimestampInUnixMs()
+	n := 0
 	for i := range fieldKeys {
+		if fieldValues[i] == nil {
+			continue
+		}
 		myLabels := labels
 		if i+1 < len(fieldKeys) {
 			myLabels = make([]prompb.Label, len(labels))
@@ -122,9 +128,10 @@ func convertToPromSeries(p *data.Point, buffer []prompb.TimeSeries) error {
 			Labels:  myLabels,
 			Samples: []prompb.Sample{{Value: getFloat64(fieldValues[i]), Timestamp: tsMs}},
 		}
-		buffer[i] = ts
+		buffer[n] = ts
+		n++
 	}
-	return nil
+	return n, nil
 }
 
 func getFloat64(fieldValue interface{}) float64 {
@@ -106,6 +106,13 @@ func TestConvertToPromSeries(t *testing.T) {
 		Samples: []prompb.Sample{{Value: 2, Timestamp: twoFieldPoint.Timestamp().UnixNano() / 1000000}},
 	}
 
+	missingFieldPoint := data.NewPoint()
+	missingFieldPoint.SetTimestamp(&someTimeAgo)
+	missingFieldPoint.AppendField([]byte("f"), nil)
+	missingFieldPoint.AppendField([]byte("g"), 2)
+	missingFieldPoint.AppendTag([]byte("b"), "t1")
+	missingFieldPoint.AppendTag([]byte("a"), "t2")
+
 	testCases := []struct {
 		desc      string
 		expError  bool
@@ -124,12 +131,17 @@ func TestConvertToPromSeries(t *testing.T) {
 			inPoint:   twoFieldPoint,
 			inBuffer:  make([]prompb.TimeSeries, 2),
 			expBuffer: []prompb.TimeSeries{tfTS1, tfTS2},
+		}, {
+			desc:      "Missing field, one time-series",
+			inPoint:   missingFieldPoint,
+			inBuffer:  make([]prompb.TimeSeries, 2),
+			expBuffer: []prompb.TimeSeries{tfTS2},
 		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.desc, func(t *testing.T) {
-			err := convertToPromSeries(tc.inPoint, tc.inBuffer)
+			n, err := convertToPromSeries(tc.inPoint, tc.inBuffer)
 			if tc.expError && err != nil {
 				return
 			} else if tc.expError {
@@ -139,6 +151,10 @@ func TestConvertToPromSeries(t *testing.T) {
 				t.Errorf("unexpected error: %v", err)
 				return
 			}
+			if n != len(tc.expBuffer) {
+				t.Errorf("wrong number of time-series; exp: %d; got %d", len(tc.expBuffer), n)
+				return
+			}
 
 			for i, ts := range tc.expBuffer {
 				returnedTS := tc.inBuffer[i]
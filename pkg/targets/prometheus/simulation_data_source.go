@@ -88,10 +88,11 @@ func (t *timeSeriesIterator) Set(p *data.Point) error {
 	// reset state of iterator
 	t.currentInd = 0
 	t.generatedSeries = make([]prompb.TimeSeries, len(p.FieldKeys()))
-	err := convertToPromSeries(p, t.generatedSeries)
+	n, err := convertToPromSeries(p, t.generatedSeries)
 	if err != nil {
 		return err
 	}
+	t.generatedSeries = t.generatedSeries[:n]
 	if t.useCurrentTime {
 		t.updateTimestamps()
 	}
@@ -57,6 +57,10 @@ func (s *Serializer) Serialize(p *data.Point, w io.Writer) error {
 	fieldValues := p.FieldValues()
 	fieldKeys := p.FieldKeys()
 	for i, value := range fieldValues {
+		// fields missing from the point have no value to insert
+		if value == nil {
+			continue
+		}
 
 		indexLenData := len(line) + 4
 
@@ -63,6 +63,14 @@ func TestSiriDBSerializerSerialize(t *testing.T) {
 				value:     [][]interface{}{{1451606400000000000, 38.24311829}},
 			},
 		},
+		{
+			desc:       "a Point with a missing field",
+			inputPoint: serialize.TestPointWithNilField(),
+			want: output{
+				seriename: []string{"cpu||usage_guest_nice"},
+				value:     [][]interface{}{{1451606400000000000, 38.24311829}},
+			},
+		},
 	}
 
 	ps := &Serializer{}